@@ -0,0 +1,109 @@
+package binpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	values := []int64{0, 1, -1, 63, -64, 300, -300, 1 << 40, -(1 << 62)}
+	for _, v := range values {
+		buf := AppendVarint(nil, v)
+		got, n, err := Varint(buf)
+		if err != nil {
+			t.Fatalf("varint %d decode failed, err = %s", v, err)
+		}
+		if got != v || n != len(buf) {
+			t.Fatalf("varint %d decode got %d, n = %d, len = %d", v, got, n, len(buf))
+		}
+	}
+	if ZigZag(-1) != 1 || ZigZag(1) != 2 {
+		t.Fatalf("zigzag not right")
+	}
+	if _, _, err := Uvarint([]byte{0x80, 0x80}); err != ErrShortBuffer {
+		t.Fatalf("expect short buffer, err = %v", err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	w := NewWriter(make([]byte, 7), binary.BigEndian)
+	if err := w.PutUint16(0x0102); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.PutUint32(0x03040506); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.PutUint16(0x0708); err != ErrShortBuffer {
+		t.Fatalf("expect short buffer, err = %v", err)
+	}
+	if err := w.PutUint8(0x07); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Bytes(), []byte{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("write not right: %v", w.Bytes())
+	}
+
+	r := NewReader(w.Bytes(), binary.BigEndian)
+	v16, _ := r.Uint16()
+	v32, _ := r.Uint32()
+	if v16 != 0x0102 || v32 != 0x03040506 {
+		t.Fatalf("read not right: %x %x", v16, v32)
+	}
+	if _, err := r.Uint16(); err != ErrShortBuffer {
+		t.Fatalf("expect short buffer, err = %v", err)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("cursor moved on failed read, len = %d", r.Len())
+	}
+
+	b := NewBuffer(1, binary.LittleEndian)
+	b.PutUint64(1)
+	b.PutUvarint(300)
+	if b.Len() != 10 {
+		t.Fatalf("buffer len = %d", b.Len())
+	}
+}
+
+type head struct {
+	Command uint64
+	Length  uint32
+	Flag    uint16 `binpack:"le"`
+	Name    string `binpack:"size=6"`
+	Magic   [2]byte
+	Ignore  int `binpack:"-"`
+	Sub     struct {
+		A int8
+		B bool
+	}
+}
+
+func TestPack(t *testing.T) {
+	h := &head{Command: 0x00010001, Length: 5, Flag: 0x0102, Name: "abc"}
+	h.Magic = [2]byte{'M', 'X'}
+	h.Sub.A = -2
+	h.Sub.B = true
+
+	size, err := Size(h)
+	if err != nil || size != 8+4+2+6+2+2 {
+		t.Fatalf("size = %d, err = %v", size, err)
+	}
+	data, err := Pack(h, binary.BigEndian)
+	if err != nil {
+		t.Fatalf("pack failed, err = %s", err)
+	}
+	if data[12] != 0x02 || data[13] != 0x01 {
+		t.Fatalf("le field not right: %v", data[12:14])
+	}
+
+	var got head
+	if err = Unpack(data, &got, binary.BigEndian); err != nil {
+		t.Fatalf("unpack failed, err = %s", err)
+	}
+	if got != *h {
+		t.Fatalf("unpack not equal: %+v != %+v", got, *h)
+	}
+	if err = Unpack(data[:10], &got, binary.BigEndian); err != ErrShortBuffer {
+		t.Fatalf("expect short buffer, err = %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package binpack
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	// ErrShortBuffer 数据不足或者缓冲区空间不足
+	ErrShortBuffer = fmt.Errorf("binpack: short buffer")
+	// ErrOverflow varint超过64位
+	ErrOverflow = fmt.Errorf("binpack: varint overflow")
+)
+
+// Reader 带边界检查的[]byte读游标
+type Reader struct {
+	buf   []byte
+	off   int
+	order binary.ByteOrder
+}
+
+// NewReader 创建读游标，order为多字节整数的字节序
+func NewReader(buf []byte, order binary.ByteOrder) *Reader {
+	return &Reader{
+		buf:   buf,
+		order: order,
+	}
+}
+
+// Offset 当前位置
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// Len 剩余未读字节数
+func (r *Reader) Len() int {
+	return len(r.buf) - r.off
+}
+
+// Remaining 剩余未读数据，不移动游标
+func (r *Reader) Remaining() []byte {
+	return r.buf[r.off:]
+}
+
+func (r *Reader) next(n int) ([]byte, error) {
+	if n < 0 || r.Len() < n {
+		return nil, ErrShortBuffer
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+// Skip 跳过n个字节
+func (r *Reader) Skip(n int) error {
+	_, err := r.next(n)
+	return err
+}
+
+func (r *Reader) Uint8() (uint8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *Reader) Uint16() (uint16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint16(b), nil
+}
+
+func (r *Reader) Uint32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint32(b), nil
+}
+
+func (r *Reader) Uint64() (uint64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint64(b), nil
+}
+
+// Bytes 读取n个字节，返回的切片引用底层缓冲区
+func (r *Reader) Bytes(n int) ([]byte, error) {
+	return r.next(n)
+}
+
+func (r *Reader) Uvarint() (uint64, error) {
+	v, n, err := Uvarint(r.Remaining())
+	if err != nil {
+		return 0, err
+	}
+	r.off += n
+	return v, nil
+}
+
+func (r *Reader) Varint() (int64, error) {
+	v, err := r.Uvarint()
+	if err != nil {
+		return 0, err
+	}
+	return UnZigZag(v), nil
+}
+
+// Writer 带边界检查的[]byte写游标，
+// 以NewWriter创建时写入固定的缓冲区，空间不足返回ErrShortBuffer，
+// 以NewBuffer创建时自动扩容
+type Writer struct {
+	buf   []byte
+	grow  bool
+	order binary.ByteOrder
+}
+
+// NewWriter 在buf上创建写游标，最多写入len(buf)个字节
+func NewWriter(buf []byte, order binary.ByteOrder) *Writer {
+	return &Writer{
+		buf:   buf[:0:len(buf)],
+		order: order,
+	}
+}
+
+// NewBuffer 创建自动扩容的写游标
+func NewBuffer(size int, order binary.ByteOrder) *Writer {
+	return &Writer{
+		buf:   make([]byte, 0, size),
+		grow:  true,
+		order: order,
+	}
+}
+
+// Len 已写入字节数
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+// Bytes 已写入数据
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Reset 清空已写入数据
+func (w *Writer) Reset() {
+	w.buf = w.buf[:0]
+}
+
+func (w *Writer) next(n int) ([]byte, error) {
+	l := len(w.buf)
+	if cap(w.buf)-l < n {
+		if !w.grow {
+			return nil, ErrShortBuffer
+		}
+		buf := make([]byte, l, 2*cap(w.buf)+n)
+		copy(buf, w.buf)
+		w.buf = buf
+	}
+	w.buf = w.buf[:l+n]
+	return w.buf[l:], nil
+}
+
+func (w *Writer) PutUint8(v uint8) error {
+	b, err := w.next(1)
+	if err != nil {
+		return err
+	}
+	b[0] = v
+	return nil
+}
+
+func (w *Writer) PutUint16(v uint16) error {
+	b, err := w.next(2)
+	if err != nil {
+		return err
+	}
+	w.order.PutUint16(b, v)
+	return nil
+}
+
+func (w *Writer) PutUint32(v uint32) error {
+	b, err := w.next(4)
+	if err != nil {
+		return err
+	}
+	w.order.PutUint32(b, v)
+	return nil
+}
+
+func (w *Writer) PutUint64(v uint64) error {
+	b, err := w.next(8)
+	if err != nil {
+		return err
+	}
+	w.order.PutUint64(b, v)
+	return nil
+}
+
+func (w *Writer) PutBytes(v []byte) error {
+	b, err := w.next(len(v))
+	if err != nil {
+		return err
+	}
+	copy(b, v)
+	return nil
+}
+
+func (w *Writer) PutUvarint(v uint64) error {
+	b, err := w.next(UvarintSize(v))
+	if err != nil {
+		return err
+	}
+	binary.PutUvarint(b, v)
+	return nil
+}
+
+func (w *Writer) PutVarint(v int64) error {
+	return w.PutUvarint(ZigZag(v))
+}
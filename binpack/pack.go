@@ -0,0 +1,307 @@
+package binpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 结构体定长布局打包，字段按定义顺序依次排列，支持的字段类型:
+//   bool, int8~int64, uint8~uint64, [N]byte, 嵌套struct,
+//   []byte/string 需要tag指定长度 `binpack:"size=16"`，不足补0，解包时string去掉末尾的0
+// tag选项(逗号分隔):
+//   -       忽略该字段
+//   be/le   该字段使用大端/小端
+//   size=N  []byte/string的定长长度
+
+type fieldOpt struct {
+	skip  bool
+	size  int
+	order binary.ByteOrder
+}
+
+func parseTag(tag string, order binary.ByteOrder) (*fieldOpt, error) {
+	opt := &fieldOpt{size: -1, order: order}
+	if tag == "" {
+		return opt, nil
+	}
+	for _, v := range strings.Split(tag, ",") {
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "-":
+			opt.skip = true
+		case v == "be":
+			opt.order = binary.BigEndian
+		case v == "le":
+			opt.order = binary.LittleEndian
+		case strings.HasPrefix(v, "size="):
+			size, err := strconv.Atoi(v[len("size="):])
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("binpack: invalid tag %s", v)
+			}
+			opt.size = size
+		case v == "":
+		default:
+			return nil, fmt.Errorf("binpack: unknown tag option %s", v)
+		}
+	}
+	return opt, nil
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, fmt.Errorf("binpack: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("binpack: %s is not struct", rv.Type())
+	}
+	return rv, nil
+}
+
+// Size 结构体打包后的字节数
+func Size(v interface{}) (int, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return 0, err
+	}
+	return sizeOf(rv.Type(), &fieldOpt{size: -1})
+}
+
+func sizeOf(t reflect.Type, opt *fieldOpt) (int, error) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1, nil
+	case reflect.Int16, reflect.Uint16:
+		return 2, nil
+	case reflect.Int32, reflect.Uint32:
+		return 4, nil
+	case reflect.Int64, reflect.Uint64:
+		return 8, nil
+	case reflect.Array:
+		if t.Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		return t.Len(), nil
+	case reflect.Slice, reflect.String:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if opt.size < 0 {
+			return 0, fmt.Errorf("binpack: %s requires size tag", t)
+		}
+		return opt.size, nil
+	case reflect.Struct:
+		total := 0
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fopt, err := parseTag(f.Tag.Get("binpack"), nil)
+			if err != nil {
+				return 0, err
+			}
+			if fopt.skip || f.PkgPath != "" {
+				continue
+			}
+			n, err := sizeOf(f.Type, fopt)
+			if err != nil {
+				return 0, fmt.Errorf("binpack: field %s: %s", f.Name, err)
+			}
+			total += n
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("binpack: unsupported type %s", t)
+}
+
+// Pack 按定长布局打包结构体
+func Pack(v interface{}, order binary.ByteOrder) ([]byte, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	size, err := sizeOf(rv.Type(), &fieldOpt{size: -1})
+	if err != nil {
+		return nil, err
+	}
+	w := NewWriter(make([]byte, size), order)
+	if err = packValue(w, rv, &fieldOpt{size: -1, order: order}); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func packValue(w *Writer, v reflect.Value, opt *fieldOpt) error {
+	saved := w.order
+	w.order = opt.order
+	defer func() { w.order = saved }()
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b := uint8(0)
+		if v.Bool() {
+			b = 1
+		}
+		return w.PutUint8(b)
+	case reflect.Int8:
+		return w.PutUint8(uint8(v.Int()))
+	case reflect.Int16:
+		return w.PutUint16(uint16(v.Int()))
+	case reflect.Int32:
+		return w.PutUint32(uint32(v.Int()))
+	case reflect.Int64:
+		return w.PutUint64(uint64(v.Int()))
+	case reflect.Uint8:
+		return w.PutUint8(uint8(v.Uint()))
+	case reflect.Uint16:
+		return w.PutUint16(uint16(v.Uint()))
+	case reflect.Uint32:
+		return w.PutUint32(uint32(v.Uint()))
+	case reflect.Uint64:
+		return w.PutUint64(v.Uint())
+	case reflect.Array:
+		b, err := w.next(v.Len())
+		if err != nil {
+			return err
+		}
+		reflect.Copy(reflect.ValueOf(b), v)
+		return nil
+	case reflect.Slice, reflect.String:
+		var data []byte
+		if v.Kind() == reflect.String {
+			data = []byte(v.String())
+		} else {
+			data = v.Bytes()
+		}
+		if len(data) > opt.size {
+			return fmt.Errorf("binpack: data length %d exceeds size %d", len(data), opt.size)
+		}
+		b, err := w.next(opt.size)
+		if err != nil {
+			return err
+		}
+		n := copy(b, data)
+		for i := n; i < len(b); i++ {
+			b[i] = 0
+		}
+		return nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fopt, err := parseTag(f.Tag.Get("binpack"), opt.order)
+			if err != nil {
+				return err
+			}
+			if fopt.skip || f.PkgPath != "" {
+				continue
+			}
+			if err = packValue(w, v.Field(i), fopt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("binpack: unsupported type %s", v.Type())
+}
+
+// Unpack 按定长布局解包到结构体指针v
+func Unpack(data []byte, v interface{}, order binary.ByteOrder) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return fmt.Errorf("binpack: unpack requires pointer")
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	r := NewReader(data, order)
+	return unpackValue(r, rv, &fieldOpt{size: -1, order: order})
+}
+
+func unpackValue(r *Reader, v reflect.Value, opt *fieldOpt) error {
+	saved := r.order
+	r.order = opt.order
+	defer func() { r.order = saved }()
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := r.Uint8()
+		v.SetBool(b != 0)
+		return err
+	case reflect.Int8:
+		b, err := r.Uint8()
+		v.SetInt(int64(int8(b)))
+		return err
+	case reflect.Int16:
+		b, err := r.Uint16()
+		v.SetInt(int64(int16(b)))
+		return err
+	case reflect.Int32:
+		b, err := r.Uint32()
+		v.SetInt(int64(int32(b)))
+		return err
+	case reflect.Int64:
+		b, err := r.Uint64()
+		v.SetInt(int64(b))
+		return err
+	case reflect.Uint8:
+		b, err := r.Uint8()
+		v.SetUint(uint64(b))
+		return err
+	case reflect.Uint16:
+		b, err := r.Uint16()
+		v.SetUint(uint64(b))
+		return err
+	case reflect.Uint32:
+		b, err := r.Uint32()
+		v.SetUint(uint64(b))
+		return err
+	case reflect.Uint64:
+		b, err := r.Uint64()
+		v.SetUint(b)
+		return err
+	case reflect.Array:
+		b, err := r.Bytes(v.Len())
+		if err != nil {
+			return err
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		return nil
+	case reflect.Slice, reflect.String:
+		if opt.size < 0 {
+			return fmt.Errorf("binpack: %s requires size tag", v.Type())
+		}
+		b, err := r.Bytes(opt.size)
+		if err != nil {
+			return err
+		}
+		if v.Kind() == reflect.String {
+			v.SetString(strings.TrimRight(string(b), "\x00"))
+		} else {
+			v.SetBytes(append([]byte(nil), b...))
+		}
+		return nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fopt, err := parseTag(f.Tag.Get("binpack"), opt.order)
+			if err != nil {
+				return err
+			}
+			if fopt.skip || f.PkgPath != "" {
+				continue
+			}
+			if err = unpackValue(r, v.Field(i), fopt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("binpack: unsupported type %s", v.Type())
+}
@@ -0,0 +1,60 @@
+package binpack
+
+import (
+	"encoding/binary"
+)
+
+// MaxVarintLen64 64位varint的最大长度
+const MaxVarintLen64 = binary.MaxVarintLen64
+
+// ZigZag 有符号数zigzag编码，使绝对值小的负数也能编码成短的varint
+func ZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// UnZigZag zigzag解码
+func UnZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// UvarintSize v编码后的字节数
+func UvarintSize(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// AppendUvarint 追加无符号varint
+func AppendUvarint(buf []byte, v uint64) []byte {
+	return binary.AppendUvarint(buf, v)
+}
+
+// AppendVarint 追加zigzag编码的有符号varint
+func AppendVarint(buf []byte, v int64) []byte {
+	return binary.AppendUvarint(buf, ZigZag(v))
+}
+
+// Uvarint 解码无符号varint，返回值和消耗的字节数，
+// 数据不完整返回ErrShortBuffer，溢出返回ErrOverflow
+func Uvarint(buf []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(buf)
+	if n == 0 {
+		return 0, 0, ErrShortBuffer
+	}
+	if n < 0 {
+		return 0, -n, ErrOverflow
+	}
+	return v, n, nil
+}
+
+// Varint 解码zigzag编码的有符号varint
+func Varint(buf []byte) (int64, int, error) {
+	v, n, err := Uvarint(buf)
+	if err != nil {
+		return 0, n, err
+	}
+	return UnZigZag(v), n, nil
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestNetstring(t *testing.T) {
+	buf := AppendNetstring(nil, []byte("hello"))
+	buf = AppendNetstring(buf, []byte(""))
+	buf = AppendNetstring(buf, []byte("world!"))
+	if string(buf) != "5:hello,0:,6:world!," {
+		t.Fatalf("encode not right: %s", buf)
+	}
+
+	var got []string
+	it := NewNetstringIterator(buf)
+	for it.Next() {
+		got = append(got, string(it.Bytes()))
+	}
+	if it.Err() != nil || len(got) != 3 || got[2] != "world!" {
+		t.Fatalf("iterate not right: %v, err = %v", got, it.Err())
+	}
+
+	if _, _, err := DecodeNetstring([]byte("5:hel")); err != ErrIncomplete {
+		t.Fatalf("expect incomplete, err = %v", err)
+	}
+	bad := []string{"x:a,", ":a,", "01:a,", "1:ab"}
+	for _, v := range bad {
+		if _, _, err := DecodeNetstring([]byte(v)); err != ErrInvalidNetstring {
+			t.Fatalf("%s expect invalid, err = %v", v, err)
+		}
+	}
+
+	r := bufio.NewReader(bytes.NewReader(buf))
+	data, err := ReadNetstring(r, 0)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("read not right: %s, err = %v", data, err)
+	}
+	ReadNetstring(r, 0)
+	if _, err = ReadNetstring(r, 3); err != ErrTooLong {
+		t.Fatalf("expect too long, err = %v", err)
+	}
+}
+
+func TestTLV(t *testing.T) {
+	buf := EncodeTLV(TLV{Type: 1, Value: []byte("a")},
+		TLV{Type: 300, Value: bytes.Repeat([]byte("b"), 200)})
+	buf = AppendTLV(buf, 2, nil)
+
+	count := 0
+	it := NewTLVIterator(buf)
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != 3 {
+		t.Fatalf("iterate count = %d, err = %v", count, it.Err())
+	}
+
+	rec, ok := FindTLV(buf, 300)
+	if !ok || len(rec.Value) != 200 {
+		t.Fatalf("find not right: %v", ok)
+	}
+	if _, _, err := DecodeTLV(buf[3:10]); err != ErrIncomplete {
+		t.Fatalf("expect incomplete, err = %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// netstring格式: <长度>:<数据>,  如 "5:hello,"
+
+var (
+	// ErrIncomplete 数据不完整
+	ErrIncomplete = fmt.Errorf("codec: incomplete data")
+	// ErrInvalidNetstring netstring格式错误
+	ErrInvalidNetstring = fmt.Errorf("codec: invalid netstring")
+	// ErrTooLong 长度超过限制
+	ErrTooLong = fmt.Errorf("codec: data too long")
+)
+
+// maxNetstringDigits 长度字段最多的位数
+const maxNetstringDigits = 10
+
+// AppendNetstring 追加netstring编码的data
+func AppendNetstring(buf []byte, data []byte) []byte {
+	buf = strconv.AppendInt(buf, int64(len(data)), 10)
+	buf = append(buf, ':')
+	buf = append(buf, data...)
+	return append(buf, ',')
+}
+
+// EncodeNetstring netstring编码
+func EncodeNetstring(data []byte) []byte {
+	return AppendNetstring(make([]byte, 0, len(data)+maxNetstringDigits+2), data)
+}
+
+// DecodeNetstring 解码buf开头的一个netstring，返回数据和剩余部分，
+// 数据引用buf，不完整返回ErrIncomplete
+func DecodeNetstring(buf []byte) ([]byte, []byte, error) {
+	length := 0
+	i := 0
+	for ; i < len(buf); i++ {
+		c := buf[i]
+		if c == ':' {
+			break
+		}
+		if c < '0' || c > '9' || i >= maxNetstringDigits {
+			return nil, buf, ErrInvalidNetstring
+		}
+		// 不允许前导0
+		if i == 1 && buf[0] == '0' {
+			return nil, buf, ErrInvalidNetstring
+		}
+		length = length*10 + int(c-'0')
+	}
+	if i == len(buf) {
+		return nil, buf, ErrIncomplete
+	}
+	if i == 0 {
+		return nil, buf, ErrInvalidNetstring
+	}
+	start := i + 1
+	end := start + length
+	if end >= len(buf) {
+		return nil, buf, ErrIncomplete
+	}
+	if buf[end] != ',' {
+		return nil, buf, ErrInvalidNetstring
+	}
+	return buf[start:end], buf[end+1:], nil
+}
+
+// ReadNetstring 从r读取一个netstring，max为数据的最大长度，<=0不限制
+func ReadNetstring(r *bufio.Reader, max int) ([]byte, error) {
+	length := 0
+	for i := 0; ; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == ':' {
+			if i == 0 {
+				return nil, ErrInvalidNetstring
+			}
+			break
+		}
+		if c < '0' || c > '9' || i >= maxNetstringDigits || (i == 1 && length == 0) {
+			return nil, ErrInvalidNetstring
+		}
+		length = length*10 + int(c-'0')
+	}
+	if max > 0 && length > max {
+		return nil, ErrTooLong
+	}
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if data[length] != ',' {
+		return nil, ErrInvalidNetstring
+	}
+	return data[:length], nil
+}
+
+// NetstringIterator 遍历[]byte中连续的netstring
+//
+//	it := NewNetstringIterator(buf)
+//	for it.Next() {
+//		data := it.Bytes()
+//	}
+//	if it.Err() != nil {
+//	}
+type NetstringIterator struct {
+	buf  []byte
+	data []byte
+	err  error
+}
+
+func NewNetstringIterator(buf []byte) *NetstringIterator {
+	return &NetstringIterator{buf: buf}
+}
+
+// Next 移动到下一个netstring，没有更多数据或者出错返回false
+func (it *NetstringIterator) Next() bool {
+	if it.err != nil || len(it.buf) == 0 {
+		return false
+	}
+	it.data, it.buf, it.err = DecodeNetstring(it.buf)
+	return it.err == nil
+}
+
+// Bytes 当前netstring的数据
+func (it *NetstringIterator) Bytes() []byte {
+	return it.data
+}
+
+// Rest 未解析的数据
+func (it *NetstringIterator) Rest() []byte {
+	return it.buf
+}
+
+func (it *NetstringIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"github.com/buf1024/golib/binpack"
+)
+
+// TLV 记录，type和length均为varint编码: <type><length><value>
+
+// TLV 一条type-length-value记录
+type TLV struct {
+	Type  uint64
+	Value []byte
+}
+
+// AppendTLV 追加一条TLV记录
+func AppendTLV(buf []byte, typ uint64, value []byte) []byte {
+	buf = binpack.AppendUvarint(buf, typ)
+	buf = binpack.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// EncodeTLV 编码多条TLV记录
+func EncodeTLV(records ...TLV) []byte {
+	size := 0
+	for _, v := range records {
+		size += binpack.UvarintSize(v.Type) +
+			binpack.UvarintSize(uint64(len(v.Value))) + len(v.Value)
+	}
+	buf := make([]byte, 0, size)
+	for _, v := range records {
+		buf = AppendTLV(buf, v.Type, v.Value)
+	}
+	return buf
+}
+
+// DecodeTLV 解码buf开头的一条TLV记录，返回记录和剩余部分，Value引用buf
+func DecodeTLV(buf []byte) (TLV, []byte, error) {
+	r := binpack.NewReader(buf, nil)
+	typ, err := r.Uvarint()
+	if err != nil {
+		return TLV{}, buf, tlvErr(err)
+	}
+	length, err := r.Uvarint()
+	if err != nil {
+		return TLV{}, buf, tlvErr(err)
+	}
+	if length > uint64(r.Len()) {
+		return TLV{}, buf, ErrIncomplete
+	}
+	value, _ := r.Bytes(int(length))
+	return TLV{Type: typ, Value: value}, r.Remaining(), nil
+}
+
+func tlvErr(err error) error {
+	if err == binpack.ErrShortBuffer {
+		return ErrIncomplete
+	}
+	return err
+}
+
+// TLVIterator 遍历[]byte中连续的TLV记录
+//
+//	it := NewTLVIterator(buf)
+//	for it.Next() {
+//		rec := it.Record()
+//	}
+type TLVIterator struct {
+	buf []byte
+	rec TLV
+	err error
+}
+
+func NewTLVIterator(buf []byte) *TLVIterator {
+	return &TLVIterator{buf: buf}
+}
+
+// Next 移动到下一条记录，没有更多数据或者出错返回false
+func (it *TLVIterator) Next() bool {
+	if it.err != nil || len(it.buf) == 0 {
+		return false
+	}
+	it.rec, it.buf, it.err = DecodeTLV(it.buf)
+	return it.err == nil
+}
+
+// Record 当前记录
+func (it *TLVIterator) Record() TLV {
+	return it.rec
+}
+
+// Rest 未解析的数据
+func (it *TLVIterator) Rest() []byte {
+	return it.buf
+}
+
+func (it *TLVIterator) Err() error {
+	return it.err
+}
+
+// FindTLV 查找第一条类型为typ的记录
+func FindTLV(buf []byte, typ uint64) (TLV, bool) {
+	it := NewTLVIterator(buf)
+	for it.Next() {
+		if it.Record().Type == typ {
+			return it.Record(), true
+		}
+	}
+	return TLV{}, false
+}
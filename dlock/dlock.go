@@ -0,0 +1,59 @@
+package dlock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLocked 锁已被其他节点持有
+	ErrLocked = fmt.Errorf("dlock: locked by another owner")
+	// ErrNotOwner 当前不是锁的持有者(未加锁或已过期)
+	ErrNotOwner = fmt.Errorf("dlock: not lock owner")
+)
+
+// Locker 分布式锁，同一把锁同一时刻只有一个持有者
+//
+// 加锁成功返回 fencing token，token随每次加锁单调递增，
+// 下游资源可以据此拒绝过期持有者的写入
+type Locker interface {
+	// TryLock 尝试加锁，锁被占用时立即返回ErrLocked
+	TryLock(ctx context.Context) (int64, error)
+	// Lock 加锁，锁被占用时阻塞直到获得锁或者ctx结束
+	Lock(ctx context.Context) (int64, error)
+	// Refresh 续期，已失去锁时返回ErrNotOwner
+	Refresh(ctx context.Context) error
+	// Unlock 解锁
+	Unlock(ctx context.Context) error
+	// Token 当前持有锁的fencing token，未持有为0
+	Token() int64
+}
+
+const (
+	defRetryInterval = time.Millisecond * 100
+)
+
+func ownerID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func waitLock(ctx context.Context, interval time.Duration, try func() (int64, error)) (int64, error) {
+	for {
+		token, err := try()
+		if err != ErrLocked {
+			return token, err
+		}
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return 0, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package dlock
+
+import (
+	"context"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// EtcdLocker 基于etcd lease的锁，lease由session自动续期，
+// fencing token 为加锁时的集群revision
+type EtcdLocker struct {
+	client *clientv3.Client
+	prefix string
+	ttl    int
+
+	mutex   sync.Mutex
+	session *concurrency.Session
+	locker  *concurrency.Mutex
+	token   int64
+}
+
+// NewEtcdLocker 创建etcd锁，ttl为lease的秒数
+func NewEtcdLocker(client *clientv3.Client, prefix string, ttl int) *EtcdLocker {
+	return &EtcdLocker{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (l *EtcdLocker) newMutex() (*concurrency.Mutex, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.session != nil {
+		select {
+		case <-l.session.Done():
+			l.session = nil
+		default:
+		}
+	}
+	if l.session == nil {
+		session, err := concurrency.NewSession(l.client, concurrency.WithTTL(l.ttl))
+		if err != nil {
+			return nil, err
+		}
+		l.session = session
+	}
+	return concurrency.NewMutex(l.session, l.prefix), nil
+}
+
+func (l *EtcdLocker) acquired(m *concurrency.Mutex) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.locker = m
+	l.token = m.Header().Revision
+	return l.token
+}
+
+func (l *EtcdLocker) TryLock(ctx context.Context) (int64, error) {
+	m, err := l.newMutex()
+	if err != nil {
+		return 0, err
+	}
+	err = m.TryLock(ctx)
+	if err == concurrency.ErrLocked {
+		return 0, ErrLocked
+	}
+	if err != nil {
+		return 0, err
+	}
+	return l.acquired(m), nil
+}
+
+func (l *EtcdLocker) Lock(ctx context.Context) (int64, error) {
+	m, err := l.newMutex()
+	if err != nil {
+		return 0, err
+	}
+	if err = m.Lock(ctx); err != nil {
+		return 0, err
+	}
+	return l.acquired(m), nil
+}
+
+// Refresh lease由session自动续期，这里只检查session是否仍然有效
+func (l *EtcdLocker) Refresh(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.locker == nil {
+		return ErrNotOwner
+	}
+	select {
+	case <-l.session.Done():
+		l.locker = nil
+		l.token = 0
+		return ErrNotOwner
+	default:
+	}
+	return nil
+}
+
+func (l *EtcdLocker) Unlock(ctx context.Context) error {
+	l.mutex.Lock()
+	m := l.locker
+	l.locker = nil
+	l.token = 0
+	l.mutex.Unlock()
+
+	if m == nil {
+		return ErrNotOwner
+	}
+	return m.Unlock(ctx)
+}
+
+func (l *EtcdLocker) Token() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.token
+}
+
+// Close 释放session，持有的锁随lease一起失效
+func (l *EtcdLocker) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.locker = nil
+	l.token = 0
+	if l.session != nil {
+		err := l.session.Close()
+		l.session = nil
+		return err
+	}
+	return nil
+}
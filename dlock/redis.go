@@ -0,0 +1,117 @@
+package dlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 只有持有者才能删除/续期
+var redisUnlock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+var redisRefresh = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// RedisLocker 基于 SET NX PX 的redis锁，fencing token 由 INCR 生成
+type RedisLocker struct {
+	client redis.UniversalClient
+	key    string
+	ttl    time.Duration
+	owner  string
+
+	RetryInterval time.Duration
+
+	mutex sync.Mutex
+	token int64
+}
+
+// NewRedisLocker 创建redis锁，ttl为锁的过期时间
+func NewRedisLocker(client redis.UniversalClient, key string, ttl time.Duration) *RedisLocker {
+	return &RedisLocker{
+		client:        client,
+		key:           key,
+		ttl:           ttl,
+		owner:         ownerID(),
+		RetryInterval: defRetryInterval,
+	}
+}
+
+func (l *RedisLocker) fenceKey() string {
+	return fmt.Sprintf("%s:fence", l.key)
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context) (int64, error) {
+	ok, err := l.client.SetNX(ctx, l.key, l.owner, l.ttl).Result()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrLocked
+	}
+	token, err := l.client.Incr(ctx, l.fenceKey()).Result()
+	if err != nil {
+		redisUnlock.Run(ctx, l.client, []string{l.key}, l.owner)
+		return 0, err
+	}
+
+	l.mutex.Lock()
+	l.token = token
+	l.mutex.Unlock()
+
+	return token, nil
+}
+
+func (l *RedisLocker) Lock(ctx context.Context) (int64, error) {
+	return waitLock(ctx, l.RetryInterval, func() (int64, error) {
+		return l.TryLock(ctx)
+	})
+}
+
+func (l *RedisLocker) Refresh(ctx context.Context) error {
+	ret, err := redisRefresh.Run(ctx, l.client, []string{l.key},
+		l.owner, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		l.reset()
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (l *RedisLocker) Unlock(ctx context.Context) error {
+	defer l.reset()
+
+	ret, err := redisUnlock.Run(ctx, l.client, []string{l.key}, l.owner).Int64()
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (l *RedisLocker) Token() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.token
+}
+
+func (l *RedisLocker) reset() {
+	l.mutex.Lock()
+	l.token = 0
+	l.mutex.Unlock()
+}
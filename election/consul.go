@@ -0,0 +1,104 @@
+package election
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulCampaigner 基于consul session + KV lock的选举后端，session自动续约
+type ConsulCampaigner struct {
+	client *api.Client
+	key    string
+	ttl    string
+
+	mutex sync.Mutex
+	lock  *api.Lock
+}
+
+// NewConsulCampaigner 创建consul选举后端，ttl为session ttl，如"15s"
+func NewConsulCampaigner(client *api.Client, key string, ttl string) *ConsulCampaigner {
+	return &ConsulCampaigner{
+		client: client,
+		key:    key,
+		ttl:    ttl,
+	}
+}
+
+func (c *ConsulCampaigner) Campaign(ctx context.Context, id string) (<-chan struct{}, error) {
+	lock, err := c.client.LockOpts(&api.LockOptions{
+		Key:        c.key,
+		Value:      []byte(id),
+		SessionTTL: c.ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stop)
+		case <-done:
+		}
+	}()
+
+	lost, err := lock.Lock(stop)
+	if err != nil {
+		return nil, err
+	}
+	if lost == nil {
+		return nil, ctx.Err()
+	}
+
+	c.mutex.Lock()
+	c.lock = lock
+	c.mutex.Unlock()
+
+	go c.cleanup(lock, lost)
+	return lost, nil
+}
+
+// cleanup 失去lock后释放lock并删除session，已经Resign的由Resign释放
+func (c *ConsulCampaigner) cleanup(lock *api.Lock, lost <-chan struct{}) {
+	<-lost
+
+	c.mutex.Lock()
+	current := c.lock == lock
+	if current {
+		c.lock = nil
+	}
+	c.mutex.Unlock()
+
+	if current {
+		lock.Unlock()
+	}
+}
+
+func (c *ConsulCampaigner) Resign(ctx context.Context) error {
+	c.mutex.Lock()
+	lock := c.lock
+	c.lock = nil
+	c.mutex.Unlock()
+
+	if lock == nil {
+		return nil
+	}
+	return lock.Unlock()
+}
+
+// Leader 查询当前leader的id
+func (c *ConsulCampaigner) Leader(ctx context.Context) (string, error) {
+	pair, _, err := c.client.KV().Get(c.key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	if pair == nil || pair.Session == "" {
+		return "", nil
+	}
+	return string(pair.Value), nil
+}
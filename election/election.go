@@ -0,0 +1,96 @@
+package election
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Campaigner 选举后端
+type Campaigner interface {
+	// Campaign 竞选，阻塞直到当选或者ctx结束，
+	// 返回的channel在失去leader身份(lease/session失效)时关闭
+	Campaign(ctx context.Context, id string) (<-chan struct{}, error)
+	// Resign 主动放弃leader身份
+	Resign(ctx context.Context) error
+}
+
+const (
+	defRetryInterval = time.Second
+)
+
+// Election 在集群中选出唯一的leader，由后端自动续约
+type Election struct {
+	backend Campaigner
+	id      string
+	leader  int32
+
+	// OnElected 当选时回调，ctx在失去leader身份时取消
+	OnElected func(ctx context.Context)
+	// OnResigned 失去leader身份时回调
+	OnResigned func()
+
+	RetryInterval time.Duration
+}
+
+// NewElection 创建选举，id为本节点标识
+func NewElection(backend Campaigner, id string) *Election {
+	return &Election{
+		backend:       backend,
+		id:            id,
+		RetryInterval: defRetryInterval,
+	}
+}
+
+func (e *Election) ID() string {
+	return e.id
+}
+
+// IsLeader 当前节点是否为leader
+func (e *Election) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// Run 持续参与选举直到ctx结束，失去leader身份后会重新竞选
+func (e *Election) Run(ctx context.Context) error {
+	for {
+		lost, err := e.backend.Campaign(ctx, e.id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			t := time.NewTimer(e.RetryInterval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+			continue
+		}
+
+		leaderCtx, cancel := context.WithCancel(ctx)
+		atomic.StoreInt32(&e.leader, 1)
+		if e.OnElected != nil {
+			go e.OnElected(leaderCtx)
+		}
+
+		select {
+		case <-lost:
+		case <-ctx.Done():
+			resignCtx, resignCancel := context.WithTimeout(context.Background(), e.RetryInterval)
+			e.backend.Resign(resignCtx)
+			resignCancel()
+		}
+
+		cancel()
+		atomic.StoreInt32(&e.leader, 0)
+		if e.OnResigned != nil {
+			e.OnResigned()
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
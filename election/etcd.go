@@ -0,0 +1,92 @@
+package election
+
+import (
+	"context"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// EtcdCampaigner 基于etcd session的选举后端，session自动续约
+type EtcdCampaigner struct {
+	client *clientv3.Client
+	prefix string
+	ttl    int
+
+	mutex    sync.Mutex
+	session  *concurrency.Session
+	election *concurrency.Election
+}
+
+// NewEtcdCampaigner 创建etcd选举后端，ttl为session的秒数
+func NewEtcdCampaigner(client *clientv3.Client, prefix string, ttl int) *EtcdCampaigner {
+	return &EtcdCampaigner{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (c *EtcdCampaigner) Campaign(ctx context.Context, id string) (<-chan struct{}, error) {
+	// session不绑定ctx，ctx结束后仍然可以Resign并撤销lease
+	session, err := concurrency.NewSession(c.client, concurrency.WithTTL(c.ttl))
+	if err != nil {
+		return nil, err
+	}
+	election := concurrency.NewElection(session, c.prefix)
+	if err = election.Campaign(ctx, id); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.session = session
+	c.election = election
+	c.mutex.Unlock()
+
+	go c.cleanup(session)
+	return session.Done(), nil
+}
+
+// cleanup session失效后关闭session，已经Resign的由Resign关闭
+func (c *EtcdCampaigner) cleanup(session *concurrency.Session) {
+	<-session.Done()
+
+	c.mutex.Lock()
+	current := c.session == session
+	if current {
+		c.session, c.election = nil, nil
+	}
+	c.mutex.Unlock()
+
+	if current {
+		session.Close()
+	}
+}
+
+func (c *EtcdCampaigner) Resign(ctx context.Context) error {
+	c.mutex.Lock()
+	session, election := c.session, c.election
+	c.session, c.election = nil, nil
+	c.mutex.Unlock()
+
+	if session == nil {
+		return nil
+	}
+	err := election.Resign(ctx)
+	session.Close()
+	return err
+}
+
+// Leader 查询当前leader的id，直接读取选举的key，不创建session
+func (c *EtcdCampaigner) Leader(ctx context.Context) (string, error) {
+	rsp, err := c.client.Get(ctx, c.prefix+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(rsp.Kvs) == 0 {
+		return "", concurrency.ErrElectionNoLeader
+	}
+	return string(rsp.Kvs[0].Value), nil
+}
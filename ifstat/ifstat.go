@@ -0,0 +1,161 @@
+package ifstat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buf1024/golib/metrics"
+)
+
+// Stat 网卡累计计数
+type Stat struct {
+	Name      string
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// Read 读取所有网卡的计数
+func Read() ([]Stat, error) {
+	return readStats()
+}
+
+const defInterval = time.Second * 10
+
+// Collector 定时采集网卡计数并发布到metrics
+//
+// 发布的指标(标签iface):
+//
+//	netif_rx_bytes, netif_rx_packets, netif_rx_errors, netif_rx_dropped,
+//	netif_tx_bytes, netif_tx_packets, netif_tx_errors, netif_tx_dropped  累计计数
+//	netif_rx_bytes_rate, netif_tx_bytes_rate                             字节/秒
+type Collector struct {
+	registry *metrics.Registry
+	interval time.Duration
+
+	// Filter 返回false的网卡不采集
+	Filter func(name string) bool
+
+	mutex  sync.Mutex
+	last   map[string]Stat
+	lastAt time.Time
+	stop   chan struct{}
+}
+
+// NewCollector 创建采集器，registry为空使用metrics.Default
+func NewCollector(registry *metrics.Registry, interval time.Duration) *Collector {
+	if registry == nil {
+		registry = metrics.Default
+	}
+	if interval <= 0 {
+		interval = defInterval
+	}
+	return &Collector{
+		registry: registry,
+		interval: interval,
+	}
+}
+
+// Start 开始定时采集
+func (c *Collector) Start() error {
+	if err := c.Collect(); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stop != nil {
+		return nil
+	}
+	c.stop = make(chan struct{})
+	go c.loop(c.stop)
+	return nil
+}
+
+// Stop 停止采集
+func (c *Collector) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *Collector) loop(stop chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.Collect()
+		}
+	}
+}
+
+// Collect 采集一次
+func (c *Collector) Collect() error {
+	stats, err := readStats()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elapsed := now.Sub(c.lastAt).Seconds()
+	last := make(map[string]Stat, len(stats))
+	for _, s := range stats {
+		if c.Filter != nil && !c.Filter(s.Name) {
+			continue
+		}
+		last[s.Name] = s
+		c.publish(s)
+
+		prev, ok := c.last[s.Name]
+		if ok && elapsed > 0 && s.RxBytes >= prev.RxBytes && s.TxBytes >= prev.TxBytes {
+			labels := metrics.Labels{"iface": s.Name}
+			c.registry.Gauge("netif_rx_bytes_rate", labels).Set(float64(s.RxBytes-prev.RxBytes) / elapsed)
+			c.registry.Gauge("netif_tx_bytes_rate", labels).Set(float64(s.TxBytes-prev.TxBytes) / elapsed)
+		}
+	}
+	c.last = last
+	c.lastAt = now
+
+	return nil
+}
+
+func (c *Collector) publish(s Stat) {
+	labels := metrics.Labels{"iface": s.Name}
+	r := c.registry
+	r.Counter("netif_rx_bytes", labels).Set(s.RxBytes)
+	r.Counter("netif_rx_packets", labels).Set(s.RxPackets)
+	r.Counter("netif_rx_errors", labels).Set(s.RxErrors)
+	r.Counter("netif_rx_dropped", labels).Set(s.RxDropped)
+	r.Counter("netif_tx_bytes", labels).Set(s.TxBytes)
+	r.Counter("netif_tx_packets", labels).Set(s.TxPackets)
+	r.Counter("netif_tx_errors", labels).Set(s.TxErrors)
+	r.Counter("netif_tx_dropped", labels).Set(s.TxDropped)
+}
+
+// Last 最近一次采集的结果
+func (c *Collector) Last() []Stat {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := make([]Stat, 0, len(c.last))
+	for _, v := range c.last {
+		stats = append(stats, v)
+	}
+	return stats
+}
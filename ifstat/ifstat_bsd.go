@@ -0,0 +1,44 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ifstat
+
+import (
+	"encoding/binary"
+	"syscall"
+
+	"golang.org/x/net/route"
+)
+
+// 通过sysctl读取接口列表(route.FetchRIB)，网卡名由route解析，计数由各平台的ifCounters从if_msghdr中取出
+func readStats() ([]Stat, error) {
+	rib, err := route.FetchRIB(syscall.AF_UNSPEC, ribType, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := route.ParseRIB(ribType, rib)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string)
+	for _, msg := range msgs {
+		if m, ok := msg.(*route.InterfaceMessage); ok && m.Name != "" {
+			names[m.Index] = m.Name
+		}
+	}
+
+	var stats []Stat
+	for len(rib) >= 4 {
+		l := int(binary.NativeEndian.Uint16(rib[:2]))
+		if l < 4 || l > len(rib) {
+			break
+		}
+		if index, stat, ok := ifCounters(int(rib[3]), rib[:l]); ok {
+			if name, ok := names[index]; ok {
+				stat.Name = name
+				stats = append(stats, stat)
+			}
+		}
+		rib = rib[l:]
+	}
+	return stats, nil
+}
@@ -0,0 +1,29 @@
+package ifstat
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// NET_RT_IFLIST2返回if_msghdr2，计数是64位的
+const ribType = unix.NET_RT_IFLIST2
+
+func ifCounters(typ int, b []byte) (int, Stat, bool) {
+	if typ != unix.RTM_IFINFO2 || len(b) < unix.SizeofIfMsghdr2 {
+		return 0, Stat{}, false
+	}
+	// 报文只按4字节对齐，复制出来再读64位的计数
+	var m unix.IfMsghdr2
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&m)), unsafe.Sizeof(m)), b)
+	return int(m.Index), Stat{
+		RxBytes:   m.Data.Ibytes,
+		RxPackets: m.Data.Ipackets,
+		RxErrors:  m.Data.Ierrors,
+		RxDropped: m.Data.Iqdrops,
+		TxBytes:   m.Data.Obytes,
+		TxPackets: m.Data.Opackets,
+		TxErrors:  m.Data.Oerrors,
+		TxDropped: uint64(m.Snd_drops),
+	}, true
+}
@@ -0,0 +1,69 @@
+package ifstat
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const ribType = unix.NET_RT_IFLIST
+
+// ifMsghdr 内核的struct if_msghdr，unix.IfMsghdr是FreeBSD 8的旧布局
+type ifMsghdr struct {
+	Msglen  uint16
+	Version uint8
+	Type    uint8
+	Addrs   int32
+	Flags   int32
+	Index   uint16
+	_       uint16
+	Data    ifData
+}
+
+// ifData 内核的struct if_data
+type ifData struct {
+	Type       uint8
+	Physical   uint8
+	Addrlen    uint8
+	Hdrlen     uint8
+	Link_state uint8
+	Vhid       uint8
+	Datalen    uint16
+	Mtu        uint32
+	Metric     uint32
+	Baudrate   uint64
+	Ipackets   uint64
+	Ierrors    uint64
+	Opackets   uint64
+	Oerrors    uint64
+	Collisions uint64
+	Ibytes     uint64
+	Obytes     uint64
+	Imcasts    uint64
+	Omcasts    uint64
+	Iqdrops    uint64
+	Oqdrops    uint64
+	Noproto    uint64
+	Hwassist   uint64
+	_          [8]byte
+	_          [16]byte
+}
+
+func ifCounters(typ int, b []byte) (int, Stat, bool) {
+	if typ != unix.RTM_IFINFO || len(b) < int(unsafe.Sizeof(ifMsghdr{})) {
+		return 0, Stat{}, false
+	}
+	// 报文只按4字节对齐，复制出来再读64位的计数
+	var m ifMsghdr
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&m)), unsafe.Sizeof(m)), b)
+	return int(m.Index), Stat{
+		RxBytes:   m.Data.Ibytes,
+		RxPackets: m.Data.Ipackets,
+		RxErrors:  m.Data.Ierrors,
+		RxDropped: m.Data.Iqdrops,
+		TxBytes:   m.Data.Obytes,
+		TxPackets: m.Data.Opackets,
+		TxErrors:  m.Data.Oerrors,
+		TxDropped: m.Data.Oqdrops,
+	}, true
+}
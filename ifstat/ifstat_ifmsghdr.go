@@ -0,0 +1,29 @@
+//go:build netbsd || openbsd || dragonfly
+
+package ifstat
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const ribType = unix.NET_RT_IFLIST
+
+func ifCounters(typ int, b []byte) (int, Stat, bool) {
+	if typ != unix.RTM_IFINFO || len(b) < unix.SizeofIfMsghdr {
+		return 0, Stat{}, false
+	}
+	// 报文只按4字节对齐，复制出来再读64位的计数
+	var m unix.IfMsghdr
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&m)), unsafe.Sizeof(m)), b)
+	return int(m.Index), Stat{
+		RxBytes:   uint64(m.Data.Ibytes),
+		RxPackets: uint64(m.Data.Ipackets),
+		RxErrors:  uint64(m.Data.Ierrors),
+		RxDropped: uint64(m.Data.Iqdrops),
+		TxBytes:   uint64(m.Data.Obytes),
+		TxPackets: uint64(m.Data.Opackets),
+		TxErrors:  uint64(m.Data.Oerrors),
+	}, true
+}
@@ -0,0 +1,56 @@
+package ifstat
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const procNetDev = "/proc/net/dev"
+
+// 读取 /proc/net/dev，前两行为表头，之后每行为 "网卡名: 接收8列 发送8列"
+func readStats() ([]Stat, error) {
+	f, err := os.Open(procNetDev)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stats []Stat
+	scanner := bufio.NewScanner(f)
+	for line := 0; scanner.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+		text := scanner.Text()
+		i := strings.Index(text, ":")
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(text[i+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("%s format not right: %s", procNetDev, text)
+		}
+		v := make([]uint64, 16)
+		for j := range v {
+			v[j], err = strconv.ParseUint(fields[j], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		stats = append(stats, Stat{
+			Name:      strings.TrimSpace(text[:i]),
+			RxBytes:   v[0],
+			RxPackets: v[1],
+			RxErrors:  v[2],
+			RxDropped: v[3],
+			TxBytes:   v[8],
+			TxPackets: v[9],
+			TxErrors:  v[10],
+			TxDropped: v[11],
+		})
+	}
+	return stats, scanner.Err()
+}
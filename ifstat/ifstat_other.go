@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package ifstat
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readStats() ([]Stat, error) {
+	return nil, fmt.Errorf("ifstat not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,66 @@
+package ipnet
+
+import (
+	"net"
+)
+
+var privateSet, bogonSet *CIDRSet
+
+// IsPrivate 是否为私有地址(RFC1918, RFC4193, 回环, 链路本地)
+func IsPrivate(ip net.IP) bool {
+	return privateSet.Contains(ip)
+}
+
+// IsBogon 是否为不应该出现在公网上的地址(私有, 保留, 文档, 组播等)
+func IsBogon(ip net.IP) bool {
+	return bogonSet.Contains(ip)
+}
+
+// IsPublic 是否为公网地址
+func IsPublic(ip net.IP) bool {
+	return ip != nil && !IsBogon(ip)
+}
+
+func init() {
+	var err error
+	privateSet, err = NewCIDRSet(
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	bogonSet, err = NewCIDRSet(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"100::/64",
+		"2001:db8::/32",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,59 @@
+package ipnet
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HostPort 主机和端口
+type HostPort struct {
+	Host string
+	Port int
+}
+
+func (h HostPort) String() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+// ParseHostPort 解析 host:port，没有端口时使用defPort(defPort<=0时要求必须有端口)，
+// ipv6需要用[]括起来
+func ParseHostPort(s string, defPort int) (HostPort, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return HostPort{}, fmt.Errorf("empty address")
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		if defPort <= 0 {
+			return HostPort{}, err
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			return HostPort{}, err
+		}
+		return HostPort{Host: host, Port: defPort}, nil
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return HostPort{}, fmt.Errorf("invalid port in %s", s)
+	}
+	return HostPort{Host: host, Port: p}, nil
+}
+
+// ParseHostPorts 解析以逗号或空白分隔的 host:port 列表
+func ParseHostPorts(s string, defPort int) ([]HostPort, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n'
+	})
+	addrs := make([]HostPort, 0, len(fields))
+	for _, v := range fields {
+		hp, err := ParseHostPort(v, defPort)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, hp)
+	}
+	return addrs, nil
+}
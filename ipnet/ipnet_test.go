@@ -0,0 +1,87 @@
+package ipnet
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCIDRSet(t *testing.T) {
+	s, err := NewCIDRSet("10.0.0.0/8", "10.1.0.0/16", "192.168.1.1", "2001:db8::/32")
+	if err != nil {
+		t.Fatalf("new set failed, err = %s", err)
+	}
+	cases := map[string]bool{
+		"10.2.3.4":        true,
+		"10.1.2.3":        true,
+		"11.0.0.1":        false,
+		"192.168.1.1":     true,
+		"192.168.1.2":     false,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+		"::ffff:10.0.0.1": true,
+	}
+	for ip, expect := range cases {
+		if s.Contains(net.ParseIP(ip)) != expect {
+			t.Errorf("contains %s expect %v", ip, expect)
+		}
+	}
+	n, _ := s.Match(net.ParseIP("10.1.2.3"))
+	if n.String() != "10.1.0.0/16" {
+		t.Errorf("longest match not right: %s", n)
+	}
+	if ok, _ := s.Remove("10.1.0.0/16"); !ok {
+		t.Errorf("remove failed")
+	}
+	n, _ = s.Match(net.ParseIP("10.1.2.3"))
+	if n.String() != "10.0.0.0/8" || s.Len() != 3 {
+		t.Errorf("match after remove not right: %s, len = %d", n, s.Len())
+	}
+	if !s.ContainsString("10.0.0.1:8080") {
+		t.Errorf("contains host:port failed")
+	}
+}
+
+func TestClass(t *testing.T) {
+	if !IsPrivate(net.ParseIP("192.168.0.1")) || IsPrivate(net.ParseIP("8.8.8.8")) {
+		t.Errorf("private not right")
+	}
+	if !IsBogon(net.ParseIP("203.0.113.5")) || !IsPublic(net.ParseIP("1.1.1.1")) {
+		t.Errorf("bogon not right")
+	}
+}
+
+func TestRange(t *testing.T) {
+	r, err := ParseRange("10.0.0.254-10.0.1.1")
+	if err != nil {
+		t.Fatalf("parse range failed, err = %s", err)
+	}
+	var ips []string
+	r.Each(func(ip net.IP) bool {
+		ips = append(ips, ip.String())
+		return true
+	})
+	if len(ips) != 4 || ips[2] != "10.0.1.0" {
+		t.Errorf("range each not right: %v", ips)
+	}
+	r, _ = ParseRange("192.168.0.0/30")
+	if r.End.String() != "192.168.0.3" || !r.Contains(net.ParseIP("192.168.0.2")) {
+		t.Errorf("cidr range not right: %s", r)
+	}
+	if _, err = ParseRange("10.0.0.9-10.0.0.1"); err == nil {
+		t.Errorf("expect invalid range")
+	}
+}
+
+func TestHostPorts(t *testing.T) {
+	addrs, err := ParseHostPorts("a.com:80, 10.0.0.1 [::1]:53,[fe80::1]", 8080)
+	if err != nil {
+		t.Fatalf("parse failed, err = %s", err)
+	}
+	if len(addrs) != 4 || addrs[1].Port != 8080 || addrs[2].Host != "::1" ||
+		addrs[3].String() != "[fe80::1]:8080" {
+		t.Errorf("parse not right: %v", addrs)
+	}
+	if _, err = ParseHostPorts("a.com", 0); err == nil {
+		t.Errorf("expect missing port error")
+	}
+}
@@ -0,0 +1,113 @@
+package ipnet
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Range 闭区间ip段
+type Range struct {
+	Start net.IP
+	End   net.IP
+}
+
+// ParseRange 解析ip段，支持 "10.0.0.1-10.0.0.9", "10.0.0.0/24" 和单个ip
+func ParseRange(s string) (*Range, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "-"); i > 0 {
+		start := net.ParseIP(strings.TrimSpace(s[:i]))
+		end := net.ParseIP(strings.TrimSpace(s[i+1:]))
+		if start == nil || end == nil {
+			return nil, fmt.Errorf("invalid range %s", s)
+		}
+		r := newRange(start, end)
+		if len(r.Start) != len(r.End) || bytes.Compare(r.Start, r.End) > 0 {
+			return nil, fmt.Errorf("invalid range %s", s)
+		}
+		return r, nil
+	}
+	n, err := ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return RangeOf(n), nil
+}
+
+func newRange(start, end net.IP) *Range {
+	if s4, e4 := start.To4(), end.To4(); s4 != nil && e4 != nil {
+		return &Range{Start: s4, End: e4}
+	}
+	return &Range{Start: start.To16(), End: end.To16()}
+}
+
+// RangeOf 网段对应的ip段
+func RangeOf(n *net.IPNet) *Range {
+	n = normalize(n)
+	start := n.IP.Mask(n.Mask)
+	end := make(net.IP, len(start))
+	for i := range start {
+		end[i] = start[i] | ^n.Mask[i]
+	}
+	return &Range{Start: start, End: end}
+}
+
+// Contains ip是否在段内
+func (r *Range) Contains(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && len(r.Start) == net.IPv4len {
+		ip = ip4
+	} else {
+		ip = ip.To16()
+	}
+	if len(ip) != len(r.Start) {
+		return false
+	}
+	return bytes.Compare(ip, r.Start) >= 0 && bytes.Compare(ip, r.End) <= 0
+}
+
+// Each 按顺序遍历段内所有ip，fn返回false停止
+func (r *Range) Each(fn func(ip net.IP) bool) {
+	for ip := dupIP(r.Start); bytes.Compare(ip, r.End) <= 0; ip = NextIP(ip) {
+		if !fn(dupIP(ip)) {
+			return
+		}
+		if ip.Equal(r.End) {
+			return
+		}
+	}
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("%s-%s", r.Start, r.End)
+}
+
+func dupIP(ip net.IP) net.IP {
+	dup := make(net.IP, len(ip))
+	copy(dup, ip)
+	return dup
+}
+
+// NextIP 下一个ip，溢出时回绕到0
+func NextIP(ip net.IP) net.IP {
+	next := dupIP(ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// PrevIP 上一个ip，溢出时回绕到全1
+func PrevIP(ip net.IP) net.IP {
+	prev := dupIP(ip)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			break
+		}
+	}
+	return prev
+}
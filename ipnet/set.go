@@ -0,0 +1,226 @@
+package ipnet
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+type radixNode struct {
+	child [2]*radixNode
+	ipnet *net.IPNet // 非空表示该节点是一个网段
+}
+
+// CIDRSet 基于二叉radix树的网段集合，查询复杂度与地址位数相关，与网段数量无关，
+// 并发安全
+type CIDRSet struct {
+	mutex sync.RWMutex
+	v4    *radixNode
+	v6    *radixNode
+	size  int
+}
+
+// NewCIDRSet 创建集合，cidrs可以是网段或者单个ip
+func NewCIDRSet(cidrs ...string) (*CIDRSet, error) {
+	s := &CIDRSet{
+		v4: &radixNode{},
+		v6: &radixNode{},
+	}
+	for _, v := range cidrs {
+		if err := s.Add(v); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ParseCIDR 解析网段，单个ip视为/32或者/128
+func ParseCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		return normalize(n), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func normalize(n *net.IPNet) *net.IPNet {
+	ones, bits := n.Mask.Size()
+	if ip4 := n.IP.To4(); ip4 != nil && bits == 32 {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones, 32)}
+	}
+	return n
+}
+
+func (s *CIDRSet) root(ip net.IP) (*radixNode, net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return s.v4, ip4
+	}
+	return s.v6, ip.To16()
+}
+
+func (s *CIDRSet) netRoot(n *net.IPNet) (*radixNode, net.IP) {
+	if len(n.Mask) == net.IPv4len {
+		return s.v4, n.IP.To4()
+	}
+	return s.v6, n.IP.To16()
+}
+
+func bit(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
+
+// Add 添加网段
+func (s *CIDRSet) Add(cidr string) error {
+	n, err := ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	s.AddNet(n)
+	return nil
+}
+
+// AddNet 添加网段
+func (s *CIDRSet) AddNet(n *net.IPNet) {
+	n = normalize(n)
+	ones, _ := n.Mask.Size()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	node, ip := s.netRoot(n)
+	for i := 0; i < ones; i++ {
+		b := bit(ip, i)
+		if node.child[b] == nil {
+			node.child[b] = &radixNode{}
+		}
+		node = node.child[b]
+	}
+	if node.ipnet == nil {
+		s.size++
+	}
+	node.ipnet = n
+}
+
+// Remove 删除网段，网段不存在返回false
+func (s *CIDRSet) Remove(cidr string) (bool, error) {
+	n, err := ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	return s.RemoveNet(n), nil
+}
+
+// RemoveNet 删除网段，网段不存在返回false
+func (s *CIDRSet) RemoveNet(n *net.IPNet) bool {
+	n = normalize(n)
+	ones, _ := n.Mask.Size()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	node, ip := s.netRoot(n)
+	path := []*radixNode{node}
+	for i := 0; i < ones; i++ {
+		node = node.child[bit(ip, i)]
+		if node == nil {
+			return false
+		}
+		path = append(path, node)
+	}
+	if node.ipnet == nil {
+		return false
+	}
+	node.ipnet = nil
+	s.size--
+
+	// 回收空分支
+	for i := len(path) - 1; i > 0; i-- {
+		cur := path[i]
+		if cur.ipnet != nil || cur.child[0] != nil || cur.child[1] != nil {
+			break
+		}
+		path[i-1].child[bit(ip, i-1)] = nil
+	}
+	return true
+}
+
+// Match 最长前缀匹配，返回包含ip的最小网段
+func (s *CIDRSet) Match(ip net.IP) (*net.IPNet, bool) {
+	if ip == nil {
+		return nil, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	node, key := s.root(ip)
+	if key == nil {
+		return nil, false
+	}
+	var match *net.IPNet
+	for i := 0; node != nil; i++ {
+		if node.ipnet != nil {
+			match = node.ipnet
+		}
+		if i == len(key)*8 {
+			break
+		}
+		node = node.child[bit(key, i)]
+	}
+	return match, match != nil
+}
+
+// Contains ip是否在集合中的任一网段内
+func (s *CIDRSet) Contains(ip net.IP) bool {
+	_, ok := s.Match(ip)
+	return ok
+}
+
+// ContainsString 同Contains，s可以是ip或者host:port
+func (s *CIDRSet) ContainsString(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return s.Contains(net.ParseIP(addr))
+}
+
+// Len 网段数量
+func (s *CIDRSet) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.size
+}
+
+// List 所有网段
+func (s *CIDRSet) List() []*net.IPNet {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var nets []*net.IPNet
+	var walk func(n *radixNode)
+	walk = func(n *radixNode) {
+		if n == nil {
+			return
+		}
+		if n.ipnet != nil {
+			nets = append(nets, n.ipnet)
+		}
+		walk(n.child[0])
+		walk(n.child[1])
+	}
+	walk(s.v4)
+	walk(s.v6)
+	return nets
+}
@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	KindCounter = iota
+	KindGauge
+	KindHistogram
+)
+
+// Labels 指标标签
+type Labels map[string]string
+
+func (l Labels) key() string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString("=\"")
+		b.WriteString(l[k])
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// Counter 单调递增计数
+type Counter struct {
+	v uint64
+}
+
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+func (c *Counter) Add(v uint64) {
+	atomic.AddUint64(&c.v, v)
+}
+
+// Set 直接设置计数，用于采集外部的累计值
+func (c *Counter) Set(v uint64) {
+	atomic.StoreUint64(&c.v, v)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// Gauge 可增可减的瞬时值
+type Gauge struct {
+	bits uint64
+}
+
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+func (g *Gauge) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&g.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// DefBuckets 默认的直方图桶，单位秒
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram 直方图，桶的上界为累计(le)语义
+type Histogram struct {
+	upper  []float64
+	counts []uint64
+	count  uint64
+	sum    Gauge
+}
+
+// NewHistogram 创建直方图，buckets为空时使用DefBuckets
+func NewHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	upper := append([]float64(nil), buckets...)
+	sort.Float64s(upper)
+	return &Histogram{
+		upper:  upper,
+		counts: make([]uint64, len(upper)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.sum.Add(v)
+}
+
+// Bucket 直方图的桶，Count为小于等于Upper的累计个数
+type Bucket struct {
+	Upper float64
+	Count uint64
+}
+
+// Snapshot 返回累计的桶、总个数和总和
+func (h *Histogram) Snapshot() ([]Bucket, uint64, float64) {
+	buckets := make([]Bucket, len(h.upper))
+	var total uint64
+	for i, upper := range h.upper {
+		total += atomic.LoadUint64(&h.counts[i])
+		buckets[i] = Bucket{Upper: upper, Count: total}
+	}
+	count := atomic.LoadUint64(&h.count)
+	if count < total {
+		count = total
+	}
+	return buckets, count, h.sum.Value()
+}
+
+type metric struct {
+	name   string
+	labels Labels
+	kind   int
+	value  interface{}
+}
+
+// Registry 指标注册表，相同名字和标签返回同一个指标
+type Registry struct {
+	mutex   sync.RWMutex
+	metrics map[string]*metric
+	help    map[string]string
+}
+
+// Default 默认注册表
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]*metric),
+		help:    make(map[string]string),
+	}
+}
+
+func (r *Registry) get(name string, labels Labels, kind int, create func() interface{}) interface{} {
+	key := name + "{" + labels.key() + "}"
+
+	r.mutex.RLock()
+	m, ok := r.metrics[key]
+	r.mutex.RUnlock()
+	if ok {
+		return m.value
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if m, ok = r.metrics[key]; ok {
+		return m.value
+	}
+	dup := make(Labels, len(labels))
+	for k, v := range labels {
+		dup[k] = v
+	}
+	m = &metric{
+		name:   name,
+		labels: dup,
+		kind:   kind,
+		value:  create(),
+	}
+	r.metrics[key] = m
+	return m.value
+}
+
+// Counter 获取或者创建计数
+func (r *Registry) Counter(name string, labels Labels) *Counter {
+	return r.get(name, labels, KindCounter, func() interface{} { return &Counter{} }).(*Counter)
+}
+
+// Gauge 获取或者创建瞬时值
+func (r *Registry) Gauge(name string, labels Labels) *Gauge {
+	return r.get(name, labels, KindGauge, func() interface{} { return &Gauge{} }).(*Gauge)
+}
+
+// Histogram 获取或者创建直方图，buckets只在创建时使用
+func (r *Registry) Histogram(name string, labels Labels, buckets []float64) *Histogram {
+	return r.get(name, labels, KindHistogram, func() interface{} { return NewHistogram(buckets) }).(*Histogram)
+}
+
+// Register 注册已有的指标，m为*Counter、*Gauge或*Histogram，
+// 相同名字和标签的指标会被替换
+func (r *Registry) Register(name string, labels Labels, m interface{}) error {
+	kind := KindCounter
+	switch m.(type) {
+	case *Counter:
+	case *Gauge:
+		kind = KindGauge
+	case *Histogram:
+		kind = KindHistogram
+	default:
+		return fmt.Errorf("unexpect metric type %T", m)
+	}
+	dup := make(Labels, len(labels))
+	for k, v := range labels {
+		dup[k] = v
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.metrics[name+"{"+labels.key()+"}"] = &metric{
+		name:   name,
+		labels: dup,
+		kind:   kind,
+		value:  m,
+	}
+	return nil
+}
+
+// Help 设置指标说明
+func (r *Registry) Help(name string, help string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.help[name] = help
+}
+
+// Unregister 删除指标
+func (r *Registry) Unregister(name string, labels Labels) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.metrics, name+"{"+labels.key()+"}")
+}
+
+// Sample 指标采样值，直方图的Value为总和
+type Sample struct {
+	Name   string
+	Labels Labels
+	Kind   int
+	Value  float64
+
+	Buckets []Bucket
+	Count   uint64
+}
+
+// Gather 采集所有指标，按名字和标签排序
+func (r *Registry) Gather() []Sample {
+	r.mutex.RLock()
+	list := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].name != list[j].name {
+			return list[i].name < list[j].name
+		}
+		return list[i].labels.key() < list[j].labels.key()
+	})
+
+	samples := make([]Sample, 0, len(list))
+	for _, m := range list {
+		s := Sample{
+			Name:   m.name,
+			Labels: m.labels,
+			Kind:   m.kind,
+		}
+		switch v := m.value.(type) {
+		case *Counter:
+			s.Value = float64(v.Value())
+		case *Gauge:
+			s.Value = v.Value()
+		case *Histogram:
+			s.Buckets, s.Count, s.Value = v.Snapshot()
+		}
+		samples = append(samples, s)
+	}
+	r.mutex.RUnlock()
+
+	return samples
+}
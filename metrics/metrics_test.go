@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{1, 5, 10})
+	for _, v := range []float64{0.5, 1, 3, 7, 20} {
+		h.Observe(v)
+	}
+	buckets, count, sum := h.Snapshot()
+	want := []uint64{2, 3, 4}
+	for i, b := range buckets {
+		if b.Count != want[i] {
+			t.Fatalf("bucket %v count = %d, want %d", b.Upper, b.Count, want[i])
+		}
+	}
+	if count != 5 || sum != 31.5 {
+		t.Fatalf("count = %d, sum = %v", count, sum)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.Help("requests_total", "total requests")
+	r.Counter("requests_total", Labels{"code": "200"}).Add(3)
+	r.Counter("requests_total", Labels{"code": "500"}).Inc()
+	r.Gauge("temp", Labels{"room": "a\"b"}).Set(1.5)
+	r.Histogram("latency", nil, []float64{0.1}).Observe(0.05)
+
+	var buf bytes.Buffer
+	if err := r.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE latency histogram
+latency_bucket{le="0.1"} 1
+latency_bucket{le="+Inf"} 1
+latency_sum 0.05
+latency_count 1
+# HELP requests_total total requests
+# TYPE requests_total counter
+requests_total{code="200"} 3
+requests_total{code="500"} 1
+# TYPE temp gauge
+temp{room="a\"b"} 1.5
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("output not right:\n%s", got)
+	}
+	if strings.Count(buf.String(), "# TYPE requests_total") != 1 {
+		t.Fatalf("type line repeated")
+	}
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus 以Prometheus文本格式输出所有指标
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	samples := r.Gather()
+
+	r.mutex.RLock()
+	help := make(map[string]string, len(r.help))
+	for k, v := range r.help {
+		help[k] = v
+	}
+	r.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	last := ""
+	for _, s := range samples {
+		if s.Name != last {
+			last = s.Name
+			if h, ok := help[s.Name]; ok {
+				bw.WriteString("# HELP " + s.Name + " " + escapeHelp(h) + "\n")
+			}
+			bw.WriteString("# TYPE " + s.Name + " " + kindName(s.Kind) + "\n")
+		}
+		if s.Kind != KindHistogram {
+			writeSample(bw, s.Name, s.Labels, "", "", s.Value)
+			continue
+		}
+		for _, b := range s.Buckets {
+			writeSample(bw, s.Name+"_bucket", s.Labels, "le", formatFloat(b.Upper), float64(b.Count))
+		}
+		writeSample(bw, s.Name+"_bucket", s.Labels, "le", "+Inf", float64(s.Count))
+		writeSample(bw, s.Name+"_sum", s.Labels, "", "", s.Value)
+		writeSample(bw, s.Name+"_count", s.Labels, "", "", float64(s.Count))
+	}
+	return bw.Flush()
+}
+
+// Handler 用于暴露/metrics的http.Handler
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+func kindName(kind int) string {
+	switch kind {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	case KindHistogram:
+		return "histogram"
+	}
+	return "untyped"
+}
+
+func writeSample(w *bufio.Writer, name string, labels Labels, extraKey, extraValue string, v float64) {
+	w.WriteString(name)
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if extraKey != "" {
+		keys = append(keys, extraKey)
+	}
+	if len(keys) > 0 {
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			value := labels[k]
+			if k == extraKey {
+				value = extraValue
+			}
+			w.WriteString(k + "=\"" + escapeLabel(value) + "\"")
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,102 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// accept出错后的等待时间，连续出错时翻倍，成功后重置
+const (
+	acceptMinBackoff = 5 * time.Millisecond
+	acceptMaxBackoff = time.Second
+)
+
+// SetAcceptRate 限制每秒接受的连接数，burst为允许的突发，<=0时取rate的1/10(至少为1)。
+// 超过速率时暂停accept，新连接留在系统的backlog中。rate<=0不限制，可以在运行时修改
+func (l *Listener) SetAcceptRate(rate int64, burst int) {
+	if rate <= 0 {
+		l.acceptLimit.Store(nil)
+		return
+	}
+	if lim := l.acceptLimit.Load(); lim != nil {
+		lim.SetRate(rate, burst)
+		return
+	}
+	l.acceptLimit.CompareAndSwap(nil, newLimiter(rate, burst, 1))
+}
+
+// AcceptRate 当前每秒接受连接数的限制，0表示不限制
+func (l *Listener) AcceptRate() int64 {
+	if lim := l.acceptLimit.Load(); lim != nil {
+		return lim.Rate()
+	}
+	return 0
+}
+
+// waitAccept 等待accept的额度，监听关闭返回false
+func (l *Listener) waitAccept() bool {
+	if lim := l.acceptLimit.Load(); lim != nil {
+		return lim.WaitN(l.ctx, 1) == nil
+	}
+	return true
+}
+
+// acceptBackoff accept出错后等待，避免持续出错(如文件描述符耗尽)时空转。
+// 返回下一次的等待时间，监听关闭返回0
+func (n *SimpleNet) acceptBackoff(l *Listener, delay time.Duration, err error) time.Duration {
+	if delay == 0 {
+		delay = acceptMinBackoff
+	} else {
+		delay = min(delay*2, acceptMaxBackoff)
+	}
+	n.logMsg(mylog.LevelError,
+		fmt.Sprintf("accept failed, retry in %s, err = %s\n", delay, err))
+	if sleepCtx(l.ctx, delay) != nil {
+		return 0
+	}
+	return delay
+}
+
+// RejectError EventConnectionRejected的Data，Reason为拒绝的类型(RejectXXX)，
+// Err为AcceptFilter或者PeerVerifier返回的原因，可能为nil
+type RejectError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	if e.Err == nil {
+		return "connection rejected, reason = " + e.Reason
+	}
+	return "connection rejected, reason = " + e.Reason + ", err = " + e.Err.Error()
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// AcceptFilter 连接过滤，在握手和黑白名单检查之后、创建Connection之前调用，
+// remote为对端地址(PROXY protocol时为真实地址)。返回false拒绝连接，err为拒绝的原因
+type AcceptFilter func(l *Listener, remote net.Addr) (bool, error)
+
+// SetAcceptFilter 设置监听的连接过滤，被拒绝的连接关闭并发出EventConnectionRejected，
+// Data为Reason是RejectFilter的*RejectError。nil清除
+func (l *Listener) SetAcceptFilter(f AcceptFilter) {
+	if f == nil {
+		l.acceptFilter.Store(nil)
+		return
+	}
+	l.acceptFilter.Store(&f)
+}
+
+// filterAccept 调用AcceptFilter，没有设置时接受
+func (l *Listener) filterAccept(remote net.Addr) (bool, error) {
+	f := l.acceptFilter.Load()
+	if f == nil {
+		return true, nil
+	}
+	return (*f)(l, remote)
+}
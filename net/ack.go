@@ -0,0 +1,240 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+var (
+	// ErrAckTimeout 重传次数用完仍然没有收到确认
+	ErrAckTimeout = errors.New("ack timeout")
+	// ErrAckConnClosed 收到确认前连接已经关闭
+	ErrAckConnClosed = errors.New("connection closed before ack")
+)
+
+// Acker proto的可选扩展，用于确认投递模式
+type Acker interface {
+	// SetAckID 给要发送的报文设置确认ID，返回用于序列化的报文
+	SetAckID(data interface{}, id uint64) (interface{}, error)
+	// AckOf 收到的报文是确认帧时返回确认的ID
+	AckOf(data interface{}) (uint64, bool)
+	// NeedAck 收到的报文需要确认时返回ID
+	NeedAck(data interface{}) (uint64, bool)
+	// Ack 构造确认帧
+	Ack(id uint64) interface{}
+}
+
+// AckPolicy 重传策略
+type AckPolicy struct {
+	Timeout  time.Duration // 等待确认的超时，超时后重传
+	MaxRetry int           // 最大重传次数
+}
+
+// DefAckPolicy 默认重传策略
+var DefAckPolicy = AckPolicy{
+	Timeout:  3 * time.Second,
+	MaxRetry: 3,
+}
+
+// Delivery 确认投递的句柄
+type Delivery struct {
+	ID uint64
+
+	conn    *Connection
+	msg     []byte
+	policy  AckPolicy
+	retries int
+	timer   *time.Timer
+
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+// Done 收到确认或者失败后关闭
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err 投递结果，nil表示已确认，未完成时也为nil
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+		return d.err
+	default:
+		return nil
+	}
+}
+
+// Retries 已经重传的次数
+func (d *Delivery) Retries() int {
+	d.conn.acks.lock.Lock()
+	defer d.conn.acks.lock.Unlock()
+
+	return d.retries
+}
+
+// Wait 等待投递结果，timeout<=0一直等待
+func (d *Delivery) Wait(timeout time.Duration) error {
+	if timeout <= 0 {
+		<-d.done
+		return d.err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-d.done:
+		return d.err
+	case <-timer.C:
+		return fmt.Errorf("wait ack timeout")
+	}
+}
+
+func (d *Delivery) finish(err error) {
+	d.once.Do(func() {
+		d.err = err
+		close(d.done)
+	})
+}
+
+type ackTable struct {
+	lock    sync.Mutex
+	nextID  atomic.Uint64
+	pending map[uint64]*Delivery
+}
+
+// SendReliable 以确认投递模式发送，proto需要实现Acker，
+// 超时没有收到确认时重传，policy为nil时使用DefAckPolicy
+func (n *SimpleNet) SendReliable(conn *Connection, data interface{}, policy *AckPolicy) (*Delivery, error) {
+	acker, ok := protoAs[Acker](conn.proto)
+	if !ok {
+		return nil, fmt.Errorf("proto not support ack")
+	}
+	if policy == nil {
+		policy = &DefAckPolicy
+	}
+	if policy.Timeout <= 0 {
+		return nil, fmt.Errorf("invalid ack timeout")
+	}
+	id := conn.acks.nextID.Add(1)
+	data, err := acker.SetAckID(data, id)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := conn.proto.Serialize(data)
+	if err != nil {
+		return nil, err
+	}
+	d := &Delivery{
+		ID:     id,
+		conn:   conn,
+		msg:    msg,
+		policy: *policy,
+		done:   make(chan struct{}),
+	}
+
+	conn.acks.lock.Lock()
+	if conn.Status() != StatusConnected {
+		conn.acks.lock.Unlock()
+		return nil, ErrConnClosed
+	}
+	if conn.acks.pending == nil {
+		conn.acks.pending = make(map[uint64]*Delivery)
+	}
+	conn.acks.pending[id] = d
+	d.timer = time.AfterFunc(policy.Timeout, func() { conn.retransmit(d) })
+	conn.acks.lock.Unlock()
+
+	if !conn.trySend(msg) {
+		// 队列满时等待超时重传
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("send queue full, ack id = %d wait for retransmit\n", id))
+	}
+	return d, nil
+}
+
+func (c *Connection) retransmit(d *Delivery) {
+	c.acks.lock.Lock()
+	if _, ok := c.acks.pending[d.ID]; !ok {
+		c.acks.lock.Unlock()
+		return
+	}
+	if d.retries >= d.policy.MaxRetry {
+		delete(c.acks.pending, d.ID)
+		c.acks.lock.Unlock()
+		d.finish(ErrAckTimeout)
+		return
+	}
+	d.retries++
+	d.timer.Reset(d.policy.Timeout)
+	c.acks.lock.Unlock()
+
+	c.trySend(d.msg)
+}
+
+// handleAck 处理收到的报文中和确认有关的部分，返回true表示是确认帧，不需要继续处理
+func (c *Connection) handleAck(data interface{}) bool {
+	acker, ok := protoAs[Acker](c.proto)
+	if !ok {
+		return false
+	}
+	if id, ok := acker.AckOf(data); ok {
+		c.acks.lock.Lock()
+		d, ok := c.acks.pending[id]
+		if ok {
+			delete(c.acks.pending, id)
+			d.timer.Stop()
+		}
+		c.acks.lock.Unlock()
+		if ok {
+			d.finish(nil)
+		}
+		return true
+	}
+	if id, ok := acker.NeedAck(data); ok {
+		// 重复的报文也要确认，对端重传可能是因为确认丢了
+		if msg, err := c.proto.Serialize(acker.Ack(id)); err == nil {
+			c.trySend(msg)
+		}
+	}
+	return false
+}
+
+// intercept 依次处理确认、心跳、会话、响应、订阅和去重，返回true表示报文已经处理，不再作为EventNewConnectionData发出。
+// release归还data引用的接收缓冲区：确认、心跳和重复报文处理完就归还，响应交给Future，
+// 会话和订阅可能保留data中的内容，不归还由GC回收
+func (c *Connection) intercept(data interface{}, release func()) bool {
+	switch {
+	case c.handleAck(data), c.handleHeartbeat(data):
+		release()
+	case c.handleSession(data):
+	case c.handleReply(data, release):
+	case c.handleSubscription(data):
+	case c.isDuplicate(data):
+		release()
+	default:
+		return false
+	}
+	return true
+}
+
+// noRelease 报文不引用BufferProvider的缓冲区时传给intercept
+func noRelease() {}
+
+// failAcks 连接关闭时结束所有等待确认的投递
+func (c *Connection) failAcks() {
+	c.acks.lock.Lock()
+	pending := c.acks.pending
+	c.acks.pending = nil
+	c.acks.lock.Unlock()
+
+	for _, d := range pending {
+		d.timer.Stop()
+		d.finish(ErrAckConnClosed)
+	}
+}
@@ -0,0 +1,165 @@
+package net
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Allocator 报文内存分配器，用于读路径和序列化
+type Allocator interface {
+	Alloc(size int) []byte
+	Free(buf []byte)
+}
+
+// AllocSerializer proto的可选扩展，使用Allocator分配序列化的缓冲区，
+// 缓冲区在写出后由SimpleNet释放
+type AllocSerializer interface {
+	SerializeAlloc(data interface{}, alloc Allocator) ([]byte, error)
+}
+
+// HeapAllocator 默认的堆分配器，Free什么也不做，由GC回收
+type HeapAllocator struct{}
+
+func (HeapAllocator) Alloc(size int) []byte {
+	return make([]byte, size)
+}
+
+func (HeapAllocator) Free(buf []byte) {}
+
+// Arena 按块分配的arena，Alloc从当前块顺序切分，Free什么也不做，
+// 由使用者在确认所有报文都处理完后调用Reset整体回收
+type Arena struct {
+	lock      sync.Mutex
+	chunkSize int
+	chunks    [][]byte
+	cur       int
+	off       int
+}
+
+// NewArena 创建Arena，chunkSize为每块的大小
+func NewArena(chunkSize int) *Arena {
+	if chunkSize <= 0 {
+		chunkSize = 64 * 1024
+	}
+	return &Arena{chunkSize: chunkSize}
+}
+
+func (a *Arena) Alloc(size int) []byte {
+	if size > a.chunkSize {
+		// 大块单独分配，不放入arena
+		return make([]byte, size)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for {
+		if a.cur < len(a.chunks) {
+			chunk := a.chunks[a.cur]
+			if a.off+size <= len(chunk) {
+				buf := chunk[a.off : a.off+size : a.off+size]
+				a.off += size
+				return buf
+			}
+			a.cur++
+			a.off = 0
+			continue
+		}
+		a.chunks = append(a.chunks, make([]byte, a.chunkSize))
+	}
+}
+
+func (a *Arena) Free(buf []byte) {}
+
+// Reset 回收所有分配的内存，块保留下来复用，之前分配的缓冲区不能再使用
+func (a *Arena) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i := 0; i < a.cur && i < len(a.chunks); i++ {
+		clear(a.chunks[i])
+	}
+	if a.cur < len(a.chunks) {
+		clear(a.chunks[a.cur][:a.off])
+	}
+	a.cur = 0
+	a.off = 0
+}
+
+// Allocated 当前已分配的字节数(不含单独分配的大块)
+func (a *Arena) Allocated() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.cur*a.chunkSize + a.off
+}
+
+type allocatorHolder struct {
+	a Allocator
+}
+
+// SetAllocator 监听下的连接使用a分配接收和发送的缓冲区，
+// 接收的缓冲区在ConnEvent.Release时释放，nil恢复默认
+func (l *Listener) SetAllocator(a Allocator) {
+	setAllocator(&l.allocator, a)
+}
+
+// SetAllocator 连接使用a分配缓冲区，优先于监听的设置
+func (c *Connection) SetAllocator(a Allocator) {
+	setAllocator(&c.allocator, a)
+}
+
+func setAllocator(h *atomic.Pointer[allocatorHolder], a Allocator) {
+	if a == nil {
+		h.Store(nil)
+		return
+	}
+	h.Store(&allocatorHolder{a: a})
+}
+
+func (c *Connection) getAllocator() Allocator {
+	h := c.allocator.Load()
+	if h == nil && c.listen != nil {
+		h = c.listen.allocator.Load()
+	}
+	if h == nil {
+		return nil
+	}
+	return h.a
+}
+
+// allocProvider 把Allocator用作接收的BufferProvider
+type allocProvider struct {
+	a Allocator
+}
+
+func (p allocProvider) Get(size int) []byte {
+	return p.a.Alloc(size)
+}
+
+func (p allocProvider) Put(buf []byte) {
+	p.a.Free(buf)
+}
+
+// serialize 序列化要发送的数据，返回的alloc非空时缓冲区需要在写出后释放
+func (c *Connection) serialize(data interface{}) ([]byte, Allocator, error) {
+	if c.proto == nil {
+		msg, ok := (data).([]byte)
+		if !ok {
+			return nil, nil, fmt.Errorf("unexpect data type")
+		}
+		return msg, nil, nil
+	}
+	if s, ok := protoAs[AllocSerializer](c.proto); ok {
+		if alloc := c.getAllocator(); alloc != nil {
+			msg, err := s.SerializeAlloc(data, alloc)
+			if err != nil {
+				return nil, nil, err
+			}
+			return msg, alloc, nil
+		}
+	}
+	msg, err := c.proto.Serialize(data)
+	return msg, nil, err
+}
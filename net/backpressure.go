@@ -0,0 +1,171 @@
+package net
+
+import (
+	"errors"
+	"time"
+)
+
+// 发送队列满时的处理方式
+const (
+	BackpressureBlock      = iota // 阻塞直到有空间(默认)
+	BackpressureTimeout           // 最多阻塞Timeout，超时返回ErrWriteQueueFull
+	BackpressureError             // 立即返回ErrWriteQueueFull
+	BackpressureDropOldest        // 丢弃队列中最旧的报文，被丢弃报文的回调收到ErrWriteQueueFull
+)
+
+const defQueueSize = 1024
+
+// ErrWriteQueueFull 发送队列满
+var ErrWriteQueueFull = errors.New("write queue full")
+
+// QueuePolicy 发送队列参数，队列满时发出EventWriteQueueFull，
+// 直到队列写空之前不再重复发出
+type QueuePolicy struct {
+	Size    int // 队列长度(报文个数)，只对新连接有效，<=0时为1024
+	Mode    int
+	Timeout time.Duration // BackpressureTimeout的等待时间
+}
+
+// SetQueuePolicy 设置新连接默认的发送队列参数
+func (n *SimpleNet) SetQueuePolicy(policy *QueuePolicy) {
+	n.queuePolicy.Store(policy)
+}
+
+// SetQueuePolicy 设置监听下新连接的发送队列参数，优先于SimpleNet的设置
+func (l *Listener) SetQueuePolicy(policy *QueuePolicy) {
+	l.queuePolicy.Store(policy)
+}
+
+// SetQueuePolicy 修改连接队列满时的处理方式，队列长度在连接建立时确定，Size被忽略
+func (c *Connection) SetQueuePolicy(policy *QueuePolicy) {
+	c.queuePolicyPtr.Store(policy)
+}
+
+// QueuePolicy 连接当前生效的发送队列参数
+func (c *Connection) QueuePolicy() QueuePolicy {
+	policy := QueuePolicy{Size: cap(c.msgChan)}
+	if p := c.effectivePolicy(); p != nil {
+		policy.Mode, policy.Timeout = p.Mode, p.Timeout
+	}
+	return policy
+}
+
+func (c *Connection) effectivePolicy() *QueuePolicy {
+	if p := c.queuePolicyPtr.Load(); p != nil {
+		return p
+	}
+	return c.net.policyFor(c.listen)
+}
+
+func (n *SimpleNet) policyFor(l *Listener) *QueuePolicy {
+	if l != nil {
+		if p := l.queuePolicy.Load(); p != nil {
+			return p
+		}
+	}
+	return n.queuePolicy.Load()
+}
+
+// newMsgChan 按监听或SimpleNet的设置创建发送队列
+func (n *SimpleNet) newMsgChan(l *Listener) chan *writeReq {
+	size := defQueueSize
+	if p := n.policyFor(l); p != nil && p.Size > 0 {
+		size = p.Size
+	}
+	return make(chan *writeReq, size)
+}
+
+// enqueue 放入发送队列，队列满时按QueuePolicy处理。
+// 入队失败时释放缓冲区并返回错误(ErrWriteQueueFull或ErrConnClosed)，不调用req的回调
+func (c *Connection) enqueue(req *writeReq) error {
+	err := ErrConnClosed
+	if c.Status() == StatusConnected {
+		err = c.outbound(req)
+	}
+	if err != nil {
+		req.done = nil
+		req.finish(err)
+		return err
+	}
+	c.addQueued(req.size)
+	select {
+	case c.msgChan <- req:
+		c.kickWrite()
+		c.checkClosed()
+		return nil
+	default:
+	}
+
+	// 队列满，确保写协程在运行
+	c.kickWrite()
+	c.queueFull()
+	mode, timeout := BackpressureBlock, time.Duration(0)
+	if p := c.effectivePolicy(); p != nil {
+		mode, timeout = p.Mode, p.Timeout
+	}
+	switch mode {
+	case BackpressureTimeout:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case c.msgChan <- req:
+		case <-timer.C:
+			return c.reject(req, ErrWriteQueueFull)
+		case <-c.ctx.Done():
+			return c.reject(req, ErrConnClosed)
+		}
+	case BackpressureError:
+		return c.reject(req, ErrWriteQueueFull)
+	case BackpressureDropOldest:
+		for sent := false; !sent; {
+			select {
+			case c.msgChan <- req:
+				sent = true
+			default:
+				select {
+				case old := <-c.msgChan:
+					c.addQueued(-old.size)
+					old.finish(ErrWriteQueueFull)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case c.msgChan <- req:
+		case <-c.ctx.Done():
+			return c.reject(req, ErrConnClosed)
+		}
+	}
+	c.kickWrite()
+	c.checkClosed()
+	return nil
+}
+
+// reject 计入排队字节数之后入队失败，扣除计数并释放缓冲区，不调用回调
+func (c *Connection) reject(req *writeReq, err error) error {
+	c.addQueued(-req.size)
+	req.done = nil
+	req.finish(err)
+	return err
+}
+
+// checkClosed 入队后发现连接已经关闭(和closed的清理竞争)，自己清理队列
+func (c *Connection) checkClosed() {
+	if c.ctx.Err() != nil {
+		c.drainQueue()
+	}
+}
+
+// queueFull 发出EventWriteQueueFull，队列写空前只发一次
+func (c *Connection) queueFull() {
+	if !c.overflowing.CompareAndSwap(false, true) {
+		return
+	}
+	// 发送方可能就是事件处理协程，不能阻塞在事件队列上
+	go c.net.emit(&ConnEvent{
+		EventType: EventWriteQueueFull,
+		Conn:      c,
+		Data:      ErrWriteQueueFull,
+	})
+}
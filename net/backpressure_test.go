@@ -0,0 +1,75 @@
+package net
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fillQueue 向不读数据的对端发送直到出错或者发满count个
+func fillQueue(n *SimpleNet, conn *Connection, count int) error {
+	msg := make([]byte, 1<<20)
+	for i := 0; i < count; i++ {
+		if err := n.SendData(conn, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBackpressureModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy QueuePolicy
+		want   error
+	}{
+		{"error", QueuePolicy{Size: 2, Mode: BackpressureError}, ErrWriteQueueFull},
+		{"timeout", QueuePolicy{Size: 2, Mode: BackpressureTimeout, Timeout: 10 * time.Millisecond}, ErrWriteQueueFull},
+		{"drop oldest", QueuePolicy{Size: 2, Mode: BackpressureDropOldest}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNet(t)
+			addr, _ := rawServer(t)
+			n.SetQueuePolicy(&tt.policy)
+			conn, err := n.Connect(addr, &benchProto{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := fillQueue(n, conn, 64); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			waitEvent(t, n, EventWriteQueueFull)
+		})
+	}
+}
+
+// TestQueuedAfterClose 和关闭竞争的发送不会让排队字节数残留
+func TestQueuedAfterClose(t *testing.T) {
+	n := newTestNet(t)
+	addr, _ := rawServer(t)
+	n.SetQueuePolicy(&QueuePolicy{Size: 4})
+	for i := 0; i < 20; i++ {
+		conn, err := n.Connect(addr, &benchProto{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fillQueue(n, conn, 16)
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		n.CloseConn(conn)
+		wg.Wait()
+		if err := n.SendData(conn, []byte("x")); !errors.Is(err, ErrConnClosed) {
+			t.Fatalf("send after close err = %v", err)
+		}
+		waitFor(t, "queue drained", func() bool { return conn.queued.Load() == 0 })
+	}
+	waitFor(t, "net drained", func() bool { return n.queued.Load() == 0 })
+}
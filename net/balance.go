@@ -0,0 +1,232 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// EndpointStats ConnectBalanced的地址状态
+type EndpointStats struct {
+	Active   int64 // 通过ConnectBalanced建立的活动连接数
+	Failures int   // 连续连接失败的次数
+	Ejected  bool  // 连续失败达到EjectPolicy.MaxFailures，暂时摘除
+}
+
+// BalancePolicy ConnectBalanced选择地址的策略
+type BalancePolicy interface {
+	// Pick 从候选地址中选择一个，返回下标，stats与addrs一一对应
+	Pick(addrs []string, stats []EndpointStats) int
+}
+
+// EjectPolicy 地址的健康检查，连续连接失败MaxFailures次后摘除Duration，
+// 之后重新作为候选，再次失败立即摘除。所有地址都被摘除时全部作为候选
+type EjectPolicy struct {
+	MaxFailures int // <=0不摘除
+	Duration    time.Duration
+}
+
+// DefEjectPolicy 默认连续失败3次摘除30秒
+var DefEjectPolicy = EjectPolicy{
+	MaxFailures: 3,
+	Duration:    30 * time.Second,
+}
+
+type endpointHealth struct {
+	active atomic.Int64
+
+	lock     sync.Mutex
+	failures int
+	ejected  time.Time // 摘除到的时间
+}
+
+// SetEjectPolicy 设置ConnectBalanced的健康检查，nil恢复默认
+func (n *SimpleNet) SetEjectPolicy(p *EjectPolicy) {
+	n.ejectPolicy.Store(p)
+}
+
+func (n *SimpleNet) ejectPolicyOf() EjectPolicy {
+	if p := n.ejectPolicy.Load(); p != nil {
+		return *p
+	}
+	return DefEjectPolicy
+}
+
+// Endpoint ConnectBalanced的地址状态
+func (n *SimpleNet) Endpoint(addr string) EndpointStats {
+	return n.endpointHealth(addr).stats()
+}
+
+func (n *SimpleNet) endpointHealth(addr string) *endpointHealth {
+	if h, ok := n.endpoints.Load(addr); ok {
+		return h.(*endpointHealth)
+	}
+	h, _ := n.endpoints.LoadOrStore(addr, &endpointHealth{})
+	return h.(*endpointHealth)
+}
+
+func (h *endpointHealth) stats() EndpointStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return EndpointStats{
+		Active:   h.active.Load(),
+		Failures: h.failures,
+		Ejected:  time.Now().Before(h.ejected),
+	}
+}
+
+// ConnectBalanced 按policy从addrs中选择一个地址连接，失败时从其余地址中重新选择，
+// 地址也可以是服务地址。连续失败的地址按EjectPolicy摘除
+func (n *SimpleNet) ConnectBalanced(addrs []string, policy BalancePolicy, proto IProto) (*Connection, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("no balance policy")
+	}
+	candidates := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if !n.Endpoint(addr).Ejected {
+			candidates = append(candidates, addr)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = slices.Clone(addrs)
+	}
+
+	var errs []error
+	for len(candidates) > 0 {
+		stats := make([]EndpointStats, len(candidates))
+		for i, addr := range candidates {
+			stats[i] = n.Endpoint(addr)
+		}
+		i := policy.Pick(candidates, stats)
+		if i < 0 || i >= len(candidates) {
+			i = 0
+		}
+		addr := candidates[i]
+		candidates = slices.Delete(candidates, i, i+1)
+
+		h := n.endpointHealth(addr)
+		conn, err := n.Connect(addr, proto)
+		if err != nil {
+			n.endpointFailed(addr, h)
+			errs = append(errs, err)
+			continue
+		}
+		h.lock.Lock()
+		h.failures = 0
+		h.ejected = time.Time{}
+		h.lock.Unlock()
+
+		h.active.Add(1)
+		context.AfterFunc(conn.ctx, func() {
+			h.active.Add(-1)
+		})
+		return conn, nil
+	}
+	return nil, fmt.Errorf("connect balanced failed, err = %w", errors.Join(errs...))
+}
+
+func (n *SimpleNet) endpointFailed(addr string, h *endpointHealth) {
+	p := n.ejectPolicyOf()
+
+	h.lock.Lock()
+	h.failures++
+	eject := p.MaxFailures > 0 && h.failures >= p.MaxFailures
+	if eject {
+		h.ejected = time.Now().Add(p.Duration)
+	}
+	failures := h.failures
+	h.lock.Unlock()
+
+	if eject {
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("endpoint ejected, addr = %s, failures = %d\n", addr, failures))
+	}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobin 轮询
+func NewRoundRobin() BalancePolicy {
+	return &roundRobin{}
+}
+
+func (r *roundRobin) Pick(addrs []string, stats []EndpointStats) int {
+	return int((r.next.Add(1) - 1) % uint64(len(addrs)))
+}
+
+type leastConn struct{}
+
+// NewLeastConn 活动连接数最少，相同时随机选择
+func NewLeastConn() BalancePolicy {
+	return leastConn{}
+}
+
+func (leastConn) Pick(addrs []string, stats []EndpointStats) int {
+	start := rand.Intn(len(addrs))
+	best := start
+	for k := 1; k < len(addrs); k++ {
+		i := (start + k) % len(addrs)
+		if stats[i].Active < stats[best].Active {
+			best = i
+		}
+	}
+	return best
+}
+
+type weighted struct {
+	weights map[string]int
+
+	lock    sync.Mutex
+	current map[string]int
+}
+
+// NewWeighted 平滑加权轮询，weights中没有的地址权重为1，权重<=0的地址只在没有其他地址时选择
+func NewWeighted(weights map[string]int) BalancePolicy {
+	w := &weighted{
+		weights: make(map[string]int, len(weights)),
+		current: make(map[string]int),
+	}
+	for addr, weight := range weights {
+		w.weights[addr] = weight
+	}
+	return w
+}
+
+func (w *weighted) Pick(addrs []string, stats []EndpointStats) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	best, total := -1, 0
+	for i, addr := range addrs {
+		weight, ok := w.weights[addr]
+		if !ok {
+			weight = 1
+		}
+		if weight <= 0 {
+			continue
+		}
+		w.current[addr] += weight
+		total += weight
+		if best < 0 || w.current[addr] > w.current[addrs[best]] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return rand.Intn(len(addrs))
+	}
+	w.current[addrs[best]] -= total
+	return best
+}
@@ -0,0 +1,178 @@
+package net
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// SetBandwidth 限制监听下所有连接的总带宽(字节/秒)，读写分别计算，
+// 0表示不限制。可以在运行时修改，已有的连接立即生效
+func (l *Listener) SetBandwidth(read, write int64) {
+	setLimit(&l.readLimit, read)
+	setLimit(&l.writeLimit, write)
+}
+
+// Bandwidth 当前的带宽限制
+func (l *Listener) Bandwidth() (read, write int64) {
+	if lim := l.readLimit.Load(); lim != nil {
+		read = lim.Rate()
+	}
+	if lim := l.writeLimit.Load(); lim != nil {
+		write = lim.Rate()
+	}
+	return
+}
+
+// SetBandwidth 限制所有连接(包括客户端连接)共享的总带宽(字节/秒)，读写分别计算，
+// 0表示不限制。连接按limiterQuantum分段轮流获得额度，大流量的连接不会饿死其他连接
+func (n *SimpleNet) SetBandwidth(read, write int64) {
+	setLimit(&n.readLimit, read)
+	setLimit(&n.writeLimit, write)
+}
+
+// Bandwidth 当前的总带宽限制
+func (n *SimpleNet) Bandwidth() (read, write int64) {
+	if lim := n.readLimit.Load(); lim != nil {
+		read = lim.Rate()
+	}
+	if lim := n.writeLimit.Load(); lim != nil {
+		write = lim.Rate()
+	}
+	return
+}
+
+// SetBandwidth 限制单个连接的带宽(字节/秒)，读写分别计算，0表示不限制。
+// 同时受监听的总带宽限制，可以在运行时修改
+func (c *Connection) SetBandwidth(read, write int64) {
+	setLimit(&c.readLimit, read)
+	setLimit(&c.writeLimit, write)
+}
+
+// Bandwidth 连接当前的带宽限制
+func (c *Connection) Bandwidth() (read, write int64) {
+	if lim := c.readLimit.Load(); lim != nil {
+		read = lim.Rate()
+	}
+	if lim := c.writeLimit.Load(); lim != nil {
+		write = lim.Rate()
+	}
+	return
+}
+
+func setLimit(p *atomic.Pointer[Limiter], rate int64) {
+	if rate <= 0 {
+		p.Store(nil)
+		return
+	}
+	if lim := p.Load(); lim != nil {
+		lim.SetRate(rate, 0)
+		return
+	}
+	p.CompareAndSwap(nil, NewLimiter(rate, 0))
+}
+
+// limiters 连接受到的限速
+func (c *Connection) limiters(write bool) []*Limiter {
+	var limiters []*Limiter
+	lim := c.readLimit.Load()
+	if write {
+		lim = c.writeLimit.Load()
+	}
+	if lim != nil {
+		limiters = append(limiters, lim)
+	}
+	if c.listen != nil {
+		lim := c.listen.readLimit.Load()
+		if write {
+			lim = c.listen.writeLimit.Load()
+		}
+		if lim != nil {
+			limiters = append(limiters, lim)
+		}
+	}
+	lim = c.net.readLimit.Load()
+	if write {
+		lim = c.net.writeLimit.Load()
+	}
+	if lim != nil {
+		limiters = append(limiters, lim)
+	}
+	return limiters
+}
+
+// readConn 读满buf，TCP不保证一次Read读到完整的报文头或报文体。
+// 受限速时按limiterQuantum分段读取并扣减额度，通过TCP窗口反压到对端
+func (n *SimpleNet) readConn(conn *Connection, buf []byte) (int, error) {
+	limiters := conn.limiters(false)
+	if len(limiters) == 0 {
+		return io.ReadFull(conn.conn, buf)
+	}
+	total := 0
+	for total < len(buf) {
+		size := min(len(buf)-total, limiterQuantum)
+		count, err := io.ReadFull(conn.conn, buf[total:total+size])
+		total += count
+		for _, lim := range limiters {
+			lim.WaitN(conn.ctx, count)
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// readSome 读取已经到达的数据，最多len(buf)，受限速时最多读取limiterQuantum
+func (n *SimpleNet) readSome(conn *Connection, buf []byte) (int, error) {
+	limiters := conn.limiters(false)
+	if len(limiters) > 0 && len(buf) > limiterQuantum {
+		buf = buf[:limiterQuantum]
+	}
+	count, err := conn.conn.Read(buf)
+	for _, lim := range limiters {
+		lim.WaitN(conn.ctx, count)
+	}
+	return count, err
+}
+
+// writeConn 写数据，多个报文时使用writev，
+// 受限速时按limiterQuantum分段写
+func (n *SimpleNet) writeConn(conn *Connection, bufs [][]byte) (int, error) {
+	_, datagram := conn.conn.(datagramConn)
+	if datagram && len(bufs) > 1 {
+		// 一个报文在一个数据报中发出
+		bufs = [][]byte{bytes.Join(bufs, nil)}
+	}
+	limiters := conn.limiters(true)
+	wd := n.watchdog.Load()
+	if len(limiters) == 0 && wd == nil {
+		if len(bufs) == 1 {
+			return conn.conn.Write(bufs[0])
+		}
+		// WriteTo会修改切片，复制一份，写完后还要用于镜像
+		buffers := append(net.Buffers(nil), bufs...)
+		count, err := buffers.WriteTo(conn.conn)
+		return int(count), err
+	}
+	total := 0
+	for _, msg := range bufs {
+		for len(msg) > 0 {
+			size := len(msg)
+			if len(limiters) > 0 && size > limiterQuantum && !datagram {
+				size = limiterQuantum
+			}
+			for _, lim := range limiters {
+				lim.WaitN(conn.ctx, size)
+			}
+			count, err := n.writeWatched(conn, wd, msg[:size])
+			total += count
+			if err != nil {
+				return total, err
+			}
+			msg = msg[size:]
+		}
+	}
+	return total, nil
+}
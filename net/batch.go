@@ -0,0 +1,60 @@
+package net
+
+import "fmt"
+
+// writeReq 发送队列中的一个写单元，bufs在一次writev中写出
+type writeReq struct {
+	bufs  [][]byte
+	size  int
+	alloc Allocator   // bufs由alloc分配，写完后释放
+	done  func(error) // 写完或者失败后回调
+}
+
+func newWriteReq(bufs ...[]byte) *writeReq {
+	req := &writeReq{bufs: bufs}
+	for _, buf := range bufs {
+		req.size += len(buf)
+	}
+	return req
+}
+
+// finish 写完或者失败，释放缓冲区并回调
+func (r *writeReq) finish(err error) {
+	if r.alloc != nil {
+		for _, buf := range r.bufs {
+			r.alloc.Free(buf)
+		}
+	}
+	if r.done != nil {
+		r.done(err)
+	}
+}
+
+// SendBatch 批量发送，所有报文序列化后作为一个单元入队，保持顺序并一次writev写出。
+// 返回每个报文的序列化错误，出错的报文不发送；没有报文可以发送时返回error
+func (n *SimpleNet) SendBatch(conn *Connection, data []interface{}) ([]error, error) {
+	if conn.Status() != StatusConnected {
+		return nil, ErrConnClosed
+	}
+	errs := make([]error, len(data))
+	bufs := make([][]byte, 0, len(data))
+	var alloc Allocator
+	for i, v := range data {
+		msg, a, err := conn.serialize(v)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		alloc = a
+		bufs = append(bufs, msg)
+	}
+	if len(bufs) == 0 {
+		return errs, fmt.Errorf("no message to send")
+	}
+	req := newWriteReq(bufs...)
+	req.alloc = alloc
+	if err := conn.enqueue(req); err != nil {
+		return errs, err
+	}
+	return errs, nil
+}
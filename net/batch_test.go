@@ -0,0 +1,36 @@
+package net
+
+import (
+	"testing"
+)
+
+func TestSendBatch(t *testing.T) {
+	n := newTestNet(t)
+	conn, err := n.Connect(listenTest(t, n, &benchProto{}), &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := n.SendBatch(conn, []interface{}{[]byte("a"), 1, []byte("b"), []byte("c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 1) {
+			t.Fatalf("message %d err = %v", i, err)
+		}
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		evt := waitEvent(t, n, EventNewConnectionData)
+		if got := string(evt.Data.([]byte)); got != want {
+			t.Fatalf("receive %q, want %q", got, want)
+		}
+	}
+
+	if _, err := n.SendBatch(conn, []interface{}{1}); err == nil {
+		t.Fatal("expect error when no message can be sent")
+	}
+	n.CloseConn(conn)
+	if _, err := n.SendBatch(conn, []interface{}{[]byte("d")}); err != ErrConnClosed {
+		t.Fatalf("send after close err = %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package net
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+type benchProto struct{}
+
+func (p *benchProto) FilterAccept(conn *Connection) bool { return true }
+func (p *benchProto) HeadLen() uint32                    { return 4 }
+func (p *benchProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, binary.BigEndian.Uint32(head), nil
+}
+func (p *benchProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return body, nil
+}
+func (p *benchProto) Serialize(data interface{}) ([]byte, error) {
+	body, ok := data.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(body))), body...), nil
+}
+
+// discardLoger 丢弃所有日志
+type discardLoger struct{}
+
+func (discardLoger) Name() string                          { return "discard" }
+func (discardLoger) Open(conf string) error                { return nil }
+func (discardLoger) Write(msg *mylog.Message) (int, error) { return 0, nil }
+func (discardLoger) Close() error                          { return nil }
+func (discardLoger) Sync() error                           { return nil }
+
+var (
+	discardOnce sync.Once
+	discard     *mylog.Log
+)
+
+// discardLog 没有日志时SimpleNet输出到stdout，测试时传入丢弃日志的Log
+func discardLog(tb testing.TB) *mylog.Log {
+	discardOnce.Do(func() {
+		mylog.Register(discardLoger{})
+		if _, err := mylog.SetupLog("discard", ""); err != nil {
+			return
+		}
+		log, _ := mylog.NewLogging()
+		if log.StartSync() == nil {
+			discard = log
+		}
+	})
+	if discard == nil {
+		tb.Fatal("setup discard log failed")
+	}
+	return discard
+}
+
+func echoServer(b *testing.B) (*SimpleNet, *Listener) {
+	n := NewSimpleNet(discardLog(b))
+	l, err := n.Listen("127.0.0.1:0", &benchProto{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	go func() {
+		for {
+			evt, err := n.PollEvent(1000)
+			if err != nil {
+				return
+			}
+			if evt.EventType == EventNewConnectionData {
+				n.SendData(evt.Conn, evt.Data)
+			}
+		}
+	}()
+	b.Cleanup(func() { SimpleNetDestroy(n) })
+	return n, l
+}
+
+func BenchmarkEcho(b *testing.B) {
+	_, l := echoServer(b)
+
+	c, err := net.Dial("tcp", l.LocalAddress())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	msg, _ := (&benchProto{}).Serialize(make([]byte, 256))
+	reply := make([]byte, len(msg))
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Write(msg); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.ReadFull(c, reply); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkIdleConns 空闲连接占用的协程数，每次打开count个连接
+func BenchmarkIdleConns(b *testing.B) {
+	_, l := echoServer(b)
+
+	const count = 200
+	accepted := func() int {
+		l.lockClient.Lock()
+		defer l.lockClient.Unlock()
+		return len(l.conns)
+	}
+	waitAccepted := func(want int) {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && accepted() != want; {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var goroutines float64
+	conns := make([]net.Conn, 0, count)
+	for i := 0; i < b.N; i++ {
+		before := runtime.NumGoroutine()
+		for j := 0; j < count; j++ {
+			c, err := net.Dial("tcp", l.LocalAddress())
+			if err != nil {
+				b.Fatal(err)
+			}
+			conns = append(conns, c)
+		}
+		waitAccepted(count)
+		goroutines += float64(runtime.NumGoroutine()-before) / count
+
+		b.StopTimer()
+		for _, c := range conns {
+			c.Close()
+		}
+		conns = conns[:0]
+		waitAccepted(0)
+		b.StartTimer()
+	}
+	b.ReportMetric(goroutines/float64(b.N), "goroutines/conn")
+}
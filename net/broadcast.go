@@ -0,0 +1,62 @@
+package net
+
+import "fmt"
+
+// Conns 监听下当前连接的快照，可以安全遍历
+func (l *Listener) Conns() []*Connection {
+	l.lockClient.Lock()
+	defer l.lockClient.Unlock()
+
+	return append([]*Connection(nil), l.conns...)
+}
+
+// Broadcast 向监听下所有连接发送data，使用监听当前的proto只序列化一次
+// (使用ProtoFactory时每个连接分别序列化)。
+// 返回发送失败的连接及原因，发送队列满时按连接的QueuePolicy处理
+func (l *Listener) Broadcast(data interface{}) (map[*Connection]error, error) {
+	return l.BroadcastExcept(nil, data)
+}
+
+// BroadcastExcept 向除except外的所有连接发送data
+func (l *Listener) BroadcastExcept(except *Connection, data interface{}) (map[*Connection]error, error) {
+	var msg []byte
+	if proto := l.Proto(); proto != nil {
+		var err error
+		if msg, err = proto.Serialize(data); err != nil {
+			return nil, err
+		}
+	} else if l.factoryOf() == nil {
+		var ok bool
+		if msg, ok = data.([]byte); !ok {
+			return nil, fmt.Errorf("unexpect data type")
+		}
+	}
+
+	var failed map[*Connection]error
+	for _, conn := range l.Conns() {
+		if conn == except {
+			continue
+		}
+		var err error
+		if msg != nil {
+			// 共享的缓冲区，不能由连接释放
+			err = conn.enqueue(newWriteReq(msg))
+		} else {
+			err = l.net.SendData(conn, data)
+		}
+		if err != nil {
+			if failed == nil {
+				failed = make(map[*Connection]error)
+			}
+			failed[conn] = err
+		}
+	}
+	return failed, nil
+}
+
+func (l *Listener) factoryOf() ProtoFactory {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	return l.factory
+}
@@ -0,0 +1,125 @@
+package net
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// BufferProvider 接收缓冲区的提供者，设置后读取的报文直接放在提供的缓冲区中，
+// EventNewConnectionData的Data(及proto解析时引用的body)指向这些缓冲区，
+// 处理完后调用ConnEvent.Release归还
+type BufferProvider interface {
+	Get(size int) []byte
+	Put(buf []byte)
+}
+
+type providerHolder struct {
+	p BufferProvider
+}
+
+// SetBufferProvider 监听下的连接使用p提供接收缓冲区，nil为默认的每报文分配
+func (l *Listener) SetBufferProvider(p BufferProvider) {
+	setProvider(&l.provider, p)
+}
+
+// SetBufferProvider 连接使用p提供接收缓冲区，优先于监听的设置
+func (c *Connection) SetBufferProvider(p BufferProvider) {
+	setProvider(&c.provider, p)
+}
+
+func setProvider(h *atomic.Pointer[providerHolder], p BufferProvider) {
+	if p == nil {
+		h.Store(nil)
+		return
+	}
+	h.Store(&providerHolder{p: p})
+}
+
+func (c *Connection) bufferProvider() BufferProvider {
+	h := c.provider.Load()
+	if h == nil && c.listen != nil {
+		h = c.listen.provider.Load()
+	}
+	if h != nil {
+		return h.p
+	}
+	if alloc := c.getAllocator(); alloc != nil {
+		return allocProvider{a: alloc}
+	}
+	return nil
+}
+
+func allocBuf(p BufferProvider, size int) []byte {
+	if p == nil {
+		return make([]byte, size)
+	}
+	return p.Get(size)[:size]
+}
+
+func freeBuf(p BufferProvider, bufs ...[]byte) {
+	if p == nil {
+		return
+	}
+	for _, buf := range bufs {
+		p.Put(buf)
+	}
+}
+
+// Release 把事件引用的接收缓冲区还给BufferProvider，之后不能再使用Data，
+// 没有设置BufferProvider时什么也不做，重复调用无效
+func (e *ConnEvent) Release() {
+	freeBuf(e.provider, e.bufs...)
+	e.provider = nil
+	e.bufs = nil
+}
+
+// PoolProvider 基于sync.Pool的BufferProvider，按2的幂分级，
+// 超过maxSize的缓冲区不复用
+type PoolProvider struct {
+	maxSize int
+	pools   []sync.Pool
+}
+
+const minPoolShift = 6 // 64字节
+
+// NewPoolProvider 创建PoolProvider
+func NewPoolProvider(maxSize int) *PoolProvider {
+	p := &PoolProvider{maxSize: maxSize}
+	if maxSize > 0 {
+		p.pools = make([]sync.Pool, poolClass(maxSize)+1)
+	}
+	return p
+}
+
+func poolClass(size int) int {
+	if size <= 1<<minPoolShift {
+		return 0
+	}
+	return bits.Len(uint(size-1)) - minPoolShift
+}
+
+func (p *PoolProvider) Get(size int) []byte {
+	if size > p.maxSize {
+		return make([]byte, size)
+	}
+	class := poolClass(size)
+	if v := p.pools[class].Get(); v != nil {
+		return (*v.(*[]byte))[:size]
+	}
+	return make([]byte, size, 1<<(class+minPoolShift))
+}
+
+func (p *PoolProvider) Put(buf []byte) {
+	c := cap(buf)
+	if c < 1<<minPoolShift {
+		return
+	}
+	class := poolClass(c)
+	if class >= len(p.pools) || 1<<(class+minPoolShift) != c {
+		// 不是Get分配的缓冲区
+		return
+	}
+	buf = buf[:0]
+	p.pools[class].Put(&buf)
+}
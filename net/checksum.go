@@ -0,0 +1,56 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 报文校验算法
+const (
+	ChecksumNone   Checksum = iota
+	ChecksumCRC32           // CRC32 IEEE，4字节
+	ChecksumCRC32C          // CRC32 Castagnoli，4字节
+	ChecksumXXHash          // xxhash64，8字节
+)
+
+// ErrChecksum 报文校验失败
+var ErrChecksum = errors.New("checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum Pipeline在每个报文后附加的校验值，大端
+type Checksum int
+
+// Size 校验值的字节数
+func (c Checksum) Size() int {
+	switch c {
+	case ChecksumCRC32, ChecksumCRC32C:
+		return 4
+	case ChecksumXXHash:
+		return 8
+	}
+	return 0
+}
+
+// appendSum 把data的校验值追加到dst
+func (c Checksum) appendSum(dst, data []byte) []byte {
+	switch c {
+	case ChecksumCRC32:
+		return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(data))
+	case ChecksumCRC32C:
+		return binary.BigEndian.AppendUint32(dst, crc32.Checksum(data, castagnoli))
+	case ChecksumXXHash:
+		return binary.BigEndian.AppendUint64(dst, xxhash.Sum64(data))
+	}
+	return dst
+}
+
+// verify 检查data的校验值是否为sum
+func (c Checksum) verify(data, sum []byte) bool {
+	var buf [8]byte
+	expect := c.appendSum(buf[:0], data)
+	return string(expect) == string(sum)
+}
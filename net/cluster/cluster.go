@@ -0,0 +1,364 @@
+// Package cluster 多个SimpleNet节点之间的全连接桥接，把SendToUser和Publish转发到所有节点，
+// 水平扩展的网关可以把消息发给连接在任一节点上的客户端。
+// 每个节点监听节点间的地址并连接其他所有节点，只在主动发起的连接上发送，
+// 收到的消息只在本节点投递，不再转发。节点间连接没有认证，监听地址只应在内网开放
+package cluster
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+	mynet "github.com/buf1024/golib/net"
+)
+
+// 节点间转发的消息类型
+const (
+	msgUser    byte = 1
+	msgPublish byte = 2
+	msgGossip  byte = 3
+)
+
+var frameMagic = []byte("GLCB")
+
+// ErrPeerDown 节点还没有连接上
+var ErrPeerDown = errors.New("peer not connected")
+
+// Options 节点参数
+type Options struct {
+	Listen    string                 // 节点间连接的监听地址
+	Peers     []string               // 其他节点的地址，包含本节点(Advertise)时忽略
+	Advertise string                 // 其他节点连接本节点使用的地址，为空时使用监听地址
+	Gossip    *GossipOptions         // 开启gossip成员协议，Peers作为种子节点，为nil时只连接Peers
+	Reconnect *mynet.ReconnectPolicy // 为nil时使用DefReconnectPolicy
+	Log       *mylog.Log
+
+	// Encode/Decode 转发数据的编解码，为nil时数据必须是[]byte
+	Encode func(data interface{}) ([]byte, error)
+	Decode func(b []byte) (interface{}, error)
+}
+
+// Node 集群中的一个节点，gw为客户端连接所在的SimpleNet
+type Node struct {
+	gw     *mynet.SimpleNet
+	mesh   *mynet.SimpleNet
+	listen *mynet.Listener
+	proto  *mynet.LengthProto
+	policy mynet.ReconnectPolicy
+	encode func(data interface{}) ([]byte, error)
+	decode func(b []byte) (interface{}, error)
+	log    *mylog.Log
+	self   string
+	gossip atomic.Pointer[gossiper] // 监听之后才创建，之前收到的成员表丢弃
+
+	lock   sync.Mutex
+	peers  map[string]*peer
+	closed bool
+}
+
+type peer struct {
+	addr string
+	stop chan struct{}
+
+	lock sync.Mutex
+	mc   *mynet.ManagedConnection
+}
+
+// New 创建节点，监听opts.Listen并连接opts.Peers，没有启动的节点在后台重试
+func New(gw *mynet.SimpleNet, opts *Options) (*Node, error) {
+	proto, err := mynet.NewLengthProto(4, binary.BigEndian, frameMagic)
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{
+		gw:     gw,
+		mesh:   mynet.NewSimpleNet(opts.Log),
+		proto:  proto,
+		policy: mynet.DefReconnectPolicy,
+		encode: opts.Encode,
+		decode: opts.Decode,
+		log:    opts.Log,
+		peers:  make(map[string]*peer),
+	}
+	if opts.Reconnect != nil {
+		n.policy = *opts.Reconnect
+	}
+	if n.policy.MinDelay <= 0 {
+		n.policy.MinDelay = mynet.DefReconnectPolicy.MinDelay
+	}
+	if n.policy.Multiplier < 1 {
+		n.policy.Multiplier = 1
+	}
+	if n.encode == nil {
+		n.encode = encodeBytes
+	}
+	if n.decode == nil {
+		n.decode = decodeBytes
+	}
+	n.mesh.SetHandler(&meshHandler{node: n}, 1)
+
+	n.listen, err = n.mesh.Listen(opts.Listen, proto)
+	if err != nil {
+		mynet.SimpleNetDestroy(n.mesh)
+		return nil, fmt.Errorf("listen %s failed, err = %s", opts.Listen, err)
+	}
+	n.self = opts.Advertise
+	if n.self == "" {
+		n.self = n.listen.LocalAddress()
+	}
+	if opts.Gossip != nil {
+		if len(n.self) > 255 {
+			mynet.SimpleNetDestroy(n.mesh)
+			return nil, fmt.Errorf("advertise address too long")
+		}
+		n.gossip.Store(newGossiper(n, n.self, *opts.Gossip))
+	}
+	for _, addr := range opts.Peers {
+		n.AddPeer(addr)
+	}
+	return n, nil
+}
+
+func encodeBytes(data interface{}) ([]byte, error) {
+	b, ok := data.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type %T", data)
+	}
+	return b, nil
+}
+
+func decodeBytes(b []byte) (interface{}, error) {
+	return append([]byte(nil), b...), nil
+}
+
+// Addr 节点间连接的监听地址
+func (n *Node) Addr() string {
+	return n.listen.LocalAddress()
+}
+
+// AddPeer 连接节点，已经存在时不变
+func (n *Node) AddPeer(addr string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.closed || addr == n.self {
+		return
+	}
+	if _, ok := n.peers[addr]; ok {
+		return
+	}
+	p := &peer{addr: addr, stop: make(chan struct{})}
+	n.peers[addr] = p
+	go n.dial(p)
+}
+
+// RemovePeer 断开节点
+func (n *Node) RemovePeer(addr string) {
+	n.lock.Lock()
+	p, ok := n.peers[addr]
+	delete(n.peers, addr)
+	n.lock.Unlock()
+
+	if ok {
+		p.close()
+	}
+}
+
+// Peers 节点地址及是否已经连接
+func (n *Node) Peers() map[string]bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	peers := make(map[string]bool, len(n.peers))
+	for addr, p := range n.peers {
+		mc := p.conn()
+		peers[addr] = mc != nil && mc.Connected()
+	}
+	return peers
+}
+
+// dial 首次连接成功前按重连策略重试，之后由ManagedConnection重连
+func (n *Node) dial(p *peer) {
+	delay := n.policy.MinDelay
+	for {
+		mc, err := n.mesh.ConnectManaged(p.addr, n.proto, &n.policy)
+		if err == nil {
+			p.lock.Lock()
+			select {
+			case <-p.stop:
+				p.lock.Unlock()
+				mc.Close()
+				return
+			default:
+			}
+			p.mc = mc
+			p.lock.Unlock()
+			return
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * n.policy.Multiplier)
+		if n.policy.MaxDelay > 0 && delay > n.policy.MaxDelay {
+			delay = n.policy.MaxDelay
+		}
+	}
+}
+
+// connectedPeers 已经连接上的节点
+func (n *Node) connectedPeers() []*peer {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	peers := make([]*peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		if mc := p.conn(); mc != nil && mc.Connected() {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+func (p *peer) conn() *mynet.ManagedConnection {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.mc
+}
+
+func (p *peer) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	close(p.stop)
+	if p.mc != nil {
+		p.mc.Close()
+	}
+}
+
+// SendToUser 向所有节点上的用户连接发送data，返回本节点发送成功的连接数，
+// 转发失败的节点的错误合并返回
+func (n *Node) SendToUser(user string, data interface{}) (int, error) {
+	payload, err := n.encode(data)
+	if err != nil {
+		return 0, err
+	}
+	sent, _ := n.gw.SendToUser(user, data)
+	return sent, n.forward(msgUser, user, payload)
+}
+
+// Publish 向所有节点上订阅了topic的连接发送msg
+func (n *Node) Publish(topic string, msg interface{}) error {
+	payload, err := n.encode(msg)
+	if err != nil {
+		return err
+	}
+	n.gw.Publish(topic, msg)
+	return n.forward(msgPublish, topic, payload)
+}
+
+// forward 消息格式：类型(1) + key长度(2) + key + 数据
+func (n *Node) forward(typ byte, key string, payload []byte) error {
+	if len(key) > 0xffff {
+		return fmt.Errorf("key too long")
+	}
+	frame := make([]byte, 0, 3+len(key)+len(payload))
+	frame = append(frame, typ)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(key)))
+	frame = append(frame, key...)
+	frame = append(frame, payload...)
+
+	n.lock.Lock()
+	peers := make([]*peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	n.lock.Unlock()
+
+	var errs []error
+	for _, p := range peers {
+		mc := p.conn()
+		if mc == nil {
+			errs = append(errs, fmt.Errorf("%w, addr = %s", ErrPeerDown, p.addr))
+			continue
+		}
+		if err := mc.SendData(frame); err != nil {
+			errs = append(errs, fmt.Errorf("forward to %s failed, err = %w", p.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver 在本节点投递其他节点转发的消息
+func (n *Node) deliver(frame []byte) error {
+	if len(frame) < 3 {
+		return fmt.Errorf("short frame, len = %d", len(frame))
+	}
+	size := int(binary.BigEndian.Uint16(frame[1:]))
+	if len(frame) < 3+size {
+		return fmt.Errorf("short frame, len = %d", len(frame))
+	}
+	if frame[0] == msgGossip {
+		g := n.gossip.Load()
+		if g == nil {
+			return nil
+		}
+		return g.merge(frame[3+size:])
+	}
+	key := string(frame[3 : 3+size])
+	data, err := n.decode(frame[3+size:])
+	if err != nil {
+		return err
+	}
+	switch frame[0] {
+	case msgUser:
+		n.gw.SendToUser(key, data)
+	case msgPublish:
+		n.gw.Publish(key, data)
+	default:
+		return fmt.Errorf("unknown message type %d", frame[0])
+	}
+	return nil
+}
+
+// Close 断开所有节点并停止监听
+func (n *Node) Close() {
+	n.lock.Lock()
+	if n.closed {
+		n.lock.Unlock()
+		return
+	}
+	n.closed = true
+	peers := n.peers
+	n.peers = make(map[string]*peer)
+	n.lock.Unlock()
+
+	for _, p := range peers {
+		p.close()
+	}
+	if g := n.gossip.Load(); g != nil {
+		g.close()
+	}
+	mynet.SimpleNetDestroy(n.mesh)
+}
+
+type meshHandler struct {
+	mynet.BaseHandler
+	node *Node
+}
+
+func (h *meshHandler) OnData(conn *mynet.Connection, data interface{}) {
+	frame, ok := data.([]byte)
+	if !ok {
+		return
+	}
+	if err := h.node.deliver(frame); err != nil && h.node.log != nil {
+		h.node.log.Warning("deliver message from %s failed, err = %s\n", conn.RemoteAddress(), err)
+	}
+}
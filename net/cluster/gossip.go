@@ -0,0 +1,292 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// 成员状态
+const (
+	MemberAlive MemberState = iota
+	MemberDead              // FailTimeout内没有收到心跳
+	MemberLeft              // 主动离开
+)
+
+const (
+	defGossipInterval = time.Second
+	defGossipFanout   = 3
+	leaveTimeout      = time.Second
+)
+
+// MemberState 成员状态
+type MemberState int
+
+// Member 集群成员，Addr为节点间连接的地址，同时作为节点ID。
+// 心跳计数从节点启动时间开始，重启后的节点心跳大于之前的记录
+type Member struct {
+	Addr      string
+	Heartbeat uint64
+	State     MemberState
+}
+
+// GossipOptions gossip成员协议的参数，每个Interval增加本节点的心跳，
+// 把成员表发给随机的Fanout个节点，收到的成员表按心跳合并，新成员自动连接。
+// 成员上线和下线(失效或者离开)时在gw上发出EventNodeUp/EventNodeDown，Data为Member
+type GossipOptions struct {
+	Interval       time.Duration // <=0时为1秒
+	Fanout         int           // <=0时为3
+	FailTimeout    time.Duration // 超过时间没有收到心跳认为失效，<=0时为5个Interval
+	CleanupTimeout time.Duration // 失效或者离开的成员保留的时间，之后断开并删除，<=0时为2个FailTimeout
+}
+
+type memberInfo struct {
+	Member
+	seen time.Time // 最近一次心跳增加的时间
+}
+
+type gossiper struct {
+	node *Node
+	opts GossipOptions
+	self string
+	stop chan struct{}
+
+	lock    sync.Mutex
+	members map[string]*memberInfo
+}
+
+func newGossiper(n *Node, self string, opts GossipOptions) *gossiper {
+	if opts.Interval <= 0 {
+		opts.Interval = defGossipInterval
+	}
+	if opts.Fanout <= 0 {
+		opts.Fanout = defGossipFanout
+	}
+	if opts.FailTimeout <= 0 {
+		opts.FailTimeout = 5 * opts.Interval
+	}
+	if opts.CleanupTimeout <= 0 {
+		opts.CleanupTimeout = 2 * opts.FailTimeout
+	}
+	g := &gossiper{
+		node:    n,
+		opts:    opts,
+		self:    self,
+		stop:    make(chan struct{}),
+		members: make(map[string]*memberInfo),
+	}
+	g.members[self] = &memberInfo{
+		Member: Member{Addr: self, Heartbeat: uint64(time.Now().UnixNano())},
+		seen:   time.Now(),
+	}
+	go g.run()
+	return g
+}
+
+// Members 成员表的快照，包括本节点
+func (n *Node) Members() []Member {
+	g := n.gossip.Load()
+	if g == nil {
+		return nil
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	members := make([]Member, 0, len(g.members))
+	for _, m := range g.members {
+		members = append(members, m.Member)
+	}
+	return members
+}
+
+// Leave 通知其他节点本节点离开，然后关闭节点
+func (n *Node) Leave() {
+	if g := n.gossip.Load(); g != nil {
+		g.lock.Lock()
+		self := g.members[g.self]
+		self.Heartbeat++
+		self.State = MemberLeft
+		frame := g.encode()
+		g.lock.Unlock()
+
+		var waits []<-chan error
+		for _, p := range n.connectedPeers() {
+			if ch, err := n.mesh.SendDataNotify(p.conn().Conn(), frame); err == nil {
+				waits = append(waits, ch)
+			}
+		}
+		timeout := time.After(leaveTimeout)
+		for _, ch := range waits {
+			select {
+			case <-ch:
+			case <-timeout:
+			}
+		}
+	}
+	n.Close()
+}
+
+func (g *gossiper) run() {
+	ticker := time.NewTicker(g.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+		}
+		g.tick()
+	}
+}
+
+// tick 增加心跳，检查失效的成员，把成员表发给随机的节点
+func (g *gossiper) tick() {
+	now := time.Now()
+	var down, removed []Member
+
+	g.lock.Lock()
+	self := g.members[g.self]
+	self.Heartbeat++
+	self.seen = now
+	for addr, m := range g.members {
+		if addr == g.self {
+			continue
+		}
+		elapsed := now.Sub(m.seen)
+		if m.State == MemberAlive && elapsed > g.opts.FailTimeout {
+			m.State = MemberDead
+			down = append(down, m.Member)
+		}
+		if m.State != MemberAlive && elapsed > g.opts.FailTimeout+g.opts.CleanupTimeout {
+			delete(g.members, addr)
+			removed = append(removed, m.Member)
+		}
+	}
+	frame := g.encode()
+	g.lock.Unlock()
+
+	for _, m := range down {
+		g.node.gw.PostEvent(mynet.EventNodeDown, m)
+	}
+	for _, m := range removed {
+		g.node.RemovePeer(m.Addr)
+	}
+
+	peers := g.node.connectedPeers()
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	if len(peers) > g.opts.Fanout {
+		peers = peers[:g.opts.Fanout]
+	}
+	for _, p := range peers {
+		p.conn().SendData(frame)
+	}
+}
+
+// merge 合并收到的成员表，心跳更大的记录覆盖本地的记录
+func (g *gossiper) merge(payload []byte) error {
+	members, err := decodeMembers(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var up, down, joined, left []Member
+
+	g.lock.Lock()
+	for _, in := range members {
+		if in.Addr == g.self {
+			continue
+		}
+		m, ok := g.members[in.Addr]
+		if !ok {
+			if in.State != MemberAlive {
+				continue
+			}
+			g.members[in.Addr] = &memberInfo{Member: in, seen: now}
+			up = append(up, in)
+			joined = append(joined, in)
+			continue
+		}
+		if in.Heartbeat <= m.Heartbeat {
+			continue
+		}
+		wasAlive := m.State == MemberAlive
+		m.Member = in
+		m.seen = now
+		switch {
+		case !wasAlive && in.State == MemberAlive:
+			up = append(up, in)
+			joined = append(joined, in)
+		case wasAlive && in.State != MemberAlive:
+			down = append(down, in)
+			if in.State == MemberLeft {
+				left = append(left, in)
+			}
+		}
+	}
+	g.lock.Unlock()
+
+	for _, m := range joined {
+		g.node.AddPeer(m.Addr)
+	}
+	for _, m := range left {
+		g.node.RemovePeer(m.Addr)
+	}
+	for _, m := range up {
+		g.node.gw.PostEvent(mynet.EventNodeUp, m)
+	}
+	for _, m := range down {
+		g.node.gw.PostEvent(mynet.EventNodeDown, m)
+	}
+	return nil
+}
+
+// encode 成员表报文：类型(1) + key长度(2，为0) + 成员数(2) +
+// 每个成员：地址长度(1) + 地址 + 心跳(8) + 状态(1)
+func (g *gossiper) encode() []byte {
+	frame := []byte{msgGossip, 0, 0}
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(g.members)))
+	for _, m := range g.members {
+		frame = append(frame, byte(len(m.Addr)))
+		frame = append(frame, m.Addr...)
+		frame = binary.BigEndian.AppendUint64(frame, m.Heartbeat)
+		frame = append(frame, byte(m.State))
+	}
+	return frame
+}
+
+func decodeMembers(b []byte) ([]Member, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("short gossip message")
+	}
+	count := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	members := make([]Member, 0, count)
+	for i := 0; i < count; i++ {
+		if len(b) < 1 || len(b) < 1+int(b[0])+9 {
+			return nil, fmt.Errorf("short gossip message")
+		}
+		size := int(b[0])
+		m := Member{
+			Addr:      string(b[1 : 1+size]),
+			Heartbeat: binary.BigEndian.Uint64(b[1+size:]),
+			State:     MemberState(b[1+size+8]),
+		}
+		if m.State > MemberLeft {
+			return nil, fmt.Errorf("unknown member state %d", m.State)
+		}
+		members = append(members, m)
+		b = b[1+size+9:]
+	}
+	return members, nil
+}
+
+func (g *gossiper) close() {
+	close(g.stop)
+}
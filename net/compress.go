@@ -0,0 +1,297 @@
+package net
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// 压缩算法ID，在帧头中标识，取值1~7。snappy/zstd没有标准库实现，
+// 需要用第三方库通过RegisterCodec注册后才能使用
+const (
+	CompressNone    byte = 0
+	CompressGzip    byte = 1
+	CompressDeflate byte = 2
+	CompressSnappy  byte = 3
+	CompressZstd    byte = 4
+
+	compressMaxID   = 7
+	compressHeadLen = 6
+
+	defCompressMinSize = 256
+	defCompressMaxSize = 64 * 1024 * 1024
+)
+
+// ErrDecompressTooLarge 解压后超过CompressOptions.MaxSize
+var ErrDecompressTooLarge = errors.New("decompressed frame too large")
+
+// Codec 压缩算法
+type Codec struct {
+	ID   byte
+	Name string
+	// Compress 压缩src，追加到dst
+	Compress func(dst, src []byte) ([]byte, error)
+	// Decompress 解压src，结果超过max时返回ErrDecompressTooLarge
+	Decompress func(src []byte, max int) ([]byte, error)
+}
+
+var codecs = struct {
+	lock sync.RWMutex
+	ids  [compressMaxID + 1]*Codec
+}{}
+
+func init() {
+	RegisterCodec(streamCodec(CompressGzip, "gzip",
+		func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }))
+	RegisterCodec(streamCodec(CompressDeflate, "deflate",
+		func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.DefaultCompression) },
+		func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil }))
+}
+
+// RegisterCodec 注册压缩算法，相同ID的算法被替换
+func RegisterCodec(codec *Codec) error {
+	if codec.ID == CompressNone || codec.ID > compressMaxID {
+		return fmt.Errorf("invalid codec id %d", codec.ID)
+	}
+	if codec.Compress == nil || codec.Decompress == nil {
+		return fmt.Errorf("codec %s incomplete", codec.Name)
+	}
+	codecs.lock.Lock()
+	defer codecs.lock.Unlock()
+
+	codecs.ids[codec.ID] = codec
+	return nil
+}
+
+func codecOf(id byte) *Codec {
+	if id > compressMaxID {
+		return nil
+	}
+	codecs.lock.RLock()
+	defer codecs.lock.RUnlock()
+
+	return codecs.ids[id]
+}
+
+// streamCodec 用io.Writer/io.Reader形式的压缩库构造Codec
+func streamCodec(id byte, name string,
+	newWriter func(w io.Writer) (io.WriteCloser, error),
+	newReader func(r io.Reader) (io.ReadCloser, error)) *Codec {
+	return &Codec{
+		ID:   id,
+		Name: name,
+		Compress: func(dst, src []byte) ([]byte, error) {
+			buf := bytes.NewBuffer(dst)
+			w, err := newWriter(buf)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(src); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		Decompress: func(src []byte, max int) ([]byte, error) {
+			r, err := newReader(bytes.NewReader(src))
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			out, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+			if err != nil {
+				return nil, err
+			}
+			if len(out) > max {
+				return nil, ErrDecompressTooLarge
+			}
+			return out, nil
+		},
+	}
+}
+
+// CompressOptions 压缩层的配置
+type CompressOptions struct {
+	Codecs  []byte // 本端支持的算法，按优先顺序，为空时使用gzip
+	MinSize int    // 小于MinSize的报文不压缩，<=0时为256
+	MaxSize int    // 解压后报文的最大长度，<=0时为64M
+}
+
+// CompressProto 压缩层，包装proto(可以为nil，报文为[]byte)，
+// Serialize的报文整体压缩后加上6字节帧头：算法ID(1) + 本端能解压的算法掩码(1) + 长度(4，大端)，
+// 收到的帧解压后再交给proto解析。每一帧都带有本端的算法掩码，
+// 发送时使用对端支持的本端最优先的算法，收到对端的第一帧之前不压缩。
+// 保存了对端的状态，每个连接使用一个实例，监听时使用CompressFactory。
+// 被包装的proto的可选扩展(如Acker)不再生效
+type CompressProto struct {
+	proto   IProto
+	codecs  []byte
+	mask    byte
+	minSize int
+	maxSize int
+
+	peer atomic.Uint32 // 对端的算法掩码
+}
+
+// NewCompressProto 创建压缩层，opts为nil时使用默认配置
+func NewCompressProto(proto IProto, opts *CompressOptions) *CompressProto {
+	p := &CompressProto{
+		proto:   proto,
+		codecs:  []byte{CompressGzip},
+		minSize: defCompressMinSize,
+		maxSize: defCompressMaxSize,
+	}
+	if opts != nil {
+		if len(opts.Codecs) > 0 {
+			p.codecs = opts.Codecs
+		}
+		if opts.MinSize > 0 {
+			p.minSize = opts.MinSize
+		}
+		if opts.MaxSize > 0 {
+			p.maxSize = opts.MaxSize
+		}
+	}
+	for _, id := range p.codecs {
+		if codecOf(id) != nil {
+			p.mask |= 1 << id
+		}
+	}
+	return p
+}
+
+// CompressFactory 每个连接使用独立的压缩层
+func CompressFactory(factory ProtoFactory, opts *CompressOptions) ProtoFactory {
+	return func(conn *Connection) IProto {
+		return NewCompressProto(factory(conn), opts)
+	}
+}
+
+// Codec 当前发送使用的算法，还不能压缩时返回CompressNone
+func (p *CompressProto) Codec() byte {
+	peer := byte(p.peer.Load())
+	for _, id := range p.codecs {
+		if p.mask&peer&(1<<id) != 0 {
+			return id
+		}
+	}
+	return CompressNone
+}
+
+func (p *CompressProto) FilterAccept(conn *Connection) bool {
+	return p.proto == nil || p.proto.FilterAccept(conn)
+}
+
+func (p *CompressProto) HeadLen() uint32 {
+	return compressHeadLen
+}
+
+func (p *CompressProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	id := head[0]
+	if id != CompressNone && codecOf(id) == nil {
+		return nil, 0, fmt.Errorf("unknown codec %d", id)
+	}
+	size := binary.BigEndian.Uint32(head[2:])
+	if uint64(size) > uint64(p.maxSize) {
+		return nil, 0, fmt.Errorf("compressed frame too large, size = %d", size)
+	}
+	p.peer.Store(uint32(head[1]))
+	return id, size, nil
+}
+
+func (p *CompressProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	msg := body
+	if id := head.(byte); id != CompressNone {
+		var err error
+		msg, err = codecOf(id).Decompress(body, p.maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("decompress failed, err = %s", err)
+		}
+	}
+	return parseWrapped(p.proto, msg)
+}
+
+// parseWrapped 用被包装的proto解析一个完整的报文，proto为nil时返回[]byte
+func parseWrapped(proto IProto, msg []byte) (interface{}, error) {
+	headlen := 0
+	if proto != nil {
+		headlen = int(proto.HeadLen())
+	}
+	if headlen <= 0 {
+		splitter, ok := protoAs[Splitter](proto)
+		if !ok {
+			return msg, nil
+		}
+		// 一个报文是一次Serialize的结果，应当正好切分出一个报文
+		advance, frame, err := splitter.Split(msg, true)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil || advance != len(msg) {
+			return nil, fmt.Errorf("frame length mismatch, expect = %d, got = %d", len(msg), advance)
+		}
+		return proto.Parse(nil, frame)
+	}
+	if len(msg) < headlen {
+		return nil, fmt.Errorf("short frame, len = %d", len(msg))
+	}
+	headmsg, bodylen, err := proto.BodyLen(msg[:headlen])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(headlen)+uint64(bodylen) != uint64(len(msg)) {
+		return nil, fmt.Errorf("frame length mismatch, expect = %d, got = %d",
+			uint64(headlen)+uint64(bodylen), len(msg))
+	}
+	return proto.Parse(headmsg, msg[headlen:])
+}
+
+// serializeWrapped 用被包装的proto序列化，proto为nil时data必须是[]byte
+func serializeWrapped(proto IProto, data interface{}) ([]byte, error) {
+	if proto == nil {
+		msg, ok := data.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpect data type")
+		}
+		return msg, nil
+	}
+	return proto.Serialize(data)
+}
+
+func (p *CompressProto) Serialize(data interface{}) ([]byte, error) {
+	msg, err := serializeWrapped(p.proto, data)
+	if err != nil {
+		return nil, err
+	}
+	id := CompressNone
+	if len(msg) >= p.minSize {
+		id = p.Codec()
+	}
+	frame := make([]byte, compressHeadLen, compressHeadLen+len(msg))
+	frame[0], frame[1] = id, p.mask
+	if id != CompressNone {
+		frame, err = codecOf(id).Compress(frame, msg)
+		if err != nil {
+			return nil, fmt.Errorf("compress failed, err = %s", err)
+		}
+		// 压缩后更大时直接发送原文
+		if len(frame)-compressHeadLen >= len(msg) {
+			id = CompressNone
+			frame = append(frame[:compressHeadLen], msg...)
+			frame[0] = id
+		}
+	} else {
+		frame = append(frame, msg...)
+	}
+	binary.BigEndian.PutUint32(frame[2:], uint32(len(frame)-compressHeadLen))
+	return frame, nil
+}
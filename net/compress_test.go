@@ -0,0 +1,78 @@
+package net
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// compressTransfer from序列化的报文交给to解析，返回帧使用的算法
+func compressTransfer(t *testing.T, from, to *CompressProto, msg []byte) byte {
+	t.Helper()
+	frame, err := from.Serialize(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, size, err := to.BodyLen(frame[:to.HeadLen()])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(size) != len(frame)-compressHeadLen {
+		t.Fatalf("body len = %d, frame len = %d", size, len(frame))
+	}
+	data, err := to.Parse(head, frame[compressHeadLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data.([]byte), msg) {
+		t.Fatal("message mismatch")
+	}
+	return frame[0]
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	a := NewCompressProto(nil, &CompressOptions{Codecs: []byte{CompressGzip, CompressDeflate}})
+	b := NewCompressProto(nil, &CompressOptions{Codecs: []byte{CompressDeflate}})
+	msg := []byte(strings.Repeat("compress me ", 100))
+
+	// 收到对端的第一帧之前不压缩
+	if id := compressTransfer(t, a, b, msg); id != CompressNone {
+		t.Fatalf("first frame codec = %d", id)
+	}
+	if id := compressTransfer(t, b, a, msg); id != CompressDeflate {
+		t.Fatalf("b codec = %d, expect deflate", id)
+	}
+	// a优先gzip，但b只支持deflate
+	if id := compressTransfer(t, a, b, msg); id != CompressDeflate {
+		t.Fatalf("a codec = %d, expect deflate", id)
+	}
+	if id := compressTransfer(t, a, b, []byte("short")); id != CompressNone {
+		t.Fatalf("short message codec = %d", id)
+	}
+}
+
+func TestCompressLimits(t *testing.T) {
+	a := NewCompressProto(nil, nil)
+	b := NewCompressProto(nil, &CompressOptions{MaxSize: 1024})
+	compressTransfer(t, b, a, []byte("hello"))
+
+	frame, err := a.Serialize(make([]byte, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame[0] != CompressGzip {
+		t.Fatalf("codec = %d, expect gzip", frame[0])
+	}
+	head, _, err := b.BodyLen(frame[:compressHeadLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Parse(head, frame[compressHeadLen:]); err == nil {
+		t.Fatal("expect decompressed frame too large")
+	}
+
+	frame[0] = compressMaxID
+	if _, _, err := b.BodyLen(frame[:compressHeadLen]); err == nil {
+		t.Fatal("expect unknown codec error")
+	}
+}
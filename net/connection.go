@@ -1,9 +1,12 @@
 package net
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +22,32 @@ const (
 	EventNewConnectionData
 	EventProtoError
 	EventTimeout
+	EventEvicted
+	EventStateChanged
+	EventWriteStalled
+	EventReconnecting
+	EventReconnected
+	EventReconnectFailed
+	EventConnectionIdleClosed
+	EventHeartbeatMissed
+	EventWriteQueueFull
+	EventConnectionRejected
+	EventHandshakeFailed
+	EventProtoErrorLimit
+	EventStreamChunk
+	EventStreamEnd
+	EventSessionEstablished
+	EventSessionRejected
+	EventNodeUp
+	EventNodeDown
+)
+
+// 连接的地址族
+const (
+	FamilyUnknown = iota
+	FamilyIPv4
+	FamilyIPv6
+	FamilyUnix
 )
 
 const (
@@ -32,6 +61,9 @@ type ConnEvent struct {
 	EventType int
 	Conn      *Connection
 	Data      interface{}
+
+	provider BufferProvider
+	bufs     [][]byte
 }
 
 type Connection struct {
@@ -41,11 +73,56 @@ type Connection struct {
 	id      int64
 	status  int64
 	conn    net.Conn
-	msgChan chan []byte
+	msgChan chan *writeReq
+	writing atomic.Bool
+
+	queuePolicyPtr atomic.Pointer[QueuePolicy]
+	overflowing    atomic.Bool
+
+	readLimit  atomic.Pointer[Limiter]
+	writeLimit atomic.Pointer[Limiter]
 
 	localAddr  string
 	remoteAddr string
-	upTime     time.Time
+	upTime     atomic.Int64
+
+	mirror atomic.Pointer[mirror]
+	events atomic.Pointer[chan *ConnEvent]
+
+	dedup      atomic.Pointer[dedupHolder]
+	duplicates atomic.Int64
+
+	provider  atomic.Pointer[providerHolder]
+	allocator atomic.Pointer[allocatorHolder]
+
+	acks     ackTable
+	calls    callTable
+	streamID atomic.Uint64
+	session  sessionState
+
+	queued   atomic.Int64
+	priority atomic.Int32
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	errPolicy   atomic.Pointer[ProtoErrorPolicy]
+	protoErrors atomic.Int64
+	errTimes    []int64 // 最近的proto错误时间，只在读协程中访问
+	maxBodyLen  atomic.Int64
+
+	lockState sync.Mutex
+	stateAt   time.Time
+	stateCbs  []StateFunc
+
+	managed   *ManagedConnection
+	heartbeat heartbeatState
+	identity  interface{}
+	upgrade   atomic.Pointer[upgradeState]
+	pending   []byte // Splitter未切分的数据，只由读协程访问
+	reactor   reactorConn
+	pipeline  *Pipeline
+	version   uint16
 
 	proto    IProto // 为了实现多种proto
 	UserData interface{}
@@ -59,7 +136,7 @@ func (c *Connection) ID() int64 {
 }
 
 func (c *Connection) Status() int64 {
-	return c.status
+	return atomic.LoadInt64(&c.status)
 }
 
 func (c *Connection) LocalAddress() string {
@@ -69,24 +146,100 @@ func (c *Connection) LocalAddress() string {
 func (c *Connection) RemoteAddress() string {
 	return c.remoteAddr
 }
+
+// Family 连接的地址族(FamilyXXX)，按本端地址判断，
+// 双栈监听上IPv4映射的地址(::ffff:a.b.c.d)为FamilyIPv4
+func (c *Connection) Family() int {
+	switch addr := c.conn.LocalAddr().(type) {
+	case *net.UnixAddr:
+		return FamilyUnix
+	case *net.TCPAddr:
+		return ipFamily(addr.IP)
+	case *net.UDPAddr:
+		return ipFamily(addr.IP)
+	}
+	if ap, err := netip.ParseAddrPort(c.localAddr); err == nil {
+		return ipFamily(ap.Addr().AsSlice())
+	}
+	return FamilyUnknown
+}
+
+func ipFamily(ip net.IP) int {
+	switch {
+	case ip.To4() != nil:
+		return FamilyIPv4
+	case len(ip) == net.IPv6len:
+		return FamilyIPv6
+	}
+	return FamilyUnknown
+}
 func (c *Connection) UpdateTime() time.Time {
-	return c.upTime
+	return time.Unix(0, c.upTime.Load())
+}
+
+// touch 更新最近活动时间
+func (c *Connection) touch() {
+	c.upTime.Store(time.Now().UnixNano())
+}
+
+// NetConn 底层的net.Conn
+func (c *Connection) NetConn() net.Conn {
+	return c.conn
 }
 
 type Listener struct {
 	net *SimpleNet
 
-	id     int64
-	status int64
-	listen net.Listener
-	conns  []*Connection
+	id      int64
+	status  int64
+	listens []*boundAddr
+	conns   []*Connection
 
 	lockClient sync.Locker
+	lockListen sync.Mutex
+
+	readLimit  atomic.Pointer[Limiter]
+	writeLimit atomic.Pointer[Limiter]
+
+	events     atomic.Pointer[chan *ConnEvent]
+	dispatcher atomic.Pointer[dispatcher]
+	shards     atomic.Pointer[shardSet]
+
+	queuePolicy atomic.Pointer[QueuePolicy]
+
+	stats            listenerStats
+	handshakeTimeout atomic.Int64
+	limit            connLimit
+	acceptLimit      atomic.Pointer[Limiter]
+	listenOptions    atomic.Pointer[ListenOptions]
+	ipFilter         ipFilter
+	verifier         atomic.Pointer[PeerVerifier]
+	acceptFilter     atomic.Pointer[AcceptFilter]
+
+	dedup     atomic.Pointer[dedupHolder]
+	provider  atomic.Pointer[providerHolder]
+	allocator atomic.Pointer[allocatorHolder]
+
+	ctx    context.Context
+	cancel context.CancelFunc
 
+	errPolicy  atomic.Pointer[ProtoErrorPolicy]
+	maxBodyLen atomic.Int64
+	versionHS  atomic.Pointer[VersionHandshake]
+	sessionMgr atomic.Pointer[SessionManager]
+
+	listenFunc func(addr string) (net.Listener, error)
+
+	factory  ProtoFactory
 	proto    IProto
 	UserData interface{}
 }
 
+// Status 监听状态
+func (l *Listener) Status() int64 {
+	return atomic.LoadInt64(&l.status)
+}
+
 func (l *Listener) ID() int64 {
 	return l.id
 }
@@ -94,11 +247,18 @@ func (l *Listener) Net() *SimpleNet {
 	return l.net
 }
 func (l *Listener) LocalAddress() string {
-	return l.listen.Addr().String()
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	if len(l.listens) == 0 {
+		return ""
+	}
+	return l.listens[0].listen.Addr().String()
 }
 
 type SimpleNet struct {
-	events chan *ConnEvent
+	events       chan *ConnEvent
+	clientEvents atomic.Pointer[chan *ConnEvent]
 
 	connClient []*Connection
 	connServer []*Listener
@@ -107,7 +267,51 @@ type SimpleNet struct {
 	lockClient sync.Locker
 
 	nextid  int64
-	destroy bool
+	destroy atomic.Bool
+
+	queued   atomic.Int64
+	eviction atomic.Pointer[evictor]
+	watchdog atomic.Pointer[watchdog]
+
+	dialTimeout atomic.Int64
+	dialFunc    atomic.Pointer[Dialer]
+	idle        atomic.Pointer[idleReaper]
+	heartbeat   atomic.Pointer[heartbeater]
+
+	wg       sync.WaitGroup
+	shutting atomic.Bool
+
+	dispatcher atomic.Pointer[dispatcher]
+	shards     atomic.Pointer[shardSet]
+
+	queuePolicy atomic.Pointer[QueuePolicy]
+
+	readLimit  atomic.Pointer[Limiter]
+	writeLimit atomic.Pointer[Limiter]
+
+	connOptions atomic.Pointer[ConnOptions]
+	pipeline    atomic.Pointer[Pipeline]
+	maxBodyLen  atomic.Int64
+	versionHS   atomic.Pointer[VersionHandshake]
+	sessionMgr  atomic.Pointer[SessionManager]
+	errPolicy   atomic.Pointer[ProtoErrorPolicy]
+	streamChunk atomic.Int64
+
+	groups groupRegistry
+	topics groupRegistry
+	users  groupRegistry
+
+	resolvers   sync.Map // scheme -> Resolver
+	endpoints   sync.Map // addr -> *endpointHealth
+	ejectPolicy atomic.Pointer[EjectPolicy]
+	reactor     atomic.Pointer[reactor]
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lockState   sync.Mutex
+	stateCbs    []StateFunc
+	stateEvents atomic.Bool
 
 	log *mylog.Log
 
@@ -130,12 +334,16 @@ func NewSimpleNet(log *mylog.Log) *SimpleNet {
 		lockClient: &sync.Mutex{},
 		log:        log,
 	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
 
 	return n
 }
 
+// SimpleNetDestroy 关闭所有连接和监听。事件队列不关闭，之后的轮询返回错误，
+// 仍在进行的emit直接丢弃事件，不会向已关闭的channel发送
 func SimpleNetDestroy(n *SimpleNet) {
-	close(n.events)
+	n.destroy.Store(true)
+	n.cancel()
 	for _, v := range n.connClient {
 		n.CloseConn(v)
 	}
@@ -143,7 +351,6 @@ func SimpleNetDestroy(n *SimpleNet) {
 	for _, v := range n.connServer {
 		n.CloseListen(v)
 	}
-	n.destroy = true
 }
 
 func (n *SimpleNet) logMsg(level int, msg string) {
@@ -194,39 +401,51 @@ func (n *SimpleNet) syncDelListen(listen *Listener) {
 }
 
 func (n *SimpleNet) syncAddClient(conn *Connection) {
-	var connQueue []*Connection
-
-	connQueue = n.connClient
 	lock := n.lockClient
 	if conn.listen != nil {
-		connQueue = conn.listen.conns
 		lock = conn.listen.lockClient
 	}
 
 	lock.Lock()
 	defer lock.Unlock()
 
+	// 在锁内取队列，并发建立的连接不会互相覆盖
+	var connQueue []*Connection
+	if conn.listen != nil {
+		connQueue = conn.listen.conns
+	} else {
+		connQueue = n.connClient
+	}
+
 	connQueue = append(connQueue, conn)
 
 	if conn.listen != nil {
+		conn.listen.stats.conns.Add(1)
 		conn.listen.conns = connQueue
 	} else {
 		n.connClient = connQueue
 	}
+	n.watchIdle(n.idle.Load(), conn)
+	n.watchHeartbeat(n.heartbeat.Load(), conn)
+	n.watchSession(conn)
 }
 func (n *SimpleNet) syncDelClient(conn *Connection) {
-	var connQueue []*Connection
-
-	connQueue = n.connClient
 	lock := n.lockClient
 	if conn.listen != nil {
-		connQueue = conn.listen.conns
 		lock = conn.listen.lockClient
 	}
 
 	lock.Lock()
 	defer lock.Unlock()
 
+	// 在锁内取队列，并发建立的连接不会互相覆盖
+	var connQueue []*Connection
+	if conn.listen != nil {
+		connQueue = conn.listen.conns
+	} else {
+		connQueue = n.connClient
+	}
+
 	var del bool
 	for i, v := range connQueue {
 		if v == conn {
@@ -242,6 +461,8 @@ func (n *SimpleNet) syncDelClient(conn *Connection) {
 
 	if del {
 		if conn.listen != nil {
+			conn.listen.stats.conns.Add(-1)
+			conn.listen.limit.release()
 			conn.listen.conns = connQueue
 		} else {
 			n.connClient = connQueue
@@ -252,17 +473,11 @@ func (n *SimpleNet) syncDelClient(conn *Connection) {
 func (n *SimpleNet) checkConnErr(count int, err error, conn *Connection) error {
 	if err != nil {
 		n.logMsg(mylog.LevelError, fmt.Sprintf("conn err = %s\n", err))
-		if conn.net.destroy {
+		if conn.net.destroy.Load() {
 			n.logMsg(mylog.LevelError, fmt.Sprintf("net destroy\n"))
 			return err
 		}
-		if conn.status == StatusConnected {
-			close(conn.msgChan)
-			conn.conn.Close()
-			conn.status = StatusBroken
-
-			n.syncDelClient(conn)
-		}
+		n.closeConn(conn)
 		evt := EventConnectionError
 		if err == io.EOF {
 			evt = EventConnectionClosed
@@ -275,94 +490,169 @@ func (n *SimpleNet) checkConnErr(count int, err error, conn *Connection) error {
 			Conn:      conn,
 			Data:      err,
 		}
-		n.events <- event
+		n.emit(event)
 	}
 	return err
 }
 func (n *SimpleNet) handleRead(conn *Connection) {
+	defer n.wg.Done()
 	defer func() {
 		err := recover()
 		if err != nil {
 			n.logMsg(mylog.LevelError, fmt.Sprintf("handleRead panic: %s\n", err))
 		}
 	}()
-	for {
-		headlen := (uint32)(0)
-		if conn.proto != nil {
-			headlen = conn.proto.HeadLen()
-		}
-		if headlen <= 0 {
-			buf := make([]byte, 1)
-			count, err := conn.conn.Read(buf)
-			if err = n.checkConnErr(count, err, conn); err != nil {
-				return
-			}
+	for n.readOnce(conn) {
+	}
+}
+
+// readOnce 读取并处理一个报文(没有帧格式时为一次读到的数据)，连接关闭时返回false
+func (n *SimpleNet) readOnce(conn *Connection) bool {
+	if !conn.waitUpgrade() {
+		return false
+	}
+	if dc, ok := conn.conn.(datagramConn); ok && !conn.pipeline.inbound() {
+		if !n.readDatagram(conn, dc) {
+			return false
+		}
+		conn.touch()
+		return true
+	}
+	if splitter, ok := protoAs[Splitter](conn.proto); ok && conn.proto.HeadLen() <= 0 && !conn.pipeline.inbound() {
+		if !n.readSplit(conn, splitter, &conn.pending) {
+			return false
+		}
+		conn.touch()
+		return true
+	}
+	if conn.pipeline.inbound() {
+		if !n.readPipeline(conn) {
+			return false
+		}
+		conn.touch()
+		return true
+	}
+	headlen := (uint32)(0)
+	if conn.proto != nil {
+		headlen = conn.proto.HeadLen()
+	}
+	provider := conn.bufferProvider()
+	if headlen <= 0 {
+		// 没有帧格式，每次读取已经到达的数据
+		buf := allocBuf(provider, rawReadSize)
+		count, err := n.readSome(conn, buf)
+		if err != nil && conn.upgradeInterrupted(err) {
+			freeBuf(provider, buf)
+			return true
+		}
+		if count > 0 {
 			n.logMsg(mylog.LevelInformational,
 				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
 					count, conn.conn.RemoteAddr()))
+			conn.mirrorFrame(false, buf[:count])
 
 			// emit
 			event := &ConnEvent{
 				EventType: EventNewConnectionData,
 				Conn:      conn,
-				Data:      buf,
+				Data:      buf[:count],
+				provider:  provider,
+				bufs:      [][]byte{buf},
 			}
-			n.events <- event
-
+			n.emit(event)
 		} else {
-			head := make([]byte, headlen)
-			count, err := conn.conn.Read(head)
-			if err = n.checkConnErr(count, err, conn); err != nil {
-				return
+			freeBuf(provider, buf)
+		}
+		if err != nil {
+			n.checkConnErr(count, err, conn)
+			return false
+		}
+
+	} else {
+		head := allocBuf(provider, int(headlen))
+		count, err := n.readConn(conn, head)
+		if err != nil && conn.upgradeInterrupted(err) {
+			freeBuf(provider, head)
+			return true
+		}
+		if err = n.checkConnErr(count, err, conn); err != nil {
+			return false
+		}
+		n.logMsg(mylog.LevelInformational,
+			fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
+				count, conn.conn.RemoteAddr()))
+		headmsg, bodylen, err := conn.proto.BodyLen(head)
+		if err != nil {
+			freeBuf(provider, head)
+			// emit EventConnectionError
+			event := &ConnEvent{
+				EventType: EventProtoError,
+				Conn:      conn,
+				Data:      err,
 			}
-			n.logMsg(mylog.LevelInformational,
-				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
-					count, conn.conn.RemoteAddr()))
-			headmsg, bodylen, err := conn.proto.BodyLen(head)
-			if err != nil {
-				// emit EventConnectionError
-				event := &ConnEvent{
-					EventType: EventProtoError,
-					Conn:      conn,
-					Data:      err,
-				}
-				n.events <- event
-				continue
+			n.emit(event)
+			if !n.handleProtoError(conn, err) {
+				return false
 			}
+			return true
+		}
+		if !n.checkBodyLen(conn, bodylen) {
+			freeBuf(provider, head)
+			return false
+		}
 
-			body := make([]byte, bodylen)
-			count, err = conn.conn.Read(body)
-			if err = n.checkConnErr(count, err, conn); err != nil {
-				return
-			}
-			n.logMsg(mylog.LevelInformational,
-				fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
-					count, conn.conn.RemoteAddr()))
+		body := allocBuf(provider, int(bodylen))
+		count, err = n.readConn(conn, body)
+		if err != nil && conn.upgradeInterrupted(err) {
+			freeBuf(provider, head, body)
+			return true
+		}
+		if err = n.checkConnErr(count, err, conn); err != nil {
+			return false
+		}
+		n.logMsg(mylog.LevelInformational,
+			fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n",
+				count, conn.conn.RemoteAddr()))
+		conn.mirrorFrame(false, head, body)
 
-			data, err := conn.proto.Parse(headmsg, body)
-			if err != nil {
-				// emit EventConnectionError
-				event := &ConnEvent{
-					EventType: EventProtoError,
-					Conn:      conn,
-					Data:      err,
-				}
-				n.events <- event
-				continue
-			}
-			// emit EventNewConnectionData
+		data, err := conn.proto.Parse(headmsg, body)
+		if err != nil {
+			freeBuf(provider, head, body)
+			// emit EventConnectionError
 			event := &ConnEvent{
-				EventType: EventNewConnectionData,
+				EventType: EventProtoError,
 				Conn:      conn,
-				Data:      data,
+				Data:      err,
+			}
+			n.emit(event)
+			if !n.handleProtoError(conn, err) {
+				return false
 			}
-			n.events <- event
+			return true
 		}
-		conn.upTime = time.Now()
+		if conn.intercept(data, func() { freeBuf(provider, head, body) }) {
+			conn.touch()
+			return true
+		}
+		// emit EventNewConnectionData
+		evtType, data := conn.dataEvent(data)
+		event := &ConnEvent{
+			EventType: evtType,
+			Conn:      conn,
+			Data:      data,
+			provider:  provider,
+			bufs:      [][]byte{head, body},
+		}
+		n.emit(event)
 	}
+	conn.touch()
+	return true
 }
 
+// handleWrite 发送队列的写协程，只在队列有数据时运行，
+// 空闲连接只占用一个读协程
 func (n *SimpleNet) handleWrite(conn *Connection) {
+	defer n.wg.Done()
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -372,25 +662,49 @@ func (n *SimpleNet) handleWrite(conn *Connection) {
 	}()
 	for {
 		select {
-		case msg, ok := <-conn.msgChan:
+		case req := <-conn.msgChan:
 			{
-				if !ok {
-					return
+				if conn.Status() != StatusConnected {
+					conn.addQueued(-req.size)
+					req.finish(ErrConnClosed)
+					continue
+				}
+				count, err := n.writeConn(conn, req.bufs)
+				conn.addQueued(-req.size)
+				if err != nil {
+					req.finish(err)
 				}
-				count, err := conn.conn.Write(msg)
 				if err = n.checkConnErr(count, err, conn); err != nil {
 					return
 				}
-				conn.upTime = time.Now()
+				conn.mirrorFrame(true, req.bufs...)
+				req.finish(nil)
+				conn.touch()
 				n.logMsg(mylog.LevelInformational,
 					fmt.Sprintf("send data, count = %d, remoteAddr = %s\n",
 						count, conn.conn.RemoteAddr()))
 			}
+		default:
+			conn.overflowing.Store(false)
+			conn.writing.Store(false)
+			// 退出前再检查一次，防止和kickWrite竞争丢失数据
+			if len(conn.msgChan) == 0 || !conn.writing.CompareAndSwap(false, true) {
+				return
+			}
 		}
 	}
 }
 
-func (n *SimpleNet) listening(l *Listener) {
+// kickWrite 数据入队后调用，没有写协程时启动一个
+func (c *Connection) kickWrite() {
+	if c.writing.CompareAndSwap(false, true) {
+		c.net.wg.Add(1)
+		go c.net.handleWrite(c)
+	}
+}
+
+func (n *SimpleNet) listening(l *Listener, listen net.Listener) {
+	defer n.wg.Done()
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -398,112 +712,236 @@ func (n *SimpleNet) listening(l *Listener) {
 				fmt.Sprintf("listenning panic: %s\n", err))
 		}
 	}()
+	var backoff time.Duration
 	for {
-		newconn, err := l.listen.Accept()
+		if !l.waitAccept() {
+			break
+		}
+		// park模式在accept之前等待名额
+		slot := false
+		if l.limit.parking() {
+			if !l.limit.acquire(l.ctx) {
+				break
+			}
+			slot = true
+		}
+		newconn, err := listen.Accept()
 		if err != nil {
-			n.logMsg(mylog.LevelError,
-				fmt.Sprintf("accept failed, err = %s\n", err))
-			if l.status != StatusListenning {
+			if slot {
+				l.limit.release()
+			}
+			if l.Status() != StatusListenning || errors.Is(err, net.ErrClosed) {
 				break
 			}
+			l.stats.acceptErrors.Inc()
+			if backoff = n.acceptBackoff(l, backoff, err); backoff == 0 {
+				break
+			}
+			continue
+		}
+		backoff = 0
+		// PROXY protocol的真实地址在握手后检查
+		if proxyOf(newconn) == nil && !l.ipFilter.permit(newconn.RemoteAddr()) {
+			if slot {
+				l.limit.release()
+			}
+			n.rejectConn(l, newconn, RejectDeny, nil)
+			continue
+		}
+		if !slot && !l.limit.acquire(l.ctx) {
+			n.rejectConn(l, newconn, RejectLimit, nil)
 			continue
 		}
 
-		conn := &Connection{
-			net:        l.net,
-			listen:     l,
-			id:         atomic.AddInt64(&n.nextid, 1),
-			status:     StatusConnected,
-			conn:       newconn,
-			msgChan:    make(chan []byte, 1024),
-			localAddr:  newconn.LocalAddr().String(),
-			remoteAddr: newconn.RemoteAddr().String(),
-			proto:      l.proto,
-			upTime:     time.Now(),
+		// 握手和FilterAccept可能较慢，不阻塞accept
+		l.stats.pending.Add(1)
+		n.wg.Add(1)
+		go n.acceptConn(l, newconn, time.Now())
+	}
+}
+
+func (n *SimpleNet) acceptConn(l *Listener, newconn net.Conn, start time.Time) {
+	defer n.wg.Done()
+	defer func() {
+		err := recover()
+		if err != nil {
+			n.logMsg(mylog.LevelError,
+				fmt.Sprintf("acceptConn panic: %s\n", err))
 		}
+	}()
+	defer l.stats.pending.Add(-1)
 
-		if conn.proto != nil {
-			if !conn.proto.FilterAccept(conn) {
-				continue
-			}
+	// 加入连接列表后名额在syncDelClient中释放
+	added := false
+	defer func() {
+		if !added {
+			l.limit.release()
 		}
+	}()
 
-		n.syncAddClient(conn)
+	n.applyOptions(l, newconn)
+	if err := l.handshake(newconn); err != nil {
+		l.stats.handshakeFailures.Inc()
+		n.logMsg(mylog.LevelError,
+			fmt.Sprintf("handshake failed, err = %s\n", err))
+		newconn.Close()
+		return
+	}
+	if proxyOf(newconn) != nil && !l.ipFilter.permit(newconn.RemoteAddr()) {
+		n.rejectConn(l, newconn, RejectDeny, nil)
+		return
+	}
+	if ok, err := l.filterAccept(newconn.RemoteAddr()); !ok {
+		n.rejectConn(l, newconn, RejectFilter, err)
+		return
+	}
 
-		// emit EventNewConnection
-		event := &ConnEvent{
-			EventType: EventNewConnection,
-			Conn:      conn,
-		}
-		n.events <- event
+	conn := &Connection{
+		net:        l.net,
+		listen:     l,
+		id:         atomic.AddInt64(&n.nextid, 1),
+		status:     StatusConnected,
+		conn:       newconn,
+		msgChan:    n.newMsgChan(l),
+		localAddr:  newconn.LocalAddr().String(),
+		remoteAddr: newconn.RemoteAddr().String(),
+		pipeline:   n.pipeline.Load(),
+	}
+	conn.touch()
+	conn.ctx, conn.cancel = context.WithCancelCause(l.ctx)
+
+	if err := l.verifyPeer(conn); err != nil {
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("verify peer %s failed, err = %s\n", conn.remoteAddr, err))
+		conn.cancel(ErrConnClosed)
+		n.rejectConn(l, newconn, RejectPeer, err)
+		return
+	}
+	conn.proto = l.newProto(conn)
 
-		go n.handleRead(conn)
-		go n.handleWrite(conn)
+	if conn.proto != nil {
+		if !conn.proto.FilterAccept(conn) {
+			conn.cancel(ErrConnClosed)
+			n.rejectConn(l, newconn, RejectFilter, nil)
+			return
+		}
+	}
+	if err := n.negotiate(conn); err != nil {
+		return
+	}
 
+	n.syncAddClient(conn)
+	added = true
+	conn.notifyState(StatusNone, StatusConnected)
+	l.stats.accepted.Inc()
+	l.stats.latency.Observe(time.Since(start).Seconds())
+
+	// emit EventNewConnection
+	event := &ConnEvent{
+		EventType: EventNewConnection,
+		Conn:      conn,
 	}
+	n.emit(event)
+
+	n.startRead(conn)
 }
 
-// Listen 监听网络 addr 为监听地址
+// Listen 监听网络 addr 为监听地址，多个地址用逗号分隔(如"0.0.0.0:80,[::]:80")
 func (n *SimpleNet) Listen(addr string, proto IProto) (*Listener, error) {
-	listen, err := net.Listen("tcp", addr)
-	if err != nil {
-		return nil, err
-	}
+	return n.listenWith(addr, listenTCP, proto, nil)
+}
 
+// AttachListener 在已有的net.Listener上接受连接，
+// 可以用于自定义的传输层(如把h2c的stream作为连接)
+func (n *SimpleNet) AttachListener(listen net.Listener, proto IProto) (*Listener, error) {
+	return n.attachListener(listen.Addr().String(), listen, proto, nil), nil
+}
+
+func (n *SimpleNet) attachListener(addr string, listen net.Listener, proto IProto, factory ProtoFactory) *Listener {
+	l := n.newListener(proto, factory)
+	l.addListener(addr, listen)
+
+	return l
+}
+
+// newListener 创建还没有监听地址的Listener
+func (n *SimpleNet) newListener(proto IProto, factory ProtoFactory) *Listener {
 	l := &Listener{
 		net: n,
 
 		id:         atomic.AddInt64(&n.nextid, 1),
 		status:     StatusListenning,
-		listen:     listen,
 		lockClient: &sync.Mutex{},
 
-		proto: proto,
+		factory: factory,
+		proto:   proto,
 	}
+	l.ctx, l.cancel = context.WithCancel(n.ctx)
+	l.stats.init()
 	n.syncAddListen(l)
 
-	go n.listening(l)
-
-	return l, nil
+	return l
 }
 
 // Connect 连接服务器器
 func (n *SimpleNet) Connect(addr string, proto IProto) (*Connection, error) {
-	newconn, err := net.Dial("tcp", addr)
+	newconn, _, err := n.dialEndpoint(context.Background(), "tcp", addr)
 	if err != nil {
 		return nil, err
 	}
 
+	return n.AttachConn(newconn, proto)
+}
+
+// AttachConn 管理已建立的net.Conn，
+// 可以用于自定义的传输层(如ssh的channel)
+func (n *SimpleNet) AttachConn(newconn net.Conn, proto IProto) (*Connection, error) {
+	return n.attachConn(newconn, func(*Connection) IProto { return proto }, nil)
+}
+
+func (n *SimpleNet) attachConn(newconn net.Conn, factory ProtoFactory, managed *ManagedConnection) (*Connection, error) {
 	conn := &Connection{
 		net:        n,
 		id:         atomic.AddInt64(&n.nextid, 1),
 		status:     StatusConnected,
 		conn:       newconn,
-		msgChan:    make(chan []byte, 1024),
+		msgChan:    n.newMsgChan(nil),
 		localAddr:  newconn.LocalAddr().String(),
 		remoteAddr: newconn.RemoteAddr().String(),
-		upTime:     time.Now(),
-		proto:      proto,
+		managed:    managed,
+		pipeline:   n.pipeline.Load(),
+	}
+	conn.touch()
+	conn.ctx, conn.cancel = context.WithCancelCause(n.ctx)
+	conn.proto = factory(conn)
+	if err := n.negotiate(conn); err != nil {
+		return nil, err
 	}
 	n.syncAddClient(conn)
+	conn.notifyState(StatusNone, StatusConnected)
 
-	go n.handleRead(conn)
-	go n.handleWrite(conn)
+	n.startRead(conn)
 
 	return conn, nil
 }
 
 // PollEvent 事件轮询
 func (n *SimpleNet) PollEvent(timeout int) (*ConnEvent, error) {
+	return pollQueue(n.events, n.ctx.Done(), timeout)
+}
+
+// pollQueue 轮询队列，done关闭(SimpleNet销毁)后返回错误
+func pollQueue(events chan *ConnEvent, done <-chan struct{}, timeout int) (*ConnEvent, error) {
 	t := time.After(time.Millisecond * (time.Duration)(timeout))
 	select {
-	case event, ok := <-n.events:
+	case event := <-events:
 		{
-			if !ok {
-				return nil, fmt.Errorf("SimpleNet destroyed")
-			}
 			return event, nil
 		}
+	case <-done:
+		{
+			return nil, ErrNetDestroyed
+		}
 	case <-t:
 		{
 			evt := &ConnEvent{
@@ -516,46 +954,57 @@ func (n *SimpleNet) PollEvent(timeout int) (*ConnEvent, error) {
 
 // SendData 向connection发送数据，如果connection不支持，data为[]byte
 func (n *SimpleNet) SendData(conn *Connection, data interface{}) error {
-	if conn.status != StatusConnected {
-		return fmt.Errorf("not connected connection")
+	if conn.Status() != StatusConnected {
+		return ErrConnClosed
 	}
-	if conn.proto == nil {
-		msg, ok := (data).([]byte)
-		if !ok {
-			return fmt.Errorf("unexpect data type")
-		}
-		conn.msgChan <- msg
-	} else {
-		msg, err := conn.proto.Serialize(data)
-		if err != nil {
-			return err
-		}
-		conn.msgChan <- msg
+	msg, alloc, err := conn.serialize(data)
+	if err != nil {
+		return err
 	}
-	return nil
+	req := newWriteReq(msg)
+	req.alloc = alloc
+	return conn.enqueue(req)
 }
 
-// CloseConn 关闭连接
+// CloseConn 关闭连接，可以重复调用
 func (n *SimpleNet) CloseConn(conn *Connection) error {
-	if conn.status == StatusConnected {
-		conn.status = StatusBroken
-		close(conn.msgChan)
-		conn.conn.Close()
+	n.closeConn(conn)
+	return nil
+}
 
-		n.syncDelClient(conn)
+// closeConn 关闭连接，状态切换保证只执行一次，返回是否由本次调用关闭。
+// msgChan不关闭，发送方通过连接的context判断连接已关闭，避免向已关闭的channel发送
+func (n *SimpleNet) closeConn(conn *Connection) bool {
+	if !conn.transition(StatusConnected, StatusBroken) {
+		return false
 	}
-	return nil
+	conn.conn.Close()
+
+	n.syncDelClient(conn)
+	conn.closed()
+	return true
 }
 
 // CloseListen 关闭服务器
 func (n *SimpleNet) CloseListen(listen *Listener) error {
-	if listen.status == StatusListenning {
-		for _, v := range listen.conns {
-			n.CloseConn(v)
-		}
-		listen.status = StatusBroken
-		listen.listen.Close()
+	if !atomic.CompareAndSwapInt64(&listen.status, StatusListenning, StatusBroken) {
+		return nil
+	}
+	// CloseConn在syncDelClient中修改conns，先在锁内复制
+	listen.lockClient.Lock()
+	conns := append([]*Connection(nil), listen.conns...)
+	listen.lockClient.Unlock()
+	for _, v := range conns {
+		n.CloseConn(v)
+	}
+
+	listen.lockListen.Lock()
+	for _, v := range listen.listens {
+		v.listen.Close()
 	}
+	listen.listens = nil
+	listen.lockListen.Unlock()
+	listen.cancel()
 
 	return nil
 }
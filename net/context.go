@@ -0,0 +1,31 @@
+package net
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrConnClosed 连接已经关闭，也是连接context的取消原因
+var ErrConnClosed = errors.New("connection closed")
+
+// ErrNetDestroyed SimpleNet已经销毁，轮询事件时返回
+var ErrNetDestroyed = errors.New("SimpleNet destroyed")
+
+// Context 连接的context，从监听(或SimpleNet)的context派生，连接关闭时取消，
+// context.Cause返回ErrConnClosed。可以把下游的调用和连接的生命周期绑定
+func (c *Connection) Context() context.Context {
+	return c.ctx
+}
+
+// Context 监听的context，CloseListen或者SimpleNetDestroy时取消
+func (l *Listener) Context() context.Context {
+	return l.ctx
+}
+
+// Context 事件所属连接的context，没有连接的事件返回context.Background()
+func (e *ConnEvent) Context() context.Context {
+	if e.Conn != nil && e.Conn.ctx != nil {
+		return e.Conn.ctx
+	}
+	return context.Background()
+}
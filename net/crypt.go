@@ -0,0 +1,222 @@
+package net
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	cryptHeadLen  = 4
+	cryptKeyIDLen = 16
+	cryptSeqLen   = 8
+	cryptNonceLen = 12
+	cryptWindow   = 64
+
+	defCryptMaxSize = 64 * 1024 * 1024
+)
+
+var cryptInfo = []byte("golib net crypt v2")
+
+// ErrCryptReplay 收到重放或者序号超出窗口的加密帧
+var ErrCryptReplay = errors.New("crypt frame replayed")
+
+// CryptProto AES-GCM加密层，用于不能使用TLS的链路，包装proto(可以为nil，报文为[]byte)，
+// 双方使用相同的预共享密钥(PSK)。每个实例生成16字节随机的key ID，发送方向的会话密钥为
+// HKDF-SHA256(PSK, key ID)，不同连接、不同方向的密钥不同，相同的nonce不会在同一个密钥下重复。
+// 帧格式：长度(4，大端) + key ID(16) + 序号(8，大端) + 密文和认证标签，
+// nonce为4字节0加序号，长度和key ID作为附加数据参与认证。
+// 收到的第一帧确定对端的key ID，之后的帧必须使用相同的key ID并且序号没有收到过，否则返回ErrCryptReplay。
+//
+// 重放的限制：只能发现同一个连接内的重放。会话密钥只由发送方的key ID决定，
+// 录下的一个方向的完整报文从头开始发到新连接上仍然可以通过校验，
+// 需要防止跨连接重放时由应用在报文中加入对端提供的挑战或者时间戳。
+//
+// 保存了双方的状态，每个连接使用一个实例，监听时使用CryptFactory。
+// 被包装的proto的可选扩展(如Acker)不再生效
+type CryptProto struct {
+	proto   IProto
+	psk     []byte
+	maxSize int
+
+	lock  sync.Mutex
+	keyID [cryptKeyIDLen]byte
+	aead  cipher.AEAD
+	seq   uint64
+
+	peerLock   sync.Mutex
+	peerKeyID  [cryptKeyIDLen]byte
+	peerAEAD   cipher.AEAD
+	peerSeq    uint64
+	peerWindow uint64 // 第i位表示peerSeq-i已经收到
+}
+
+// NewCryptProto 创建加密层，key的长度为16/24/32，对应AES-128/192/256
+func NewCryptProto(proto IProto, key []byte) (*CryptProto, error) {
+	p := &CryptProto{
+		proto:   proto,
+		psk:     append([]byte(nil), key...),
+		maxSize: defCryptMaxSize,
+	}
+	if _, err := rand.Read(p.keyID[:]); err != nil {
+		return nil, err
+	}
+	aead, err := p.sessionAEAD(p.keyID[:])
+	if err != nil {
+		return nil, err
+	}
+	p.aead = aead
+	return p, nil
+}
+
+// sessionAEAD 按key ID派生一个方向的会话密钥
+func (p *CryptProto) sessionAEAD(keyID []byte) (cipher.AEAD, error) {
+	if _, err := aes.NewCipher(p.psk); err != nil {
+		return nil, err
+	}
+	key := make([]byte, len(p.psk))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, p.psk, keyID, cryptInfo), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// CryptFactory 每个连接使用独立的加密层
+func CryptFactory(factory ProtoFactory, key []byte) (ProtoFactory, error) {
+	// 提前检查密钥
+	if _, err := NewCryptProto(nil, key); err != nil {
+		return nil, err
+	}
+	key = append([]byte(nil), key...)
+	return func(conn *Connection) IProto {
+		p, _ := NewCryptProto(factory(conn), key)
+		return p
+	}, nil
+}
+
+// SetMaxSize 修改允许接收的最大帧，默认64M
+func (p *CryptProto) SetMaxSize(size int) {
+	p.maxSize = size
+}
+
+func (p *CryptProto) FilterAccept(conn *Connection) bool {
+	return p.proto == nil || p.proto.FilterAccept(conn)
+}
+
+func (p *CryptProto) HeadLen() uint32 {
+	return cryptHeadLen
+}
+
+func (p *CryptProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	size := binary.BigEndian.Uint32(head)
+	if size < uint32(cryptKeyIDLen+cryptSeqLen+p.aead.Overhead()) {
+		return nil, 0, fmt.Errorf("crypt frame too short, size = %d", size)
+	}
+	if uint64(size) > uint64(p.maxSize) {
+		return nil, 0, fmt.Errorf("crypt frame too large, size = %d", size)
+	}
+	return append([]byte(nil), head...), size, nil
+}
+
+func (p *CryptProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	keyID, seq := body[:cryptKeyIDLen], body[cryptKeyIDLen:cryptKeyIDLen+cryptSeqLen]
+	sealed := body[cryptKeyIDLen+cryptSeqLen:]
+	aead, err := p.peerSession(keyID)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [cryptNonceLen]byte
+	copy(nonce[cryptNonceLen-cryptSeqLen:], seq)
+	ad := append(append([]byte(nil), head.([]byte)...), keyID...)
+	msg, err := aead.Open(nil, nonce[:], sealed, ad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt failed, err = %s", err)
+	}
+	if err := p.checkSeq(keyID, aead, binary.BigEndian.Uint64(seq)); err != nil {
+		return nil, err
+	}
+	return parseWrapped(p.proto, msg)
+}
+
+// peerSession 对端的会话密钥，第一帧认证通过前不保存
+func (p *CryptProto) peerSession(keyID []byte) (cipher.AEAD, error) {
+	p.peerLock.Lock()
+	defer p.peerLock.Unlock()
+
+	if p.peerAEAD != nil {
+		if string(p.peerKeyID[:]) != string(keyID) {
+			return nil, ErrCryptReplay
+		}
+		return p.peerAEAD, nil
+	}
+	return p.sessionAEAD(keyID)
+}
+
+// checkSeq 认证通过后检查序号，防止重放。并发发送时帧的顺序和序号可能不一致，
+// 使用64个序号的滑动窗口
+func (p *CryptProto) checkSeq(keyID []byte, aead cipher.AEAD, seq uint64) error {
+	p.peerLock.Lock()
+	defer p.peerLock.Unlock()
+
+	if p.peerAEAD == nil {
+		copy(p.peerKeyID[:], keyID)
+		p.peerAEAD = aead
+		p.peerSeq, p.peerWindow = seq, 1
+		return nil
+	}
+	if string(p.peerKeyID[:]) != string(keyID) {
+		return ErrCryptReplay
+	}
+	switch {
+	case seq > p.peerSeq:
+		if shift := seq - p.peerSeq; shift < cryptWindow {
+			p.peerWindow = p.peerWindow<<shift | 1
+		} else {
+			p.peerWindow = 1
+		}
+		p.peerSeq = seq
+	case p.peerSeq-seq >= cryptWindow:
+		return ErrCryptReplay
+	default:
+		bit := uint64(1) << (p.peerSeq - seq)
+		if p.peerWindow&bit != 0 {
+			return ErrCryptReplay
+		}
+		p.peerWindow |= bit
+	}
+	return nil
+}
+
+func (p *CryptProto) Serialize(data interface{}) ([]byte, error) {
+	msg, err := serializeWrapped(p.proto, data)
+	if err != nil {
+		return nil, err
+	}
+	const prefix = cryptHeadLen + cryptKeyIDLen + cryptSeqLen
+	size := cryptKeyIDLen + cryptSeqLen + len(msg) + p.aead.Overhead()
+	frame := make([]byte, prefix, cryptHeadLen+size)
+	binary.BigEndian.PutUint32(frame, uint32(size))
+	copy(frame[cryptHeadLen:], p.keyID[:])
+
+	p.lock.Lock()
+	p.seq++
+	seq := p.seq
+	p.lock.Unlock()
+
+	binary.BigEndian.PutUint64(frame[cryptHeadLen+cryptKeyIDLen:], seq)
+	var nonce [cryptNonceLen]byte
+	binary.BigEndian.PutUint64(nonce[cryptNonceLen-cryptSeqLen:], seq)
+	return p.aead.Seal(frame, nonce[:], msg, frame[:cryptHeadLen+cryptKeyIDLen]), nil
+}
@@ -0,0 +1,117 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// parseFrame 按HeadLen/BodyLen/Parse解析一个完整的帧
+func parseFrame(p *CryptProto, frame []byte) (interface{}, error) {
+	head, size, err := p.BodyLen(frame[:p.HeadLen()])
+	if err != nil {
+		return nil, err
+	}
+	if int(size) != len(frame)-int(p.HeadLen()) {
+		return nil, errors.New("bad frame size")
+	}
+	return p.Parse(head, frame[p.HeadLen():])
+}
+
+func newCryptPair(t *testing.T, key []byte) (*CryptProto, *CryptProto) {
+	t.Helper()
+	a, err := NewCryptProto(nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewCryptProto(nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	a, b := newCryptPair(t, key)
+	for _, msg := range []string{"hello", "", "world"} {
+		frame, err := a.Serialize([]byte(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseFrame(b, frame)
+		if err != nil || string(got.([]byte)) != msg {
+			t.Fatalf("got %q, err = %v", got, err)
+		}
+	}
+}
+
+func TestCryptReplay(t *testing.T) {
+	key := bytes.Repeat([]byte{2}, 16)
+	a, b := newCryptPair(t, key)
+	frame, _ := a.Serialize([]byte("x"))
+	if _, err := parseFrame(b, frame); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseFrame(b, frame); !errors.Is(err, ErrCryptReplay) {
+		t.Fatalf("replay err = %v", err)
+	}
+
+	// 其他实例(连接)的帧使用不同的key ID
+	other, _ := NewCryptProto(nil, key)
+	frame, _ = other.Serialize([]byte("x"))
+	if _, err := parseFrame(b, frame); !errors.Is(err, ErrCryptReplay) {
+		t.Fatalf("other session err = %v", err)
+	}
+
+	// 乱序在窗口内可以接受，超出窗口拒绝
+	var frames [][]byte
+	for i := 0; i < cryptWindow+2; i++ {
+		f, _ := a.Serialize([]byte("y"))
+		frames = append(frames, f)
+	}
+	if _, err := parseFrame(b, frames[len(frames)-1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseFrame(b, frames[len(frames)-2]); err != nil {
+		t.Fatalf("reordered err = %v", err)
+	}
+	if _, err := parseFrame(b, frames[0]); !errors.Is(err, ErrCryptReplay) {
+		t.Fatalf("out of window err = %v", err)
+	}
+}
+
+// TestCryptSessionKeys 相同PSK的两个实例使用不同的会话密钥，相同序号的密文不同
+func TestCryptSessionKeys(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	a, b := newCryptPair(t, key)
+	fa, _ := a.Serialize([]byte("same"))
+	fb, _ := b.Serialize([]byte("same"))
+	prefix := cryptHeadLen + cryptKeyIDLen + cryptSeqLen
+	if bytes.Equal(fa[prefix:], fb[prefix:]) {
+		t.Fatal("same ciphertext for different sessions")
+	}
+}
+
+func TestCryptReject(t *testing.T) {
+	a, _ := newCryptPair(t, bytes.Repeat([]byte{4}, 32))
+	wrong, _ := NewCryptProto(nil, bytes.Repeat([]byte{5}, 32))
+	frame, _ := a.Serialize([]byte("secret"))
+
+	if _, err := parseFrame(wrong, frame); err == nil {
+		t.Fatal("wrong key accepted")
+	}
+	tampered := append([]byte(nil), frame...)
+	tampered[len(tampered)-1] ^= 1
+	receiver, _ := NewCryptProto(nil, bytes.Repeat([]byte{4}, 32))
+	if _, err := parseFrame(receiver, tampered); err == nil {
+		t.Fatal("tampered frame accepted")
+	}
+	// 认证失败的帧不会确定对端的key ID
+	if _, err := parseFrame(receiver, frame); err != nil {
+		t.Fatalf("valid frame after tampered err = %v", err)
+	}
+	if _, err := NewCryptProto(nil, []byte("short")); err == nil {
+		t.Fatal("bad key accepted")
+	}
+}
@@ -0,0 +1,114 @@
+package net
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/buf1024/golib/ttlmap"
+)
+
+// MessageIDer proto的可选扩展，返回解析后报文的ID，用于接收端去重。ID只需要在同一个发送端内唯一
+type MessageIDer interface {
+	MessageID(data interface{}) (string, bool)
+}
+
+// PeerIDer MessageIDer的可选扩展，返回报文发送端的ID(如客户端ID)，发送端重连后不变。
+// 监听共享的Deduper按发送端区分报文ID
+type PeerIDer interface {
+	PeerID(data interface{}) (string, bool)
+}
+
+// Deduper 去重器，Seen记录key并返回之前是否已经出现过
+type Deduper interface {
+	Seen(key string) bool
+}
+
+// Dedup 基于ttlmap的去重器，ID在ttl内重复出现视为重复报文
+type Dedup struct {
+	ids *ttlmap.Map
+	ttl time.Duration
+}
+
+// NewDedup 创建去重器，maxKeys限制记录的ID个数，<=0不限制
+func NewDedup(ttl time.Duration, maxKeys int) *Dedup {
+	return &Dedup{
+		ids: ttlmap.New(maxKeys),
+		ttl: ttl,
+	}
+}
+
+func (d *Dedup) Seen(key string) bool {
+	return !d.ids.SetNX(key, nil, d.ttl)
+}
+
+type dedupHolder struct {
+	d Deduper
+}
+
+// SetDedup 给监听下的连接开启接收去重，proto需要实现MessageIDer，d为nil时关闭。
+// 多个连接共享同一个Deduper，key按发送端区分：proto实现了PeerIDer时用PeerID，
+// 否则用会话的Identity，都没有时只在连接内去重。有PeerID或者会话时发送端重连后重发的报文也能去掉
+func (l *Listener) SetDedup(d Deduper) {
+	setDedup(&l.dedup, d)
+}
+
+// SetDedup 给连接开启接收去重，优先于监听的设置
+func (c *Connection) SetDedup(d Deduper) {
+	setDedup(&c.dedup, d)
+}
+
+// Duplicates 连接丢弃的重复报文数
+func (c *Connection) Duplicates() int64 {
+	return c.duplicates.Load()
+}
+
+func setDedup(p *atomic.Pointer[dedupHolder], d Deduper) {
+	if d == nil {
+		p.Store(nil)
+		return
+	}
+	p.Store(&dedupHolder{d: d})
+}
+
+// isDuplicate 报文是否重复
+func (c *Connection) isDuplicate(data interface{}) bool {
+	h := c.dedup.Load()
+	shared := false
+	if h == nil && c.listen != nil {
+		h = c.listen.dedup.Load()
+		shared = true
+	}
+	if h == nil {
+		return false
+	}
+	ider, ok := protoAs[MessageIDer](c.proto)
+	if !ok {
+		return false
+	}
+	id, ok := ider.MessageID(data)
+	if !ok {
+		return false
+	}
+	if shared {
+		id = c.dedupScope(data) + id
+	}
+	if !h.d.Seen(id) {
+		return false
+	}
+	c.duplicates.Add(1)
+	return true
+}
+
+// dedupScope 共享Deduper的key前缀，不同发送端相同的报文ID不会互相冲突
+func (c *Connection) dedupScope(data interface{}) string {
+	if peer, ok := protoAs[PeerIDer](c.proto); ok {
+		if id, ok := peer.PeerID(data); ok {
+			return "p" + strconv.Itoa(len(id)) + ":" + id + ":"
+		}
+	}
+	if sess := c.Session(); sess != nil {
+		return "s" + strconv.Itoa(len(sess.Identity)) + ":" + sess.Identity + ":"
+	}
+	return "c" + strconv.FormatInt(c.id, 10) + ":"
+}
@@ -0,0 +1,41 @@
+package net
+
+import (
+	"testing"
+	"time"
+)
+
+// idProto 报文本身就是ID
+type idProto struct {
+	benchProto
+}
+
+func (p *idProto) MessageID(data interface{}) (string, bool) {
+	id, ok := data.(string)
+	return id, ok
+}
+
+// TestListenerDedupScope 监听共享的Deduper不会把不同连接相同的报文ID当作重复
+func TestListenerDedupScope(t *testing.T) {
+	l := &Listener{}
+	l.SetDedup(NewDedup(time.Minute, 0))
+	a := &Connection{id: 1, proto: &idProto{}, listen: l}
+	b := &Connection{id: 2, proto: &idProto{}, listen: l}
+
+	if a.isDuplicate("1") || b.isDuplicate("1") {
+		t.Fatal("first id dropped")
+	}
+	if !a.isDuplicate("1") || !b.isDuplicate("1") {
+		t.Fatal("duplicate not dropped")
+	}
+
+	// 同一个身份的会话重连后仍然去重
+	sess := &Session{ID: "s", Identity: "alice"}
+	c := &Connection{id: 3, proto: &idProto{}, listen: l}
+	d := &Connection{id: 4, proto: &idProto{}, listen: l}
+	c.session.session.Store(sess)
+	d.session.session.Store(sess)
+	if c.isDuplicate("1") || !d.isDuplicate("1") {
+		t.Fatal("session scope")
+	}
+}
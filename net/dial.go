@@ -0,0 +1,128 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// SetDialTimeout 设置Connect系列的连接超时，0表示不超时(由系统决定)
+func (n *SimpleNet) SetDialTimeout(timeout time.Duration) {
+	n.dialTimeout.Store(int64(timeout))
+}
+
+// DialTimeout 连接超时
+func (n *SimpleNet) DialTimeout() time.Duration {
+	return time.Duration(n.dialTimeout.Load())
+}
+
+// Dialer 建立底层连接，可以用于走代理、绑定本地网卡或者注入测试用的传输层
+type Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SetDialer 设置Connect系列(包括TLS和自动重连)使用的Dialer，nil恢复默认。
+// 连接超时通过ctx传给Dialer
+func (n *SimpleNet) SetDialer(d Dialer) {
+	if d == nil {
+		n.dialFunc.Store(nil)
+		return
+	}
+	n.dialFunc.Store(&d)
+}
+
+// ConnectDialer 使用指定的Dialer连接服务器，不影响其他连接
+func (n *SimpleNet) ConnectDialer(ctx context.Context, d Dialer, network, addr string, proto IProto) (*Connection, error) {
+	ctx, cancel := n.withDialTimeout(ctx)
+	defer cancel()
+
+	newconn, err := d(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	n.applyOptions(nil, newconn)
+	return n.AttachConn(newconn, proto)
+}
+
+func (n *SimpleNet) withDialTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := n.DialTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// dialRaw 使用设置的Dialer或者net.Dialer连接
+func (n *SimpleNet) dialRaw(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d := n.dialFunc.Load(); d != nil {
+		return (*d)(ctx, network, addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// dial 使用连接超时连接，ctx取消时中止连接
+func (n *SimpleNet) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx, cancel := n.withDialTimeout(ctx)
+	defer cancel()
+
+	conn, err := n.dialRaw(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	n.applyOptions(nil, conn)
+	return conn, nil
+}
+
+// dialTLS 使用连接超时连接并完成TLS握手，连接超时包括握手
+func (n *SimpleNet) dialTLS(ctx context.Context, addr string, config *tls.Config) (net.Conn, error) {
+	ctx, cancel := n.withDialTimeout(ctx)
+	defer cancel()
+
+	raw, err := n.dialRaw(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	conn := tls.Client(raw, config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	n.applyOptions(nil, conn)
+	return conn, nil
+}
+
+// ConnectContext 连接服务器，ctx取消时中止正在进行的连接，
+// 连接建立后ctx取消则关闭连接(停止读写)
+func (n *SimpleNet) ConnectContext(ctx context.Context, addr string, proto IProto) (*Connection, error) {
+	newconn, _, err := n.dialEndpoint(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := n.AttachConn(newconn, proto)
+	if err != nil {
+		return nil, err
+	}
+	n.bindContext(ctx, conn)
+	return conn, nil
+}
+
+// bindContext ctx取消时关闭连接，连接先关闭则解除绑定
+func (n *SimpleNet) bindContext(ctx context.Context, conn *Connection) {
+	stop := context.AfterFunc(ctx, func() {
+		n.CloseConn(conn)
+	})
+	context.AfterFunc(conn.ctx, func() {
+		stop()
+	})
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulResolver 基于consul健康检查的服务发现，只返回检查通过的实例
+type ConsulResolver struct {
+	client *api.Client
+	tag    string
+}
+
+// NewConsulResolver 创建consul服务发现，tag不为空时只返回带有tag的实例
+func NewConsulResolver(client *api.Client, tag string) *ConsulResolver {
+	return &ConsulResolver{
+		client: client,
+		tag:    tag,
+	}
+}
+
+func (r *ConsulResolver) Resolve(ctx context.Context, service string) ([]string, error) {
+	endpoints, _, err := r.query(ctx, service, 0)
+	return endpoints, err
+}
+
+// query waitIndex不为0时为阻塞查询，等到服务有变化或者超时才返回
+func (r *ConsulResolver) query(ctx context.Context, service string, waitIndex uint64) ([]string, uint64, error) {
+	opts := (&api.QueryOptions{WaitIndex: waitIndex}).WithContext(ctx)
+	entries, meta, err := r.client.Health().Service(service, r.tag, true, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	endpoints := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints = append(endpoints, net.JoinHostPort(host, fmt.Sprint(entry.Service.Port)))
+	}
+	return endpoints, meta.LastIndex, nil
+}
+
+func (r *ConsulResolver) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	endpoints, index, err := r.query(ctx, service, 0)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []string, 1)
+	ch <- endpoints
+	go r.watch(ctx, service, endpoints, index, ch)
+	return ch, nil
+}
+
+func (r *ConsulResolver) watch(ctx context.Context, service string, last []string, index uint64, ch chan<- []string) {
+	defer close(ch)
+
+	for ctx.Err() == nil {
+		endpoints, cur, err := r.query(ctx, service, index)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		// index回退时(如consul重启)重新开始
+		if cur < index {
+			cur = 0
+		}
+		index = cur
+		if !send(ctx, ch, last, endpoints) {
+			return
+		}
+		last = endpoints
+	}
+}
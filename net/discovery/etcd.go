@@ -0,0 +1,123 @@
+// Package discovery net.Resolver的etcd和consul实现，
+// 通过SimpleNet.SetResolver注册后可以用"scheme://服务名"连接
+package discovery
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const retryDelay = time.Second
+
+// EtcdResolver 基于etcd的服务发现，服务实例注册为prefix/服务名/实例ID，值为地址(host:port)
+type EtcdResolver struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// NewEtcdResolver 创建etcd服务发现
+func NewEtcdResolver(client *clientv3.Client, prefix string) *EtcdResolver {
+	return &EtcdResolver{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (r *EtcdResolver) key(service string) string {
+	return r.prefix + "/" + service + "/"
+}
+
+func (r *EtcdResolver) Resolve(ctx context.Context, service string) ([]string, error) {
+	endpoints, _, err := r.get(ctx, service)
+	return endpoints, err
+}
+
+func (r *EtcdResolver) get(ctx context.Context, service string) ([]string, int64, error) {
+	rsp, err := r.client.Get(ctx, r.key(service), clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+	endpoints := make([]string, 0, len(rsp.Kvs))
+	for _, kv := range rsp.Kvs {
+		endpoints = append(endpoints, string(kv.Value))
+	}
+	return endpoints, rsp.Header.Revision, nil
+}
+
+func (r *EtcdResolver) Watch(ctx context.Context, service string) (<-chan []string, error) {
+	endpoints, rev, err := r.get(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []string, 1)
+	ch <- endpoints
+	go r.watch(ctx, service, endpoints, rev, ch)
+	return ch, nil
+}
+
+// watch 有变化时重新读取地址列表，watch中断时重新读取后从新的revision继续
+func (r *EtcdResolver) watch(ctx context.Context, service string, last []string, rev int64, ch chan<- []string) {
+	defer close(ch)
+
+	for ctx.Err() == nil {
+		if !r.watchOnce(ctx, service, &last, &rev, ch) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+		endpoints, cur, err := r.get(ctx, service)
+		if err != nil {
+			continue
+		}
+		rev = cur
+		if !send(ctx, ch, last, endpoints) {
+			return
+		}
+		last = endpoints
+	}
+}
+
+// watchOnce watch直到出错，ctx结束时返回false
+func (r *EtcdResolver) watchOnce(ctx context.Context, service string, last *[]string, rev *int64, ch chan<- []string) bool {
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	wch := r.client.Watch(wctx, r.key(service), clientv3.WithPrefix(), clientv3.WithRev(*rev+1))
+	for wrsp := range wch {
+		if wrsp.Err() != nil {
+			return true
+		}
+		endpoints, cur, err := r.get(ctx, service)
+		if err != nil {
+			return true
+		}
+		*rev = cur
+		if !send(ctx, ch, *last, endpoints) {
+			return false
+		}
+		*last = endpoints
+	}
+	return ctx.Err() == nil
+}
+
+// send 地址列表有变化时发送，ctx结束时返回false
+func send(ctx context.Context, ch chan<- []string, last, endpoints []string) bool {
+	a, b := slices.Clone(last), slices.Clone(endpoints)
+	slices.Sort(a)
+	slices.Sort(b)
+	if slices.Equal(a, b) {
+		return true
+	}
+	select {
+	case ch <- endpoints:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,357 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// RR类型
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+	TypeSRV   uint16 = 33
+	TypeOPT   uint16 = 41
+	TypeANY   uint16 = 255
+
+	ClassINET uint16 = 1
+)
+
+// 响应码
+const (
+	RcodeSuccess        = 0
+	RcodeFormatError    = 1
+	RcodeServerFailure  = 2
+	RcodeNameError      = 3
+	RcodeNotImplemented = 4
+	RcodeRefused        = 5
+)
+
+const (
+	headerLen  = 12
+	maxPointer = 0x3fff
+	maxNameLen = 255
+)
+
+// Header 报文头
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	Rcode              uint8
+}
+
+func (h *Header) flags() uint16 {
+	f := uint16(h.Opcode&0xf)<<11 | uint16(h.Rcode&0xf)
+	if h.Response {
+		f |= 1 << 15
+	}
+	if h.Authoritative {
+		f |= 1 << 10
+	}
+	if h.Truncated {
+		f |= 1 << 9
+	}
+	if h.RecursionDesired {
+		f |= 1 << 8
+	}
+	if h.RecursionAvailable {
+		f |= 1 << 7
+	}
+	return f
+}
+
+func (h *Header) setFlags(f uint16) {
+	h.Response = f&(1<<15) != 0
+	h.Opcode = uint8(f>>11) & 0xf
+	h.Authoritative = f&(1<<10) != 0
+	h.Truncated = f&(1<<9) != 0
+	h.RecursionDesired = f&(1<<8) != 0
+	h.RecursionAvailable = f&(1<<7) != 0
+	h.Rcode = uint8(f & 0xf)
+}
+
+// Question 问题
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// RR 资源记录，Data为rdata的原始数据，
+// 解包时rdata中的压缩域名会被展开，所以Data不依赖原报文
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// Message DNS报文
+type Message struct {
+	Header
+	Questions  []Question
+	Answers    []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// Reply 根据请求创建响应
+func (m *Message) Reply() *Message {
+	r := &Message{
+		Header: Header{
+			ID:               m.ID,
+			Response:         true,
+			Opcode:           m.Opcode,
+			RecursionDesired: m.RecursionDesired,
+		},
+	}
+	r.Questions = append(r.Questions, m.Questions...)
+	return r
+}
+
+// Pack 编码，域名使用压缩
+func (m *Message) Pack() ([]byte, error) {
+	return m.AppendPack(make([]byte, 0, 512))
+}
+
+// AppendPack 编码追加到buf，压缩指针相对于追加的起始位置
+func (m *Message) AppendPack(buf []byte) ([]byte, error) {
+	if len(m.Questions) > 0xffff || len(m.Answers) > 0xffff ||
+		len(m.Authority) > 0xffff || len(m.Additional) > 0xffff {
+		return nil, fmt.Errorf("too many records")
+	}
+	base := len(buf)
+	buf = binary.BigEndian.AppendUint16(buf, m.ID)
+	buf = binary.BigEndian.AppendUint16(buf, m.flags())
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(m.Questions)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(m.Answers)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(m.Authority)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(m.Additional)))
+
+	comp := make(map[string]int)
+	var err error
+	for _, q := range m.Questions {
+		if buf, err = packName(buf, base, q.Name, comp); err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint16(buf, q.Type)
+		buf = binary.BigEndian.AppendUint16(buf, q.Class)
+	}
+	for _, section := range [][]RR{m.Answers, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if len(rr.Data) > 0xffff {
+				return nil, fmt.Errorf("rdata too long")
+			}
+			if buf, err = packName(buf, base, rr.Name, comp); err != nil {
+				return nil, err
+			}
+			buf = binary.BigEndian.AppendUint16(buf, rr.Type)
+			buf = binary.BigEndian.AppendUint16(buf, rr.Class)
+			buf = binary.BigEndian.AppendUint32(buf, rr.TTL)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(rr.Data)))
+			buf = append(buf, rr.Data...)
+		}
+	}
+	return buf, nil
+}
+
+// packName 编码域名，comp非空时使用/记录压缩指针
+func packName(buf []byte, base int, name string, comp map[string]int) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > maxNameLen {
+		return nil, fmt.Errorf("name %s too long", name)
+	}
+	for name != "" {
+		if comp != nil {
+			if off, ok := comp[strings.ToLower(name)]; ok {
+				return binary.BigEndian.AppendUint16(buf, uint16(0xc000|off)), nil
+			}
+			if off := len(buf) - base; off <= maxPointer {
+				comp[strings.ToLower(name)] = off
+			}
+		}
+		label := name
+		rest := ""
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			label, rest = name[:i], name[i+1:]
+		}
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid label in %s", name)
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+		name = rest
+	}
+	return append(buf, 0), nil
+}
+
+// unpackName 解码msg中off处的域名，返回域名(以.结尾)和之后的偏移
+func unpackName(msg []byte, off int) (string, int, error) {
+	var b strings.Builder
+	next := -1
+	jumps := 0
+	for {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("name out of range")
+		}
+		c := int(msg[off])
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				off++
+				if next < 0 {
+					next = off
+				}
+				if b.Len() == 0 {
+					return ".", next, nil
+				}
+				return b.String(), next, nil
+			}
+			if off+1+c > len(msg) {
+				return "", 0, fmt.Errorf("label out of range")
+			}
+			b.Write(msg[off+1 : off+1+c])
+			b.WriteByte('.')
+			if b.Len() > maxNameLen+1 {
+				return "", 0, fmt.Errorf("name too long")
+			}
+			off += 1 + c
+		case 0xc0:
+			if off+2 > len(msg) {
+				return "", 0, fmt.Errorf("pointer out of range")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			jumps++
+			if jumps > 64 {
+				return "", 0, fmt.Errorf("too many compression pointers")
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & maxPointer)
+		default:
+			return "", 0, fmt.Errorf("invalid label type 0x%x", c)
+		}
+	}
+}
+
+// Unpack 解码报文
+func Unpack(msg []byte) (*Message, error) {
+	if len(msg) < headerLen {
+		return nil, fmt.Errorf("message too short")
+	}
+	m := &Message{}
+	m.ID = binary.BigEndian.Uint16(msg)
+	m.setFlags(binary.BigEndian.Uint16(msg[2:]))
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	an := int(binary.BigEndian.Uint16(msg[6:]))
+	ns := int(binary.BigEndian.Uint16(msg[8:]))
+	ar := int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := headerLen
+	for i := 0; i < qd; i++ {
+		name, n, err := unpackName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if n+4 > len(msg) {
+			return nil, fmt.Errorf("question out of range")
+		}
+		m.Questions = append(m.Questions, Question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(msg[n:]),
+			Class: binary.BigEndian.Uint16(msg[n+2:]),
+		})
+		off = n + 4
+	}
+
+	var err error
+	if m.Answers, off, err = unpackRRs(msg, off, an); err != nil {
+		return nil, err
+	}
+	if m.Authority, off, err = unpackRRs(msg, off, ns); err != nil {
+		return nil, err
+	}
+	if m.Additional, _, err = unpackRRs(msg, off, ar); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func unpackRRs(msg []byte, off int, count int) ([]RR, int, error) {
+	var rrs []RR
+	for i := 0; i < count; i++ {
+		name, n, err := unpackName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n+10 > len(msg) {
+			return nil, 0, fmt.Errorf("rr out of range")
+		}
+		rr := RR{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(msg[n:]),
+			Class: binary.BigEndian.Uint16(msg[n+2:]),
+			TTL:   binary.BigEndian.Uint32(msg[n+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(msg[n+8:]))
+		start := n + 10
+		end := start + length
+		if end > len(msg) {
+			return nil, 0, fmt.Errorf("rdata out of range")
+		}
+		if rr.Data, err = expandRData(msg, rr.Type, start, end); err != nil {
+			return nil, 0, err
+		}
+		rrs = append(rrs, rr)
+		off = end
+	}
+	return rrs, off, nil
+}
+
+// expandRData 展开rdata中的压缩域名
+func expandRData(msg []byte, typ uint16, start, end int) ([]byte, error) {
+	var (
+		prefix int // 域名之前的定长部分
+		names  int // 域名个数
+	)
+	switch typ {
+	case TypeNS, TypeCNAME, TypePTR:
+		names = 1
+	case TypeMX:
+		prefix, names = 2, 1
+	case TypeSRV:
+		prefix, names = 6, 1
+	case TypeSOA:
+		names = 2
+	default:
+		return append([]byte(nil), msg[start:end]...), nil
+	}
+	if start+prefix > end {
+		return nil, fmt.Errorf("rdata too short")
+	}
+	data := append([]byte(nil), msg[start:start+prefix]...)
+	off := start + prefix
+	for i := 0; i < names; i++ {
+		name, n, err := unpackName(msg[:end], off)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = packName(data, 0, name, nil); err != nil {
+			return nil, err
+		}
+		off = n
+	}
+	return append(data, msg[off:end]...), nil
+}
@@ -0,0 +1,62 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	q := &Message{
+		Header: Header{ID: 0x1234, RecursionDesired: true},
+		Questions: []Question{
+			{Name: "www.example.com.", Type: TypeA, Class: ClassINET},
+		},
+	}
+	r := q.Reply()
+	r.Authoritative = true
+	a, _ := NewA("www.example.com", 60, net.ParseIP("10.0.0.1"))
+	cname, _ := NewName("alias.example.com", TypeCNAME, 60, "www.example.com")
+	mx, _ := NewMX("example.com", 300, 10, "mail.example.com")
+	txt, _ := NewTXT("example.com", 300, "v=spf1", "-all")
+	r.Answers = []RR{a, cname}
+	r.Additional = []RR{mx, txt}
+
+	buf, err := r.Pack()
+	if err != nil {
+		t.Fatalf("pack failed, err = %s", err)
+	}
+	m, err := Unpack(buf)
+	if err != nil {
+		t.Fatalf("unpack failed, err = %s", err)
+	}
+	if m.ID != 0x1234 || !m.Response || !m.RecursionDesired || !m.Authoritative {
+		t.Fatalf("header not right: %+v", m.Header)
+	}
+	if len(m.Questions) != 1 || m.Questions[0].Name != "www.example.com." {
+		t.Fatalf("question not right: %+v", m.Questions)
+	}
+	if len(m.Answers) != 2 || !m.Answers[0].IP().Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("answer not right: %+v", m.Answers)
+	}
+	if target, _ := m.Answers[1].Target(); target != "www.example.com." {
+		t.Fatalf("cname target = %s", target)
+	}
+	if target, _ := m.Additional[0].Target(); target != "mail.example.com." {
+		t.Fatalf("mx target = %s", target)
+	}
+	if s, _ := m.Additional[1].TXT(); len(s) != 2 || s[1] != "-all" {
+		t.Fatalf("txt = %v", s)
+	}
+
+	// 不压缩时长度为187
+	if len(buf) >= 187 {
+		t.Fatalf("name compression not applied, len = %d", len(buf))
+	}
+}
+
+func TestUnpackLoop(t *testing.T) {
+	msg := []byte{0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 1, 0, 1}
+	if _, err := Unpack(msg); err == nil {
+		t.Fatalf("expect pointer loop error")
+	}
+}
@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// TCPProto DNS over TCP的IProto实现，报文前为2字节大端长度，
+// SendData接受*Message或已编码的[]byte
+//
+// UDP上一个数据报即一个报文，直接使用Message.Pack/Unpack
+type TCPProto struct {
+}
+
+func (p *TCPProto) FilterAccept(conn *mynet.Connection) bool {
+	return true
+}
+
+func (p *TCPProto) HeadLen() uint32 {
+	return 2
+}
+
+func (p *TCPProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	if len(head) != 2 {
+		return nil, 0, fmt.Errorf("head size not right")
+	}
+	length := binary.BigEndian.Uint16(head)
+	if length < headerLen {
+		return nil, 0, fmt.Errorf("message too short")
+	}
+	return nil, uint32(length), nil
+}
+
+func (p *TCPProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return Unpack(body)
+}
+
+func (p *TCPProto) Serialize(data interface{}) ([]byte, error) {
+	var msg []byte
+	switch m := data.(type) {
+	case *Message:
+		buf, err := m.AppendPack(make([]byte, 2, 514))
+		if err != nil {
+			return nil, err
+		}
+		msg = buf[2:]
+		if len(msg) > 0xffff {
+			return nil, fmt.Errorf("message too long")
+		}
+		binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+		return buf, nil
+	case []byte:
+		msg = m
+	default:
+		return nil, fmt.Errorf("unexpect data type %T", data)
+	}
+	if len(msg) > 0xffff {
+		return nil, fmt.Errorf("message too long")
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	return buf, nil
+}
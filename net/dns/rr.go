@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// NewA 创建A记录
+func NewA(name string, ttl uint32, ip net.IP) (RR, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return RR{}, fmt.Errorf("%s is not ipv4", ip)
+	}
+	return RR{Name: name, Type: TypeA, Class: ClassINET, TTL: ttl, Data: []byte(ip4)}, nil
+}
+
+// NewAAAA 创建AAAA记录
+func NewAAAA(name string, ttl uint32, ip net.IP) (RR, error) {
+	if ip.To4() != nil || ip.To16() == nil {
+		return RR{}, fmt.Errorf("%s is not ipv6", ip)
+	}
+	return RR{Name: name, Type: TypeAAAA, Class: ClassINET, TTL: ttl, Data: []byte(ip.To16())}, nil
+}
+
+// NewName 创建rdata为单个域名的记录(CNAME, NS, PTR)
+func NewName(name string, typ uint16, ttl uint32, target string) (RR, error) {
+	data, err := packName(nil, 0, target, nil)
+	if err != nil {
+		return RR{}, err
+	}
+	return RR{Name: name, Type: typ, Class: ClassINET, TTL: ttl, Data: data}, nil
+}
+
+// NewMX 创建MX记录
+func NewMX(name string, ttl uint32, pref uint16, host string) (RR, error) {
+	data := binary.BigEndian.AppendUint16(nil, pref)
+	data, err := packName(data, 0, host, nil)
+	if err != nil {
+		return RR{}, err
+	}
+	return RR{Name: name, Type: TypeMX, Class: ClassINET, TTL: ttl, Data: data}, nil
+}
+
+// NewTXT 创建TXT记录，每个字符串不超过255字节
+func NewTXT(name string, ttl uint32, txt ...string) (RR, error) {
+	var data []byte
+	for _, v := range txt {
+		if len(v) > 255 {
+			return RR{}, fmt.Errorf("txt string too long")
+		}
+		data = append(data, byte(len(v)))
+		data = append(data, v...)
+	}
+	return RR{Name: name, Type: TypeTXT, Class: ClassINET, TTL: ttl, Data: data}, nil
+}
+
+// IP A/AAAA记录的地址
+func (rr *RR) IP() net.IP {
+	if (rr.Type == TypeA && len(rr.Data) == net.IPv4len) ||
+		(rr.Type == TypeAAAA && len(rr.Data) == net.IPv6len) {
+		return net.IP(rr.Data)
+	}
+	return nil
+}
+
+// Target CNAME/NS/PTR/MX/SRV记录指向的域名
+func (rr *RR) Target() (string, error) {
+	off := 0
+	switch rr.Type {
+	case TypeNS, TypeCNAME, TypePTR:
+	case TypeMX:
+		off = 2
+	case TypeSRV:
+		off = 6
+	default:
+		return "", fmt.Errorf("type %d has no target", rr.Type)
+	}
+	if len(rr.Data) < off {
+		return "", fmt.Errorf("rdata too short")
+	}
+	name, _, err := unpackName(rr.Data, off)
+	return name, err
+}
+
+// TXT TXT记录的字符串
+func (rr *RR) TXT() ([]string, error) {
+	if rr.Type != TypeTXT {
+		return nil, fmt.Errorf("not txt record")
+	}
+	var txt []string
+	for data := rr.Data; len(data) > 0; {
+		n := int(data[0])
+		if 1+n > len(data) {
+			return nil, fmt.Errorf("txt out of range")
+		}
+		txt = append(txt, string(data[1:1+n]))
+		data = data[1+n:]
+	}
+	return txt, nil
+}
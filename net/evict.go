@@ -0,0 +1,206 @@
+package net
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// 驱逐原因，EventEvicted事件的Data
+const (
+	EvictMemory   = "memory"
+	EvictBuffered = "buffered"
+)
+
+// EvictionPolicy 内存压力下的连接驱逐策略
+type EvictionPolicy struct {
+	// MaxMemory 进程堆内存上限(字节)，0不检查
+	MaxMemory uint64
+	// MaxBuffered 所有连接发送队列中的字节数上限，0不检查
+	MaxBuffered int64
+	// Interval 检查间隔，默认1秒
+	Interval time.Duration
+	// MaxEvict 每次检查最多驱逐的连接数，默认10
+	MaxEvict int
+	// Score 连接的驱逐分数，越大越先驱逐，默认DefaultScore
+	Score func(conn *Connection) float64
+}
+
+// DefaultScore 默认驱逐分数: 空闲秒数 + 积压的KB数 - 优先级*100
+func DefaultScore(conn *Connection) float64 {
+	idle := time.Since(conn.UpdateTime()).Seconds()
+	backlog := float64(conn.Queued()) / 1024
+	return idle + backlog - float64(conn.Priority())*100
+}
+
+type evictor struct {
+	policy EvictionPolicy
+	stop   chan struct{}
+}
+
+// SetEviction 开启内存压力驱逐，超过阈值时按分数关闭连接并发出EventEvicted，
+// policy为nil时关闭
+func (n *SimpleNet) SetEviction(policy *EvictionPolicy) {
+	var e *evictor
+	if policy != nil {
+		e = &evictor{
+			policy: *policy,
+			stop:   make(chan struct{}),
+		}
+		if e.policy.Interval <= 0 {
+			e.policy.Interval = time.Second
+		}
+		if e.policy.MaxEvict <= 0 {
+			e.policy.MaxEvict = 10
+		}
+		if e.policy.Score == nil {
+			e.policy.Score = DefaultScore
+		}
+	}
+	if old := n.eviction.Swap(e); old != nil {
+		close(old.stop)
+	}
+	if e != nil {
+		go n.evicting(e)
+	}
+}
+
+func (n *SimpleNet) evicting(e *evictor) {
+	ticker := time.NewTicker(e.policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			if n.destroy.Load() {
+				return
+			}
+			n.evict(&e.policy)
+		}
+	}
+}
+
+func (n *SimpleNet) evict(policy *EvictionPolicy) {
+	reason := ""
+	if policy.MaxBuffered > 0 && n.queued.Load() > policy.MaxBuffered {
+		reason = EvictBuffered
+	}
+	if reason == "" && policy.MaxMemory > 0 {
+		var stat runtime.MemStats
+		runtime.ReadMemStats(&stat)
+		if stat.HeapAlloc > policy.MaxMemory {
+			reason = EvictMemory
+		}
+	}
+	if reason == "" {
+		return
+	}
+
+	type scored struct {
+		conn  *Connection
+		score float64
+	}
+	conns := n.allConns()
+	list := make([]scored, 0, len(conns))
+	for _, conn := range conns {
+		list = append(list, scored{conn: conn, score: policy.Score(conn)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].score > list[j].score })
+
+	for i := 0; i < len(list) && i < policy.MaxEvict; i++ {
+		conn := list[i].conn
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("evict connection, reason = %s, remoteAddr = %s\n",
+				reason, conn.RemoteAddress()))
+		n.CloseConn(conn)
+		n.emit(&ConnEvent{
+			EventType: EventEvicted,
+			Conn:      conn,
+			Data:      reason,
+		})
+		// 队列积压在关闭连接时释放，可以提前判断是否已经足够
+		if reason == EvictBuffered && n.queued.Load() <= policy.MaxBuffered {
+			break
+		}
+	}
+}
+
+// allConns 所有连接的快照
+func (n *SimpleNet) allConns() []*Connection {
+	n.lockClient.Lock()
+	conns := append([]*Connection(nil), n.connClient...)
+	n.lockClient.Unlock()
+
+	n.lockServer.Lock()
+	listens := append([]*Listener(nil), n.connServer...)
+	n.lockServer.Unlock()
+
+	for _, l := range listens {
+		l.lockClient.Lock()
+		conns = append(conns, l.conns...)
+		l.lockClient.Unlock()
+	}
+	return conns
+}
+
+// SetPriority 设置连接的优先级，优先级高的连接较晚被驱逐
+func (c *Connection) SetPriority(priority int) {
+	c.priority.Store(int32(priority))
+}
+
+func (c *Connection) Priority() int {
+	return int(c.priority.Load())
+}
+
+// Queued 发送队列中等待发送的字节数
+func (c *Connection) Queued() int64 {
+	if v := c.queued.Load(); v > 0 {
+		return v
+	}
+	return 0
+}
+
+// Buffered 所有连接发送队列中等待发送的字节数
+func (n *SimpleNet) Buffered() int64 {
+	if v := n.queued.Load(); v > 0 {
+		return v
+	}
+	return 0
+}
+
+func (c *Connection) addQueued(size int) {
+	c.queued.Add(int64(size))
+	c.net.queued.Add(int64(size))
+}
+
+// closed 连接关闭后的清理
+func (c *Connection) closed() {
+	c.cancel(ErrConnClosed)
+	c.failAcks()
+	c.failCalls()
+	c.closeSession()
+	// 队列中没有发出去的数据被丢弃
+	c.drainQueue()
+	c.net.groups.leaveAll(c)
+	c.net.topics.leaveAll(c)
+	c.net.users.leaveAll(c)
+	c.notifyState(StatusConnected, StatusBroken)
+	c.leaveReactor()
+}
+
+// drainQueue 丢弃队列中没有发出去的数据并扣除计数，连接关闭后入队的发送方也会调用
+func (c *Connection) drainQueue() {
+	for {
+		select {
+		case req := <-c.msgChan:
+			c.addQueued(-req.size)
+			req.finish(ErrConnClosed)
+		default:
+			return
+		}
+	}
+}
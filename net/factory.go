@@ -0,0 +1,43 @@
+package net
+
+import "context"
+
+// ProtoFactory 为每个连接创建独立的proto实例，
+// 用于保存了每个连接状态的编解码(如压缩字典、序号)
+type ProtoFactory func(conn *Connection) IProto
+
+// ListenFactory 监听网络，每个连接使用factory创建的proto
+func (n *SimpleNet) ListenFactory(addr string, factory ProtoFactory) (*Listener, error) {
+	return n.listenWith(addr, listenTCP, nil, factory)
+}
+
+// ConnectFactory 连接服务器，连接使用factory创建的proto
+func (n *SimpleNet) ConnectFactory(addr string, factory ProtoFactory) (*Connection, error) {
+	newconn, _, err := n.dialEndpoint(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.attachConn(newconn, factory, nil)
+}
+
+// SetProtoFactory 修改新连接的proto工厂，已有连接不变
+func (l *Listener) SetProtoFactory(factory ProtoFactory) {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	l.factory = factory
+	l.proto = nil
+}
+
+// newProto 新连接使用的proto
+func (l *Listener) newProto(conn *Connection) IProto {
+	l.lockListen.Lock()
+	factory, proto := l.factory, l.proto
+	l.lockListen.Unlock()
+
+	if factory != nil {
+		return factory(conn)
+	}
+	return proto
+}
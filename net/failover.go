@@ -0,0 +1,52 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// ConnectFailover 主备连接，addrs[0]为主地址，其余为按顺序尝试的备用地址，
+// 每个地址的连接超时为timeout(<=0时使用SetDialTimeout的设置)。
+// 断开后的每次重连都先尝试主地址，用法同ConnectManaged，Endpoint为当前连接的地址
+func (n *SimpleNet) ConnectFailover(addrs []string, proto IProto, timeout time.Duration, policy *ReconnectPolicy) (*ManagedConnection, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	m := n.newManaged(addrs[0], func(*Connection) IProto { return proto }, policy)
+	m.fallbacks = append([]string(nil), addrs[1:]...)
+	m.attemptTimeout = timeout
+	if err := m.start(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// dialFailover 按顺序连接主地址和备用地址直到成功
+func (m *ManagedConnection) dialFailover() (net.Conn, string, error) {
+	var errs []error
+	for i, addr := range append([]string{m.addr}, m.fallbacks...) {
+		ctx, cancel := m.ctx, context.CancelFunc(func() {})
+		if m.attemptTimeout > 0 {
+			ctx, cancel = context.WithTimeout(m.ctx, m.attemptTimeout)
+		}
+		conn, endpoint, err := m.net.dialEndpoint(ctx, "tcp", addr)
+		cancel()
+		if err == nil {
+			if i > 0 {
+				m.net.logMsg(mylog.LevelWarning,
+					fmt.Sprintf("primary %s unavailable, failover to %s\n", m.addr, addr))
+			}
+			return conn, endpoint, nil
+		}
+		errs = append(errs, err)
+		if m.ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", fmt.Errorf("connect failover failed, err = %w", errors.Join(errs...))
+}
@@ -0,0 +1,65 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefMaxBodyLen 默认允许的最大报文体长度
+const DefMaxBodyLen = 64 * 1024 * 1024
+
+// ErrFrameTooLarge BodyLen返回的长度超过限制
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// SetMaxBodyLen 设置允许的最大报文体长度，BodyLen返回更大的长度时发出EventProtoError并关闭连接，
+// 防止伪造的报文头导致分配大量内存。0使用DefMaxBodyLen，<0不限制
+func (n *SimpleNet) SetMaxBodyLen(size int64) {
+	n.maxBodyLen.Store(size)
+}
+
+// MaxBodyLen 允许的最大报文体长度
+func (n *SimpleNet) MaxBodyLen() int64 {
+	if size := n.maxBodyLen.Load(); size != 0 {
+		return size
+	}
+	return DefMaxBodyLen
+}
+
+// SetMaxBodyLen 设置监听下连接允许的最大报文体长度，优先于SimpleNet的设置，0取消
+func (l *Listener) SetMaxBodyLen(size int64) {
+	l.maxBodyLen.Store(size)
+}
+
+// SetMaxBodyLen 设置连接允许的最大报文体长度，优先于监听的设置，0取消
+func (c *Connection) SetMaxBodyLen(size int64) {
+	c.maxBodyLen.Store(size)
+}
+
+// MaxBodyLen 连接生效的最大报文体长度，<0不限制
+func (c *Connection) MaxBodyLen() int64 {
+	if size := c.maxBodyLen.Load(); size != 0 {
+		return size
+	}
+	if c.listen != nil {
+		if size := c.listen.maxBodyLen.Load(); size != 0 {
+			return size
+		}
+	}
+	return c.net.MaxBodyLen()
+}
+
+// checkBodyLen 检查BodyLen返回的长度，超过限制时发出EventProtoError并关闭连接
+func (n *SimpleNet) checkBodyLen(conn *Connection, bodylen uint32) bool {
+	max := conn.MaxBodyLen()
+	if max < 0 || int64(bodylen) <= max {
+		return true
+	}
+	err := fmt.Errorf("%w, size = %d, max = %d", ErrFrameTooLarge, bodylen, max)
+	n.emit(&ConnEvent{
+		EventType: EventProtoError,
+		Conn:      conn,
+		Data:      err,
+	})
+	n.closeProtoError(conn, err)
+	return false
+}
@@ -0,0 +1,51 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestMaxBodyLen(t *testing.T) {
+	n := newTestNet(t)
+	l, err := n.Listen("127.0.0.1:0", &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetMaxBodyLen(16)
+	c := dialRaw(t, l.LocalAddress())
+
+	frame := binary.BigEndian.AppendUint32(nil, 5)
+	frame = append(frame, "hello"...)
+	// 伪造的长度，没有body
+	frame = binary.BigEndian.AppendUint32(frame, 1<<30)
+	if _, err := c.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	evt := waitEvent(t, n, EventNewConnectionData)
+	if string(evt.Data.([]byte)) != "hello" {
+		t.Fatalf("receive %q", evt.Data)
+	}
+	evt = waitEvent(t, n, EventProtoError)
+	if err, _ := evt.Data.(error); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("proto error = %v", evt.Data)
+	}
+	waitEvent(t, n, EventConnectionError)
+	if evt.Conn.Status() != StatusBroken {
+		t.Fatal("expect connection closed")
+	}
+
+	// 连接 > 监听 > SimpleNet
+	if evt.Conn.MaxBodyLen() != 16 {
+		t.Fatalf("listener max body len = %d", evt.Conn.MaxBodyLen())
+	}
+	evt.Conn.SetMaxBodyLen(-1)
+	if evt.Conn.MaxBodyLen() != -1 {
+		t.Fatalf("connection max body len = %d", evt.Conn.MaxBodyLen())
+	}
+	l.SetMaxBodyLen(0)
+	evt.Conn.SetMaxBodyLen(0)
+	if evt.Conn.MaxBodyLen() != DefMaxBodyLen {
+		t.Fatalf("default max body len = %d", evt.Conn.MaxBodyLen())
+	}
+}
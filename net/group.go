@@ -0,0 +1,134 @@
+package net
+
+import "sync"
+
+// groupRegistry 分组，连接关闭时自动离开所有分组
+type groupRegistry struct {
+	lock   sync.RWMutex
+	groups map[string]map[*Connection]struct{}
+	joined map[*Connection]map[string]struct{}
+}
+
+// JoinGroup 连接加入分组，已经在分组中时不变
+func (n *SimpleNet) JoinGroup(conn *Connection, group string) error {
+	return n.groups.join(conn, group)
+}
+
+func (g *groupRegistry) join(conn *Connection, group string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	// 在锁内检查，避免和关闭时的清理竞争
+	if conn.Status() != StatusConnected {
+		return ErrConnClosed
+	}
+	if g.groups == nil {
+		g.groups = make(map[string]map[*Connection]struct{})
+		g.joined = make(map[*Connection]map[string]struct{})
+	}
+	members, ok := g.groups[group]
+	if !ok {
+		members = make(map[*Connection]struct{})
+		g.groups[group] = members
+	}
+	members[conn] = struct{}{}
+	groups, ok := g.joined[conn]
+	if !ok {
+		groups = make(map[string]struct{})
+		g.joined[conn] = groups
+	}
+	groups[group] = struct{}{}
+	return nil
+}
+
+// LeaveGroup 连接离开分组，分组没有成员时删除
+func (n *SimpleNet) LeaveGroup(conn *Connection, group string) {
+	n.groups.remove(conn, group)
+}
+
+func (g *groupRegistry) remove(conn *Connection, group string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.leave(conn, group)
+}
+
+func (g *groupRegistry) leave(conn *Connection, group string) {
+	if members, ok := g.groups[group]; ok {
+		delete(members, conn)
+		if len(members) == 0 {
+			delete(g.groups, group)
+		}
+	}
+	if groups, ok := g.joined[conn]; ok {
+		delete(groups, group)
+		if len(groups) == 0 {
+			delete(g.joined, conn)
+		}
+	}
+}
+
+// leaveAll 连接关闭时离开所有分组
+func (g *groupRegistry) leaveAll(conn *Connection) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for group := range g.joined[conn] {
+		g.leave(conn, group)
+	}
+}
+
+// GroupMembers 分组中连接的快照
+func (n *SimpleNet) GroupMembers(group string) []*Connection {
+	return n.groups.members(group)
+}
+
+func (g *groupRegistry) members(group string) []*Connection {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	members := make([]*Connection, 0, len(g.groups[group]))
+	for conn := range g.groups[group] {
+		members = append(members, conn)
+	}
+	return members
+}
+
+// Groups 连接所在的分组
+func (n *SimpleNet) Groups(conn *Connection) []string {
+	return n.groups.of(conn)
+}
+
+func (g *groupRegistry) of(conn *Connection) []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	groups := make([]string, 0, len(g.joined[conn]))
+	for group := range g.joined[conn] {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// SendToGroup 向分组中所有连接发送data，每个连接按自己的proto序列化，
+// 返回发送失败的连接及原因
+func (n *SimpleNet) SendToGroup(group string, data interface{}) map[*Connection]error {
+	return n.SendToGroupExcept(group, nil, data)
+}
+
+// SendToGroupExcept 向分组中除except外的连接发送data
+func (n *SimpleNet) SendToGroupExcept(group string, except *Connection, data interface{}) map[*Connection]error {
+	var failed map[*Connection]error
+	for _, conn := range n.GroupMembers(group) {
+		if conn == except {
+			continue
+		}
+		if err := n.SendData(conn, data); err != nil {
+			if failed == nil {
+				failed = make(map[*Connection]error)
+			}
+			failed[conn] = err
+		}
+	}
+	return failed
+}
@@ -0,0 +1,212 @@
+package grpcproto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	mynet "github.com/buf1024/golib/net"
+	"github.com/buf1024/golib/net/h2c"
+)
+
+// gRPC 消息帧: 1字节压缩标志 + 4字节大端长度 + 消息(通常为protobuf编码)
+
+const (
+	constHeadLen = 5
+
+	defMaxMessageSize = 4 << 20
+)
+
+// gRPC 状态码
+const (
+	CodeOK                = 0
+	CodeCanceled          = 1
+	CodeUnknown           = 2
+	CodeInvalidArgument   = 3
+	CodeNotFound          = 5
+	CodeResourceExhausted = 8
+	CodeUnimplemented     = 12
+	CodeInternal          = 13
+	CodeUnavailable       = 14
+)
+
+// Message 一个gRPC消息
+type Message struct {
+	Compressed bool
+	Data       []byte
+}
+
+type head struct {
+	compressed bool
+	length     uint32
+}
+
+// Proto gRPC消息帧的IProto实现，通常用于h2c.Listen监听的stream，
+// 也可以用在任意承载gRPC帧的字节流上
+type Proto struct {
+	// MaxMessageSize 消息最大长度
+	MaxMessageSize uint32
+	// Compress 发送的消息是否gzip压缩
+	Compress bool
+}
+
+// NewProto 创建
+func NewProto() *Proto {
+	return &Proto{
+		MaxMessageSize: defMaxMessageSize,
+	}
+}
+
+// FilterAccept h2c stream需要content-type为application/grpc，否则以415结束stream
+func (p *Proto) FilterAccept(conn *mynet.Connection) bool {
+	st, ok := conn.NetConn().(*h2c.Stream)
+	if !ok {
+		return true
+	}
+	if !strings.HasPrefix(st.RequestHeader("content-type"), "application/grpc") {
+		st.SetStatus(415)
+		st.Close()
+		return false
+	}
+	st.SetHeader("content-type", "application/grpc")
+	if p.Compress {
+		st.SetHeader("grpc-encoding", "gzip")
+	}
+	return true
+}
+
+func (p *Proto) HeadLen() uint32 {
+	return constHeadLen
+}
+
+func (p *Proto) BodyLen(data []byte) (interface{}, uint32, error) {
+	if len(data) != constHeadLen {
+		return nil, 0, fmt.Errorf("head size not right")
+	}
+	h := &head{
+		compressed: data[0] == 1,
+		length:     binary.BigEndian.Uint32(data[1:]),
+	}
+	if data[0] > 1 {
+		return nil, 0, fmt.Errorf("invalid compressed flag %d", data[0])
+	}
+	if p.MaxMessageSize > 0 && h.length > p.MaxMessageSize {
+		return nil, 0, fmt.Errorf("message size %d exceeds %d", h.length, p.MaxMessageSize)
+	}
+	return h, h.length, nil
+}
+
+func (p *Proto) Parse(data interface{}, body []byte) (interface{}, error) {
+	h := data.(*head)
+	m := &Message{
+		Compressed: h.compressed,
+		Data:       body,
+	}
+	if h.compressed {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		var limit io.Reader = r
+		if p.MaxMessageSize > 0 {
+			limit = io.LimitReader(r, int64(p.MaxMessageSize)+1)
+		}
+		m.Data, err = io.ReadAll(limit)
+		if err != nil {
+			return nil, err
+		}
+		if p.MaxMessageSize > 0 && uint32(len(m.Data)) > p.MaxMessageSize {
+			return nil, fmt.Errorf("decompressed message exceeds %d", p.MaxMessageSize)
+		}
+	}
+	return m, nil
+}
+
+// Serialize data为*Message或[]byte
+func (p *Proto) Serialize(data interface{}) ([]byte, error) {
+	var payload []byte
+	switch m := data.(type) {
+	case *Message:
+		payload = m.Data
+	case []byte:
+		payload = m
+	default:
+		return nil, fmt.Errorf("unexpect data type %T", data)
+	}
+
+	flag := byte(0)
+	if p.Compress {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+		flag = 1
+	}
+
+	frame := make([]byte, constHeadLen+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[constHeadLen:], payload)
+	return frame, nil
+}
+
+// Method 请求的方法，如 /pkg.Service/Method
+func Method(conn *mynet.Connection) string {
+	if st, ok := conn.NetConn().(*h2c.Stream); ok {
+		return st.Path()
+	}
+	return ""
+}
+
+// SetStatus 设置结束stream时返回的grpc-status和grpc-message
+func SetStatus(conn *mynet.Connection, code int, msg string) error {
+	st, ok := conn.NetConn().(*h2c.Stream)
+	if !ok {
+		return fmt.Errorf("connection is not h2c stream")
+	}
+	st.SetTrailer("grpc-status", strconv.Itoa(code))
+	if msg != "" {
+		st.SetTrailer("grpc-message", msg)
+	}
+	return nil
+}
+
+// Reply 同步发送一个响应消息并以code结束stream，用于一元调用，
+// 不经过SendData的发送队列，调用前不应有尚未发送完的SendData
+func (p *Proto) Reply(conn *mynet.Connection, data interface{}, code int, msg string) error {
+	if err := SetStatus(conn, code, msg); err != nil {
+		return err
+	}
+	st := conn.NetConn()
+	if data != nil {
+		frame, err := p.Serialize(data)
+		if err != nil {
+			st.Close()
+			return err
+		}
+		if _, err = st.Write(frame); err != nil {
+			st.Close()
+			return err
+		}
+	}
+	return st.Close()
+}
+
+// Finish 以code结束stream
+func Finish(conn *mynet.Connection, code int, msg string) error {
+	if err := SetStatus(conn, code, msg); err != nil {
+		return err
+	}
+	return conn.NetConn().Close()
+}
@@ -0,0 +1,389 @@
+package h2c
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	defWindowSize   = 65535
+	streamWindow    = 1 << 20
+	maxReadFrame    = 1 << 20
+	defMaxFrameSize = 16384
+	maxWindow       = 1<<31 - 1
+)
+
+// serverConn 一个h2c的tcp连接，负责读取frame并分发到stream
+type serverConn struct {
+	l    *Listener
+	conn net.Conn
+
+	framer *http2.Framer
+
+	// 写frame和hpack编码需要串行
+	wmutex sync.Mutex
+	henc   *hpack.Encoder
+	hbuf   bytes.Buffer
+
+	mutex        sync.Mutex
+	cond         *sync.Cond
+	streams      map[uint32]*Stream
+	lastStreamID uint32
+	sendWindow   int64 // 连接级别发送窗口
+	recvWindow   int64 // 连接级别接收窗口
+	initWindow   int64 // 对端SETTINGS_INITIAL_WINDOW_SIZE
+	maxFrameSize uint32
+	closed       bool
+}
+
+func newServerConn(l *Listener, conn net.Conn, r *bufio.Reader) *serverConn {
+	sc := &serverConn{
+		l:            l,
+		conn:         conn,
+		streams:      make(map[uint32]*Stream),
+		sendWindow:   defWindowSize,
+		recvWindow:   defWindowSize,
+		initWindow:   defWindowSize,
+		maxFrameSize: defMaxFrameSize,
+	}
+	sc.cond = sync.NewCond(&sc.mutex)
+	sc.framer = http2.NewFramer(conn, r)
+	sc.framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	sc.framer.SetMaxReadFrameSize(maxReadFrame)
+	sc.henc = hpack.NewEncoder(&sc.hbuf)
+	return sc
+}
+
+func (sc *serverConn) serve() {
+	defer sc.close(fmt.Errorf("h2c connection closed"))
+
+	err := sc.writeFrame(func(f *http2.Framer) error {
+		return f.WriteSettings(
+			http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: sc.l.MaxConcurrentStreams},
+			http2.Setting{ID: http2.SettingInitialWindowSize, Val: streamWindow},
+			http2.Setting{ID: http2.SettingMaxFrameSize, Val: maxReadFrame},
+		)
+	})
+	if err != nil {
+		return
+	}
+
+	for {
+		frame, err := sc.framer.ReadFrame()
+		if err != nil {
+			if se, ok := err.(http2.StreamError); ok {
+				sc.resetStream(se.StreamID, se.Code, se)
+				continue
+			}
+			if ce, ok := err.(http2.ConnectionError); ok {
+				sc.goAway(http2.ErrCode(ce))
+			}
+			return
+		}
+		if err = sc.processFrame(frame); err != nil {
+			return
+		}
+	}
+}
+
+func (sc *serverConn) processFrame(frame http2.Frame) error {
+	switch f := frame.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			return nil
+		}
+		if err := sc.applySettings(f); err != nil {
+			return err
+		}
+		return sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteSettingsAck()
+		})
+	case *http2.MetaHeadersFrame:
+		return sc.processHeaders(f)
+	case *http2.DataFrame:
+		return sc.processData(f)
+	case *http2.WindowUpdateFrame:
+		return sc.processWindowUpdate(f)
+	case *http2.RSTStreamFrame:
+		sc.mutex.Lock()
+		st := sc.streams[f.StreamID]
+		sc.mutex.Unlock()
+		if st != nil {
+			st.abort(fmt.Errorf("stream reset by peer: %s", f.ErrCode))
+		}
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			return sc.writeFrame(func(fr *http2.Framer) error {
+				return fr.WritePing(true, f.Data)
+			})
+		}
+	case *http2.GoAwayFrame:
+		return fmt.Errorf("goaway received: %s", f.ErrCode)
+	}
+	return nil
+}
+
+func (sc *serverConn) applySettings(f *http2.SettingsFrame) error {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	return f.ForeachSetting(func(s http2.Setting) error {
+		if err := s.Valid(); err != nil {
+			return err
+		}
+		switch s.ID {
+		case http2.SettingInitialWindowSize:
+			diff := int64(s.Val) - sc.initWindow
+			sc.initWindow = int64(s.Val)
+			for _, st := range sc.streams {
+				st.sendWindow += diff
+			}
+			sc.cond.Broadcast()
+		case http2.SettingMaxFrameSize:
+			sc.maxFrameSize = s.Val
+		}
+		return nil
+	})
+}
+
+func (sc *serverConn) processHeaders(f *http2.MetaHeadersFrame) error {
+	id := f.StreamID
+
+	sc.mutex.Lock()
+	st, ok := sc.streams[id]
+	if ok {
+		// trailers
+		sc.mutex.Unlock()
+		if f.StreamEnded() {
+			st.endRemote()
+		}
+		return nil
+	}
+	if id%2 != 1 || id <= sc.lastStreamID {
+		sc.mutex.Unlock()
+		sc.goAway(http2.ErrCodeProtocol)
+		return fmt.Errorf("invalid stream id %d", id)
+	}
+	sc.lastStreamID = id
+	if uint32(len(sc.streams)) >= sc.l.MaxConcurrentStreams {
+		sc.mutex.Unlock()
+		return sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteRSTStream(id, http2.ErrCodeRefusedStream)
+		})
+	}
+	st = newStream(sc, id, f.Fields, sc.initWindow)
+	sc.streams[id] = st
+	sc.mutex.Unlock()
+
+	if f.StreamEnded() {
+		st.endRemote()
+	}
+	// 不能阻塞读取frame，accept队列满时拒绝stream，由客户端重试
+	ok, err := sc.l.offer(st)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		sc.resetStream(id, http2.ErrCodeRefusedStream, fmt.Errorf("h2c accept queue full"))
+	}
+	return nil
+}
+
+func (sc *serverConn) processData(f *http2.DataFrame) error {
+	size := f.Length
+
+	sc.mutex.Lock()
+	sc.recvWindow -= int64(size)
+	overflow := sc.recvWindow < 0
+	st := sc.streams[f.StreamID]
+	sc.mutex.Unlock()
+	if overflow {
+		sc.goAway(http2.ErrCodeFlowControl)
+		return fmt.Errorf("h2c connection flow control window exceeded")
+	}
+
+	// 连接级别的窗口收到即归还，stream级别的在应用读取后归还，
+	// 缓存的数据由stream的接收窗口限制
+	if size > 0 {
+		err := sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteWindowUpdate(0, size)
+		})
+		if err != nil {
+			return err
+		}
+		sc.mutex.Lock()
+		sc.recvWindow += int64(size)
+		sc.mutex.Unlock()
+	}
+
+	if st == nil {
+		return sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteRSTStream(f.StreamID, http2.ErrCodeStreamClosed)
+		})
+	}
+	if err := st.receive(f.Data(), size, f.StreamEnded()); err != nil {
+		se := err.(http2.StreamError)
+		sc.resetStream(se.StreamID, se.Code, se)
+	}
+	return nil
+}
+
+// processWindowUpdate 增加发送窗口，超过2^31-1时stream以RST_STREAM结束，连接以GOAWAY结束
+func (sc *serverConn) processWindowUpdate(f *http2.WindowUpdateFrame) error {
+	sc.mutex.Lock()
+	var st *Stream
+	overflow := false
+	if f.StreamID == 0 {
+		sc.sendWindow += int64(f.Increment)
+		overflow = sc.sendWindow > maxWindow
+	} else if st = sc.streams[f.StreamID]; st != nil {
+		st.sendWindow += int64(f.Increment)
+		overflow = st.sendWindow > maxWindow
+	}
+	sc.cond.Broadcast()
+	sc.mutex.Unlock()
+
+	if !overflow {
+		return nil
+	}
+	if st == nil {
+		sc.goAway(http2.ErrCodeFlowControl)
+		return fmt.Errorf("h2c connection send window overflow")
+	}
+	sc.resetStream(st.id, http2.ErrCodeFlowControl, fmt.Errorf("h2c stream %d send window overflow", st.id))
+	return nil
+}
+
+func (sc *serverConn) writeFrame(fn func(f *http2.Framer) error) error {
+	sc.wmutex.Lock()
+	defer sc.wmutex.Unlock()
+
+	return fn(sc.framer)
+}
+
+func (sc *serverConn) writeHeaders(id uint32, fields []hpack.HeaderField, endStream bool) error {
+	sc.wmutex.Lock()
+	defer sc.wmutex.Unlock()
+
+	sc.hbuf.Reset()
+	for _, v := range fields {
+		if err := sc.henc.WriteField(v); err != nil {
+			return err
+		}
+	}
+	block := sc.hbuf.Bytes()
+
+	sc.mutex.Lock()
+	max := int(sc.maxFrameSize)
+	sc.mutex.Unlock()
+
+	first := true
+	for first || len(block) > 0 {
+		chunk := block
+		if len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		block = block[len(chunk):]
+		var err error
+		if first {
+			err = sc.framer.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      id,
+				BlockFragment: chunk,
+				EndStream:     endStream,
+				EndHeaders:    len(block) == 0,
+			})
+			first = false
+		} else {
+			err = sc.framer.WriteContinuation(id, len(block) == 0, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveWindow 等待发送窗口，返回本次可以发送的字节数
+func (sc *serverConn) reserveWindow(st *Stream, want int) (int, error) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	for {
+		if sc.closed {
+			return 0, fmt.Errorf("h2c connection closed")
+		}
+		if err := st.sendErr(); err != nil {
+			return 0, err
+		}
+		avail := sc.sendWindow
+		if st.sendWindow < avail {
+			avail = st.sendWindow
+		}
+		if avail > 0 {
+			n := int64(want)
+			if n > avail {
+				n = avail
+			}
+			if n > int64(sc.maxFrameSize) {
+				n = int64(sc.maxFrameSize)
+			}
+			sc.sendWindow -= n
+			st.sendWindow -= n
+			return int(n), nil
+		}
+		sc.cond.Wait()
+	}
+}
+
+func (sc *serverConn) removeStream(id uint32) {
+	sc.mutex.Lock()
+	delete(sc.streams, id)
+	sc.mutex.Unlock()
+}
+
+func (sc *serverConn) resetStream(id uint32, code http2.ErrCode, err error) {
+	sc.mutex.Lock()
+	st := sc.streams[id]
+	sc.mutex.Unlock()
+	if st != nil {
+		st.abort(err)
+	}
+	sc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteRSTStream(id, code)
+	})
+}
+
+func (sc *serverConn) goAway(code http2.ErrCode) {
+	sc.mutex.Lock()
+	last := sc.lastStreamID
+	sc.mutex.Unlock()
+	sc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteGoAway(last, code, nil)
+	})
+}
+
+func (sc *serverConn) close(err error) {
+	sc.mutex.Lock()
+	if sc.closed {
+		sc.mutex.Unlock()
+		return
+	}
+	sc.closed = true
+	streams := make([]*Stream, 0, len(sc.streams))
+	for _, st := range sc.streams {
+		streams = append(streams, st)
+	}
+	sc.cond.Broadcast()
+	sc.mutex.Unlock()
+
+	for _, st := range streams {
+		st.abort(err)
+	}
+	sc.conn.Close()
+}
@@ -0,0 +1,223 @@
+package h2c
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// testClient 按需遵守流控的h2c客户端
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	fr   *http2.Framer
+
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	connWindow int64
+	initWindow int64
+	streamWins map[uint32]int64
+	rst        map[uint32]http2.ErrCode
+	goAway     *http2.ErrCode
+	closed     bool
+}
+
+func newTestListener(t *testing.T) *Listener {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func dialTestClient(t *testing.T, l *Listener) *testClient {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testClient{
+		t:          t,
+		conn:       conn,
+		fr:         http2.NewFramer(conn, conn),
+		connWindow: defWindowSize,
+		initWindow: defWindowSize,
+		streamWins: make(map[uint32]int64),
+		rst:        make(map[uint32]http2.ErrCode),
+	}
+	c.cond = sync.NewCond(&c.mutex)
+	c.fr.SetMaxReadFrameSize(maxReadFrame)
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.fr.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *testClient) readLoop() {
+	defer func() {
+		c.mutex.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mutex.Unlock()
+	}()
+	for {
+		frame, err := c.fr.ReadFrame()
+		if err != nil {
+			return
+		}
+		c.mutex.Lock()
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if v, ok := f.Value(http2.SettingInitialWindowSize); ok {
+				for id := range c.streamWins {
+					c.streamWins[id] += int64(v) - c.initWindow
+				}
+				c.initWindow = int64(v)
+			}
+		case *http2.WindowUpdateFrame:
+			if f.StreamID == 0 {
+				c.connWindow += int64(f.Increment)
+			} else {
+				c.streamWins[f.StreamID] += int64(f.Increment)
+			}
+		case *http2.RSTStreamFrame:
+			c.rst[f.StreamID] = f.ErrCode
+		case *http2.GoAwayFrame:
+			code := f.ErrCode
+			c.goAway = &code
+		}
+		c.cond.Broadcast()
+		c.mutex.Unlock()
+	}
+}
+
+func (c *testClient) openStream(id uint32) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	for _, v := range [][2]string{{":method", "POST"}, {":scheme", "http"}, {":path", "/"}, {":authority", "test"}} {
+		enc.WriteField(hpack.HeaderField{Name: v[0], Value: v[1]})
+	}
+	c.mutex.Lock()
+	c.streamWins[id] = c.initWindow
+	c.mutex.Unlock()
+	err := c.fr.WriteHeaders(http2.HeadersFrameParam{StreamID: id, BlockFragment: buf.Bytes(), EndHeaders: true})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// send 发送total字节，等待连接窗口，streamFlow为true时同时等待stream窗口
+func (c *testClient) send(id uint32, total int, streamFlow bool) error {
+	chunk := make([]byte, defMaxFrameSize)
+	for total > 0 {
+		n := int64(min(total, len(chunk)))
+		c.mutex.Lock()
+		for !c.closed && (c.connWindow < n || (streamFlow && c.streamWins[id] < n)) {
+			c.cond.Wait()
+		}
+		if c.closed {
+			c.mutex.Unlock()
+			return net.ErrClosed
+		}
+		c.connWindow -= n
+		c.streamWins[id] -= n
+		c.mutex.Unlock()
+		if err := c.fr.WriteData(id, false, chunk[:n]); err != nil {
+			return err
+		}
+		total -= int(n)
+	}
+	return nil
+}
+
+// wait 等待cond成立
+func (c *testClient) wait(what string, cond func() bool) {
+	c.t.Helper()
+	timer := time.AfterFunc(5*time.Second, func() {
+		c.mutex.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for !cond() {
+		if c.closed {
+			c.t.Fatalf("%s not happened", what)
+		}
+		c.cond.Wait()
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	l := newTestListener(t)
+	c := dialTestClient(t, l)
+	c.openStream(1)
+	// 应用不读取，超出stream窗口后被RST
+	go c.send(1, streamWindow+defMaxFrameSize, false)
+	c.wait("stream flow control reset", func() bool { return c.rst[1] == http2.ErrCodeFlowControl })
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.goAway != nil {
+		t.Fatalf("unexpected goaway %s", *c.goAway)
+	}
+}
+
+func TestConnFlowControl(t *testing.T) {
+	l := newTestListener(t)
+	c := dialTestClient(t, l)
+	c.openStream(1)
+	if err := c.fr.WriteData(1, false, make([]byte, defWindowSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	c.wait("connection flow control goaway", func() bool {
+		return c.goAway != nil && *c.goAway == http2.ErrCodeFlowControl
+	})
+}
+
+func TestStreamWindowRefund(t *testing.T) {
+	l := newTestListener(t)
+	c := dialTestClient(t, l)
+	c.openStream(1)
+
+	const total = 3 * streamWindow
+	received := make(chan int64, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		n, _ := io.CopyN(io.Discard, conn, total)
+		received <- n
+	}()
+	if err := c.send(1, total, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-received:
+		if n != total {
+			t.Fatalf("received %d, expect %d", n, total)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for data")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if code, ok := c.rst[1]; ok {
+		t.Fatalf("unexpected reset %s", code)
+	}
+}
@@ -0,0 +1,168 @@
+package h2c
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+	"golang.org/x/net/http2"
+)
+
+// Listener 包装net.Listener，以prior knowledge方式(直接发送preface)接入的h2c连接，
+// 每个stream作为一个独立的net.Conn由Accept返回；
+// 不以HTTP/2 preface开头的连接原样返回，所以h2c和自定义的二进制协议可以共用一个端口
+type Listener struct {
+	inner net.Listener
+
+	// SniffTimeout 等待客户端首个数据的时间，超时视为非h2c连接，0表示一直等待
+	SniffTimeout time.Duration
+	// MaxConcurrentStreams 每个h2c连接允许的最大并发stream数
+	MaxConcurrentStreams uint32
+
+	conns chan net.Conn
+
+	once   sync.Once
+	closed chan struct{}
+	err    error
+}
+
+const (
+	defMaxConcurrentStreams = 250
+	defAcceptQueue          = 1024
+)
+
+// NewListener 包装inner
+func NewListener(inner net.Listener) *Listener {
+	l := &Listener{
+		inner:                inner,
+		MaxConcurrentStreams: defMaxConcurrentStreams,
+		conns:                make(chan net.Conn, defAcceptQueue),
+		closed:               make(chan struct{}),
+	}
+	go l.serve()
+	return l
+}
+
+// Listen 在addr上监听h2c，stream上的数据使用proto解析
+func Listen(n *mynet.SimpleNet, addr string, proto mynet.IProto) (*mynet.Listener, error) {
+	inner, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachListener(NewListener(inner), proto)
+}
+
+func (l *Listener) serve() {
+	for {
+		conn, err := l.inner.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			l.err = err
+			l.Close()
+			return
+		}
+		go l.sniff(conn)
+	}
+}
+
+// sniff 逐字节比较preface，第一个不匹配的字节即判定为非h2c连接
+func (l *Listener) sniff(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if l.SniffTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(l.SniffTimeout))
+	}
+	isH2 := true
+	for i := 1; i <= len(http2.ClientPreface); i++ {
+		buf, err := r.Peek(i)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && len(buf) < i {
+				isH2 = false
+				break
+			}
+			conn.Close()
+			return
+		}
+		if buf[i-1] != http2.ClientPreface[i-1] {
+			isH2 = false
+			break
+		}
+	}
+	if l.SniffTimeout > 0 {
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	if !isH2 {
+		l.deliver(&peekedConn{Conn: conn, r: r})
+		return
+	}
+	r.Discard(len(http2.ClientPreface))
+	sc := newServerConn(l, conn, r)
+	sc.serve()
+}
+
+func (l *Listener) deliver(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.closed:
+		conn.Close()
+		return false
+	}
+}
+
+// offer 不阻塞地投递stream，accept队列满时返回false
+func (l *Listener) offer(conn net.Conn) (bool, error) {
+	select {
+	case <-l.closed:
+		return false, net.ErrClosed
+	default:
+	}
+	select {
+	case l.conns <- conn:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.closed)
+		err = l.inner.Close()
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.inner.Addr()
+}
+
+// peekedConn 非h2c连接，先读出sniff时缓存的数据
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(p)
+	}
+	return c.Conn.Read(p)
+}
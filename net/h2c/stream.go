@@ -0,0 +1,375 @@
+package h2c
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Stream 一个h2c stream，实现net.Conn:
+// Read 读取请求的DATA，Write 以DATA frame发送响应(首次Write前先发送响应头)，
+// Close 结束stream(有trailer时以trailer结束)
+//
+// 对端发送END_STREAM后Read不返回io.EOF而是继续阻塞，
+// 因为SimpleNet读到EOF会关闭连接，而此时响应往往还没有发送；stream由服务端Close结束，
+// 可以通过RemoteEnded判断请求是否已经结束
+type Stream struct {
+	sc *serverConn
+	id uint32
+
+	reqHeader []hpack.HeaderField
+
+	// 接收，由mutex保护
+	mutex         sync.Mutex
+	cond          *sync.Cond
+	buf           bytes.Buffer
+	recvWindow    int64 // 接收窗口，读取后归还
+	remoteEnded   bool
+	rerr          error
+	readDeadline  time.Time
+	readTimer     *time.Timer
+	writeDeadline time.Time
+	writeTimer    *time.Timer
+
+	// 发送窗口和错误，由sc.mutex保护
+	sendWindow int64
+	serr       error
+
+	// 发送，由wmutex保护
+	wmutex      sync.Mutex
+	headersSent bool
+	localClosed bool
+	status      int
+	header      []hpack.HeaderField
+	trailer     []hpack.HeaderField
+}
+
+func newStream(sc *serverConn, id uint32, fields []hpack.HeaderField, window int64) *Stream {
+	st := &Stream{
+		sc:         sc,
+		id:         id,
+		reqHeader:  fields,
+		sendWindow: window,
+		recvWindow: streamWindow,
+		status:     200,
+	}
+	st.cond = sync.NewCond(&st.mutex)
+	return st
+}
+
+// ID stream id
+func (st *Stream) ID() uint32 {
+	return st.id
+}
+
+// RequestHeader 请求头中名为name的值(包括:method, :path, :authority等伪头部)
+func (st *Stream) RequestHeader(name string) string {
+	name = strings.ToLower(name)
+	for _, v := range st.reqHeader {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// RequestHeaders 全部请求头
+func (st *Stream) RequestHeaders() []hpack.HeaderField {
+	return st.reqHeader
+}
+
+func (st *Stream) Method() string {
+	return st.RequestHeader(":method")
+}
+
+func (st *Stream) Path() string {
+	return st.RequestHeader(":path")
+}
+
+func (st *Stream) Authority() string {
+	return st.RequestHeader(":authority")
+}
+
+// SetStatus 设置响应状态码，需在首次Write之前调用
+func (st *Stream) SetStatus(status int) {
+	st.wmutex.Lock()
+	defer st.wmutex.Unlock()
+
+	st.status = status
+}
+
+// SetHeader 添加响应头，需在首次Write之前调用
+func (st *Stream) SetHeader(name, value string) {
+	st.wmutex.Lock()
+	defer st.wmutex.Unlock()
+
+	st.header = append(st.header, hpack.HeaderField{Name: strings.ToLower(name), Value: value})
+}
+
+// SetTrailer 添加trailer，在Close时发送
+func (st *Stream) SetTrailer(name, value string) {
+	st.wmutex.Lock()
+	defer st.wmutex.Unlock()
+
+	st.trailer = append(st.trailer, hpack.HeaderField{Name: strings.ToLower(name), Value: value})
+}
+
+// RemoteEnded 对端是否已经发送END_STREAM
+func (st *Stream) RemoteEnded() bool {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	return st.remoteEnded
+}
+
+// receive 缓存DATA frame的数据，size包括padding，
+// 超出接收窗口或者对端已经结束时返回http2.StreamError
+func (st *Stream) receive(data []byte, size uint32, end bool) error {
+	st.mutex.Lock()
+	if st.remoteEnded {
+		st.mutex.Unlock()
+		return http2.StreamError{StreamID: st.id, Code: http2.ErrCodeStreamClosed,
+			Cause: fmt.Errorf("data after end stream")}
+	}
+	if int64(size) > st.recvWindow {
+		st.mutex.Unlock()
+		return http2.StreamError{StreamID: st.id, Code: http2.ErrCodeFlowControl,
+			Cause: fmt.Errorf("stream flow control window exceeded")}
+	}
+	st.recvWindow -= int64(size)
+	st.buf.Write(data)
+	st.cond.Broadcast()
+	st.mutex.Unlock()
+
+	// padding部分直接归还
+	if pad := int(size) - len(data); pad > 0 {
+		st.consumed(pad)
+	}
+	if end {
+		st.endRemote()
+	}
+	return nil
+}
+
+// consumed 归还n字节的接收窗口
+func (st *Stream) consumed(n int) {
+	st.mutex.Lock()
+	st.recvWindow += int64(n)
+	st.mutex.Unlock()
+
+	st.sc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteWindowUpdate(st.id, uint32(n))
+	})
+}
+
+func (st *Stream) endRemote() {
+	st.mutex.Lock()
+	st.remoteEnded = true
+	st.cond.Broadcast()
+	st.mutex.Unlock()
+
+	st.wmutex.Lock()
+	closed := st.localClosed
+	st.wmutex.Unlock()
+	if closed {
+		st.sc.removeStream(st.id)
+	}
+}
+
+func (st *Stream) abort(err error) {
+	st.mutex.Lock()
+	if st.rerr == nil {
+		st.rerr = err
+	}
+	st.cond.Broadcast()
+	st.mutex.Unlock()
+
+	st.sc.mutex.Lock()
+	if st.serr == nil {
+		st.serr = err
+	}
+	st.sc.cond.Broadcast()
+	st.sc.mutex.Unlock()
+
+	st.sc.removeStream(st.id)
+}
+
+// sendErr 调用时持有sc.mutex
+func (st *Stream) sendErr() error {
+	if st.serr != nil {
+		return st.serr
+	}
+	st.mutex.Lock()
+	deadline := st.writeDeadline
+	st.mutex.Unlock()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+func (st *Stream) Read(p []byte) (int, error) {
+	st.mutex.Lock()
+	for {
+		if st.buf.Len() > 0 {
+			n, _ := st.buf.Read(p)
+			ended := st.remoteEnded
+			st.mutex.Unlock()
+			if !ended {
+				st.consumed(n)
+			}
+			return n, nil
+		}
+		if st.rerr != nil {
+			err := st.rerr
+			st.mutex.Unlock()
+			return 0, err
+		}
+		if !st.readDeadline.IsZero() && !time.Now().Before(st.readDeadline) {
+			st.mutex.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+		st.cond.Wait()
+	}
+}
+
+func (st *Stream) responseHeader() []hpack.HeaderField {
+	fields := make([]hpack.HeaderField, 0, len(st.header)+1)
+	fields = append(fields, hpack.HeaderField{Name: ":status", Value: strconv.Itoa(st.status)})
+	return append(fields, st.header...)
+}
+
+func (st *Stream) Write(p []byte) (int, error) {
+	st.wmutex.Lock()
+	defer st.wmutex.Unlock()
+
+	if st.localClosed {
+		return 0, fmt.Errorf("h2c stream %d closed", st.id)
+	}
+	if !st.headersSent {
+		if err := st.sc.writeHeaders(st.id, st.responseHeader(), false); err != nil {
+			return 0, err
+		}
+		st.headersSent = true
+	}
+
+	written := 0
+	for written < len(p) {
+		n, err := st.sc.reserveWindow(st, len(p)-written)
+		if err != nil {
+			return written, err
+		}
+		chunk := p[written : written+n]
+		err = st.sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteData(st.id, false, chunk)
+		})
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+func (st *Stream) Close() error {
+	st.wmutex.Lock()
+	if st.localClosed {
+		st.wmutex.Unlock()
+		return nil
+	}
+	st.localClosed = true
+
+	var err error
+	switch {
+	case !st.headersSent:
+		fields := st.responseHeader()
+		if len(st.trailer) > 0 {
+			// 没有body时trailer合并到响应头(trailers-only)
+			fields = append(fields, st.trailer...)
+		}
+		err = st.sc.writeHeaders(st.id, fields, true)
+	case len(st.trailer) > 0:
+		err = st.sc.writeHeaders(st.id, st.trailer, true)
+	default:
+		err = st.sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteData(st.id, true, nil)
+		})
+	}
+	st.headersSent = true
+	st.wmutex.Unlock()
+
+	st.mutex.Lock()
+	ended := st.remoteEnded
+	st.mutex.Unlock()
+	if !ended {
+		// 对端还在发送，通知其停止
+		st.sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteRSTStream(st.id, http2.ErrCodeNo)
+		})
+	}
+	st.abort(net.ErrClosed)
+	return err
+}
+
+func (st *Stream) LocalAddr() net.Addr {
+	return st.sc.conn.LocalAddr()
+}
+
+func (st *Stream) RemoteAddr() net.Addr {
+	return st.sc.conn.RemoteAddr()
+}
+
+func (st *Stream) SetDeadline(t time.Time) error {
+	st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+func (st *Stream) SetReadDeadline(t time.Time) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.readDeadline = t
+	if st.readTimer != nil {
+		st.readTimer.Stop()
+		st.readTimer = nil
+	}
+	if !t.IsZero() {
+		st.readTimer = time.AfterFunc(time.Until(t), func() {
+			st.mutex.Lock()
+			st.cond.Broadcast()
+			st.mutex.Unlock()
+		})
+	}
+	st.cond.Broadcast()
+	return nil
+}
+
+func (st *Stream) SetWriteDeadline(t time.Time) error {
+	st.mutex.Lock()
+	st.writeDeadline = t
+	if st.writeTimer != nil {
+		st.writeTimer.Stop()
+		st.writeTimer = nil
+	}
+	if !t.IsZero() {
+		st.writeTimer = time.AfterFunc(time.Until(t), func() {
+			st.sc.mutex.Lock()
+			st.sc.cond.Broadcast()
+			st.sc.mutex.Unlock()
+		})
+	}
+	st.mutex.Unlock()
+
+	st.sc.mutex.Lock()
+	st.sc.cond.Broadcast()
+	st.sc.mutex.Unlock()
+	return nil
+}
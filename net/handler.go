@@ -0,0 +1,160 @@
+package net
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// Handler 事件回调，SetHandler后事件在工作goroutine中回调，不再进入PollEvent的队列。
+// 同一个连接的事件在同一个工作goroutine中按顺序回调
+type Handler interface {
+	// OnConnect 新连接(EventNewConnection)
+	OnConnect(conn *Connection)
+	// OnData 收到数据(EventNewConnectionData)，设置了BufferProvider时，
+	// 回调返回后缓冲区被回收，data中引用的缓冲区不能在回调外使用
+	OnData(conn *Connection, data interface{})
+	// OnClose 连接关闭(EventConnectionClosed/EventConnectionError)，对端正常关闭时err为nil
+	OnClose(conn *Connection, err error)
+	// OnError 连接上的非致命错误(EventProtoError)，连接是否关闭由ProtoErrorPolicy决定
+	OnError(conn *Connection, err error)
+}
+
+// EventHandler Handler同时实现EventHandler时，其他事件(如EventEvicted)通过OnEvent回调
+type EventHandler interface {
+	OnEvent(event *ConnEvent)
+}
+
+// BaseHandler 空实现，嵌入后只需要实现关心的回调
+type BaseHandler struct{}
+
+func (BaseHandler) OnConnect(conn *Connection)                {}
+func (BaseHandler) OnData(conn *Connection, data interface{}) {}
+func (BaseHandler) OnClose(conn *Connection, err error)       {}
+func (BaseHandler) OnError(conn *Connection, err error)       {}
+
+const dispatchQueueSize = 1024
+
+type dispatcher struct {
+	net     *SimpleNet
+	handler Handler
+	queues  []chan *ConnEvent
+	stop    chan struct{}
+}
+
+func newDispatcher(n *SimpleNet, handler Handler, workers int) *dispatcher {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	d := &dispatcher{
+		net:     n,
+		handler: handler,
+		queues:  make([]chan *ConnEvent, workers),
+		stop:    make(chan struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan *ConnEvent, dispatchQueueSize)
+		go d.run(d.queues[i])
+	}
+	return d
+}
+
+// SetHandler 以回调方式处理所有连接的事件，workers为工作goroutine数，<=0时为CPU数。
+// 监听设置了自己的Handler时优先使用监听的。handler为nil时恢复PollEvent
+func (n *SimpleNet) SetHandler(handler Handler, workers int) {
+	var d *dispatcher
+	if handler != nil {
+		d = newDispatcher(n, handler, workers)
+	}
+	if old := n.dispatcher.Swap(d); old != nil {
+		close(old.stop)
+	}
+}
+
+// SetHandler 以回调方式处理监听下连接的事件，handler为nil时取消
+func (l *Listener) SetHandler(handler Handler, workers int) {
+	var d *dispatcher
+	if handler != nil {
+		d = newDispatcher(l.net, handler, workers)
+	}
+	if old := l.dispatcher.Swap(d); old != nil {
+		close(old.stop)
+	}
+}
+
+// dispatcherFor 事件对应的回调，没有设置返回nil
+func (n *SimpleNet) dispatcherFor(event *ConnEvent) *dispatcher {
+	if conn := event.Conn; conn != nil && conn.listen != nil {
+		if d := conn.listen.dispatcher.Load(); d != nil {
+			return d
+		}
+	}
+	return n.dispatcher.Load()
+}
+
+// dispatch 按连接分配工作goroutine，已经停止返回false
+func (d *dispatcher) dispatch(event *ConnEvent) bool {
+	idx := 0
+	if event.Conn != nil {
+		idx = int(uint64(event.Conn.id) % uint64(len(d.queues)))
+	}
+	select {
+	case d.queues[idx] <- event:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
+
+func (d *dispatcher) run(queue chan *ConnEvent) {
+	for {
+		select {
+		case event := <-queue:
+			d.handle(event)
+		case <-d.stop:
+			// 处理已经分配的事件
+			for {
+				select {
+				case event := <-queue:
+					d.handle(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *dispatcher) handle(event *ConnEvent) {
+	defer func() {
+		if err := recover(); err != nil {
+			d.net.logMsg(mylog.LevelError,
+				fmt.Sprintf("handler panic: %s\n", err))
+		}
+	}()
+	conn := event.Conn
+	switch event.EventType {
+	case EventNewConnection:
+		d.handler.OnConnect(conn)
+	case EventNewConnectionData:
+		defer event.Release()
+		d.handler.OnData(conn, event.Data)
+	case EventConnectionClosed, EventConnectionError:
+		err, _ := event.Data.(error)
+		if err == io.EOF {
+			err = nil
+		}
+		d.handler.OnClose(conn, err)
+	case EventProtoError:
+		err, _ := event.Data.(error)
+		d.handler.OnError(conn, err)
+	default:
+		// 流分片可能引用读缓冲区
+		defer event.Release()
+		if h, ok := d.handler.(EventHandler); ok {
+			h.OnEvent(event)
+		}
+	}
+}
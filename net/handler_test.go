@@ -0,0 +1,56 @@
+package net
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// recordHandler 按顺序记录回调
+type recordHandler struct {
+	BaseHandler
+	calls chan string
+}
+
+func (h *recordHandler) OnConnect(conn *Connection) { h.calls <- "connect" }
+func (h *recordHandler) OnData(conn *Connection, data interface{}) {
+	h.calls <- "data " + string(data.([]byte))
+}
+func (h *recordHandler) OnClose(conn *Connection, err error) { h.calls <- fmt.Sprintf("close %v", err) }
+func (h *recordHandler) OnError(conn *Connection, err error) { h.calls <- "error " + err.Error() }
+
+func TestListenerHandler(t *testing.T) {
+	n := newTestNet(t)
+	n.SetClientEventQueue(64)
+	l, err := n.Listen("127.0.0.1:0", &badProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &recordHandler{calls: make(chan string, 16)}
+	l.SetHandler(h, 2)
+
+	conn, err := n.Connect(l.LocalAddress(), &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.SendData(conn, []byte("a"))
+	n.SendData(conn, []byte("bad"))
+	n.SendData(conn, []byte("b"))
+	waitFor(t, "messages sent", func() bool { return conn.queued.Load() == 0 })
+	n.CloseConn(conn)
+
+	for _, want := range []string{"connect", "data a", "error bad message", "data b", "close <nil>"} {
+		select {
+		case got := <-h.calls:
+			if got != want {
+				t.Fatalf("callback %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+	// 回调处理的事件不进入公共队列
+	if evt, err := n.PollEvent(10); err != nil || evt.EventType != EventTimeout {
+		t.Fatalf("unexpected event %v, err = %v", evt, err)
+	}
+}
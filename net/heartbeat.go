@@ -0,0 +1,138 @@
+package net
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+	"github.com/buf1024/golib/timewheel"
+)
+
+// Heartbeater proto实现后，连接收到的ping自动回复pong，
+// 开启SetHeartbeat时定时发送ping。ping和pong不作为EventNewConnectionData发出
+type Heartbeater interface {
+	Ping() interface{}
+	IsPing(data interface{}) bool
+	Pong(ping interface{}) interface{}
+	IsPong(data interface{}) bool
+}
+
+// HeartbeatPolicy 心跳参数
+type HeartbeatPolicy struct {
+	Interval  time.Duration // 发送ping的间隔
+	Timeout   time.Duration // 等待pong的时间，<=0时等于Interval
+	MaxMissed int           // 连续丢失次数达到MaxMissed时关闭连接，<=0不关闭
+}
+
+type heartbeater struct {
+	policy HeartbeatPolicy
+	wheel  *timewheel.Wheel
+}
+
+type heartbeatState struct {
+	pongAt atomic.Int64
+	missed atomic.Int32
+}
+
+// SetHeartbeat 对proto实现了Heartbeater的连接定时发送ping，
+// Timeout内没有收到pong时发出EventHeartbeatMissed(Data为连续丢失次数)，
+// policy为nil时关闭
+func (n *SimpleNet) SetHeartbeat(policy *HeartbeatPolicy) {
+	var h *heartbeater
+	if policy != nil && policy.Interval > 0 {
+		h = &heartbeater{policy: *policy}
+		if h.policy.Timeout <= 0 {
+			h.policy.Timeout = h.policy.Interval
+		}
+		tick := min(h.policy.Interval, h.policy.Timeout) / 16
+		if tick < 10*time.Millisecond {
+			tick = 10 * time.Millisecond
+		}
+		h.wheel = timewheel.New(tick, idleWheelSlots)
+	}
+	if old := n.heartbeat.Swap(h); old != nil {
+		old.wheel.Stop()
+	}
+	if h != nil {
+		for _, conn := range n.allConns() {
+			n.watchHeartbeat(h, conn)
+		}
+	}
+}
+
+// HeartbeatMissed 连续没有收到pong的次数
+func (c *Connection) HeartbeatMissed() int {
+	return int(c.heartbeat.missed.Load())
+}
+
+func (n *SimpleNet) watchHeartbeat(h *heartbeater, conn *Connection) {
+	if h == nil {
+		return
+	}
+	if _, ok := protoAs[Heartbeater](conn.proto); !ok {
+		return
+	}
+	h.wheel.AfterFunc(h.policy.Interval, func() {
+		n.ping(h, conn)
+	})
+}
+
+// ping 发送ping，Timeout后检查是否收到pong
+func (n *SimpleNet) ping(h *heartbeater, conn *Connection) {
+	if n.heartbeat.Load() != h || conn.Status() != StatusConnected {
+		return
+	}
+	pingAt := time.Now().UnixNano()
+	hb, _ := protoAs[Heartbeater](conn.proto)
+	if msg, err := conn.proto.Serialize(hb.Ping()); err == nil {
+		conn.trySend(msg)
+	}
+	h.wheel.AfterFunc(h.policy.Timeout, func() {
+		n.checkPong(h, conn, pingAt)
+	})
+	n.watchHeartbeat(h, conn)
+}
+
+func (n *SimpleNet) checkPong(h *heartbeater, conn *Connection, pingAt int64) {
+	if n.heartbeat.Load() != h || conn.Status() != StatusConnected {
+		return
+	}
+	if conn.heartbeat.pongAt.Load() >= pingAt {
+		return
+	}
+	missed := int(conn.heartbeat.missed.Add(1))
+	// 发事件和关闭连接可能阻塞，不占用时间轮
+	go func() {
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("heartbeat missed %d, remoteAddr = %s\n", missed, conn.RemoteAddress()))
+		n.emit(&ConnEvent{
+			EventType: EventHeartbeatMissed,
+			Conn:      conn,
+			Data:      missed,
+		})
+		if h.policy.MaxMissed > 0 && missed >= h.policy.MaxMissed {
+			n.CloseConn(conn)
+		}
+	}()
+}
+
+// handleHeartbeat 处理ping和pong，返回true表示data已经处理
+func (c *Connection) handleHeartbeat(data interface{}) bool {
+	hb, ok := protoAs[Heartbeater](c.proto)
+	if !ok {
+		return false
+	}
+	if hb.IsPong(data) {
+		c.heartbeat.pongAt.Store(time.Now().UnixNano())
+		c.heartbeat.missed.Store(0)
+		return true
+	}
+	if hb.IsPing(data) {
+		if msg, err := c.proto.Serialize(hb.Pong(data)); err == nil {
+			c.trySend(msg)
+		}
+		return true
+	}
+	return false
+}
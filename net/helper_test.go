@@ -0,0 +1,81 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestNet 创建丢弃日志的SimpleNet，测试结束时销毁
+func newTestNet(t testing.TB) *SimpleNet {
+	n := NewSimpleNet(discardLog(t))
+	t.Cleanup(func() { SimpleNetDestroy(n) })
+	return n
+}
+
+// rawServer 只accept不读数据的TCP服务，用于把发送队列塞满
+func rawServer(t testing.TB) (string, <-chan net.Conn) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		listen.Close()
+		for {
+			select {
+			case conn := <-conns:
+				conn.Close()
+			default:
+				return
+			}
+		}
+	})
+	return listen.Addr().String(), conns
+}
+
+// waitFor 等待cond成立，超时失败
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitEvent 从公共队列等待指定类型的事件，跳过其他事件
+func waitEvent(t testing.TB, n *SimpleNet, eventType int) *ConnEvent {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		evt, err := n.PollEvent(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.EventType == eventType {
+			return evt
+		}
+	}
+	t.Fatalf("timeout waiting for event %d", eventType)
+	return nil
+}
+
+// listenTest 在随机端口监听，返回监听地址
+func listenTest(t testing.TB, n *SimpleNet, proto IProto) string {
+	t.Helper()
+	l, err := n.Listen("127.0.0.1:0", proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.LocalAddress()
+}
@@ -0,0 +1,267 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defMaxHeaderBytes = 1024 * 1024
+	defMaxHTTPBody    = 10 * 1024 * 1024
+)
+
+// ErrBadRequest 不是合法的HTTP/1.x请求
+var ErrBadRequest = errors.New("bad http request")
+
+// HTTPRequest HTTPProto收到的请求，body已经完整读取
+type HTTPRequest struct {
+	Method     string
+	RequestURI string
+	URL        *url.URL
+	Proto      string // HTTP/1.1
+	Header     http.Header
+	Host       string
+	Body       []byte
+	Close      bool // 回复后需要关闭连接(Connection: close或者HTTP/1.0)
+}
+
+// HTTPResponse 回复，Content-Length和Date没有设置时自动加上
+type HTTPResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Close      bool // 写入后关闭连接
+
+	noBody bool // HEAD请求只返回头
+}
+
+// NewHTTPResponse 创建回复，body非空且没有设置Content-Type时按内容推断
+func NewHTTPResponse(status int, body []byte) *HTTPResponse {
+	return &HTTPResponse{StatusCode: status, Header: make(http.Header), Body: body}
+}
+
+// HTTPProto 简单的HTTP/1.1服务端proto，用于健康检查、管理接口等内嵌的HTTP服务。
+// 请求(包括chunked的body)完整收到后作为*HTTPRequest发出，使用ReplyHTTP回复，
+// 支持keep-alive和pipelining，回复按发送顺序返回。没有状态，可以在多个连接间共享
+type HTTPProto struct {
+	MaxHeaderBytes int // 请求行和头的最大长度，<=0时为1M
+	MaxBodyBytes   int // body的最大长度，<=0时为10M
+}
+
+// NewHTTPProto 使用默认限制创建HTTPProto
+func NewHTTPProto() *HTTPProto {
+	return &HTTPProto{}
+}
+
+// ReplyHTTP 回复请求，HEAD请求不发送body，请求或者回复要求关闭时写入后关闭连接
+func (n *SimpleNet) ReplyHTTP(conn *Connection, req *HTTPRequest, resp *HTTPResponse) error {
+	r := *resp
+	r.noBody = req != nil && req.Method == http.MethodHead
+	r.Close = r.Close || (req != nil && req.Close)
+	if !r.Close {
+		return n.SendData(conn, &r)
+	}
+	return n.SendDataFunc(conn, &r, func(err error) {
+		n.CloseConn(conn)
+	})
+}
+
+func (p *HTTPProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *HTTPProto) HeadLen() uint32 {
+	return 0
+}
+
+func (p *HTTPProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("http proto has no head")
+}
+
+func (p *HTTPProto) maxHeader() int {
+	if p.MaxHeaderBytes > 0 {
+		return p.MaxHeaderBytes
+	}
+	return defMaxHeaderBytes
+}
+
+func (p *HTTPProto) maxBody() int {
+	if p.MaxBodyBytes > 0 {
+		return p.MaxBodyBytes
+	}
+	return defMaxHTTPBody
+}
+
+// Split 找到头的结尾，再按Content-Length或者chunked找到body的结尾
+func (p *HTTPProto) Split(data []byte, atEOF bool) (int, []byte, error) {
+	// 请求之前的空行忽略
+	if skip := len(data) - len(bytes.TrimLeft(data, "\r\n")); skip > 0 {
+		return skip, nil, nil
+	}
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end < 0 {
+		if len(data) > p.maxHeader() {
+			return len(data), nil, fmt.Errorf("%w, header too large", ErrBadRequest)
+		}
+		return 0, nil, nil
+	}
+	if end > p.maxHeader() {
+		return len(data), nil, fmt.Errorf("%w, header too large", ErrBadRequest)
+	}
+	end += 4
+
+	length, chunked, err := bodyLength(data[:end])
+	if err != nil {
+		return len(data), nil, err
+	}
+	if chunked {
+		size, ok, err := chunkedLength(data[end:], p.maxBody())
+		if err != nil || !ok {
+			if err != nil {
+				return len(data), nil, err
+			}
+			return 0, nil, nil
+		}
+		length = size
+	}
+	if length > p.maxBody() {
+		return len(data), nil, fmt.Errorf("%w, body too large, size = %d, max = %d",
+			ErrBadRequest, length, p.maxBody())
+	}
+	if len(data) < end+length {
+		return 0, nil, nil
+	}
+	return end + length, data[:end+length], nil
+}
+
+// bodyLength 解析头中的Content-Length和Transfer-Encoding
+func bodyLength(head []byte) (int, bool, error) {
+	length, chunked := 0, false
+	lines := bytes.Split(head, []byte("\r\n"))
+	for _, line := range lines[1:] {
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		switch strings.ToLower(string(bytes.TrimSpace(name))) {
+		case "content-length":
+			n, err := strconv.Atoi(string(value))
+			if err != nil || n < 0 {
+				return 0, false, fmt.Errorf("%w, bad content length %q", ErrBadRequest, value)
+			}
+			length = n
+		case "transfer-encoding":
+			chunked = bytes.Contains(bytes.ToLower(value), []byte("chunked"))
+		}
+	}
+	return length, chunked, nil
+}
+
+// chunkedLength chunked body(包括trailer)的长度，数据不够时ok为false
+func chunkedLength(data []byte, limit int) (int, bool, error) {
+	pos, total := 0, 0
+	for {
+		i := bytes.Index(data[pos:], []byte("\r\n"))
+		if i < 0 {
+			return 0, false, nil
+		}
+		line, _, _ := bytes.Cut(data[pos:pos+i], []byte(";"))
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 16, 64)
+		if err != nil || size < 0 {
+			return 0, false, fmt.Errorf("%w, bad chunk size %q", ErrBadRequest, line)
+		}
+		pos += i + 2
+		if size == 0 {
+			// trailer以空行结束
+			for {
+				j := bytes.Index(data[pos:], []byte("\r\n"))
+				if j < 0 {
+					return 0, false, nil
+				}
+				pos += j + 2
+				if j == 0 {
+					return pos, true, nil
+				}
+			}
+		}
+		total += int(size)
+		if total > limit {
+			return 0, false, fmt.Errorf("%w, body too large, max = %d", ErrBadRequest, limit)
+		}
+		if len(data) < pos+int(size)+2 {
+			return 0, false, nil
+		}
+		pos += int(size) + 2
+	}
+}
+
+func (p *HTTPProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(body)))
+	if err != nil {
+		return nil, fmt.Errorf("%w, %s", ErrBadRequest, err)
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %s", ErrBadRequest, err)
+	}
+	return &HTTPRequest{
+		Method:     req.Method,
+		RequestURI: req.RequestURI,
+		URL:        req.URL,
+		Proto:      req.Proto,
+		Header:     req.Header,
+		Host:       req.Host,
+		Body:       data,
+		Close:      req.Close,
+	}, nil
+}
+
+func (p *HTTPProto) Serialize(data interface{}) ([]byte, error) {
+	resp, ok := data.(*HTTPResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	bodyAllowed := status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+	if bodyAllowed {
+		if header.Get("Content-Length") == "" {
+			header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+		}
+		if len(resp.Body) > 0 && header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(resp.Body))
+		}
+	}
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	if resp.Close {
+		header.Set("Connection", "close")
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	if err := header.Write(buf); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+	if bodyAllowed && !resp.noBody {
+		buf.Write(resp.Body)
+	}
+	return buf.Bytes(), nil
+}
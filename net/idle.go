@@ -0,0 +1,82 @@
+package net
+
+import (
+	"fmt"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+	"github.com/buf1024/golib/timewheel"
+)
+
+const idleWheelSlots = 512
+
+type idleReaper struct {
+	timeout time.Duration
+	wheel   *timewheel.Wheel
+}
+
+// SetIdleTimeout 关闭空闲(没有收发数据)超过timeout的连接，并发出EventConnectionIdleClosed，
+// 对已有的连接和新连接都有效，timeout<=0关闭。
+// 由时间轮驱动，精度为timeout/64(不小于10ms)
+func (n *SimpleNet) SetIdleTimeout(timeout time.Duration) {
+	var r *idleReaper
+	if timeout > 0 {
+		tick := timeout / 64
+		if tick < 10*time.Millisecond {
+			tick = 10 * time.Millisecond
+		}
+		r = &idleReaper{
+			timeout: timeout,
+			wheel:   timewheel.New(tick, idleWheelSlots),
+		}
+	}
+	if old := n.idle.Swap(r); old != nil {
+		old.wheel.Stop()
+	}
+	if r != nil {
+		for _, conn := range n.allConns() {
+			n.watchIdle(r, conn)
+		}
+	}
+}
+
+// IdleTimeout 空闲超时，0表示没有开启
+func (n *SimpleNet) IdleTimeout() time.Duration {
+	if r := n.idle.Load(); r != nil {
+		return r.timeout
+	}
+	return 0
+}
+
+// watchIdle 在连接最近活动时间+timeout时检查，期间有活动则顺延
+func (n *SimpleNet) watchIdle(r *idleReaper, conn *Connection) {
+	if r == nil {
+		return
+	}
+	wait := r.timeout - time.Since(conn.UpdateTime())
+	r.wheel.AfterFunc(wait, func() {
+		if n.idle.Load() != r || conn.Status() != StatusConnected {
+			return
+		}
+		if idle := time.Since(conn.UpdateTime()); idle < r.timeout {
+			n.watchIdle(r, conn)
+			return
+		}
+		// 关闭连接和发事件可能阻塞，不占用时间轮
+		go n.reapIdle(conn)
+	})
+}
+
+func (n *SimpleNet) reapIdle(conn *Connection) {
+	if conn.Status() != StatusConnected {
+		return
+	}
+	n.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("close idle connection, idle = %s, remoteAddr = %s\n",
+			time.Since(conn.UpdateTime()).Round(time.Millisecond), conn.RemoteAddress()))
+	n.CloseConn(conn)
+	n.emit(&ConnEvent{
+		EventType: EventConnectionIdleClosed,
+		Conn:      conn,
+	})
+}
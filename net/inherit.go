@@ -0,0 +1,182 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// EnvListenFDs 传给子进程的监听socket，格式为addr=fd,addr=fd，
+// addr为监听时配置的地址，同一地址可以有多个fd(SO_REUSEPORT)
+const EnvListenFDs = "GOLIB_LISTEN_FDS"
+
+// inherited 从父进程继承的监听socket，第一次使用时从环境变量中解析
+var inherited struct {
+	once  sync.Once
+	lock  sync.Mutex
+	files map[string][]*os.File
+}
+
+// Files 复制监听socket的文件描述符，返回配置的地址和对应的文件，调用方负责关闭文件。
+// 只支持TCP和unix socket的监听
+func (l *Listener) Files() ([]string, []*os.File, error) {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	addrs := make([]string, 0, len(l.listens))
+	files := make([]*os.File, 0, len(l.listens))
+	for _, v := range l.listens {
+		file, err := listenerFile(v.listen)
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+		addrs = append(addrs, v.addr)
+		files = append(files, file)
+	}
+	return addrs, files, nil
+}
+
+// listenerFile 复制监听socket。不使用TCPListener.File，它返回的文件在exec调用Fd时
+// 会把共享的socket改为阻塞模式，导致本进程的accept无法被Close打断
+func listenerFile(listen net.Listener) (*os.File, error) {
+	sc, ok := listen.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("listener %s not support file", listen.Addr())
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var fd int
+	var derr error
+	err = raw.Control(func(sysfd uintptr) {
+		fd, derr = dupFD(sysfd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if derr != nil {
+		return nil, derr
+	}
+	return os.NewFile(uintptr(fd), listen.Addr().String()), nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// ExportListeners 热重启: 把所有监听socket加入cmd.ExtraFiles，并在cmd.Env中设置EnvListenFDs，
+// 子进程通过ListenInherited接管监听。cmd.Env为nil时使用当前进程的环境变量。
+// 子进程启动后父进程关闭ExtraFiles中的文件，再调用Shutdown停止accept并处理完已有的连接，
+// 期间的新连接由子进程接受
+func (n *SimpleNet) ExportListeners(cmd *exec.Cmd) error {
+	n.lockServer.Lock()
+	listens := append([]*Listener(nil), n.connServer...)
+	n.lockServer.Unlock()
+
+	var fds []string
+	var all []*os.File
+	for _, l := range listens {
+		addrs, files, err := l.Files()
+		if err != nil {
+			closeFiles(all)
+			return err
+		}
+		for i, file := range files {
+			fd := 3 + len(cmd.ExtraFiles) + len(all)
+			fds = append(fds, addrs[i]+"="+strconv.Itoa(fd))
+			all = append(all, file)
+		}
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, EnvListenFDs+"="+strings.Join(fds, ","))
+	cmd.ExtraFiles = append(cmd.ExtraFiles, all...)
+	return nil
+}
+
+func loadInherited() {
+	inherited.files = make(map[string][]*os.File)
+	env := os.Getenv(EnvListenFDs)
+	if env == "" {
+		return
+	}
+	// 不再传给之后启动的子进程
+	os.Unsetenv(EnvListenFDs)
+	for _, item := range strings.Split(env, ",") {
+		idx := strings.LastIndex(item, "=")
+		if idx < 0 {
+			continue
+		}
+		fd, err := strconv.Atoi(item[idx+1:])
+		if err != nil || fd < 3 {
+			continue
+		}
+		addr := item[:idx]
+		inherited.files[addr] = append(inherited.files[addr], os.NewFile(uintptr(fd), addr))
+	}
+}
+
+// takeInherited 取出addr继承的socket，只能取一次
+func takeInherited(addr string) []*os.File {
+	inherited.once.Do(loadInherited)
+
+	inherited.lock.Lock()
+	defer inherited.lock.Unlock()
+
+	files := inherited.files[addr]
+	delete(inherited.files, addr)
+	return files
+}
+
+// ListenInherited 使用父进程通过ExportListeners传下来的addr的监听socket，
+// 没有时重新监听TCP。addr需要和父进程监听时配置的地址一致，多个地址用逗号分隔
+func (n *SimpleNet) ListenInherited(addr string, proto IProto) (*Listener, error) {
+	var addrs []string
+	var listens []net.Listener
+	closeAll := func() {
+		for _, v := range listens {
+			v.Close()
+		}
+	}
+	for _, v := range splitAddrs(addr) {
+		files := takeInherited(v)
+		if len(files) == 0 {
+			listen, err := listenTCP(v)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			addrs = append(addrs, v)
+			listens = append(listens, listen)
+			continue
+		}
+		for _, file := range files {
+			listen, err := net.FileListener(file)
+			if err != nil {
+				closeFiles(files)
+				closeAll()
+				return nil, fmt.Errorf("inherit listener %s failed, err = %s", v, err)
+			}
+			addrs = append(addrs, v)
+			listens = append(listens, listen)
+		}
+		closeFiles(files)
+	}
+
+	l := n.newListener(proto, nil)
+	for i, listen := range listens {
+		l.addListener(addrs[i], listen)
+	}
+	return l, nil
+}
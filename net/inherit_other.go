@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package net
+
+import "fmt"
+
+func dupFD(fd uintptr) (int, error) {
+	return -1, fmt.Errorf("listener file not supported")
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+// dupFD 复制fd并设置close-on-exec，exec时只有ExtraFiles中的fd传给子进程
+func dupFD(fd uintptr) (int, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+
+	newfd, err := syscall.Dup(int(fd))
+	if err != nil {
+		return -1, os.NewSyscallError("dup", err)
+	}
+	syscall.CloseOnExec(newfd)
+	return newfd, nil
+}
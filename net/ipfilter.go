@@ -0,0 +1,152 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ipFilter 监听的IP黑白名单，先检查黑名单，白名单不为空时只接受白名单中的地址
+type ipFilter struct {
+	lock  sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// parsePrefix 解析CIDR，单个IP作为/32或者/128
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid ip %s", cidr)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr %s", cidr)
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+	}
+	return prefix.Masked(), nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Allow 把cidr(如10.0.0.0/8或者单个IP)加入白名单，
+// 白名单不为空时只接受白名单中的地址，在握手和FilterAccept之前检查
+func (l *Listener) Allow(cidr ...string) error {
+	return l.ipFilter.add(&l.ipFilter.allow, cidr)
+}
+
+// Deny 把cidr加入黑名单，黑名单优先于白名单
+func (l *Listener) Deny(cidr ...string) error {
+	return l.ipFilter.add(&l.ipFilter.deny, cidr)
+}
+
+// RemoveAllow 从白名单中删除cidr
+func (l *Listener) RemoveAllow(cidr ...string) error {
+	return l.ipFilter.remove(&l.ipFilter.allow, cidr)
+}
+
+// RemoveDeny 从黑名单中删除cidr
+func (l *Listener) RemoveDeny(cidr ...string) error {
+	return l.ipFilter.remove(&l.ipFilter.deny, cidr)
+}
+
+// AllowList 白名单
+func (l *Listener) AllowList() []string {
+	return l.ipFilter.list(&l.ipFilter.allow)
+}
+
+// DenyList 黑名单
+func (l *Listener) DenyList() []string {
+	return l.ipFilter.list(&l.ipFilter.deny)
+}
+
+func (f *ipFilter) add(list *[]netip.Prefix, cidrs []string) error {
+	prefixes, err := parsePrefixes(cidrs)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, prefix := range prefixes {
+		if !slices.Contains(*list, prefix) {
+			*list = append(*list, prefix)
+		}
+	}
+	return nil
+}
+
+func (f *ipFilter) remove(list *[]netip.Prefix, cidrs []string) error {
+	prefixes, err := parsePrefixes(cidrs)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	*list = slices.DeleteFunc(*list, func(p netip.Prefix) bool {
+		return slices.Contains(prefixes, p)
+	})
+	return nil
+}
+
+func (f *ipFilter) list(list *[]netip.Prefix) []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	cidrs := make([]string, 0, len(*list))
+	for _, prefix := range *list {
+		cidrs = append(cidrs, prefix.String())
+	}
+	return cidrs
+}
+
+// permit 检查远端地址，没有IP的地址(如unix socket)总是接受
+func (f *ipFilter) permit(addr net.Addr) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return true
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return true
+	}
+	ip := ap.Addr().Unmap()
+	for _, prefix := range f.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, prefix := range f.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
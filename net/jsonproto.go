@@ -0,0 +1,141 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrUnknownType 报文的类型没有注册
+var ErrUnknownType = errors.New("unknown message type")
+
+// JSONMessage JSONProto的报文，Data是原始的JSON。
+// 收到未注册的类型时返回JSONMessage(设置了AllowUnknown时)，发送时直接使用Type和Data
+type JSONMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// JSONProto JSON报文：4字节长度(大端) + {"type": 类型名, "data": 报文}，
+// 收到的报文按类型名反序列化为注册的类型(指针)，SendData直接传入注册类型的值或者指针。
+// 注册完成后可以在多个连接间共享
+type JSONProto struct {
+	frame *LengthProto
+
+	lock  sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+
+	// AllowUnknown 收到未注册的类型时返回*JSONMessage，否则作为proto错误
+	AllowUnknown bool
+}
+
+// NewJSONProto 创建JSONProto
+func NewJSONProto() *JSONProto {
+	frame, _ := NewLengthProto(4, nil, nil)
+	return &JSONProto{
+		frame: frame,
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Register 注册类型名对应的Go类型，v是该类型的值或者指针，如Register("login", &Login{})
+func (p *JSONProto) Register(name string, v interface{}) error {
+	if name == "" {
+		return fmt.Errorf("empty type name")
+	}
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return fmt.Errorf("nil type")
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if old, ok := p.types[name]; ok && old != t {
+		return fmt.Errorf("type name %s registered by %s", name, old)
+	}
+	if old, ok := p.names[t]; ok && old != name {
+		return fmt.Errorf("type %s registered as %s", t, old)
+	}
+	p.types[name] = t
+	p.names[t] = name
+	return nil
+}
+
+// TypeName 值对应的类型名
+func (p *JSONProto) TypeName(v interface{}) (string, bool) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	name, ok := p.names[t]
+	return name, ok
+}
+
+func (p *JSONProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *JSONProto) HeadLen() uint32 {
+	return p.frame.HeadLen()
+}
+
+func (p *JSONProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return p.frame.BodyLen(head)
+}
+
+func (p *JSONProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	msg := &JSONMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+
+	p.lock.RLock()
+	t, ok := p.types[msg.Type]
+	p.lock.RUnlock()
+
+	if !ok {
+		if p.AllowUnknown {
+			return msg, nil
+		}
+		return nil, fmt.Errorf("%w %s", ErrUnknownType, msg.Type)
+	}
+	v := reflect.New(t)
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, v.Interface()); err != nil {
+			return nil, fmt.Errorf("unmarshal %s failed, err = %s", msg.Type, err)
+		}
+	}
+	return v.Interface(), nil
+}
+
+func (p *JSONProto) Serialize(data interface{}) ([]byte, error) {
+	msg, ok := data.(*JSONMessage)
+	if !ok {
+		name, ok := p.TypeName(data)
+		if !ok {
+			return nil, fmt.Errorf("%w %T", ErrUnknownType, data)
+		}
+		body, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		msg = &JSONMessage{Type: name, Data: body}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return p.frame.Serialize(body)
+}
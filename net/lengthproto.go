@@ -0,0 +1,74 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// LengthProto 长度前缀的二进制帧：magic(可选) + 长度 + 报文体，
+// 长度只包含报文体。Parse返回[]byte，Serialize接受[]byte或者string。
+// 没有状态，可以在多个连接间共享
+type LengthProto struct {
+	size  int
+	order binary.ByteOrder
+	magic []byte
+}
+
+// NewLengthProto 创建长度前缀的proto，size为长度字段的字节数(1/2/4/8)，
+// order为nil时使用大端，magic非空时每一帧以magic开始
+func NewLengthProto(size int, order binary.ByteOrder, magic []byte) (*LengthProto, error) {
+	if !validUintSize(size) {
+		return nil, fmt.Errorf("invalid length size %d", size)
+	}
+	if order == nil {
+		order = binary.BigEndian
+	}
+	return &LengthProto{
+		size:  size,
+		order: order,
+		magic: append([]byte(nil), magic...),
+	}, nil
+}
+
+func (p *LengthProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *LengthProto) HeadLen() uint32 {
+	return uint32(len(p.magic) + p.size)
+}
+
+func (p *LengthProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	if !bytes.Equal(head[:len(p.magic)], p.magic) {
+		return nil, 0, fmt.Errorf("bad magic %x", head[:len(p.magic)])
+	}
+	size := getUint(head[len(p.magic):], p.size, p.order)
+	if !fitsUint(size, 4) {
+		return nil, 0, fmt.Errorf("body too large, size = %d", size)
+	}
+	return nil, uint32(size), nil
+}
+
+func (p *LengthProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return body, nil
+}
+
+func (p *LengthProto) Serialize(data interface{}) ([]byte, error) {
+	var body []byte
+	switch v := data.(type) {
+	case []byte:
+		body = v
+	case string:
+		body = []byte(v)
+	default:
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	if !fitsUint(uint64(len(body)), p.size) {
+		return nil, fmt.Errorf("body too large for %d bytes length, size = %d", p.size, len(body))
+	}
+	msg := make([]byte, len(p.magic)+p.size, len(p.magic)+p.size+len(body))
+	copy(msg, p.magic)
+	putUint(msg[len(p.magic):], p.size, p.order, uint64(len(body)))
+	return append(msg, body...), nil
+}
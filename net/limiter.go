@@ -0,0 +1,106 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiterQuantum 受限连接每次写的最大字节数，大的报文被拆成多次写，
+// 多个连接共享一个Limiter时按到达顺序轮流放行，不会被一个大报文独占
+const limiterQuantum = 16 * 1024
+
+// Limiter 字节速率限制(令牌桶)，可以被多个连接共享，
+// 等待者按先来先服务的顺序放行
+type Limiter struct {
+	lock   sync.Mutex
+	rate   float64 // 每秒字节数，<=0不限制
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	minBurst float64
+}
+
+// NewLimiter 创建Limiter，rate为每秒字节数，<=0不限制，
+// burst<=0时取rate的1/10
+func NewLimiter(rate int64, burst int) *Limiter {
+	return newLimiter(rate, burst, limiterQuantum)
+}
+
+// newLimiter burst不小于minBurst，按字节限速时至少要能放行一个limiterQuantum
+func newLimiter(rate int64, burst int, minBurst int) *Limiter {
+	l := &Limiter{minBurst: float64(minBurst)}
+	l.SetRate(rate, burst)
+	l.tokens = l.burst
+	return l
+}
+
+// SetRate 修改速率，可以在运行时调用
+func (l *Limiter) SetRate(rate int64, burst int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.advance(time.Now())
+	l.rate = float64(rate)
+	if burst <= 0 {
+		burst = int(rate / 10)
+	}
+	l.burst = max(float64(burst), l.minBurst)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Rate 当前速率
+func (l *Limiter) Rate() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int64(l.rate)
+}
+
+func (l *Limiter) advance(now time.Time) {
+	if !l.last.IsZero() && l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// reserve 预扣n个字节，返回需要等待的时间
+func (l *Limiter) reserve(n int) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	l.advance(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// WaitN 等待n个字节的额度，用于已经发生的流量(如读取)
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	return sleepCtx(ctx, l.reserve(n))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
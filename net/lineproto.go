@@ -0,0 +1,174 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	rawReadSize = 4096
+
+	defMaxLineLen = 64 * 1024
+)
+
+// ErrLineTooLong 超过最大长度仍然没有找到分隔符
+var ErrLineTooLong = errors.New("line too long")
+
+// Splitter proto的可选扩展，HeadLen返回0时从缓冲的数据中切分报文，用于分隔符、varint长度等头部不定长的帧格式。
+// 语义同bufio.SplitFunc：返回消耗的字节数和报文，数据不够时返回0, nil, nil，
+// 连接关闭时atEOF为true。切分出的报文交给Parse(nil, frame)解析。
+// 返回错误时丢弃advance字节，advance为0时丢弃所有缓冲的数据
+type Splitter interface {
+	Split(data []byte, atEOF bool) (advance int, frame []byte, err error)
+}
+
+// readSplit 读取数据直到切分出一个报文，连接关闭返回false
+func (n *SimpleNet) readSplit(conn *Connection, splitter Splitter, pending *[]byte) bool {
+	for {
+		advance, frame, err := splitter.Split(*pending, false)
+		if err != nil {
+			if advance > 0 && advance <= len(*pending) {
+				*pending = (*pending)[advance:]
+			} else {
+				*pending = nil
+			}
+			return n.splitError(conn, err)
+		}
+		if advance > 0 || frame != nil {
+			*pending = (*pending)[advance:]
+			if frame == nil {
+				continue
+			}
+			n.emitSplit(conn, frame)
+			return true
+		}
+		if limit := conn.MaxBodyLen(); limit >= 0 && int64(len(*pending)) > limit {
+			n.checkConnErr(0, fmt.Errorf("%w, buffered = %d, max = %d",
+				ErrFrameTooLarge, len(*pending), limit), conn)
+			return false
+		}
+
+		// 需要更多数据，切分出的报文引用旧的缓冲区，扩容时不能原地移动
+		buf := *pending
+		if cap(buf)-len(buf) < rawReadSize {
+			buf = make([]byte, len(buf), max(2*len(buf), len(buf)+rawReadSize))
+			copy(buf, *pending)
+		}
+		count, err := n.readSome(conn, buf[len(buf):cap(buf)])
+		*pending = buf[:len(buf)+count]
+		if err != nil && conn.upgradeInterrupted(err) {
+			return true
+		}
+		if err != nil {
+			if err == io.EOF && len(*pending) > 0 {
+				// 连接关闭前最后一个不完整的报文
+				if _, frame, serr := splitter.Split(*pending, true); serr == nil && frame != nil {
+					n.emitSplit(conn, frame)
+				}
+			}
+			n.checkConnErr(count, err, conn)
+			return false
+		}
+	}
+}
+
+func (n *SimpleNet) emitSplit(conn *Connection, frame []byte) {
+	n.logMsg(mylog.LevelInformational,
+		fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n", len(frame), conn.remoteAddr))
+	conn.mirrorFrame(false, frame)
+	data, err := conn.proto.Parse(nil, frame)
+	if err != nil {
+		n.splitError(conn, err)
+		return
+	}
+	if conn.intercept(data, noRelease) {
+		return
+	}
+	evtType, data := conn.dataEvent(data)
+	n.emit(&ConnEvent{
+		EventType: evtType,
+		Conn:      conn,
+		Data:      data,
+	})
+}
+
+func (n *SimpleNet) splitError(conn *Connection, err error) bool {
+	n.emit(&ConnEvent{
+		EventType: EventProtoError,
+		Conn:      conn,
+		Data:      err,
+	})
+	return n.handleProtoError(conn, err)
+}
+
+// LineProto 以分隔符结尾的文本行，用于telnet风格的管理控制台等文本协议。
+// Parse返回不包含分隔符的string，Serialize接受string或者[]byte并加上分隔符。
+// 没有状态，可以在多个连接间共享
+type LineProto struct {
+	delim []byte
+	max   int
+}
+
+// NewLineProto 创建按行切分的proto，delim为空时使用"\n"，
+// max为一行的最大长度(不包括分隔符)，<=0时为64K
+func NewLineProto(delim string, max int) *LineProto {
+	if delim == "" {
+		delim = "\n"
+	}
+	if max <= 0 {
+		max = defMaxLineLen
+	}
+	return &LineProto{delim: []byte(delim), max: max}
+}
+
+func (p *LineProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *LineProto) HeadLen() uint32 {
+	return 0
+}
+
+func (p *LineProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("line proto has no head")
+}
+
+func (p *LineProto) Split(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, p.delim); i >= 0 {
+		if i > p.max {
+			return i + len(p.delim), nil, ErrLineTooLong
+		}
+		return i + len(p.delim), data[:i], nil
+	}
+	if len(data) > p.max+len(p.delim) {
+		// 丢弃已经收到的部分，剩余的部分作为下一行
+		return len(data), nil, ErrLineTooLong
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func (p *LineProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return string(body), nil
+}
+
+func (p *LineProto) Serialize(data interface{}) ([]byte, error) {
+	var line []byte
+	switch v := data.(type) {
+	case string:
+		line = make([]byte, 0, len(v)+len(p.delim))
+		line = append(line, v...)
+	case []byte:
+		line = make([]byte, 0, len(v)+len(p.delim))
+		line = append(line, v...)
+	default:
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	return append(line, p.delim...), nil
+}
@@ -0,0 +1,370 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+	"github.com/buf1024/golib/spool"
+)
+
+// ReconnectPolicy 重连策略，第n次重连前等待MinDelay*Multiplier^(n-1)，
+// 不超过MaxDelay，再加上±Jitter比例的随机抖动
+type ReconnectPolicy struct {
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+	Multiplier float64
+	Jitter     float64 // 0~1
+	MaxRetries int     // 连续失败的次数上限，<=0不限制
+}
+
+// DefReconnectPolicy 默认重连策略
+var DefReconnectPolicy = ReconnectPolicy{
+	MinDelay:   100 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// delay 第attempt次(从1开始)重连前的等待时间
+func (p *ReconnectPolicy) delay(attempt int) time.Duration {
+	d := float64(p.MinDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(d)
+}
+
+// Reconnect EventReconnecting事件的Data
+type Reconnect struct {
+	Attempt int
+	Delay   time.Duration
+	Err     error // 上一次连接失败的原因，第一次为nil
+}
+
+// ManagedConnection 自动重连的客户端连接，连接断开后按ReconnectPolicy重连，
+// 发出EventReconnecting/EventReconnected/EventReconnectFailed事件，
+// 事件的Conn是断开的连接或者新连接，可以通过Connection.Managed找到ManagedConnection。
+// 要停止重连需要调用Close，直接CloseConn会触发重连
+type ManagedConnection struct {
+	net     *SimpleNet
+	addr    string
+	factory ProtoFactory
+	policy  ReconnectPolicy
+
+	fallbacks      []string // ConnectFailover的备用地址
+	attemptTimeout time.Duration
+
+	lock     sync.Mutex
+	conn     *Connection
+	endpoint string // 当前连接的地址，服务地址时为解析出的地址
+	spool    *spool.Spool
+	offline  *offlineQueue
+
+	replaying bool          // 重连后正在发送离线报文
+	pending   []interface{} // 发送离线报文期间SendData的报文
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	UserData interface{}
+}
+
+// ConnectManaged 连接服务器并在断开后自动重连，policy为nil时使用DefReconnectPolicy，
+// 首次连接失败直接返回错误
+func (n *SimpleNet) ConnectManaged(addr string, proto IProto, policy *ReconnectPolicy) (*ManagedConnection, error) {
+	return n.ConnectManagedFactory(addr, func(*Connection) IProto { return proto }, policy)
+}
+
+// ConnectManagedFactory 同ConnectManaged，每次连接使用factory创建的proto
+func (n *SimpleNet) ConnectManagedFactory(addr string, factory ProtoFactory, policy *ReconnectPolicy) (*ManagedConnection, error) {
+	m := n.newManaged(addr, factory, policy)
+	if err := m.start(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (n *SimpleNet) newManaged(addr string, factory ProtoFactory, policy *ReconnectPolicy) *ManagedConnection {
+	m := &ManagedConnection{
+		net:     n,
+		addr:    addr,
+		factory: factory,
+		policy:  DefReconnectPolicy,
+	}
+	if policy != nil {
+		m.policy = *policy
+	}
+	if m.policy.Multiplier < 1 {
+		m.policy.Multiplier = 1
+	}
+	m.ctx, m.cancel = context.WithCancel(n.ctx)
+	return m
+}
+
+// start 首次连接，成功后开始等待断开重连
+func (m *ManagedConnection) start() error {
+	conn, endpoint, err := m.dial()
+	if err != nil {
+		m.cancel()
+		return err
+	}
+	m.conn, m.endpoint = conn, endpoint
+
+	go m.run()
+	if r, service, ok := m.net.resolverOf(m.addr); ok {
+		go m.watchService(r, service)
+	}
+	return nil
+}
+
+// Managed 连接所属的ManagedConnection，不是自动重连的连接返回nil
+func (c *Connection) Managed() *ManagedConnection {
+	return c.managed
+}
+
+func (m *ManagedConnection) dial() (*Connection, string, error) {
+	var (
+		newconn  net.Conn
+		endpoint string
+		err      error
+	)
+	if len(m.fallbacks) > 0 {
+		newconn, endpoint, err = m.dialFailover()
+	} else {
+		newconn, endpoint, err = m.net.dialEndpoint(m.ctx, "tcp", m.addr)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	conn, err := m.net.attachConn(newconn, m.factory, m)
+	return conn, endpoint, err
+}
+
+// run 等待连接断开后重连
+func (m *ManagedConnection) run() {
+	for {
+		conn := m.Conn()
+		select {
+		case <-conn.ctx.Done():
+		case <-m.ctx.Done():
+			return
+		}
+		if m.ctx.Err() != nil || m.net.shutting.Load() {
+			return
+		}
+		if !m.reconnect(conn) {
+			return
+		}
+	}
+}
+
+// reconnect 按策略重连，成功后重放离线报文
+func (m *ManagedConnection) reconnect(old *Connection) bool {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if m.policy.MaxRetries > 0 && attempt > m.policy.MaxRetries {
+			m.net.logMsg(mylog.LevelError,
+				fmt.Sprintf("reconnect %s failed after %d attempts, err = %s\n",
+					m.addr, attempt-1, lastErr))
+			m.net.emit(&ConnEvent{
+				EventType: EventReconnectFailed,
+				Conn:      old,
+				Data:      lastErr,
+			})
+			return false
+		}
+		delay := m.policy.delay(attempt)
+		m.net.emit(&ConnEvent{
+			EventType: EventReconnecting,
+			Conn:      old,
+			Data:      &Reconnect{Attempt: attempt, Delay: delay, Err: lastErr},
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-m.ctx.Done():
+			timer.Stop()
+			return false
+		}
+
+		conn, endpoint, err := m.dial()
+		if err != nil {
+			lastErr = err
+			m.net.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("reconnect %s attempt %d failed, err = %s\n",
+					m.addr, attempt, err))
+			continue
+		}
+
+		m.lock.Lock()
+		m.conn, m.endpoint = conn, endpoint
+		m.replaying = true
+		m.lock.Unlock()
+		m.drain(conn)
+
+		m.net.logMsg(mylog.LevelInformational,
+			fmt.Sprintf("reconnected %s after %d attempts\n", m.addr, attempt))
+		m.net.emit(&ConnEvent{
+			EventType: EventReconnected,
+			Conn:      conn,
+			Data:      attempt,
+		})
+		return true
+	}
+}
+
+// Conn 当前的连接，断开重连期间返回断开的连接
+func (m *ManagedConnection) Conn() *Connection {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.conn
+}
+
+// Connected 当前是否已连接
+func (m *ManagedConnection) Connected() bool {
+	return m.Conn().Status() == StatusConnected
+}
+
+// SetSpool 设置离线队列，断开期间SendData的报文序列化后存入s，重连后按顺序重放。
+// 重放前SendData的报文也进入s，保证顺序
+func (m *ManagedConnection) SetSpool(s *spool.Spool) {
+	m.lock.Lock()
+	m.spool = s
+	conn := m.conn
+	if m.replaying || conn.Status() != StatusConnected {
+		m.lock.Unlock()
+		return
+	}
+	m.replaying = true
+	m.lock.Unlock()
+	m.drain(conn)
+}
+
+// SendData 发送数据，断开期间有离线队列时存入队列，否则返回错误
+func (m *ManagedConnection) SendData(data interface{}) error {
+	m.lock.Lock()
+	conn := m.conn
+	switch {
+	case m.spool == nil && m.offline == nil:
+	case m.replaying:
+		// 排在正在发送的离线报文之后
+		m.pending = append(m.pending, data)
+		m.lock.Unlock()
+		return nil
+	case m.spool == nil:
+		if conn.Status() != StatusConnected || len(m.offline.items) > 0 {
+			defer m.lock.Unlock()
+			return m.offline.push(data)
+		}
+	case conn.Status() != StatusConnected || m.spool.Size() > 0:
+		defer m.lock.Unlock()
+		return spoolData(m.spool, conn, data)
+	}
+	m.lock.Unlock()
+	return m.net.SendData(conn, data)
+}
+
+// spoolData 报文序列化后存入s
+func spoolData(s *spool.Spool, conn *Connection, data interface{}) error {
+	msg, alloc, err := conn.serialize(data)
+	if err != nil {
+		return err
+	}
+	err = s.Push(msg)
+	if alloc != nil {
+		alloc.Free(msg)
+	}
+	return err
+}
+
+// drain 重连后按顺序发送spool、内存离线队列和期间SendData的报文，发送时不持有m.lock，
+// 发送失败后剩余的报文放回离线队列等待下一次重连
+func (m *ManagedConnection) drain(conn *Connection) {
+	m.lock.Lock()
+	s := m.spool
+	m.lock.Unlock()
+
+	ok := m.replay(conn, s) && m.flush(conn)
+	for {
+		m.lock.Lock()
+		pending := m.pending
+		m.pending = nil
+		if len(pending) == 0 {
+			m.replaying = false
+			m.lock.Unlock()
+			return
+		}
+		m.lock.Unlock()
+
+		for _, data := range pending {
+			if ok {
+				if err := m.net.SendData(conn, data); err == nil {
+					continue
+				}
+				ok = false
+			}
+			m.keep(data)
+		}
+	}
+}
+
+// keep 发送失败的报文放回离线队列
+func (m *ManagedConnection) keep(data interface{}) {
+	m.lock.Lock()
+	s, q, conn := m.spool, m.offline, m.conn
+	if s == nil {
+		if q != nil {
+			q.push(data)
+		}
+		m.lock.Unlock()
+		return
+	}
+	m.lock.Unlock()
+	if err := spoolData(s, conn, data); err != nil {
+		m.net.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("spool message to %s failed, err = %s\n", m.addr, err))
+	}
+}
+
+// replay 重放离线报文，等待每个报文写出，失败时剩余的报文留在队列中，返回是否全部发送
+func (m *ManagedConnection) replay(conn *Connection, s *spool.Spool) bool {
+	if s == nil || s.Size() == 0 {
+		return true
+	}
+	count, err := s.Replay(func(msg []byte) error {
+		if conn.Status() != StatusConnected {
+			return ErrConnClosed
+		}
+		done := make(chan error, 1)
+		req := newWriteReq(msg)
+		req.done = func(err error) { done <- err }
+		if err := conn.enqueue(req); err != nil {
+			return err
+		}
+		return <-done
+	})
+	if err != nil {
+		m.net.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("replay spool to %s stopped after %d messages, err = %s\n",
+				m.addr, count, err))
+		return false
+	}
+	return true
+}
+
+// Close 停止重连并关闭当前连接，离线队列由调用方关闭
+func (m *ManagedConnection) Close() error {
+	m.cancel()
+	return m.net.CloseConn(m.Conn())
+}
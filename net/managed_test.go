@@ -0,0 +1,77 @@
+package net
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buf1024/golib/spool"
+)
+
+// TestManagedReplayOrder 断开期间、重放期间和重放之后发送的报文按顺序到达
+func TestManagedReplayOrder(t *testing.T) {
+	for _, name := range []string{"offline", "spool"} {
+		t.Run(name, func(t *testing.T) {
+			n := newTestNet(t)
+			addr, conns := rawServer(t)
+			policy := ReconnectPolicy{MinDelay: 200 * time.Millisecond, Multiplier: 1}
+			m, err := n.ConnectManaged(addr, &benchProto{}, &policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { m.Close() })
+			if name == "spool" {
+				s, err := spool.Open(t.TempDir(), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { s.Close() })
+				m.SetSpool(s)
+			} else {
+				m.SetOfflineQueue(&OfflineQueue{})
+			}
+
+			(<-conns).Close()
+			waitFor(t, "disconnect", func() bool { return !m.Connected() })
+
+			const total = 300
+			go func() {
+				for i := 0; i < total; i++ {
+					if err := m.SendData([]byte(strconv.Itoa(i))); err != nil {
+						t.Errorf("send %d failed, err = %v", i, err)
+						return
+					}
+					if i%10 == 0 {
+						time.Sleep(time.Millisecond * 5)
+					}
+				}
+			}()
+
+			var server io.ReadCloser
+			select {
+			case c := <-conns:
+				server = c
+			case <-time.After(5 * time.Second):
+				t.Fatal("no reconnect")
+			}
+			defer server.Close()
+			r := bufio.NewReader(server)
+			head := make([]byte, 4)
+			for i := 0; i < total; i++ {
+				if _, err := io.ReadFull(r, head); err != nil {
+					t.Fatalf("read %d failed, err = %v", i, err)
+				}
+				body := make([]byte, binary.BigEndian.Uint32(head))
+				if _, err := io.ReadFull(r, body); err != nil {
+					t.Fatalf("read %d failed, err = %v", i, err)
+				}
+				if string(body) != strconv.Itoa(i) {
+					t.Fatalf("message %d = %s", i, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// connLimit 监听的连接数上限，已accept还在握手中的连接也占用名额
+type connLimit struct {
+	lock sync.Mutex
+	max  int
+	park bool
+	used int
+	wake chan struct{}
+}
+
+// SetMaxConns 设置最大连接数，max<=0不限制。超过上限时park为false则直接关闭新连接，
+// 发出EventConnectionRejected(Data为Reason是RejectLimit的*RejectError)；park为true则暂停accept直到有连接关闭，
+// 新连接留在系统的backlog中。可以在运行时修改，已有的连接不受影响
+func (l *Listener) SetMaxConns(max int, park bool) {
+	c := &l.limit
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.max, c.park = max, park
+	c.notify()
+}
+
+// MaxConns 当前的最大连接数设置
+func (l *Listener) MaxConns() (max int, park bool) {
+	c := &l.limit
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.max, c.park
+}
+
+func (c *connLimit) parking() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.max > 0 && c.park
+}
+
+// acquire 占用一个名额，没有名额时park模式等待，否则返回false。ctx结束返回false
+func (c *connLimit) acquire(ctx context.Context) bool {
+	for {
+		c.lock.Lock()
+		if c.max <= 0 || c.used < c.max {
+			c.used++
+			c.lock.Unlock()
+			return true
+		}
+		if !c.park {
+			c.lock.Unlock()
+			return false
+		}
+		if c.wake == nil {
+			c.wake = make(chan struct{})
+		}
+		wake := c.wake
+		c.lock.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (c *connLimit) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.used--
+	c.notify()
+}
+
+// notify 唤醒等待名额的accept，需要持有锁
+func (c *connLimit) notify() {
+	if c.wake != nil {
+		close(c.wake)
+		c.wake = nil
+	}
+}
+
+// rejectConn 关闭被拒绝的连接，发出EventConnectionRejected，Data为*RejectError。
+// 事件的Conn只用于标识监听和地址，不在连接列表中
+func (n *SimpleNet) rejectConn(l *Listener, newconn net.Conn, reason string, err error) {
+	newconn.Close()
+	l.stats.reject(reason)
+	rejectErr := &RejectError{Reason: reason, Err: err}
+	n.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("reject connection from %s, err = %s\n", newconn.RemoteAddr(), rejectErr))
+
+	conn := &Connection{
+		net:        n,
+		listen:     l,
+		id:         atomic.AddInt64(&n.nextid, 1),
+		status:     StatusBroken,
+		conn:       newconn,
+		localAddr:  newconn.LocalAddr().String(),
+		remoteAddr: newconn.RemoteAddr().String(),
+	}
+	conn.ctx, conn.cancel = context.WithCancelCause(l.ctx)
+	conn.cancel(ErrConnClosed)
+
+	n.emit(&ConnEvent{
+		EventType: EventConnectionRejected,
+		Conn:      conn,
+		Data:      rejectErr,
+	})
+}
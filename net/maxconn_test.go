@@ -0,0 +1,58 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func dialRaw(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestMaxConnsReject(t *testing.T) {
+	n := newTestNet(t)
+	l, err := n.Listen("127.0.0.1:0", &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetMaxConns(1, false)
+
+	dialRaw(t, l.LocalAddress())
+	waitEvent(t, n, EventNewConnection)
+	second := dialRaw(t, l.LocalAddress())
+	evt := waitEvent(t, n, EventConnectionRejected)
+	if rerr, ok := evt.Data.(*RejectError); !ok || rerr.Reason != RejectLimit {
+		t.Fatalf("reject data = %v", evt.Data)
+	}
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expect rejected connection closed")
+	}
+}
+
+func TestMaxConnsPark(t *testing.T) {
+	n := newTestNet(t)
+	l, err := n.Listen("127.0.0.1:0", &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetMaxConns(1, true)
+
+	first := dialRaw(t, l.LocalAddress())
+	waitEvent(t, n, EventNewConnection)
+	dialRaw(t, l.LocalAddress())
+	// 名额用完，第二个连接留在backlog中
+	if evt, _ := n.PollEvent(100); evt.EventType != EventTimeout {
+		t.Fatalf("unexpected event %d while parked", evt.EventType)
+	}
+	first.Close()
+	waitEvent(t, n, EventConnectionClosed)
+	waitEvent(t, n, EventNewConnection)
+}
@@ -0,0 +1,70 @@
+package net
+
+import "sync/atomic"
+
+type mirror struct {
+	target   *Connection
+	outbound bool
+	dropped  atomic.Int64
+}
+
+// Mirror 把连接收到的原始报文(outbound为true时也包括发出的报文)异步复制到target，
+// target的发送队列满时丢弃，不影响本连接。target为nil时停止复制。
+// 可用于把生产流量复制给新的后端做影子测试，target的响应需要自行丢弃
+func (c *Connection) Mirror(target *Connection, outbound bool) {
+	if target == nil {
+		c.mirror.Store(nil)
+		return
+	}
+	c.mirror.Store(&mirror{
+		target:   target,
+		outbound: outbound,
+	})
+}
+
+// MirrorDropped 当前复制中丢弃的报文数
+func (c *Connection) MirrorDropped() int64 {
+	if m := c.mirror.Load(); m != nil {
+		return m.dropped.Load()
+	}
+	return 0
+}
+
+func (c *Connection) mirrorFrame(outbound bool, parts ...[]byte) {
+	m := c.mirror.Load()
+	if m == nil || (outbound && !m.outbound) {
+		return
+	}
+	if m.target.Status() != StatusConnected {
+		c.mirror.CompareAndSwap(m, nil)
+		return
+	}
+	var frame []byte
+	for _, part := range parts {
+		frame = append(frame, part...)
+	}
+	if !m.target.trySend(frame) {
+		m.dropped.Add(1)
+	}
+}
+
+// trySend 不阻塞地放入发送队列，队列满或者连接已关闭时返回false
+func (c *Connection) trySend(msg []byte) bool {
+	if c.Status() != StatusConnected {
+		return false
+	}
+	req := newWriteReq(msg)
+	if c.outbound(req) != nil {
+		return false
+	}
+	c.addQueued(req.size)
+	select {
+	case c.msgChan <- req:
+		c.kickWrite()
+		c.checkClosed()
+		return true
+	default:
+		c.addQueued(-req.size)
+		return false
+	}
+}
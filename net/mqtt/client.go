@@ -0,0 +1,200 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// ClientOptions 客户端连接参数
+type ClientOptions struct {
+	ClientID     string
+	Username     string
+	Password     []byte
+	CleanSession bool
+	// KeepAlive 通知服务端的keep alive，需要同时开启SimpleNet的SetHeartbeat发送PINGREQ
+	KeepAlive time.Duration
+	Will      *Message
+}
+
+// Client 简单的MQTT客户端：连接后发送CONNECT，分配报文ID，处理QoS 1/2的确认流程。
+// 收到的报文仍然通过PollEvent获取，交给Handle处理确认后再由应用处理
+type Client struct {
+	net  *mynet.SimpleNet
+	conn *mynet.Connection
+
+	lock     sync.Mutex
+	nextID   uint16
+	inflight map[uint16]Packet   // 已发送等待确认的报文
+	received map[uint16]struct{} // 已收到等待PUBREL的QoS 2报文
+}
+
+// Dial 连接服务端并发送CONNECT，CONNACK作为EventNewConnectionData收到
+func Dial(n *mynet.SimpleNet, addr string, opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = &ClientOptions{CleanSession: true}
+	}
+	conn, err := n.Connect(addr, New())
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		net:      n,
+		conn:     conn,
+		inflight: make(map[uint16]Packet),
+		received: make(map[uint16]struct{}),
+	}
+	err = n.SendData(conn, &Connect{
+		CleanSession: opts.CleanSession,
+		KeepAlive:    uint16(min(opts.KeepAlive/time.Second, 0xffff)),
+		ClientID:     opts.ClientID,
+		Will:         opts.Will,
+		Username:     opts.Username,
+		Password:     opts.Password,
+	})
+	if err != nil {
+		n.CloseConn(conn)
+		return nil, fmt.Errorf("send connect failed, err = %s", err)
+	}
+	return c, nil
+}
+
+// Conn 客户端的连接
+func (c *Client) Conn() *mynet.Connection {
+	return c.conn
+}
+
+// Inflight 已发送还没有完成确认的报文数
+func (c *Client) Inflight() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.inflight)
+}
+
+// allocID 分配没有在使用的报文ID，0不使用
+func (c *Client) allocID(p Packet) (uint16, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i := 0; i < 0xffff; i++ {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		if _, ok := c.inflight[c.nextID]; !ok {
+			c.inflight[c.nextID] = p
+			return c.nextID, nil
+		}
+	}
+	return 0, fmt.Errorf("no free packet id")
+}
+
+func (c *Client) release(id uint16) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.inflight, id)
+}
+
+// send 发送需要确认的报文，失败时释放报文ID
+func (c *Client) send(id uint16, p Packet) (uint16, error) {
+	if err := c.net.SendData(c.conn, p); err != nil {
+		c.release(id)
+		return 0, err
+	}
+	return id, nil
+}
+
+// Publish 发布消息，QoS大于0时返回报文ID
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) (uint16, error) {
+	if err := checkQoS(qos); err != nil {
+		return 0, err
+	}
+	p := &Publish{QoS: qos, Retain: retain, Topic: topic, Payload: payload}
+	if qos == 0 {
+		return 0, c.net.SendData(c.conn, p)
+	}
+	id, err := c.allocID(p)
+	if err != nil {
+		return 0, err
+	}
+	p.PacketID = id
+	return c.send(id, p)
+}
+
+// Subscribe 订阅，结果在SUBACK中返回
+func (c *Client) Subscribe(subs ...Subscription) (uint16, error) {
+	p := &Subscribe{Subscriptions: subs}
+	id, err := c.allocID(p)
+	if err != nil {
+		return 0, err
+	}
+	p.PacketID = id
+	return c.send(id, p)
+}
+
+// Unsubscribe 取消订阅
+func (c *Client) Unsubscribe(topics ...string) (uint16, error) {
+	p := &Unsubscribe{Topics: topics}
+	id, err := c.allocID(p)
+	if err != nil {
+		return 0, err
+	}
+	p.PacketID = id
+	return c.send(id, p)
+}
+
+// Disconnect 发送DISCONNECT，写入后关闭连接
+func (c *Client) Disconnect() error {
+	return c.net.SendDataFunc(c.conn, &Disconnect{}, func(err error) {
+		c.net.CloseConn(c.conn)
+	})
+}
+
+// Handle 处理收到的报文，自动回复PUBACK/PUBREC/PUBREL/PUBCOMP并释放完成的报文ID。
+// 返回false表示报文只用于确认流程(或者是重复的QoS 2消息)，应用不需要再处理
+func (c *Client) Handle(data interface{}) (bool, error) {
+	switch p := data.(type) {
+	case *Publish:
+		switch p.QoS {
+		case 1:
+			return true, c.net.SendData(c.conn, &Puback{PacketID: p.PacketID})
+		case 2:
+			c.lock.Lock()
+			_, dup := c.received[p.PacketID]
+			c.received[p.PacketID] = struct{}{}
+			c.lock.Unlock()
+			return !dup, c.net.SendData(c.conn, &Pubrec{PacketID: p.PacketID})
+		}
+		return true, nil
+	case *Pubrel:
+		c.lock.Lock()
+		delete(c.received, p.PacketID)
+		c.lock.Unlock()
+		return false, c.net.SendData(c.conn, &Pubcomp{PacketID: p.PacketID})
+	case *Pubrec:
+		rel := &Pubrel{PacketID: p.PacketID}
+		c.lock.Lock()
+		if _, ok := c.inflight[p.PacketID]; ok {
+			c.inflight[p.PacketID] = rel
+		}
+		c.lock.Unlock()
+		return false, c.net.SendData(c.conn, rel)
+	case *Puback:
+		c.release(p.PacketID)
+		return false, nil
+	case *Pubcomp:
+		c.release(p.PacketID)
+		return false, nil
+	case *Suback:
+		c.release(p.PacketID)
+	case *Unsuback:
+		c.release(p.PacketID)
+	case *Pingresp:
+		return false, nil
+	}
+	return true, nil
+}
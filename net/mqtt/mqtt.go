@@ -0,0 +1,93 @@
+// Package mqtt MQTT 3.1.1的报文编解码(IProto)和简单的客户端，
+// 用于在SimpleNet上实现IoT网关或者设备端
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// ErrMalformed 报文格式错误
+var ErrMalformed = errors.New("malformed mqtt packet")
+
+// Proto MQTT的IProto实现，剩余长度是变长的，使用Split切分。
+// Parse返回Packet(报文的指针)，Serialize接受Packet。
+// 实现了Heartbeater，收到PINGREQ时自动回复PINGRESP，客户端开启SetHeartbeat即为keep alive。
+// 没有状态，可以在多个连接间共享
+type Proto struct {
+	// MaxPacketSize 报文(包括固定头)的最大长度，<=0时不限制(协议上限256M)
+	MaxPacketSize int
+}
+
+// New 创建Proto
+func New() *Proto {
+	return &Proto{}
+}
+
+func (p *Proto) FilterAccept(conn *mynet.Connection) bool {
+	return true
+}
+
+// HeadLen 固定头的长度不固定，使用Split切分
+func (p *Proto) HeadLen() uint32 {
+	return 0
+}
+
+func (p *Proto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("mqtt has variable length head")
+}
+
+func (p *Proto) Split(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil
+	}
+	size, n, err := readVarint(data[1:])
+	if err != nil {
+		// 无法再找到报文边界
+		return len(data), nil, err
+	}
+	if n == 0 {
+		return 0, nil, nil
+	}
+	total := 1 + n + size
+	if p.MaxPacketSize > 0 && total > p.MaxPacketSize {
+		return len(data), nil, fmt.Errorf("%w, packet too large, size = %d, max = %d",
+			ErrMalformed, total, p.MaxPacketSize)
+	}
+	if len(data) < total {
+		return 0, nil, nil
+	}
+	return total, data[:total], nil
+}
+
+func (p *Proto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return Decode(body)
+}
+
+func (p *Proto) Serialize(data interface{}) ([]byte, error) {
+	packet, ok := data.(Packet)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	return Encode(packet)
+}
+
+func (p *Proto) Ping() interface{} {
+	return &Pingreq{}
+}
+
+func (p *Proto) IsPing(data interface{}) bool {
+	_, ok := data.(*Pingreq)
+	return ok
+}
+
+func (p *Proto) Pong(ping interface{}) interface{} {
+	return &Pingresp{}
+}
+
+func (p *Proto) IsPong(data interface{}) bool {
+	_, ok := data.(*Pingresp)
+	return ok
+}
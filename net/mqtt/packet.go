@@ -0,0 +1,647 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// 报文类型
+const (
+	TypeConnect     = 1
+	TypeConnack     = 2
+	TypePublish     = 3
+	TypePuback      = 4
+	TypePubrec      = 5
+	TypePubrel      = 6
+	TypePubcomp     = 7
+	TypeSubscribe   = 8
+	TypeSuback      = 9
+	TypeUnsubscribe = 10
+	TypeUnsuback    = 11
+	TypePingreq     = 12
+	TypePingresp    = 13
+	TypeDisconnect  = 14
+)
+
+// CONNACK的返回码
+const (
+	ConnAccepted                 = 0
+	ConnRefusedProtocolVersion   = 1
+	ConnRefusedIdentifier        = 2
+	ConnRefusedServerUnavailable = 3
+	ConnRefusedBadCredentials    = 4
+	ConnRefusedNotAuthorized     = 5
+)
+
+// SubackFailure SUBACK中订阅失败的返回码
+const SubackFailure = 0x80
+
+const (
+	protocolName  = "MQTT"
+	protocolLevel = 4
+
+	maxRemainingLen = 268435455
+)
+
+// Packet MQTT报文，Parse返回报文的指针，Serialize接受报文的指针
+type Packet interface {
+	Type() byte
+	flags() byte
+	appendBody(dst []byte) ([]byte, error)
+	decode(flags byte, body []byte) error
+}
+
+// Message 遗嘱消息
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Connect CONNECT，Username/Password为空时不设置对应的标志
+type Connect struct {
+	ProtocolName  string // 默认MQTT
+	ProtocolLevel byte   // 默认4(3.1.1)
+	CleanSession  bool
+	KeepAlive     uint16 // 秒
+	ClientID      string
+	Will          *Message
+	Username      string
+	Password      []byte
+}
+
+// Connack CONNACK
+type Connack struct {
+	SessionPresent bool
+	ReturnCode     byte
+}
+
+// Publish PUBLISH，QoS为0时没有PacketID
+type Publish struct {
+	Dup      bool
+	QoS      byte
+	Retain   bool
+	Topic    string
+	PacketID uint16
+	Payload  []byte
+}
+
+// Puback PUBACK
+type Puback struct{ PacketID uint16 }
+
+// Pubrec PUBREC
+type Pubrec struct{ PacketID uint16 }
+
+// Pubrel PUBREL
+type Pubrel struct{ PacketID uint16 }
+
+// Pubcomp PUBCOMP
+type Pubcomp struct{ PacketID uint16 }
+
+// Subscription 订阅的主题
+type Subscription struct {
+	Topic string
+	QoS   byte
+}
+
+// Subscribe SUBSCRIBE
+type Subscribe struct {
+	PacketID      uint16
+	Subscriptions []Subscription
+}
+
+// Suback SUBACK，ReturnCodes依次为每个订阅授予的QoS或者SubackFailure
+type Suback struct {
+	PacketID    uint16
+	ReturnCodes []byte
+}
+
+// Unsubscribe UNSUBSCRIBE
+type Unsubscribe struct {
+	PacketID uint16
+	Topics   []string
+}
+
+// Unsuback UNSUBACK
+type Unsuback struct{ PacketID uint16 }
+
+// Pingreq PINGREQ
+type Pingreq struct{}
+
+// Pingresp PINGRESP
+type Pingresp struct{}
+
+// Disconnect DISCONNECT
+type Disconnect struct{}
+
+func newPacket(typ byte) (Packet, error) {
+	switch typ {
+	case TypeConnect:
+		return &Connect{}, nil
+	case TypeConnack:
+		return &Connack{}, nil
+	case TypePublish:
+		return &Publish{}, nil
+	case TypePuback:
+		return &Puback{}, nil
+	case TypePubrec:
+		return &Pubrec{}, nil
+	case TypePubrel:
+		return &Pubrel{}, nil
+	case TypePubcomp:
+		return &Pubcomp{}, nil
+	case TypeSubscribe:
+		return &Subscribe{}, nil
+	case TypeSuback:
+		return &Suback{}, nil
+	case TypeUnsubscribe:
+		return &Unsubscribe{}, nil
+	case TypeUnsuback:
+		return &Unsuback{}, nil
+	case TypePingreq:
+		return &Pingreq{}, nil
+	case TypePingresp:
+		return &Pingresp{}, nil
+	case TypeDisconnect:
+		return &Disconnect{}, nil
+	}
+	return nil, fmt.Errorf("%w, bad packet type %d", ErrMalformed, typ)
+}
+
+// Encode 编码报文
+func Encode(p Packet) ([]byte, error) {
+	body, err := p.appendBody(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRemainingLen {
+		return nil, fmt.Errorf("packet too large, size = %d", len(body))
+	}
+	msg := make([]byte, 0, 5+len(body))
+	msg = append(msg, p.Type()<<4|p.flags())
+	msg = appendVarint(msg, len(body))
+	return append(msg, body...), nil
+}
+
+// Decode 解码一个完整的报文
+func Decode(frame []byte) (Packet, error) {
+	size, n, err := readVarint(frame[1:])
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || len(frame) != 1+n+size {
+		return nil, fmt.Errorf("%w, bad remaining length", ErrMalformed)
+	}
+	p, err := newPacket(frame[0] >> 4)
+	if err != nil {
+		return nil, err
+	}
+	flags := frame[0] & 0x0f
+	if p.Type() != TypePublish && flags != p.flags() {
+		return nil, fmt.Errorf("%w, bad flags %x for packet type %d", ErrMalformed, flags, p.Type())
+	}
+	if err := p.decode(flags, frame[1+n:]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// readVarint 剩余长度，数据不够时n为0
+func readVarint(data []byte) (size, n int, err error) {
+	mul := 1
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, nil
+		}
+		size += int(data[i]&0x7f) * mul
+		if data[i]&0x80 == 0 {
+			return size, i + 1, nil
+		}
+		mul *= 128
+	}
+	return 0, 0, fmt.Errorf("%w, remaining length too long", ErrMalformed)
+}
+
+func appendVarint(dst []byte, size int) []byte {
+	for {
+		b := byte(size % 128)
+		size /= 128
+		if size > 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+		if size == 0 {
+			return dst
+		}
+	}
+}
+
+func appendString(dst []byte, s string) ([]byte, error) {
+	if len(s) > 0xffff {
+		return nil, fmt.Errorf("string too long, size = %d", len(s))
+	}
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...), nil
+}
+
+func appendBytes(dst []byte, b []byte) ([]byte, error) {
+	return appendString(dst, string(b))
+}
+
+// decoder 按顺序读取报文体，出错后的读取都返回零值
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(what string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w, bad %s", ErrMalformed, what)
+	}
+}
+
+func (d *decoder) byte(what string) byte {
+	if d.err != nil || len(d.data) < 1 {
+		d.fail(what)
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uint16(what string) uint16 {
+	if d.err != nil || len(d.data) < 2 {
+		d.fail(what)
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.data)
+	d.data = d.data[2:]
+	return v
+}
+
+func (d *decoder) bytes(what string) []byte {
+	size := int(d.uint16(what))
+	if d.err != nil || len(d.data) < size {
+		d.fail(what)
+		return nil
+	}
+	b := append([]byte(nil), d.data[:size]...)
+	d.data = d.data[size:]
+	return b
+}
+
+func (d *decoder) string(what string) string {
+	s := string(d.bytes(what))
+	if d.err == nil && !utf8.ValidString(s) {
+		d.fail(what)
+	}
+	return s
+}
+
+func (d *decoder) rest() []byte {
+	b := append([]byte(nil), d.data...)
+	d.data = nil
+	return b
+}
+
+func (d *decoder) done() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%w, %d trailing bytes", ErrMalformed, len(d.data))
+	}
+	return d.err
+}
+
+func checkQoS(qos byte) error {
+	if qos > 2 {
+		return fmt.Errorf("%w, bad qos %d", ErrMalformed, qos)
+	}
+	return nil
+}
+
+func (p *Connect) Type() byte  { return TypeConnect }
+func (p *Connect) flags() byte { return 0 }
+
+func (p *Connect) appendBody(dst []byte) ([]byte, error) {
+	name, level := p.ProtocolName, p.ProtocolLevel
+	if name == "" {
+		name = protocolName
+	}
+	if level == 0 {
+		level = protocolLevel
+	}
+	var flags byte
+	if p.CleanSession {
+		flags |= 0x02
+	}
+	if p.Will != nil {
+		if err := checkQoS(p.Will.QoS); err != nil {
+			return nil, err
+		}
+		flags |= 0x04 | p.Will.QoS<<3
+		if p.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if len(p.Password) > 0 {
+		flags |= 0x40
+	}
+	if p.Username != "" {
+		flags |= 0x80
+	}
+
+	dst, err := appendString(dst, name)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, level, flags)
+	dst = binary.BigEndian.AppendUint16(dst, p.KeepAlive)
+	if dst, err = appendString(dst, p.ClientID); err != nil {
+		return nil, err
+	}
+	if p.Will != nil {
+		if dst, err = appendString(dst, p.Will.Topic); err != nil {
+			return nil, err
+		}
+		if dst, err = appendBytes(dst, p.Will.Payload); err != nil {
+			return nil, err
+		}
+	}
+	if p.Username != "" {
+		if dst, err = appendString(dst, p.Username); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.Password) > 0 {
+		if dst, err = appendBytes(dst, p.Password); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func (p *Connect) decode(_ byte, body []byte) error {
+	d := &decoder{data: body}
+	p.ProtocolName = d.string("protocol name")
+	p.ProtocolLevel = d.byte("protocol level")
+	flags := d.byte("connect flags")
+	p.KeepAlive = d.uint16("keep alive")
+	if d.err != nil {
+		return d.err
+	}
+	if flags&0x01 != 0 {
+		return fmt.Errorf("%w, reserved connect flag set", ErrMalformed)
+	}
+	p.CleanSession = flags&0x02 != 0
+	p.ClientID = d.string("client id")
+	if flags&0x04 != 0 {
+		p.Will = &Message{
+			QoS:    flags >> 3 & 0x03,
+			Retain: flags&0x20 != 0,
+		}
+		if err := checkQoS(p.Will.QoS); err != nil {
+			return err
+		}
+		p.Will.Topic = d.string("will topic")
+		p.Will.Payload = d.bytes("will payload")
+	} else if flags&0x38 != 0 {
+		return fmt.Errorf("%w, will flags without will", ErrMalformed)
+	}
+	if flags&0x80 != 0 {
+		p.Username = d.string("username")
+	}
+	if flags&0x40 != 0 {
+		p.Password = d.bytes("password")
+	}
+	return d.done()
+}
+
+func (p *Connack) Type() byte  { return TypeConnack }
+func (p *Connack) flags() byte { return 0 }
+
+func (p *Connack) appendBody(dst []byte) ([]byte, error) {
+	var flags byte
+	if p.SessionPresent {
+		flags = 0x01
+	}
+	return append(dst, flags, p.ReturnCode), nil
+}
+
+func (p *Connack) decode(_ byte, body []byte) error {
+	d := &decoder{data: body}
+	flags := d.byte("connack flags")
+	p.ReturnCode = d.byte("return code")
+	if d.err == nil && flags&0xfe != 0 {
+		return fmt.Errorf("%w, reserved connack flags set", ErrMalformed)
+	}
+	p.SessionPresent = flags&0x01 != 0
+	return d.done()
+}
+
+func (p *Publish) Type() byte { return TypePublish }
+
+func (p *Publish) flags() byte {
+	flags := p.QoS << 1
+	if p.Dup {
+		flags |= 0x08
+	}
+	if p.Retain {
+		flags |= 0x01
+	}
+	return flags
+}
+
+func (p *Publish) appendBody(dst []byte) ([]byte, error) {
+	if err := checkQoS(p.QoS); err != nil {
+		return nil, err
+	}
+	dst, err := appendString(dst, p.Topic)
+	if err != nil {
+		return nil, err
+	}
+	if p.QoS > 0 {
+		if p.PacketID == 0 {
+			return nil, fmt.Errorf("packet id required for qos %d", p.QoS)
+		}
+		dst = binary.BigEndian.AppendUint16(dst, p.PacketID)
+	}
+	return append(dst, p.Payload...), nil
+}
+
+func (p *Publish) decode(flags byte, body []byte) error {
+	p.Dup = flags&0x08 != 0
+	p.QoS = flags >> 1 & 0x03
+	p.Retain = flags&0x01 != 0
+	if err := checkQoS(p.QoS); err != nil {
+		return err
+	}
+	d := &decoder{data: body}
+	p.Topic = d.string("topic")
+	if p.QoS > 0 {
+		p.PacketID = d.uint16("packet id")
+	}
+	if d.err != nil {
+		return d.err
+	}
+	p.Payload = d.rest()
+	return nil
+}
+
+func decodeID(body []byte) (uint16, error) {
+	d := &decoder{data: body}
+	id := d.uint16("packet id")
+	return id, d.done()
+}
+
+func (p *Puback) Type() byte  { return TypePuback }
+func (p *Puback) flags() byte { return 0 }
+func (p *Puback) appendBody(dst []byte) ([]byte, error) {
+	return binary.BigEndian.AppendUint16(dst, p.PacketID), nil
+}
+func (p *Puback) decode(_ byte, body []byte) (err error) {
+	p.PacketID, err = decodeID(body)
+	return err
+}
+
+func (p *Pubrec) Type() byte  { return TypePubrec }
+func (p *Pubrec) flags() byte { return 0 }
+func (p *Pubrec) appendBody(dst []byte) ([]byte, error) {
+	return binary.BigEndian.AppendUint16(dst, p.PacketID), nil
+}
+func (p *Pubrec) decode(_ byte, body []byte) (err error) {
+	p.PacketID, err = decodeID(body)
+	return err
+}
+
+func (p *Pubrel) Type() byte  { return TypePubrel }
+func (p *Pubrel) flags() byte { return 0x02 }
+func (p *Pubrel) appendBody(dst []byte) ([]byte, error) {
+	return binary.BigEndian.AppendUint16(dst, p.PacketID), nil
+}
+func (p *Pubrel) decode(_ byte, body []byte) (err error) {
+	p.PacketID, err = decodeID(body)
+	return err
+}
+
+func (p *Pubcomp) Type() byte  { return TypePubcomp }
+func (p *Pubcomp) flags() byte { return 0 }
+func (p *Pubcomp) appendBody(dst []byte) ([]byte, error) {
+	return binary.BigEndian.AppendUint16(dst, p.PacketID), nil
+}
+func (p *Pubcomp) decode(_ byte, body []byte) (err error) {
+	p.PacketID, err = decodeID(body)
+	return err
+}
+
+func (p *Subscribe) Type() byte  { return TypeSubscribe }
+func (p *Subscribe) flags() byte { return 0x02 }
+
+func (p *Subscribe) appendBody(dst []byte) ([]byte, error) {
+	if len(p.Subscriptions) == 0 {
+		return nil, fmt.Errorf("no subscription")
+	}
+	dst = binary.BigEndian.AppendUint16(dst, p.PacketID)
+	for _, s := range p.Subscriptions {
+		if err := checkQoS(s.QoS); err != nil {
+			return nil, err
+		}
+		var err error
+		if dst, err = appendString(dst, s.Topic); err != nil {
+			return nil, err
+		}
+		dst = append(dst, s.QoS)
+	}
+	return dst, nil
+}
+
+func (p *Subscribe) decode(_ byte, body []byte) error {
+	d := &decoder{data: body}
+	p.PacketID = d.uint16("packet id")
+	for d.err == nil && len(d.data) > 0 {
+		s := Subscription{Topic: d.string("topic filter"), QoS: d.byte("requested qos")}
+		if d.err == nil {
+			if err := checkQoS(s.QoS); err != nil {
+				return err
+			}
+		}
+		p.Subscriptions = append(p.Subscriptions, s)
+	}
+	if d.err == nil && len(p.Subscriptions) == 0 {
+		return fmt.Errorf("%w, no subscription", ErrMalformed)
+	}
+	return d.done()
+}
+
+func (p *Suback) Type() byte  { return TypeSuback }
+func (p *Suback) flags() byte { return 0 }
+
+func (p *Suback) appendBody(dst []byte) ([]byte, error) {
+	dst = binary.BigEndian.AppendUint16(dst, p.PacketID)
+	return append(dst, p.ReturnCodes...), nil
+}
+
+func (p *Suback) decode(_ byte, body []byte) error {
+	d := &decoder{data: body}
+	p.PacketID = d.uint16("packet id")
+	if d.err != nil {
+		return d.err
+	}
+	p.ReturnCodes = d.rest()
+	return nil
+}
+
+func (p *Unsubscribe) Type() byte  { return TypeUnsubscribe }
+func (p *Unsubscribe) flags() byte { return 0x02 }
+
+func (p *Unsubscribe) appendBody(dst []byte) ([]byte, error) {
+	if len(p.Topics) == 0 {
+		return nil, fmt.Errorf("no topic")
+	}
+	dst = binary.BigEndian.AppendUint16(dst, p.PacketID)
+	for _, topic := range p.Topics {
+		var err error
+		if dst, err = appendString(dst, topic); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func (p *Unsubscribe) decode(_ byte, body []byte) error {
+	d := &decoder{data: body}
+	p.PacketID = d.uint16("packet id")
+	for d.err == nil && len(d.data) > 0 {
+		p.Topics = append(p.Topics, d.string("topic filter"))
+	}
+	if d.err == nil && len(p.Topics) == 0 {
+		return fmt.Errorf("%w, no topic", ErrMalformed)
+	}
+	return d.done()
+}
+
+func (p *Unsuback) Type() byte  { return TypeUnsuback }
+func (p *Unsuback) flags() byte { return 0 }
+func (p *Unsuback) appendBody(dst []byte) ([]byte, error) {
+	return binary.BigEndian.AppendUint16(dst, p.PacketID), nil
+}
+func (p *Unsuback) decode(_ byte, body []byte) (err error) {
+	p.PacketID, err = decodeID(body)
+	return err
+}
+
+func (p *Pingreq) Type() byte                            { return TypePingreq }
+func (p *Pingreq) flags() byte                           { return 0 }
+func (p *Pingreq) appendBody(dst []byte) ([]byte, error) { return dst, nil }
+func (p *Pingreq) decode(_ byte, body []byte) error      { return (&decoder{data: body}).done() }
+
+func (p *Pingresp) Type() byte                            { return TypePingresp }
+func (p *Pingresp) flags() byte                           { return 0 }
+func (p *Pingresp) appendBody(dst []byte) ([]byte, error) { return dst, nil }
+func (p *Pingresp) decode(_ byte, body []byte) error      { return (&decoder{data: body}).done() }
+
+func (p *Disconnect) Type() byte                            { return TypeDisconnect }
+func (p *Disconnect) flags() byte                           { return 0 }
+func (p *Disconnect) appendBody(dst []byte) ([]byte, error) { return dst, nil }
+func (p *Disconnect) decode(_ byte, body []byte) error      { return (&decoder{data: body}).done() }
@@ -0,0 +1,30 @@
+package net
+
+// SendDataNotify 发送数据，返回的channel在数据写入socket(nil)或者失败(error)后收到结果，
+// 连接关闭时未发送的数据返回ErrConnClosed
+func (n *SimpleNet) SendDataNotify(conn *Connection, data interface{}) (<-chan error, error) {
+	ch := make(chan error, 1)
+	err := n.SendDataFunc(conn, data, func(err error) {
+		ch <- err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// SendDataFunc 发送数据，数据写入socket或者失败后调用cb，
+// cb在写协程中调用，不能阻塞。返回错误时不会调用cb
+func (n *SimpleNet) SendDataFunc(conn *Connection, data interface{}, cb func(err error)) error {
+	if conn.Status() != StatusConnected {
+		return ErrConnClosed
+	}
+	msg, alloc, err := conn.serialize(data)
+	if err != nil {
+		return err
+	}
+	req := newWriteReq(msg)
+	req.alloc = alloc
+	req.done = cb
+	return conn.enqueue(req)
+}
@@ -0,0 +1,61 @@
+package net
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSendDataNotify(t *testing.T) {
+	n := newTestNet(t)
+	addr, _ := rawServer(t)
+	conn, err := n.Connect(addr, &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := n.SendDataNotify(conn, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("notify err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for notify")
+	}
+
+	if _, err := n.SendDataNotify(conn, 1); err == nil {
+		t.Fatal("expect serialize error")
+	}
+
+	// 对端不读，塞满socket后排队的报文在关闭时收到ErrConnClosed
+	var pending []<-chan error
+	msg := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		ch, err := n.SendDataNotify(conn, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, ch)
+	}
+	n.CloseConn(conn)
+	closed := 0
+	for _, ch := range pending {
+		select {
+		case err := <-ch:
+			if errors.Is(err, ErrConnClosed) {
+				closed++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for notify")
+		}
+	}
+	if closed == 0 {
+		t.Fatal("expect pending messages to fail with ErrConnClosed")
+	}
+	if _, err := n.SendDataNotify(conn, msg); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("send after close err = %v", err)
+	}
+}
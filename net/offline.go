@@ -0,0 +1,103 @@
+package net
+
+import (
+	"fmt"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// 离线队列满时的处理方式
+const (
+	OfflineDropOldest = iota // 丢弃最旧的报文(默认)
+	OfflineReject            // SendData返回ErrWriteQueueFull
+)
+
+const defOfflineQueueSize = 1024
+
+// OfflineQueue 自动重连连接的内存离线队列参数
+type OfflineQueue struct {
+	Size int // 报文个数上限，<=0时为1024
+	Mode int
+}
+
+type offlineQueue struct {
+	OfflineQueue
+	items   []interface{}
+	dropped int64
+}
+
+// SetOfflineQueue 设置内存离线队列，断开期间SendData的报文存入队列，重连后按顺序发送，
+// 发送前SendData的报文也进入队列，保证顺序。报文在发送时才序列化。
+// 同时设置了SetSpool时使用spool。nil取消并丢弃队列中的报文
+func (m *ManagedConnection) SetOfflineQueue(q *OfflineQueue) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if q == nil {
+		m.offline = nil
+		return
+	}
+	oq := &offlineQueue{OfflineQueue: *q}
+	if oq.Size <= 0 {
+		oq.Size = defOfflineQueueSize
+	}
+	if m.offline != nil {
+		oq.items, oq.dropped = m.offline.items, m.offline.dropped
+		for len(oq.items) > oq.Size {
+			oq.items = oq.items[1:]
+			oq.dropped++
+		}
+	}
+	m.offline = oq
+}
+
+// OfflineQueued 离线队列中的报文数和累计丢弃的报文数
+func (m *ManagedConnection) OfflineQueued() (queued int, dropped int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.offline == nil {
+		return 0, 0
+	}
+	return len(m.offline.items), m.offline.dropped
+}
+
+// push 报文存入离线队列，调用方持有m.lock
+func (q *offlineQueue) push(data interface{}) error {
+	if len(q.items) >= q.Size {
+		if q.Mode == OfflineReject {
+			return ErrWriteQueueFull
+		}
+		q.items[0] = nil
+		q.items = q.items[1:]
+		q.dropped++
+	}
+	q.items = append(q.items, data)
+	return nil
+}
+
+// flush 按顺序发送离线报文，发送时不持有m.lock，失败时剩余的报文放回队列头部，返回是否全部发送
+func (m *ManagedConnection) flush(conn *Connection) bool {
+	m.lock.Lock()
+	var items []interface{}
+	if m.offline != nil {
+		items, m.offline.items = m.offline.items, nil
+	}
+	m.lock.Unlock()
+
+	for i, data := range items {
+		if err := m.net.SendData(conn, data); err != nil {
+			m.net.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("flush offline queue to %s stopped after %d messages, err = %s\n",
+					m.addr, i, err))
+			m.lock.Lock()
+			if m.offline != nil {
+				m.offline.items = append(items[i:len(items):len(items)], m.offline.items...)
+			}
+			m.lock.Unlock()
+			return false
+		}
+		items[i] = nil
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+// Package pbproto protobuf报文的IProto实现，
+// 帧格式：消息类型ID(4，大端) + 长度(4，大端) + protobuf编码的消息
+package pbproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	mynet "github.com/buf1024/golib/net"
+	"github.com/golang/protobuf/proto"
+)
+
+const constHeadLen = 8
+
+// ErrUnknownMessage 消息类型没有注册
+var ErrUnknownMessage = errors.New("unknown protobuf message")
+
+// Proto protobuf报文，收到的报文按类型ID反序列化为注册的消息，
+// SendData直接传入注册的proto.Message。注册完成后可以在多个连接间共享
+type Proto struct {
+	lock  sync.RWMutex
+	types map[uint32]reflect.Type
+	ids   map[reflect.Type]uint32
+}
+
+// New 创建Proto
+func New() *Proto {
+	return &Proto{
+		types: make(map[uint32]reflect.Type),
+		ids:   make(map[reflect.Type]uint32),
+	}
+}
+
+// Register 注册消息类型ID，msg为该消息类型的指针，如Register(1, &pb.LoginReq{})
+func (p *Proto) Register(id uint32, msg proto.Message) error {
+	t := reflect.TypeOf(msg)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return fmt.Errorf("message must be pointer")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if old, ok := p.types[id]; ok && old != t {
+		return fmt.Errorf("message id %d registered by %s", id, old)
+	}
+	if old, ok := p.ids[t]; ok && old != id {
+		return fmt.Errorf("message %s registered as %d", t, old)
+	}
+	p.types[id] = t
+	p.ids[t] = id
+	return nil
+}
+
+// MessageID 消息的类型ID
+func (p *Proto) MessageID(msg proto.Message) (uint32, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	id, ok := p.ids[reflect.TypeOf(msg)]
+	return id, ok
+}
+
+func (p *Proto) FilterAccept(conn *mynet.Connection) bool {
+	return true
+}
+
+func (p *Proto) HeadLen() uint32 {
+	return constHeadLen
+}
+
+func (p *Proto) BodyLen(head []byte) (interface{}, uint32, error) {
+	id := binary.BigEndian.Uint32(head)
+
+	p.lock.RLock()
+	t, ok := p.types[id]
+	p.lock.RUnlock()
+
+	if !ok {
+		return nil, 0, fmt.Errorf("%w, id = %d", ErrUnknownMessage, id)
+	}
+	return t, binary.BigEndian.Uint32(head[4:]), nil
+}
+
+func (p *Proto) Parse(head interface{}, body []byte) (interface{}, error) {
+	msg := reflect.New(head.(reflect.Type).Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (p *Proto) Serialize(data interface{}) ([]byte, error) {
+	msg, ok := data.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	id, ok := p.MessageID(msg)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", ErrUnknownMessage, data)
+	}
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, constHeadLen, constHeadLen+len(body))
+	binary.BigEndian.PutUint32(buf, id)
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(body)))
+	return append(buf, body...), nil
+}
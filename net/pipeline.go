@@ -0,0 +1,222 @@
+package net
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	pipelineHeadLen = 4
+	defPipelineMax  = 64 * 1024 * 1024
+)
+
+// Middleware 处理一个完整的报文，返回处理后的报文。
+// 广播时多个连接共享同一个报文，不能修改传入的frame，需要修改时返回新的切片
+type Middleware func(conn *Connection, frame []byte) ([]byte, error)
+
+// Pipeline 报文处理链，用于压缩、加密、统计、跟踪等，不需要修改每个proto。
+// 发送的报文序列化后依次经过Outbound，收到的报文依次经过Inbound后再交给proto解析。
+// 处理会改变报文长度时(如压缩、加密)设置Framed，报文前加上4字节长度(大端)，
+// 收发不再依赖proto的帧格式，双方的Framed设置必须一致。
+// Checksum非0时每个报文后附加校验值，校验失败时发出EventProtoError(ErrChecksum)，
+// 报文已经完整读取，按proto错误策略处理(默认丢弃继续)。proto没有固定的报文头时隐含Framed
+type Pipeline struct {
+	Inbound  []Middleware
+	Outbound []Middleware
+	Framed   bool
+	MaxFrame int // Framed时允许接收的最大报文，<=0时为64M
+	Checksum Checksum
+}
+
+// SetPipeline 设置报文处理链，只对之后建立的连接生效，nil取消
+func (n *SimpleNet) SetPipeline(p *Pipeline) {
+	if p == nil {
+		n.pipeline.Store(nil)
+		return
+	}
+	cp := *p
+	cp.Inbound = append([]Middleware(nil), p.Inbound...)
+	cp.Outbound = append([]Middleware(nil), p.Outbound...)
+	if cp.MaxFrame <= 0 {
+		cp.MaxFrame = defPipelineMax
+	}
+	n.pipeline.Store(&cp)
+}
+
+// Pipeline 当前的报文处理链
+func (n *SimpleNet) Pipeline() *Pipeline {
+	return n.pipeline.Load()
+}
+
+func (p *Pipeline) inbound() bool {
+	return p != nil && (p.Framed || p.Checksum != ChecksumNone || len(p.Inbound) > 0)
+}
+
+func (p *Pipeline) outbound() bool {
+	return p != nil && (p.Framed || p.Checksum != ChecksumNone || len(p.Outbound) > 0)
+}
+
+// pipelineFramed 是否加上长度前缀，校验值需要报文边界，proto没有固定的报文头时同样加上
+func (c *Connection) pipelineFramed() bool {
+	p := c.pipeline
+	return p.Framed || (p.Checksum != ChecksumNone && (c.proto == nil || c.proto.HeadLen() <= 0))
+}
+
+// outbound 入队前处理报文，处理后的报文不再由alloc释放，未改变的缓冲区交给GC
+func (c *Connection) outbound(req *writeReq) error {
+	p := c.pipeline
+	if !p.outbound() {
+		return nil
+	}
+	framed := c.pipelineFramed()
+	frames := make([][]byte, len(req.bufs))
+	for i, frame := range req.bufs {
+		for _, mw := range p.Outbound {
+			var err error
+			if frame, err = mw(c, frame); err != nil {
+				return err
+			}
+		}
+		if framed || p.Checksum != ChecksumNone {
+			// frame可能在多个连接间共享，不能原地追加
+			msg := make([]byte, 0, pipelineHeadLen+len(frame)+p.Checksum.Size())
+			if framed {
+				msg = binary.BigEndian.AppendUint32(msg, uint32(len(frame)))
+			}
+			frame = p.Checksum.appendSum(append(msg, frame...), frame)
+		}
+		frames[i] = frame
+	}
+	changed := false
+	for i, buf := range req.bufs {
+		frame := frames[i]
+		if len(frame) == len(buf) && (len(frame) == 0 || &frame[0] == &buf[0]) {
+			continue
+		}
+		if req.alloc != nil {
+			req.alloc.Free(buf)
+		}
+		req.size += len(frame) - len(buf)
+		req.bufs[i] = frame
+		changed = true
+	}
+	if changed {
+		req.alloc = nil
+	}
+	return nil
+}
+
+// readPipeline 有Inbound或者Framed时读取一个报文，经过处理链后再解析，连接关闭返回false。
+// 没有设置Framed时按proto的HeadLen/BodyLen读取完整的报文，处理后再由proto解析
+func (n *SimpleNet) readPipeline(conn *Connection) bool {
+	p := conn.pipeline
+	var frame []byte
+	offset := 0
+	headlen := 0
+	if conn.proto != nil {
+		headlen = int(conn.proto.HeadLen())
+	}
+	switch {
+	case conn.pipelineFramed():
+		head := make([]byte, pipelineHeadLen)
+		if ok, alive := n.readFull(conn, head); !ok {
+			return alive
+		}
+		size := binary.BigEndian.Uint32(head)
+		if uint64(size) > uint64(p.MaxFrame) {
+			// 无法跳过报文继续读取，关闭连接
+			n.checkConnErr(0, fmt.Errorf("frame too large, size = %d", size), conn)
+			return false
+		}
+		frame = make([]byte, size)
+	case headlen <= 0:
+		// 没有帧格式，处理已经到达的数据
+		frame = make([]byte, rawReadSize)
+		count, err := n.readSome(conn, frame)
+		if err != nil && conn.upgradeInterrupted(err) {
+			return true
+		}
+		if err != nil {
+			n.checkConnErr(count, err, conn)
+			return false
+		}
+		frame = frame[:count]
+		offset = count
+	default:
+		frame = make([]byte, headlen)
+		if ok, alive := n.readFull(conn, frame); !ok {
+			return alive
+		}
+		_, bodylen, err := conn.proto.BodyLen(frame)
+		if err != nil {
+			return n.pipelineError(conn, err)
+		}
+		if !n.checkBodyLen(conn, bodylen) {
+			return false
+		}
+		frame = append(frame, make([]byte, bodylen)...)
+		offset = headlen
+	}
+	if ok, alive := n.readFull(conn, frame[offset:]); !ok {
+		return alive
+	}
+	conn.mirrorFrame(false, frame)
+	if p.Checksum != ChecksumNone {
+		sum := make([]byte, p.Checksum.Size())
+		if ok, alive := n.readFull(conn, sum); !ok {
+			return alive
+		}
+		if !p.Checksum.verify(frame, sum) {
+			return n.pipelineError(conn, fmt.Errorf("%w, sum = %x, size = %d", ErrChecksum, sum, len(frame)))
+		}
+	}
+
+	for _, mw := range p.Inbound {
+		var err error
+		if frame, err = mw(conn, frame); err != nil {
+			return n.pipelineError(conn, err)
+		}
+	}
+	data, err := parseWrapped(conn.proto, frame)
+	if err != nil {
+		return n.pipelineError(conn, err)
+	}
+	if conn.intercept(data, noRelease) {
+		return true
+	}
+	evtType, data := conn.dataEvent(data)
+	n.emit(&ConnEvent{
+		EventType: evtType,
+		Conn:      conn,
+		Data:      data,
+	})
+	return true
+}
+
+// readFull 读取buf，返回是否读取成功和连接是否继续，被UpgradeTLS打断时连接继续
+func (n *SimpleNet) readFull(conn *Connection, buf []byte) (bool, bool) {
+	count, err := n.readConn(conn, buf)
+	if err != nil && conn.upgradeInterrupted(err) {
+		return false, true
+	}
+	if err = n.checkConnErr(count, err, conn); err != nil {
+		return false, false
+	}
+	n.logMsg(mylog.LevelInformational,
+		fmt.Sprintf("read data, count = %d, remoteAddr: = %s\n", count, conn.remoteAddr))
+	return true, true
+}
+
+// pipelineError 处理链或者解析出错，返回连接是否继续
+func (n *SimpleNet) pipelineError(conn *Connection, err error) bool {
+	n.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("pipeline error, err = %s, remoteAddr = %s\n", err, conn.remoteAddr))
+	n.emit(&ConnEvent{
+		EventType: EventProtoError,
+		Conn:      conn,
+		Data:      err,
+	})
+	return n.handleProtoError(conn, err)
+}
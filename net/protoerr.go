@@ -0,0 +1,155 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// proto错误的处理方式
+const (
+	ProtoErrorContinue = iota // 继续读取(默认)
+	ProtoErrorResync          // 调用proto的Resync重新找到报文边界
+	ProtoErrorClose           // 关闭连接
+)
+
+// ErrTooManyProtoErrors proto错误超过ProtoErrorPolicy.MaxErrors
+var ErrTooManyProtoErrors = errors.New("too many proto errors")
+
+// ProtoErrorPolicy proto错误(BodyLen或者Parse失败)的处理策略
+type ProtoErrorPolicy struct {
+	Action int
+	// MaxErrors >0时连接的proto错误达到MaxErrors个后发出EventProtoErrorLimit并关闭连接
+	MaxErrors int
+	// Window >0时只统计最近Window内的错误，否则统计连接的所有错误
+	Window time.Duration
+}
+
+// Resyncer proto的可选扩展，出错后从r中丢弃数据直到下一个报文的开始
+type Resyncer interface {
+	Resync(r io.Reader) error
+}
+
+// ProtoErrorPolicier proto的可选扩展，提供proto默认的错误处理策略
+type ProtoErrorPolicier interface {
+	ProtoErrorPolicy() ProtoErrorPolicy
+}
+
+// SetProtoErrorPolicy 设置所有连接默认的proto错误处理策略，proto的默认策略优先，nil取消
+func (n *SimpleNet) SetProtoErrorPolicy(p *ProtoErrorPolicy) {
+	n.errPolicy.Store(p)
+}
+
+// SetProtoErrorPolicy 设置监听下连接的proto错误处理策略，优先于proto的默认策略，nil取消
+func (l *Listener) SetProtoErrorPolicy(p *ProtoErrorPolicy) {
+	l.errPolicy.Store(p)
+}
+
+// SetProtoErrorPolicy 设置连接的proto错误处理策略，优先于监听的设置，nil取消
+func (c *Connection) SetProtoErrorPolicy(p *ProtoErrorPolicy) {
+	c.errPolicy.Store(p)
+}
+
+// ProtoErrors 连接累计的proto错误数
+func (c *Connection) ProtoErrors() int64 {
+	return c.protoErrors.Load()
+}
+
+func (c *Connection) protoErrorPolicy() ProtoErrorPolicy {
+	if p := c.errPolicy.Load(); p != nil {
+		return *p
+	}
+	if c.listen != nil {
+		if p := c.listen.errPolicy.Load(); p != nil {
+			return *p
+		}
+	}
+	if p, ok := protoAs[ProtoErrorPolicier](c.proto); ok {
+		return p.ProtoErrorPolicy()
+	}
+	if p := c.net.errPolicy.Load(); p != nil {
+		return *p
+	}
+	return ProtoErrorPolicy{}
+}
+
+type connReader struct {
+	n    *SimpleNet
+	conn *Connection
+}
+
+func (r *connReader) Read(p []byte) (int, error) {
+	return r.n.readConn(r.conn, p)
+}
+
+// handleProtoError 按策略处理proto错误，返回false表示连接已经关闭
+func (n *SimpleNet) handleProtoError(conn *Connection, err error) bool {
+	policy := conn.protoErrorPolicy()
+	count := conn.protoErrors.Add(1)
+
+	if conn.errorLimitReached(policy, count) {
+		closeErr := fmt.Errorf("%w, max = %d, last err = %w", ErrTooManyProtoErrors, policy.MaxErrors, err)
+		n.emit(&ConnEvent{
+			EventType: EventProtoErrorLimit,
+			Conn:      conn,
+			Data:      closeErr,
+		})
+		n.closeProtoError(conn, closeErr)
+		return false
+	}
+
+	var closeErr error
+	switch {
+	case policy.Action == ProtoErrorClose:
+		closeErr = fmt.Errorf("proto error: %w", err)
+	case policy.Action == ProtoErrorResync:
+		resyncer, ok := protoAs[Resyncer](conn.proto)
+		if !ok {
+			closeErr = fmt.Errorf("proto not support resync: %w", err)
+			break
+		}
+		if rerr := resyncer.Resync(&connReader{n: n, conn: conn}); rerr != nil {
+			closeErr = fmt.Errorf("resync failed: %w", rerr)
+		}
+	}
+	if closeErr == nil {
+		return true
+	}
+	n.closeProtoError(conn, closeErr)
+	return false
+}
+
+// errorLimitReached 记录一次错误，返回是否达到MaxErrors。
+// 有Window时保存最近MaxErrors个错误的时间，最早的一个仍在Window内即达到
+func (c *Connection) errorLimitReached(policy ProtoErrorPolicy, count int64) bool {
+	if policy.MaxErrors <= 0 {
+		return false
+	}
+	if policy.Window <= 0 {
+		return count >= int64(policy.MaxErrors)
+	}
+	now := time.Now().UnixNano()
+	c.errTimes = append(c.errTimes, now)
+	if over := len(c.errTimes) - policy.MaxErrors; over > 0 {
+		c.errTimes = append(c.errTimes[:0], c.errTimes[over:]...)
+	}
+	return len(c.errTimes) == policy.MaxErrors && now-c.errTimes[0] <= int64(policy.Window)
+}
+
+// closeProtoError proto错误导致关闭连接
+func (n *SimpleNet) closeProtoError(conn *Connection, closeErr error) {
+	n.logMsg(mylog.LevelError,
+		fmt.Sprintf("close connection, err = %s, remoteAddr = %s\n",
+			closeErr, conn.RemoteAddress()))
+	// 只有完成状态切换的一方发出事件，和读写出错的关闭路径不会重复
+	if n.closeConn(conn) {
+		n.emit(&ConnEvent{
+			EventType: EventConnectionError,
+			Conn:      conn,
+			Data:      closeErr,
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// badProto 报文内容为"bad"时Parse失败
+type badProto struct {
+	benchProto
+}
+
+func (p *badProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	if string(body) == "bad" {
+		return nil, fmt.Errorf("bad message")
+	}
+	return body, nil
+}
+
+// protoErrorConn 连接使用policy的服务端，客户端事件进入独立队列，公共队列中只有服务端的事件
+func protoErrorConn(t *testing.T, policy *ProtoErrorPolicy) (*SimpleNet, *Connection) {
+	n := newTestNet(t)
+	n.SetClientEventQueue(64)
+	n.SetProtoErrorPolicy(policy)
+	conn, err := n.Connect(listenTest(t, n, &badProto{}), &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, conn
+}
+
+func TestProtoErrorContinue(t *testing.T) {
+	n, conn := protoErrorConn(t, &ProtoErrorPolicy{Action: ProtoErrorContinue})
+	n.SendData(conn, []byte("bad"))
+	n.SendData(conn, []byte("good"))
+	waitEvent(t, n, EventProtoError)
+	evt := waitEvent(t, n, EventNewConnectionData)
+	if string(evt.Data.([]byte)) != "good" {
+		t.Fatalf("receive %q after proto error", evt.Data)
+	}
+	if evt.Conn.ProtoErrors() != 1 {
+		t.Fatalf("proto errors = %d", evt.Conn.ProtoErrors())
+	}
+}
+
+func TestProtoErrorClose(t *testing.T) {
+	for _, action := range []int{ProtoErrorClose, ProtoErrorResync} {
+		n, conn := protoErrorConn(t, &ProtoErrorPolicy{Action: action})
+		n.SendData(conn, []byte("bad"))
+		// badProto不支持Resync，同样关闭连接
+		evt := waitEvent(t, n, EventConnectionError)
+		if evt.Conn.Status() != StatusBroken {
+			t.Fatalf("action %d: connection not closed", action)
+		}
+	}
+}
+
+func TestProtoErrorLimit(t *testing.T) {
+	n, conn := protoErrorConn(t, &ProtoErrorPolicy{MaxErrors: 3})
+	for i := 0; i < 3; i++ {
+		n.SendData(conn, []byte("bad"))
+	}
+	evt := waitEvent(t, n, EventProtoErrorLimit)
+	if err, _ := evt.Data.(error); !errors.Is(err, ErrTooManyProtoErrors) {
+		t.Fatalf("limit event data = %v", evt.Data)
+	}
+	waitEvent(t, n, EventConnectionError)
+	if evt.Conn.ProtoErrors() != 3 {
+		t.Fatalf("proto errors = %d", evt.Conn.ProtoErrors())
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// HTTP 通过HTTP代理的CONNECT方法连接的Dialer，proxyURL为http://[user:password@]host:port，
+// https://时和代理之间使用TLS。URL中有用户信息时发送Proxy-Authorization(Basic)，
+// header为额外的请求头，forward为连接代理使用的Dialer，nil时直接连接
+func HTTP(proxyURL string, header http.Header, forward mynet.Dialer) (mynet.Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	proxyAddr := u.Host
+	switch u.Scheme {
+	case "http":
+		if u.Port() == "" {
+			proxyAddr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "https":
+		if u.Port() == "" {
+			proxyAddr = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %s", u.Scheme)
+	}
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return nil, fmt.Errorf("http proxy not support network %s", network)
+		}
+		conn, err := dialProxy(ctx, forward, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "https" {
+			conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		}
+		var tunnel net.Conn
+		err = withContext(ctx, conn, func() error {
+			tunnel, err = httpConnect(conn, header, addr)
+			return err
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("http connect %s via %s failed, err = %s", addr, proxyAddr, err)
+		}
+		return tunnel, nil
+	}, nil
+}
+
+func httpConnect(conn net.Conn, header http.Header, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: header,
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	// 成功时响应没有body，之后的数据属于隧道，不能读取Body
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy response %s", resp.Status)
+	}
+	if r.Buffered() > 0 {
+		// 代理在响应后紧接着转发了目标的数据
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读取握手时多读的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// NetConn 返回底层连接
+func (c *bufferedConn) NetConn() net.Conn {
+	return c.Conn
+}
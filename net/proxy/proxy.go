@@ -0,0 +1,36 @@
+// Package proxy 通过代理建立出站连接，返回的mynet.Dialer通过SimpleNet.SetDialer
+// 或者ConnectDialer使用，ConnectTLS时TLS在代理建立的隧道上握手
+package proxy
+
+import (
+	"context"
+	"net"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// dialProxy 连接代理服务器
+func dialProxy(ctx context.Context, forward mynet.Dialer, addr string) (net.Conn, error) {
+	if forward != nil {
+		return forward(ctx, "tcp", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// withContext 和代理握手期间ctx到期或者取消时中止握手
+func withContext(ctx context.Context, conn net.Conn, handshake func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	err := handshake()
+	if !stop() && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
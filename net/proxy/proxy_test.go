@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// socks5Server 最简单的SOCKS5代理，只支持CONNECT
+func socks5Server(t *testing.T, user, password string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, user, password)
+		}
+	}()
+	return l
+}
+
+func serveSOCKS5(conn net.Conn, user, password string) {
+	defer conn.Close()
+
+	head := make([]byte, 2)
+	io.ReadFull(conn, head)
+	methods := make([]byte, head[1])
+	io.ReadFull(conn, methods)
+	if user == "" {
+		conn.Write([]byte{5, socks5NoAuth})
+	} else {
+		if !bytes.Contains(methods, []byte{socks5UserPass}) {
+			conn.Write([]byte{5, socks5NoAcceptable})
+			return
+		}
+		conn.Write([]byte{5, socks5UserPass})
+		io.ReadFull(conn, head)
+		u := make([]byte, head[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, head[:1])
+		p := make([]byte, head[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	io.ReadFull(conn, req)
+	var host string
+	switch req[3] {
+	case socks5IPv4, socks5IPv6:
+		ip := make([]byte, net.IPv4len)
+		if req[3] == socks5IPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5Domain:
+		io.ReadFull(conn, head[:1])
+		name := make([]byte, head[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	io.ReadFull(conn, head)
+	port := binary.BigEndian.Uint16(head)
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, socks5Domain, 4, 'b', 'i', 'n', 'd', 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func echoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l
+}
+
+func checkEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSOCKS5(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+
+	proxy := socks5Server(t, "", "")
+	defer proxy.Close()
+	dial := SOCKS5(proxy.Addr().String(), nil, nil)
+	for _, addr := range []string{echo.Addr().String(), net.JoinHostPort("localhost", port)} {
+		conn, err := dial(context.Background(), "tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkEcho(t, conn)
+	}
+	if _, err := dial(context.Background(), "tcp", "127.0.0.1:1"); err == nil {
+		t.Fatal("expect connection refused")
+	}
+}
+
+func TestSOCKS5Auth(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+
+	proxy := socks5Server(t, "user", "secret")
+	defer proxy.Close()
+	conn, err := SOCKS5(proxy.Addr().String(), &Auth{User: "user", Password: "secret"}, nil)(
+		context.Background(), "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEcho(t, conn)
+
+	for _, auth := range []*Auth{nil, {User: "user", Password: "bad"}} {
+		_, err := SOCKS5(proxy.Addr().String(), auth, nil)(context.Background(), "tcp", echo.Addr().String())
+		if err == nil {
+			t.Fatalf("auth %v: expect error", auth)
+		}
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				req, err := http.ReadRequest(r)
+				if err != nil {
+					return
+				}
+				if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+					conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				go io.Copy(target, r)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	dial, err := HTTP("http://user:secret@"+l.Addr().String(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dial(context.Background(), "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEcho(t, conn)
+
+	dial, _ = HTTP("http://"+l.Addr().String(), nil, nil)
+	if _, err := dial(context.Background(), "tcp", echo.Addr().String()); err == nil {
+		t.Fatal("expect 407")
+	}
+}
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// Auth SOCKS5用户名密码认证(RFC 1929)
+type Auth struct {
+	User     string
+	Password string
+}
+
+const socks5Version = 5
+
+const (
+	socks5NoAuth       = 0x00
+	socks5UserPass     = 0x02
+	socks5NoAcceptable = 0xff
+
+	socks5Connect = 0x01
+
+	socks5IPv4   = 0x01
+	socks5Domain = 0x03
+	socks5IPv6   = 0x04
+)
+
+var socks5Errors = []string{
+	"",
+	"general failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"ttl expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// SOCKS5 通过SOCKS5代理连接的Dialer，auth为nil时不认证，
+// forward为连接代理使用的Dialer，nil时直接连接。目标地址中的域名由代理解析
+func SOCKS5(proxyAddr string, auth *Auth, forward mynet.Dialer) mynet.Dialer {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return nil, fmt.Errorf("socks5 not support network %s", network)
+		}
+		conn, err := dialProxy(ctx, forward, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		err = withContext(ctx, conn, func() error {
+			return socks5Handshake(conn, auth, addr)
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("socks5 connect %s via %s failed, err = %s", addr, proxyAddr, err)
+		}
+		return conn, nil
+	}
+}
+
+func socks5Handshake(conn net.Conn, auth *Auth, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", portStr)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if auth != nil {
+		methods = append(methods, socks5UserPass)
+	}
+	req := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected version %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		if auth == nil {
+			return fmt.Errorf("proxy requires authentication")
+		}
+		if err := socks5Auth(conn, auth); err != nil {
+			return err
+		}
+	case socks5NoAcceptable:
+		return fmt.Errorf("no acceptable authentication method")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	req = []byte{socks5Version, socks5Connect, 0}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip = ip.Unmap(); ip.Is4() {
+			req = append(req, socks5IPv4)
+		} else {
+			req = append(req, socks5IPv6)
+		}
+		req = append(req, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long")
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[0] != socks5Version {
+		return fmt.Errorf("unexpected version %d", head[0])
+	}
+	if code := int(head[1]); code != 0 {
+		if code < len(socks5Errors) {
+			return fmt.Errorf("%s", socks5Errors[code])
+		}
+		return fmt.Errorf("unknown error %d", code)
+	}
+	// 丢弃代理绑定的地址
+	var skip int
+	switch head[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		var size [1]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return err
+		}
+		skip = int(size[0])
+	default:
+		return fmt.Errorf("unknown address type %d", head[3])
+	}
+	_, err = io.CopyN(io.Discard, conn, int64(skip+2))
+	return err
+}
+
+func socks5Auth(conn net.Conn, auth *Auth) error {
+	if len(auth.User) > 255 || len(auth.Password) > 255 {
+		return fmt.Errorf("user or password too long")
+	}
+	req := []byte{1, byte(len(auth.User))}
+	req = append(req, auth.User...)
+	req = append(req, byte(len(auth.Password)))
+	req = append(req, auth.Password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return fmt.Errorf("authentication failed")
+	}
+	return nil
+}
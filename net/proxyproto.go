@@ -0,0 +1,251 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PROXY protocol(HAProxy)的模式
+const (
+	ProxyProtocolOff      = iota
+	ProxyProtocolOptional // 有header时解析，没有时使用实际地址，只适用于客户端先发数据的协议
+	ProxyProtocolRequired // 必须有header，否则握手失败
+)
+
+// ErrProxyHeader PROXY protocol header错误
+var ErrProxyHeader = errors.New("invalid proxy protocol header")
+
+// ErrProxyUntrusted 开启PROXY protocol时没有指定信任的地址
+var ErrProxyUntrusted = errors.New("proxy protocol without trusted addresses")
+
+var proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const proxyV1MaxLen = 107
+
+// ProxyListener 接受的连接先解析PROXY protocol v1/v2 header，RemoteAddress为真实的客户端地址。
+// trusted为允许发送header的地址(如负载均衡的CIDR)，不能为空，确实需要信任所有地址时显式传入
+// "0.0.0.0/0"和"::/0"。不信任的地址不解析header，Required模式下直接握手失败。
+// header在连接的Handshake中读取，受Listener的握手超时控制；
+// 需要TLS时在外层包装: tls.NewListener(ProxyListener(raw, ...), config)
+func ProxyListener(listen net.Listener, mode int, trusted ...string) (net.Listener, error) {
+	prefixes, err := parsePrefixes(trusted)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ProxyProtocolOff {
+		return listen, nil
+	}
+	if len(prefixes) == 0 {
+		return nil, ErrProxyUntrusted
+	}
+	return &proxyListener{Listener: listen, mode: mode, trusted: prefixes}, nil
+}
+
+type proxyListener struct {
+	net.Listener
+	mode    int
+	trusted []netip.Prefix
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	trusted := false
+	if ap, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil {
+		for _, prefix := range l.trusted {
+			if prefix.Contains(ap.Addr().Unmap()) {
+				trusted = true
+				break
+			}
+		}
+	}
+	return &proxyConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		mode:    l.mode,
+		trusted: trusted,
+	}, nil
+}
+
+// proxyConn 在Handshake(或者第一次Read)时解析header
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	mode    int
+	trusted bool
+
+	once   sync.Once
+	err    error
+	parsed atomic.Bool
+	src    net.Addr
+	dst    net.Addr
+}
+
+// proxyOf 找到被包装的proxyConn
+func proxyOf(conn net.Conn) *proxyConn {
+	for {
+		if pc, ok := conn.(*proxyConn); ok {
+			return pc
+		}
+		inner, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = inner.NetConn()
+	}
+}
+
+// Handshake 读取并解析PROXY header
+func (c *proxyConn) Handshake() error {
+	c.once.Do(func() {
+		if !c.trusted {
+			if c.mode == ProxyProtocolRequired {
+				c.err = ErrProxyHeader
+			}
+			return
+		}
+		c.src, c.dst, c.err = readProxyHeader(c.r, c.mode == ProxyProtocolRequired)
+		c.parsed.Store(true)
+	})
+	return c.err
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr header中的源地址，没有header(或者UNKNOWN/LOCAL)时为实际地址
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.parsed.Load() && c.src != nil {
+		return c.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr header中的目的地址
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.parsed.Load() && c.dst != nil {
+		return c.dst
+	}
+	return c.Conn.LocalAddr()
+}
+
+// NetConn 返回底层连接
+func (c *proxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// readProxyHeader 解析v1或v2 header，没有header且不要求时返回nil地址
+func readProxyHeader(r *bufio.Reader, required bool) (src, dst net.Addr, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch first[0] {
+	case 'P':
+		if head, err := r.Peek(6); err == nil && string(head) == "PROXY " {
+			return readProxyV1(r)
+		}
+	case '\r':
+		if head, err := r.Peek(len(proxyV2Sig)); err == nil && bytes.Equal(head, proxyV2Sig) {
+			return readProxyV2(r)
+		}
+	}
+	if required {
+		return nil, nil, ErrProxyHeader
+	}
+	return nil, nil, nil
+}
+
+// readProxyV1 如"PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func readProxyV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrProxyHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, ErrProxyHeader
+	}
+	srcIP, err1 := netip.ParseAddr(fields[2])
+	dstIP, err2 := netip.ParseAddr(fields[3])
+	srcPort, err3 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err4 := strconv.ParseUint(fields[5], 10, 16)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return nil, nil, ErrProxyHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(srcIP, uint16(srcPort))),
+		net.TCPAddrFromAddrPort(netip.AddrPortFrom(dstIP, uint16(dstPort))), nil
+}
+
+// readProxyV2 二进制格式: 签名(12) 版本和命令(1) 地址族和协议(1) 长度(2) 地址和TLV
+func readProxyV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+	var head [16]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, nil, err
+	}
+	if head[12]>>4 != 2 {
+		return nil, nil, ErrProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(head[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+	switch head[12] & 0x0f {
+	case 0x0:
+		// LOCAL，负载均衡自己的连接(如健康检查)
+		return nil, nil, nil
+	case 0x1:
+	default:
+		return nil, nil, ErrProxyHeader
+	}
+
+	var ipLen int
+	switch head[13] >> 4 {
+	case 0x1:
+		ipLen = 4
+	case 0x2:
+		ipLen = 16
+	default:
+		// AF_UNSPEC、AF_UNIX使用实际地址
+		return nil, nil, nil
+	}
+	if len(body) < ipLen*2+4 {
+		return nil, nil, ErrProxyHeader
+	}
+	srcIP, _ := netip.AddrFromSlice(body[:ipLen])
+	dstIP, _ := netip.AddrFromSlice(body[ipLen : ipLen*2])
+	srcAP := netip.AddrPortFrom(srcIP, binary.BigEndian.Uint16(body[ipLen*2:]))
+	dstAP := netip.AddrPortFrom(dstIP, binary.BigEndian.Uint16(body[ipLen*2+2:]))
+	if head[13]&0x0f == 0x2 {
+		return net.UDPAddrFromAddrPort(srcAP), net.UDPAddrFromAddrPort(dstAP), nil
+	}
+	return net.TCPAddrFromAddrPort(srcAP), net.TCPAddrFromAddrPort(dstAP), nil
+}
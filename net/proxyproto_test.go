@@ -0,0 +1,118 @@
+package net
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyV2(cmd, fam byte, addrs []byte) string {
+	head := append([]byte(nil), proxyV2Sig...)
+	head = append(head, 0x20|cmd, fam)
+	head = binary.BigEndian.AppendUint16(head, uint16(len(addrs)))
+	return string(append(head, addrs...))
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v4 := []byte{192, 168, 0, 1, 10, 0, 0, 1, 0xdc, 0x04, 0x01, 0xbb}
+	tests := []struct {
+		name     string
+		data     string
+		required bool
+		src      string
+		err      error
+	}{
+		{"v1 tcp4", "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\ndata", true, "192.168.0.1:56324", nil},
+		{"v1 tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 1000 443\r\n", true, "[2001:db8::1]:1000", nil},
+		{"v1 unknown", "PROXY UNKNOWN\r\n", true, "", nil},
+		{"v1 bad port", "PROXY TCP4 192.168.0.1 10.0.0.1 70000 443\r\n", true, "", ErrProxyHeader},
+		{"v1 no crlf", "PROXY TCP4 192.168.0.1 10.0.0.1 1 2\n", true, "", ErrProxyHeader},
+		{"v1 too long", "PROXY " + strings.Repeat("a", proxyV1MaxLen) + "\r\n", true, "", ErrProxyHeader},
+		{"v2 tcp4", proxyV2(0x1, 0x11, v4), true, "192.168.0.1:56324", nil},
+		{"v2 udp4", proxyV2(0x1, 0x12, v4), true, "192.168.0.1:56324", nil},
+		{"v2 local", proxyV2(0x0, 0x00, nil), true, "", nil},
+		{"v2 short", proxyV2(0x1, 0x11, v4[:6]), true, "", ErrProxyHeader},
+		{"v2 bad command", proxyV2(0x2, 0x11, v4), true, "", ErrProxyHeader},
+		{"missing required", "GET / HTTP/1.1\r\n", true, "", ErrProxyHeader},
+		{"missing optional", "GET / HTTP/1.1\r\n", false, "", nil},
+	}
+	for _, tt := range tests {
+		src, _, err := readProxyHeader(bufio.NewReader(strings.NewReader(tt.data)), tt.required)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		got := ""
+		if src != nil {
+			got = src.String()
+		}
+		if got != tt.src {
+			t.Errorf("%s: src = %q, want %q", tt.name, got, tt.src)
+		}
+	}
+}
+
+// proxyAccept 经过ProxyListener接受一个写入data的连接
+func proxyAccept(t *testing.T, mode int, trusted []string, data string) (*proxyConn, error) {
+	t.Helper()
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	l, err := ProxyListener(raw, mode, trusted...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.Dial("tcp", raw.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.Write([]byte(data))
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	pc := conn.(*proxyConn)
+	return pc, pc.Handshake()
+}
+
+func TestProxyListenerTrust(t *testing.T) {
+	header := "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n"
+	if _, err := proxyAccept(t, ProxyProtocolRequired, nil, header); !errors.Is(err, ErrProxyUntrusted) {
+		t.Fatalf("empty trusted err = %v", err)
+	}
+
+	// 不信任的地址不解析header
+	pc, err := proxyAccept(t, ProxyProtocolOptional, []string{"10.0.0.0/8"}, header)
+	if err != nil || !strings.HasPrefix(pc.RemoteAddr().String(), "127.0.0.1:") {
+		t.Fatalf("untrusted remote = %v, err = %v", pc.RemoteAddr(), err)
+	}
+	if _, err := proxyAccept(t, ProxyProtocolRequired, []string{"10.0.0.0/8"}, header); !errors.Is(err, ErrProxyHeader) {
+		t.Fatalf("untrusted required err = %v", err)
+	}
+
+	for _, trusted := range [][]string{{"127.0.0.0/8"}, {"0.0.0.0/0", "::/0"}} {
+		pc, err := proxyAccept(t, ProxyProtocolRequired, trusted, header+"data")
+		if err != nil || pc.RemoteAddr().String() != "192.168.0.1:56324" {
+			t.Fatalf("trusted %v remote = %v, err = %v", trusted, pc.RemoteAddr(), err)
+		}
+		buf := make([]byte, 4)
+		if _, err := pc.Read(buf); err != nil || string(buf) != "data" {
+			t.Fatalf("read %q, err = %v", buf, err)
+		}
+	}
+}
+
+func TestListenProxyUntrusted(t *testing.T) {
+	n := newTestNet(t)
+	_, err := n.ListenWithOptions("127.0.0.1:0", &ListenOptions{ProxyProtocol: ProxyProtocolRequired}, &benchProto{})
+	if !errors.Is(err, ErrProxyUntrusted) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package net
+
+import (
+	"fmt"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// PubSuber proto的可选扩展，用于主题订阅。实现后连接收到的订阅和取消订阅请求自动登记，
+// 不作为EventNewConnectionData发出，连接关闭时自动取消所有订阅
+type PubSuber interface {
+	// Subscription 报文是订阅(sub为true)或者取消订阅请求时返回主题
+	Subscription(data interface{}) (topic string, sub bool, ok bool)
+	// SubscribeMsg 构造订阅(sub为true)或者取消订阅请求
+	SubscribeMsg(topic string, sub bool) interface{}
+	// PublishMsg 构造发布到topic的报文
+	PublishMsg(topic string, msg interface{}) interface{}
+}
+
+// Subscribe 向对端发送订阅topic的请求，对端Publish到topic的报文会发到conn上
+func (n *SimpleNet) Subscribe(conn *Connection, topic string) error {
+	return n.sendSubscription(conn, topic, true)
+}
+
+// Unsubscribe 向对端发送取消订阅topic的请求
+func (n *SimpleNet) Unsubscribe(conn *Connection, topic string) error {
+	return n.sendSubscription(conn, topic, false)
+}
+
+func (n *SimpleNet) sendSubscription(conn *Connection, topic string, sub bool) error {
+	ps, ok := protoAs[PubSuber](conn.proto)
+	if !ok {
+		return fmt.Errorf("proto not support pubsub")
+	}
+	return n.SendData(conn, ps.SubscribeMsg(topic, sub))
+}
+
+// Publish 向订阅了topic的连接发送msg，每个连接用自己的proto构造发布报文并序列化，
+// 返回发送失败的连接及原因
+func (n *SimpleNet) Publish(topic string, msg interface{}) map[*Connection]error {
+	var failed map[*Connection]error
+	for _, conn := range n.topics.members(topic) {
+		ps, ok := protoAs[PubSuber](conn.proto)
+		if !ok {
+			continue
+		}
+		if err := n.SendData(conn, ps.PublishMsg(topic, msg)); err != nil {
+			if failed == nil {
+				failed = make(map[*Connection]error)
+			}
+			failed[conn] = err
+		}
+	}
+	return failed
+}
+
+// Subscribers 订阅了topic的连接的快照
+func (n *SimpleNet) Subscribers(topic string) []*Connection {
+	return n.topics.members(topic)
+}
+
+// Topics 对端通过conn订阅的主题
+func (n *SimpleNet) Topics(conn *Connection) []string {
+	return n.topics.of(conn)
+}
+
+// handleSubscription 登记收到的订阅和取消订阅请求，返回true表示data已经处理
+func (c *Connection) handleSubscription(data interface{}) bool {
+	ps, ok := protoAs[PubSuber](c.proto)
+	if !ok {
+		return false
+	}
+	topic, sub, ok := ps.Subscription(data)
+	if !ok {
+		return false
+	}
+	if sub {
+		if err := c.net.topics.join(c, topic); err != nil {
+			return true
+		}
+	} else {
+		c.net.topics.remove(c, topic)
+	}
+	c.net.logMsg(mylog.LevelDebug,
+		fmt.Sprintf("subscription changed, topic = %s, sub = %t, remoteAddr = %s\n", topic, sub, c.remoteAddr))
+	return true
+}
@@ -0,0 +1,47 @@
+package net
+
+// bindUser 服务端会话建立后按Identity登记连接，重复认证换了身份时离开原来的用户
+func (n *SimpleNet) bindUser(conn *Connection, old, sess *Session) {
+	if old != nil && old.Identity != sess.Identity {
+		n.users.remove(conn, old.Identity)
+	}
+	n.users.join(conn, sess.Identity)
+}
+
+// UserConns 用户(Session.Identity)当前会话有效的连接，同一用户可以有多个连接(多个设备)
+func (n *SimpleNet) UserConns(user string) []*Connection {
+	conns := n.users.members(user)
+	live := conns[:0]
+	for _, conn := range conns {
+		if sess := conn.Session(); sess != nil && sess.Identity == user {
+			live = append(live, conn)
+		}
+	}
+	return live
+}
+
+// SendToUser 向用户所有会话有效的连接发送data，每个连接按自己的proto序列化，
+// 返回发送成功的连接数和发送失败的连接及原因，用户不在线时返回0
+func (n *SimpleNet) SendToUser(user string, data interface{}) (int, map[*Connection]error) {
+	return n.SendToUserExcept(user, nil, data)
+}
+
+// SendToUserExcept 向用户除except外的连接发送data，用于把一个设备上的操作同步到其他设备
+func (n *SimpleNet) SendToUserExcept(user string, except *Connection, data interface{}) (int, map[*Connection]error) {
+	sent := 0
+	var failed map[*Connection]error
+	for _, conn := range n.UserConns(user) {
+		if conn == except {
+			continue
+		}
+		if err := n.SendData(conn, data); err != nil {
+			if failed == nil {
+				failed = make(map[*Connection]error)
+			}
+			failed[conn] = err
+			continue
+		}
+		sent++
+	}
+	return sent, failed
+}
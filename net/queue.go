@@ -0,0 +1,269 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetEventQueue 给监听使用独立的事件队列，size为队列长度，
+// 之后该监听下连接的事件不再进入SimpleNet的公共队列，需要用Listener.PollEvent获取，
+// 避免一个繁忙的服务拖慢同一SimpleNet上其他服务的事件处理
+func (l *Listener) SetEventQueue(size int) {
+	events := make(chan *ConnEvent, size)
+	l.events.Store(&events)
+}
+
+// PollEvent 轮询监听的独立事件队列
+func (l *Listener) PollEvent(timeout int) (*ConnEvent, error) {
+	events := l.events.Load()
+	if events == nil {
+		return nil, fmt.Errorf("listener has no event queue")
+	}
+	return pollQueue(*events, l.net.ctx.Done(), timeout)
+}
+
+// SetClientEventQueue 给Connect建立的连接使用独立的事件队列，
+// 需要用PollClientEvent获取
+func (n *SimpleNet) SetClientEventQueue(size int) {
+	events := make(chan *ConnEvent, size)
+	n.clientEvents.Store(&events)
+}
+
+// PollClientEvent 轮询客户端连接的独立事件队列
+func (n *SimpleNet) PollClientEvent(timeout int) (*ConnEvent, error) {
+	events := n.clientEvents.Load()
+	if events == nil {
+		return nil, fmt.Errorf("no client event queue")
+	}
+	return pollQueue(*events, n.ctx.Done(), timeout)
+}
+
+// emit 把事件交给回调或者投递到连接所属的队列，
+// 优先级: Handler > 连接的队列 > 监听的分片/队列 > 客户端队列 > SimpleNet的分片/公共队列
+func (n *SimpleNet) emit(event *ConnEvent) {
+	if d := n.dispatcherFor(event); d != nil && d.dispatch(event) {
+		return
+	}
+	var events *chan *ConnEvent
+	if conn := event.Conn; conn != nil {
+		events = conn.events.Load()
+		if events == nil {
+			if conn.listen != nil {
+				events = pickShard(conn.listen.shards.Load(), conn)
+				if events == nil {
+					events = conn.listen.events.Load()
+				}
+			} else {
+				events = n.clientEvents.Load()
+			}
+		}
+		if events == nil {
+			events = pickShard(n.shards.Load(), conn)
+		}
+	}
+	if events == nil {
+		events = &n.events
+	}
+	// 销毁后没有轮询者，不再等待队列空位
+	select {
+	case *events <- event:
+	case <-n.ctx.Done():
+	}
+}
+
+// PostEvent 投递不属于连接的事件，供cluster等扩展使用，事件和其他事件一样通过Handler或者PollEvent获取
+func (n *SimpleNet) PostEvent(eventType int, data interface{}) {
+	n.emit(&ConnEvent{
+		EventType: eventType,
+		Data:      data,
+	})
+}
+
+// PollEvents 一次取出最多max个事件，没有事件时最多等待timeout毫秒，
+// 超时返回空列表。more表示队列中还有事件
+func (n *SimpleNet) PollEvents(max int, timeout int) (events []*ConnEvent, more bool, err error) {
+	return pollQueueBatch(n.events, n.ctx.Done(), max, timeout)
+}
+
+// PollEvents 批量轮询监听的独立事件队列
+func (l *Listener) PollEvents(max int, timeout int) (events []*ConnEvent, more bool, err error) {
+	queue := l.events.Load()
+	if queue == nil {
+		return nil, false, fmt.Errorf("listener has no event queue")
+	}
+	return pollQueueBatch(*queue, l.net.ctx.Done(), max, timeout)
+}
+
+// PollClientEvents 批量轮询客户端连接的独立事件队列
+func (n *SimpleNet) PollClientEvents(max int, timeout int) (events []*ConnEvent, more bool, err error) {
+	queue := n.clientEvents.Load()
+	if queue == nil {
+		return nil, false, fmt.Errorf("no client event queue")
+	}
+	return pollQueueBatch(*queue, n.ctx.Done(), max, timeout)
+}
+
+// pollQueueBatch 只在队列为空时使用一个定时器等待第一个事件，之后不再等待
+func pollQueueBatch(queue chan *ConnEvent, done <-chan struct{}, max int, timeout int) ([]*ConnEvent, bool, error) {
+	if max <= 0 {
+		max = 1
+	}
+	var first *ConnEvent
+	select {
+	case event := <-queue:
+		first = event
+	default:
+		timer := time.NewTimer(time.Millisecond * (time.Duration)(timeout))
+		defer timer.Stop()
+		select {
+		case event := <-queue:
+			first = event
+		case <-done:
+			return nil, false, ErrNetDestroyed
+		case <-timer.C:
+			return nil, false, nil
+		}
+	}
+
+	events := make([]*ConnEvent, 1, min(max, len(queue)+1))
+	events[0] = first
+	for len(events) < max {
+		select {
+		case event := <-queue:
+			events = append(events, event)
+		default:
+			return events, false, nil
+		}
+	}
+	return events, len(queue) > 0, nil
+}
+
+// PollEventCtx 等待事件直到ctx取消，取消时返回ctx.Err()，
+// 可以在关闭时从外部中止阻塞的轮询
+func (n *SimpleNet) PollEventCtx(ctx context.Context) (*ConnEvent, error) {
+	return pollQueueCtx(ctx, n.events, n.ctx.Done())
+}
+
+// PollEventCtx 等待监听独立事件队列的事件直到ctx取消
+func (l *Listener) PollEventCtx(ctx context.Context) (*ConnEvent, error) {
+	queue := l.events.Load()
+	if queue == nil {
+		return nil, fmt.Errorf("listener has no event queue")
+	}
+	return pollQueueCtx(ctx, *queue, l.net.ctx.Done())
+}
+
+// PollClientEventCtx 等待客户端连接独立事件队列的事件直到ctx取消
+func (n *SimpleNet) PollClientEventCtx(ctx context.Context) (*ConnEvent, error) {
+	queue := n.clientEvents.Load()
+	if queue == nil {
+		return nil, fmt.Errorf("no client event queue")
+	}
+	return pollQueueCtx(ctx, *queue, n.ctx.Done())
+}
+
+func pollQueueCtx(ctx context.Context, queue chan *ConnEvent, done <-chan struct{}) (*ConnEvent, error) {
+	select {
+	case event := <-queue:
+		return event, nil
+	case <-done:
+		return nil, ErrNetDestroyed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// EventQueue 独立的事件队列
+type EventQueue struct {
+	events chan *ConnEvent
+	done   <-chan struct{}
+}
+
+func newEventQueues(count, size int, done <-chan struct{}) []*EventQueue {
+	queues := make([]*EventQueue, count)
+	for i := range queues {
+		queues[i] = &EventQueue{events: make(chan *ConnEvent, size), done: done}
+	}
+	return queues
+}
+
+// PollEvent 轮询事件
+func (q *EventQueue) PollEvent(timeout int) (*ConnEvent, error) {
+	return pollQueue(q.events, q.done, timeout)
+}
+
+// PollEvents 批量轮询事件
+func (q *EventQueue) PollEvents(max int, timeout int) (events []*ConnEvent, more bool, err error) {
+	return pollQueueBatch(q.events, q.done, max, timeout)
+}
+
+// PollEventCtx 等待事件直到ctx取消
+func (q *EventQueue) PollEventCtx(ctx context.Context) (*ConnEvent, error) {
+	return pollQueueCtx(ctx, q.events, q.done)
+}
+
+// Len 队列中的事件数
+func (q *EventQueue) Len() int {
+	return len(q.events)
+}
+
+type shardSet []*EventQueue
+
+// pickShard 按连接ID选择分片，同一连接的事件总在同一个分片中
+func pickShard(shards *shardSet, conn *Connection) *chan *ConnEvent {
+	if shards == nil || len(*shards) == 0 {
+		return nil
+	}
+	q := (*shards)[uint64(conn.id)%uint64(len(*shards))]
+	return &q.events
+}
+
+// SetEventShards 把没有独立队列的连接的事件按连接ID分散到count个队列，
+// 每个队列长度为size，可以由多个goroutine分别轮询，同一连接的事件保持顺序。
+// count<=0时取消，恢复公共队列
+func (n *SimpleNet) SetEventShards(count, size int) []*EventQueue {
+	if count <= 0 {
+		n.shards.Store(nil)
+		return nil
+	}
+	shards := shardSet(newEventQueues(count, size, n.ctx.Done()))
+	n.shards.Store(&shards)
+	return shards
+}
+
+// SetEventShards 把监听下连接的事件按连接ID分散到count个队列，count<=0时取消
+func (l *Listener) SetEventShards(count, size int) []*EventQueue {
+	if count <= 0 {
+		l.shards.Store(nil)
+		return nil
+	}
+	shards := shardSet(newEventQueues(count, size, l.net.ctx.Done()))
+	l.shards.Store(&shards)
+	return shards
+}
+
+// SetEventQueue 给连接使用独立的事件队列，之后该连接的事件需要用Connection.PollEvent获取，
+// 设置前已经发出的事件(如EventNewConnection)仍在原来的队列中
+func (c *Connection) SetEventQueue(size int) {
+	events := make(chan *ConnEvent, size)
+	c.events.Store(&events)
+}
+
+// PollEvent 轮询连接的独立事件队列
+func (c *Connection) PollEvent(timeout int) (*ConnEvent, error) {
+	events := c.events.Load()
+	if events == nil {
+		return nil, fmt.Errorf("connection has no event queue")
+	}
+	return pollQueue(*events, c.net.ctx.Done(), timeout)
+}
+
+// PollEventCtx 等待连接独立事件队列的事件直到ctx取消
+func (c *Connection) PollEventCtx(ctx context.Context) (*ConnEvent, error) {
+	events := c.events.Load()
+	if events == nil {
+		return nil, fmt.Errorf("connection has no event queue")
+	}
+	return pollQueueCtx(ctx, *events, c.net.ctx.Done())
+}
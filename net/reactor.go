@@ -0,0 +1,267 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// ErrReactorUnsupported 当前平台不支持reactor
+var ErrReactorUnsupported = errors.New("reactor not supported")
+
+// 连接在reactor中的状态
+const (
+	reactorNone    = iota // 不在reactor中，使用读协程
+	reactorIdle           // 等待可读事件
+	reactorRunning        // 有协程在读
+)
+
+const reactorEvents = 256
+
+// poller epoll/kqueue的封装，连接的fd只注册一次性的可读事件，读完后重新注册
+type poller interface {
+	add(fd int) error
+	rearm(fd int) error
+	del(fd int) error
+	// wait 等待可读的fd，被wake唤醒时返回0
+	wait(fds []int) (int, error)
+	wake() error
+	close() error
+}
+
+type reactor struct {
+	net  *SimpleNet
+	poll poller
+
+	lock  sync.Mutex
+	conns map[int]*Connection // fd -> 连接
+}
+
+type reactorConn struct {
+	r     *reactor
+	fd    int
+	state atomic.Int32
+}
+
+// EnableReactor 开启reactor模式，之后建立的TCP和Unix连接不再各自占用一个读协程，
+// 由epoll(Linux)或者kqueue(BSD/macOS)等待可读，可读时才启动协程读取报文，读完后释放。
+// 事件和发送接口不变，发送协程本来只在有数据时运行。
+// 报文只到达一部分时协程等待剩余部分；TLS、PROXY protocol等包装过的连接和STARTTLS升级后的连接
+// 仍然使用读协程。重复调用无影响，不支持的平台返回ErrReactorUnsupported
+func (n *SimpleNet) EnableReactor() error {
+	if n.reactor.Load() != nil {
+		return nil
+	}
+	poll, err := newPoller()
+	if err != nil {
+		return err
+	}
+	r := &reactor{
+		net:   n,
+		poll:  poll,
+		conns: make(map[int]*Connection),
+	}
+	if !n.reactor.CompareAndSwap(nil, r) {
+		poll.close()
+		return nil
+	}
+	go r.run()
+	return nil
+}
+
+// startRead 开始读取连接，reactor模式下注册到reactor，否则启动读协程
+func (n *SimpleNet) startRead(conn *Connection) {
+	if r := n.reactor.Load(); r != nil && r.register(conn) {
+		return
+	}
+	n.wg.Add(1)
+	go n.handleRead(conn)
+}
+
+// connFd 没有用户态缓冲的连接的fd，读到的数据都来自内核，可以用可读事件判断有没有数据
+func connFd(c net.Conn) (int, bool) {
+	var sc syscall.Conn
+	switch c := c.(type) {
+	case *net.TCPConn:
+		sc = c
+	case *net.UnixConn:
+		sc = c
+	default:
+		return 0, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	fd := -1
+	if err := raw.Control(func(f uintptr) { fd = int(f) }); err != nil {
+		return 0, false
+	}
+	return fd, fd >= 0
+}
+
+// reactorReady 连接是否可以由reactor读取
+func (c *Connection) reactorReady() bool {
+	if c.upgrade.Load() != nil {
+		return false
+	}
+	_, ok := connFd(c.conn)
+	return ok
+}
+
+// bufferedFrame Splitter已经读到但还没有切分的完整报文，不能等待可读事件
+func (c *Connection) bufferedFrame() bool {
+	if len(c.pending) == 0 {
+		return false
+	}
+	splitter, ok := protoAs[Splitter](c.proto)
+	if !ok {
+		return false
+	}
+	advance, frame, err := splitter.Split(c.pending, false)
+	return err != nil || advance > 0 || frame != nil
+}
+
+func (r *reactor) register(conn *Connection) bool {
+	if conn.upgrade.Load() != nil {
+		return false
+	}
+	fd, ok := connFd(conn.conn)
+	if !ok {
+		return false
+	}
+	conn.reactor.r, conn.reactor.fd = r, fd
+	conn.reactor.state.Store(reactorIdle)
+
+	r.lock.Lock()
+	r.conns[fd] = conn
+	r.lock.Unlock()
+
+	if err := r.poll.add(fd); err != nil {
+		r.net.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("reactor add %s failed, err = %s\n", conn.remoteAddr, err))
+		r.forget(conn)
+		if !conn.reactor.state.CompareAndSwap(reactorIdle, reactorNone) {
+			// 已经被唤醒读取
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// forget 从fd表中删除连接，fd可能已经被新连接复用
+func (r *reactor) forget(conn *Connection) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.conns[conn.reactor.fd] == conn {
+		delete(r.conns, conn.reactor.fd)
+	}
+}
+
+// dispatch 连接在等待可读事件时启动协程读取
+func (r *reactor) dispatch(conn *Connection) {
+	if conn.reactor.state.CompareAndSwap(reactorIdle, reactorRunning) {
+		r.net.wg.Add(1)
+		go r.serve(conn)
+	}
+}
+
+// wakeReader reactor模式下没有协程在读时启动一个，用于暂停读(UpgradeTLS)或者处理连接关闭
+func (c *Connection) wakeReader() {
+	if c.reactor.state.Load() != reactorNone {
+		c.reactor.r.dispatch(c)
+	}
+}
+
+// leaveReactor 连接关闭，和读协程一样读到错误后发出事件
+func (c *Connection) leaveReactor() {
+	if c.reactor.state.Load() != reactorNone {
+		c.reactor.r.forget(c)
+		c.reactor.r.dispatch(c)
+	}
+}
+
+func (r *reactor) run() {
+	defer func() {
+		err := recover()
+		if err != nil {
+			r.net.logMsg(mylog.LevelError, fmt.Sprintf("reactor panic: %s\n", err))
+		}
+	}()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-r.net.ctx.Done():
+			r.poll.wake()
+		case <-stop:
+		}
+	}()
+
+	fds := make([]int, reactorEvents)
+	for {
+		count, err := r.poll.wait(fds)
+		if r.net.ctx.Err() != nil {
+			r.poll.close()
+			return
+		}
+		if err != nil {
+			r.net.logMsg(mylog.LevelError, fmt.Sprintf("reactor wait failed, err = %s\n", err))
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		for _, fd := range fds[:count] {
+			r.lock.Lock()
+			conn := r.conns[fd]
+			r.lock.Unlock()
+			if conn != nil {
+				r.dispatch(conn)
+			}
+		}
+	}
+}
+
+// serve 读取已经到达的报文后重新等待可读事件
+func (r *reactor) serve(conn *Connection) {
+	defer r.net.wg.Done()
+	defer func() {
+		err := recover()
+		if err != nil {
+			r.net.logMsg(mylog.LevelError, fmt.Sprintf("reactor serve panic: %s\n", err))
+		}
+	}()
+	for {
+		if !r.net.readOnce(conn) {
+			r.forget(conn)
+			return
+		}
+		if !conn.reactorReady() {
+			// 升级为TLS等，改为使用读协程
+			r.forget(conn)
+			r.poll.del(conn.reactor.fd)
+			conn.reactor.state.Store(reactorNone)
+			for r.net.readOnce(conn) {
+			}
+			return
+		}
+		if conn.bufferedFrame() {
+			continue
+		}
+		conn.reactor.state.Store(reactorIdle)
+		if err := r.poll.rearm(conn.reactor.fd); err == nil {
+			return
+		}
+		// 连接已经关闭等，继续读取，出错时按读协程的方式处理
+		if !conn.reactor.state.CompareAndSwap(reactorIdle, reactorRunning) {
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+type kqueue struct {
+	fd     int
+	pipe   [2]int // 唤醒wait
+	events []syscall.Kevent_t
+}
+
+func newPoller() (poller, error) {
+	fd, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(fd)
+	p := &kqueue{fd: fd, events: make([]syscall.Kevent_t, reactorEvents)}
+	if err := syscall.Pipe(p.pipe[:]); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("pipe", err)
+	}
+	for _, pfd := range p.pipe {
+		syscall.CloseOnExec(pfd)
+		syscall.SetNonblock(pfd, true)
+	}
+	if err := p.ctl(p.pipe[0], syscall.EV_ADD); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *kqueue) ctl(fd int, flags int) error {
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_READ, flags)
+	_, err := syscall.Kevent(p.fd, []syscall.Kevent_t{ev}, nil, nil)
+	return os.NewSyscallError("kevent", err)
+}
+
+func (p *kqueue) add(fd int) error {
+	return p.ctl(fd, syscall.EV_ADD|syscall.EV_ONESHOT)
+}
+
+func (p *kqueue) rearm(fd int) error {
+	return p.ctl(fd, syscall.EV_ADD|syscall.EV_ONESHOT)
+}
+
+func (p *kqueue) del(fd int) error {
+	return p.ctl(fd, syscall.EV_DELETE)
+}
+
+func (p *kqueue) wait(fds []int) (int, error) {
+	count, err := syscall.Kevent(p.fd, nil, p.events[:min(len(fds), len(p.events))], nil)
+	if err == syscall.EINTR {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, os.NewSyscallError("kevent", err)
+	}
+	n := 0
+	for _, ev := range p.events[:count] {
+		if int(ev.Ident) == p.pipe[0] {
+			var buf [16]byte
+			syscall.Read(p.pipe[0], buf[:])
+			continue
+		}
+		fds[n] = int(ev.Ident)
+		n++
+	}
+	return n, nil
+}
+
+func (p *kqueue) wake() error {
+	_, err := syscall.Write(p.pipe[1], []byte{0})
+	return err
+}
+
+func (p *kqueue) close() error {
+	syscall.Close(p.pipe[0])
+	syscall.Close(p.pipe[1])
+	return syscall.Close(p.fd)
+}
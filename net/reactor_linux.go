@@ -0,0 +1,82 @@
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+type epoll struct {
+	fd     int
+	pipe   [2]int // 唤醒wait
+	events []syscall.EpollEvent
+}
+
+func newPoller() (poller, error) {
+	fd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	p := &epoll{fd: fd, events: make([]syscall.EpollEvent, reactorEvents)}
+	if err := syscall.Pipe2(p.pipe[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("pipe2", err)
+	}
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(p.pipe[0])}
+	if err := syscall.EpollCtl(fd, syscall.EPOLL_CTL_ADD, p.pipe[0], &ev); err != nil {
+		p.close()
+		return nil, os.NewSyscallError("epoll_ctl", err)
+	}
+	return p, nil
+}
+
+func (p *epoll) ctl(op, fd int) error {
+	ev := syscall.EpollEvent{
+		Events: syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT,
+		Fd:     int32(fd),
+	}
+	return os.NewSyscallError("epoll_ctl", syscall.EpollCtl(p.fd, op, fd, &ev))
+}
+
+func (p *epoll) add(fd int) error {
+	return p.ctl(syscall.EPOLL_CTL_ADD, fd)
+}
+
+func (p *epoll) rearm(fd int) error {
+	return p.ctl(syscall.EPOLL_CTL_MOD, fd)
+}
+
+func (p *epoll) del(fd int) error {
+	return os.NewSyscallError("epoll_ctl", syscall.EpollCtl(p.fd, syscall.EPOLL_CTL_DEL, fd, nil))
+}
+
+func (p *epoll) wait(fds []int) (int, error) {
+	count, err := syscall.EpollWait(p.fd, p.events[:min(len(fds), len(p.events))], -1)
+	if err == syscall.EINTR {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, os.NewSyscallError("epoll_wait", err)
+	}
+	n := 0
+	for _, ev := range p.events[:count] {
+		if int(ev.Fd) == p.pipe[0] {
+			var buf [16]byte
+			syscall.Read(p.pipe[0], buf[:])
+			continue
+		}
+		fds[n] = int(ev.Fd)
+		n++
+	}
+	return n, nil
+}
+
+func (p *epoll) wake() error {
+	_, err := syscall.Write(p.pipe[1], []byte{0})
+	return err
+}
+
+func (p *epoll) close() error {
+	syscall.Close(p.pipe[0])
+	syscall.Close(p.pipe[1])
+	return syscall.Close(p.fd)
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package net
+
+func newPoller() (poller, error) {
+	return nil, ErrReactorUnsupported
+}
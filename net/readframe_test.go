@@ -0,0 +1,39 @@
+package net
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestReadFragmentedFrame 头部和body分成单字节到达时仍然读出完整的报文
+func TestReadFragmentedFrame(t *testing.T) {
+	n := newTestNet(t)
+	c, err := net.Dial("tcp", listenTest(t, n, &benchProto{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.(*net.TCPConn).SetNoDelay(true)
+
+	var frames []byte
+	for _, body := range []string{"hello", "world"} {
+		frames = binary.BigEndian.AppendUint32(frames, uint32(len(body)))
+		frames = append(frames, body...)
+	}
+	go func() {
+		for i := range frames {
+			if _, err := c.Write(frames[i : i+1]); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for _, want := range []string{"hello", "world"} {
+		evt := waitEvent(t, n, EventNewConnectionData)
+		if got := string(evt.Data.([]byte)); got != want {
+			t.Fatalf("receive %q, want %q", got, want)
+		}
+	}
+}
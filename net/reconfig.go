@@ -0,0 +1,257 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+type boundAddr struct {
+	addr   string // 配置的地址，可能和实际地址不同(如":0")
+	listen net.Listener
+}
+
+func (b *boundAddr) match(addr string) bool {
+	return b.addr == addr || b.listen.Addr().String() == addr
+}
+
+// AddAddress 在运行中的监听上增加监听地址，返回实际监听的地址
+func (l *Listener) AddAddress(addr string) (string, error) {
+	if l.Status() != StatusListenning {
+		return "", fmt.Errorf("listener not listenning")
+	}
+	l.lockListen.Lock()
+	for _, v := range l.listens {
+		if v.match(addr) {
+			l.lockListen.Unlock()
+			return "", fmt.Errorf("address %s already bound", addr)
+		}
+	}
+	l.lockListen.Unlock()
+
+	listen, err := l.listenAddr(addr)
+	if err != nil {
+		return "", err
+	}
+	l.addListener(addr, listen)
+	return listen.Addr().String(), nil
+}
+
+// listenAddr 按监听的类型(TCP/TLS/UDP等)监听新地址
+func (l *Listener) listenAddr(addr string) (net.Listener, error) {
+	l.lockListen.Lock()
+	listenFunc := l.listenFunc
+	l.lockListen.Unlock()
+
+	if listenFunc == nil {
+		return listenTCP(addr)
+	}
+	return listenFunc(addr)
+}
+
+// splitAddrs 拆分逗号分隔的多个地址，如"0.0.0.0:80,[::]:80"
+func splitAddrs(addr string) []string {
+	var addrs []string
+	for _, v := range strings.Split(addr, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			addrs = append(addrs, v)
+		}
+	}
+	if len(addrs) == 0 {
+		// 保持net.Listen对空地址的处理
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// listenAll 监听逗号分隔的所有地址，有一个失败时关闭已经监听的地址
+func listenAll(addr string, listenFunc func(string) (net.Listener, error)) ([]string, []net.Listener, error) {
+	addrs := splitAddrs(addr)
+	listens := make([]net.Listener, 0, len(addrs))
+	for _, v := range addrs {
+		listen, err := listenFunc(v)
+		if err != nil {
+			for _, listen := range listens {
+				listen.Close()
+			}
+			return nil, nil, err
+		}
+		listens = append(listens, listen)
+	}
+	return addrs, listens, nil
+}
+
+func listenTCP(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+// listenWith 使用listenFunc监听addr(可以是逗号分隔的多个地址)，
+// 之后AddAddress增加的地址也使用listenFunc
+func (n *SimpleNet) listenWith(addr string, listenFunc func(string) (net.Listener, error), proto IProto, factory ProtoFactory) (*Listener, error) {
+	addrs, listens, err := listenAll(addr, listenFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	l := n.newListener(proto, factory)
+	l.listenFunc = listenFunc
+	for i, listen := range listens {
+		l.addListener(addrs[i], listen)
+	}
+	return l, nil
+}
+
+// AddListener 在运行中的监听上增加net.Listener
+func (l *Listener) AddListener(listen net.Listener) {
+	l.addListener(listen.Addr().String(), listen)
+}
+
+func (l *Listener) addListener(addr string, listen net.Listener) {
+	l.lockListen.Lock()
+	l.listens = append(l.listens, &boundAddr{addr: addr, listen: listen})
+	l.lockListen.Unlock()
+
+	l.net.wg.Add(1)
+	go l.net.listening(l, listen)
+}
+
+// RemoveAddress 停止监听addr(配置的地址或者实际地址)，
+// 同一地址有多个socket(SO_REUSEPORT)时全部关闭，已经建立的连接不受影响
+func (l *Listener) RemoveAddress(addr string) error {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	var removed []*boundAddr
+	l.listens = slices.DeleteFunc(l.listens, func(v *boundAddr) bool {
+		if v.match(addr) {
+			removed = append(removed, v)
+			return true
+		}
+		return false
+	})
+	if len(removed) == 0 {
+		return fmt.Errorf("address %s not bound", addr)
+	}
+	var err error
+	for _, v := range removed {
+		if cerr := v.listen.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// removeBound 按socket关闭，不影响同一地址的其他socket
+func (l *Listener) removeBound(remove []*boundAddr) {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	l.listens = slices.DeleteFunc(l.listens, func(v *boundAddr) bool {
+		if slices.Contains(remove, v) {
+			v.listen.Close()
+			return true
+		}
+		return false
+	})
+}
+
+// Addresses 实际监听的地址
+func (l *Listener) Addresses() []string {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	addrs := make([]string, 0, len(l.listens))
+	for _, v := range l.listens {
+		addrs = append(addrs, v.listen.Addr().String())
+	}
+	return addrs
+}
+
+// SetProto 修改新连接使用的proto，已有连接不变
+func (l *Listener) SetProto(proto IProto) {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	l.proto = proto
+	l.factory = nil
+}
+
+// Proto 新连接使用的proto
+func (l *Listener) Proto() IProto {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	return l.proto
+}
+
+// ListenerConfig 监听的运行时配置，用于配置热加载，
+// 每次传入完整的配置
+type ListenerConfig struct {
+	// Addresses 需要监听的地址，为空时不修改地址
+	Addresses []string
+	// Proto 新连接使用的proto，为nil时不修改
+	Proto IProto
+	// ProtoFactory 新连接的proto工厂，优先于Proto，为nil时不修改
+	ProtoFactory ProtoFactory
+	// HandshakeTimeout 握手超时，<=0使用默认值
+	HandshakeTimeout time.Duration
+	// ReadBandwidth/WriteBandwidth 总带宽(字节/秒)，0不限制
+	ReadBandwidth  int64
+	WriteBandwidth int64
+}
+
+// Reconfigure 在不断开已有连接的情况下应用新配置。
+// 地址按差异增删，新地址监听失败时返回错误，其余配置仍然生效
+func (l *Listener) Reconfigure(conf *ListenerConfig) error {
+	if conf.ProtoFactory != nil {
+		l.SetProtoFactory(conf.ProtoFactory)
+	} else if conf.Proto != nil {
+		l.SetProto(conf.Proto)
+	}
+	l.SetHandshakeTimeout(conf.HandshakeTimeout)
+	l.SetBandwidth(conf.ReadBandwidth, conf.WriteBandwidth)
+
+	if len(conf.Addresses) == 0 {
+		return nil
+	}
+
+	// 同一地址可能有多个socket(SO_REUSEPORT)，仍然需要的地址的socket全部保留
+	want := make(map[string]bool, len(conf.Addresses))
+	for _, addr := range conf.Addresses {
+		want[addr] = true
+	}
+	bound := make(map[string]bool, len(conf.Addresses))
+	var remove []*boundAddr
+	l.lockListen.Lock()
+	for _, v := range l.listens {
+		found := false
+		for addr := range want {
+			if v.match(addr) {
+				bound[addr] = true
+				found = true
+			}
+		}
+		if !found {
+			remove = append(remove, v)
+		}
+	}
+	l.lockListen.Unlock()
+
+	// 先监听新地址，失败时保留旧地址
+	var err error
+	for _, addr := range conf.Addresses {
+		if bound[addr] {
+			continue
+		}
+		if _, e := l.AddAddress(addr); e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return err
+	}
+	l.removeBound(remove)
+	return nil
+}
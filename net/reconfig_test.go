@@ -0,0 +1,43 @@
+package net
+
+import (
+	"net"
+	"testing"
+)
+
+// TestReconfigureReusePort 同一地址的多个SO_REUSEPORT socket在地址不变时全部保留
+func TestReconfigureReusePort(t *testing.T) {
+	n := NewSimpleNet(discardLog(t))
+	defer SimpleNetDestroy(n)
+
+	l, err := n.ListenWithOptions("127.0.0.1:0", &ListenOptions{ReusePort: 3}, &benchProto{})
+	if err != nil {
+		t.Skipf("reuseport not supported, err = %v", err)
+	}
+	addrs := l.Addresses()
+	if len(addrs) != 3 {
+		t.Fatalf("addresses = %v", addrs)
+	}
+
+	for _, conf := range [][]string{{"127.0.0.1:0"}, {addrs[0]}} {
+		if err := l.Reconfigure(&ListenerConfig{Addresses: conf}); err != nil {
+			t.Fatal(err)
+		}
+		if got := l.Addresses(); len(got) != 3 {
+			t.Fatalf("reconfigure %v, addresses = %v", conf, got)
+		}
+		conn, err := net.Dial("tcp", addrs[0])
+		if err != nil {
+			t.Fatalf("reconfigure %v, dial err = %v", conf, err)
+		}
+		conn.Close()
+	}
+
+	// 地址变化时旧地址的socket全部关闭
+	if err := l.Reconfigure(&ListenerConfig{Addresses: []string{"localhost:0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Addresses(); len(got) != 1 || got[0] == addrs[0] {
+		t.Fatalf("addresses = %v", got)
+	}
+}
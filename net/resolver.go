@@ -0,0 +1,114 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// ErrNoEndpoints 服务没有可用的地址
+var ErrNoEndpoints = errors.New("no endpoints")
+
+// Resolver 服务发现，把服务名解析为地址(host:port)列表
+type Resolver interface {
+	// Resolve 服务当前的地址
+	Resolve(ctx context.Context, service string) ([]string, error)
+	// Watch 先发送当前的地址，之后每次变化时发送新的地址列表，ctx结束时关闭channel
+	Watch(ctx context.Context, service string) (<-chan []string, error)
+}
+
+// SetResolver 注册scheme的Resolver，之后Connect、ConnectContext、ConnectFactory和ConnectManaged
+// 的地址可以是"scheme://服务名"，
+// 如SetResolver("service", r)后Connect("service://orders", proto)。
+// 连接时解析并随机选择一个可以连接的地址，自动重连的连接在当前地址从服务中移除时断开重连。nil取消
+func (n *SimpleNet) SetResolver(scheme string, r Resolver) {
+	if r == nil {
+		n.resolvers.Delete(scheme)
+		return
+	}
+	n.resolvers.Store(scheme, r)
+}
+
+// resolverOf addr为已注册scheme的服务地址时返回Resolver和服务名
+func (n *SimpleNet) resolverOf(addr string) (Resolver, string, bool) {
+	scheme, service, ok := strings.Cut(addr, "://")
+	if !ok {
+		return nil, "", false
+	}
+	r, ok := n.resolvers.Load(scheme)
+	if !ok {
+		return nil, "", false
+	}
+	return r.(Resolver), service, true
+}
+
+// dialEndpoint 同dial，服务地址先解析，按随机顺序连接直到成功，返回实际连接的地址
+func (n *SimpleNet) dialEndpoint(ctx context.Context, network, addr string) (net.Conn, string, error) {
+	r, service, ok := n.resolverOf(addr)
+	if !ok {
+		conn, err := n.dial(ctx, network, addr)
+		return conn, addr, err
+	}
+	endpoints, err := r.Resolve(ctx, service)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve %s failed, err = %w", addr, err)
+	}
+	if len(endpoints) == 0 {
+		return nil, "", fmt.Errorf("%w, service = %s", ErrNoEndpoints, addr)
+	}
+	endpoints = slices.Clone(endpoints)
+	rand.Shuffle(len(endpoints), func(i, j int) {
+		endpoints[i], endpoints[j] = endpoints[j], endpoints[i]
+	})
+	var errs []error
+	for _, endpoint := range endpoints {
+		conn, err := n.dial(ctx, network, endpoint)
+		if err == nil {
+			return conn, endpoint, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", fmt.Errorf("connect %s failed, err = %w", addr, errors.Join(errs...))
+}
+
+// Endpoint 自动重连的连接当前连接的地址，地址不是服务地址时和连接地址相同
+func (m *ManagedConnection) Endpoint() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.endpoint
+}
+
+// watchService 当前地址从服务中移除时断开当前连接，由重连选择新的地址。
+// 服务没有地址时保留当前连接
+func (m *ManagedConnection) watchService(r Resolver, service string) {
+	ch, err := r.Watch(m.ctx, service)
+	if err != nil {
+		m.net.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("watch service %s failed, err = %s\n", m.addr, err))
+		return
+	}
+	for endpoints := range ch {
+		if len(endpoints) == 0 {
+			continue
+		}
+		m.lock.Lock()
+		conn, endpoint := m.conn, m.endpoint
+		m.lock.Unlock()
+		if slices.Contains(endpoints, endpoint) || conn.Status() != StatusConnected {
+			continue
+		}
+		m.net.logMsg(mylog.LevelNotice,
+			fmt.Sprintf("endpoint %s removed from service %s, reconnect\n", endpoint, m.addr))
+		m.net.CloseConn(conn)
+	}
+}
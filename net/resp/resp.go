@@ -0,0 +1,521 @@
+// Package resp Redis RESP2/RESP3协议的IProto实现，
+// 可以用于实现兼容Redis的服务端或者轻量的Redis客户端，支持inline命令
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// 类型
+const (
+	TypeSimpleString = '+'
+	TypeError        = '-'
+	TypeInteger      = ':'
+	TypeBulkString   = '$'
+	TypeArray        = '*'
+	// RESP3
+	TypeNull           = '_'
+	TypeBoolean        = '#'
+	TypeDouble         = ','
+	TypeBigNumber      = '('
+	TypeBulkError      = '!'
+	TypeVerbatimString = '='
+	TypeMap            = '%'
+	TypeSet            = '~'
+	TypePush           = '>'
+	TypeAttribute      = '|'
+)
+
+const (
+	defMaxBulkLen  = 512 * 1024 * 1024
+	defMaxElements = 1024 * 1024
+	defMaxTotal    = 1024 * 1024
+	defMaxDepth    = 32
+	maxInlineLen   = 64 * 1024
+)
+
+var (
+	// ErrProtocol 不符合RESP
+	ErrProtocol = errors.New("resp protocol error")
+
+	errIncomplete = errors.New("incomplete")
+)
+
+// Value 一个RESP值。Map的Array是依次排列的键值对，
+// 带有属性(|)的值把属性的键值对放在Attrs中
+type Value struct {
+	Type  byte
+	Str   string  // SimpleString/Error/BulkString/BulkError/VerbatimString/BigNumber
+	Int   int64   // Integer
+	Float float64 // Double
+	Bool  bool    // Boolean
+	Null  bool    // Null，或者RESP2的$-1/*-1
+	Array []Value // Array/Map/Set/Push
+	Attrs []Value
+}
+
+// SimpleString +OK
+func SimpleString(s string) Value { return Value{Type: TypeSimpleString, Str: s} }
+
+// Error -ERR message
+func Error(s string) Value { return Value{Type: TypeError, Str: s} }
+
+// Integer :1
+func Integer(n int64) Value { return Value{Type: TypeInteger, Int: n} }
+
+// Bulk $3\r\nfoo
+func Bulk(s string) Value { return Value{Type: TypeBulkString, Str: s} }
+
+// NullBulk RESP2的空值$-1
+func NullBulk() Value { return Value{Type: TypeBulkString, Null: true} }
+
+// Array 数组
+func Array(values ...Value) Value { return Value{Type: TypeArray, Array: values} }
+
+// Command 客户端命令，参数作为bulk string的数组
+func Command(args ...string) Value {
+	v := Value{Type: TypeArray, Array: make([]Value, len(args))}
+	for i, arg := range args {
+		v.Array[i] = Bulk(arg)
+	}
+	return v
+}
+
+// Args 命令的参数，不是bulk/simple string组成的数组时返回nil
+func (v Value) Args() []string {
+	if v.Type != TypeArray || v.Null {
+		return nil
+	}
+	args := make([]string, len(v.Array))
+	for i, e := range v.Array {
+		if e.Type != TypeBulkString && e.Type != TypeSimpleString {
+			return nil
+		}
+		args[i] = e.Str
+	}
+	return args
+}
+
+// IsError 是否为错误
+func (v Value) IsError() bool {
+	return v.Type == TypeError || v.Type == TypeBulkError
+}
+
+func (v Value) String() string {
+	switch {
+	case v.Null:
+		return "(nil)"
+	case v.Type == TypeInteger:
+		return strconv.FormatInt(v.Int, 10)
+	case v.Type == TypeDouble:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case v.Type == TypeBoolean:
+		return strconv.FormatBool(v.Bool)
+	case v.Array != nil:
+		parts := make([]string, len(v.Array))
+		for i, e := range v.Array {
+			parts[i] = e.String()
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	return v.Str
+}
+
+// Append 把v编码后追加到dst
+func (v Value) Append(dst []byte) []byte {
+	if len(v.Attrs) > 0 {
+		dst = appendAggregate(dst, TypeAttribute, len(v.Attrs)/2, v.Attrs)
+	}
+	switch v.Type {
+	case TypeNull:
+		return append(dst, "_\r\n"...)
+	case TypeSimpleString, TypeError, TypeBigNumber:
+		dst = append(dst, v.Type)
+		dst = append(dst, v.Str...)
+		return append(dst, "\r\n"...)
+	case TypeInteger:
+		dst = append(dst, TypeInteger)
+		dst = strconv.AppendInt(dst, v.Int, 10)
+		return append(dst, "\r\n"...)
+	case TypeDouble:
+		dst = append(dst, TypeDouble)
+		switch {
+		case math.IsInf(v.Float, 1):
+			dst = append(dst, "inf"...)
+		case math.IsInf(v.Float, -1):
+			dst = append(dst, "-inf"...)
+		case math.IsNaN(v.Float):
+			dst = append(dst, "nan"...)
+		default:
+			dst = strconv.AppendFloat(dst, v.Float, 'g', -1, 64)
+		}
+		return append(dst, "\r\n"...)
+	case TypeBoolean:
+		if v.Bool {
+			return append(dst, "#t\r\n"...)
+		}
+		return append(dst, "#f\r\n"...)
+	case TypeBulkString, TypeBulkError, TypeVerbatimString:
+		if v.Null {
+			return append(dst, "$-1\r\n"...)
+		}
+		dst = append(dst, v.Type)
+		dst = strconv.AppendInt(dst, int64(len(v.Str)), 10)
+		dst = append(dst, "\r\n"...)
+		dst = append(dst, v.Str...)
+		return append(dst, "\r\n"...)
+	case TypeArray, TypeSet, TypePush:
+		if v.Null {
+			return append(dst, "*-1\r\n"...)
+		}
+		return appendAggregate(dst, v.Type, len(v.Array), v.Array)
+	case TypeMap:
+		return appendAggregate(dst, TypeMap, len(v.Array)/2, v.Array)
+	}
+	return dst
+}
+
+func appendAggregate(dst []byte, typ byte, count int, values []Value) []byte {
+	dst = append(dst, typ)
+	dst = strconv.AppendInt(dst, int64(count), 10)
+	dst = append(dst, "\r\n"...)
+	for _, e := range values {
+		dst = e.Append(dst)
+	}
+	return dst
+}
+
+// Proto RESP的IProto实现，Parse返回Value，Serialize接受Value、*Value、
+// []string(作为命令)或者error(作为错误)。
+// Split记录不完整报文的扫描进度，数据到达后从上次的位置继续，
+// 可以在多个连接间共享，但连接交替到达数据时需要从头扫描，
+// 大报文较多时每个连接使用独立的Proto(ListenFactory)
+type Proto struct {
+	MaxBulkLen  int // bulk string的最大长度，<=0时为512M
+	MaxElements int // 聚合类型的最大元素个数，<=0时为1M
+	MaxDepth    int // 最大嵌套层数，属性也算一层，<=0时为32
+	MaxTotal    int // 一个报文中所有聚合类型的元素总数上限，<=0时为1M
+
+	lock sync.Mutex
+	scan scanState
+}
+
+// scanState 不完整报文的扫描进度，data的起始地址不变并且只在后面追加时有效
+type scanState struct {
+	base  *byte
+	pos   int   // 下一个值的开始
+	stack []int // 每一层聚合类型剩余的元素个数
+	total int
+}
+
+func (s *scanState) reset() {
+	s.base, s.pos, s.stack, s.total = nil, 0, s.stack[:0], 0
+}
+
+// New 使用默认限制创建Proto
+func New() *Proto {
+	return &Proto{}
+}
+
+func (p *Proto) FilterAccept(conn *mynet.Connection) bool {
+	return true
+}
+
+// HeadLen RESP没有固定的报文头，使用Split切分
+func (p *Proto) HeadLen() uint32 {
+	return 0
+}
+
+func (p *Proto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("resp has no head")
+}
+
+func (p *Proto) Split(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s := &p.scan
+	if s.base != &data[0] || len(data) < s.pos {
+		s.reset()
+		s.base = &data[0]
+	}
+	end, err := p.scanFrame(s, data)
+	if err == errIncomplete && !atEOF {
+		return 0, nil, nil
+	}
+	s.reset()
+	if err == errIncomplete {
+		return 0, nil, fmt.Errorf("%w, unexpected eof", ErrProtocol)
+	}
+	if err != nil {
+		// 丢弃出错的报文到行尾
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, nil, err
+		}
+		return 0, nil, err
+	}
+	if len(bytes.TrimSpace(data[:end])) == 0 {
+		// 空的inline命令直接忽略
+		return end, nil, nil
+	}
+	return end, data[:end], nil
+}
+
+// scanFrame 从s记录的位置继续扫描，返回报文的结束位置，
+// 数据不完整时返回errIncomplete并保存进度，已经扫描过的值不再重复扫描
+func (p *Proto) scanFrame(s *scanState, data []byte) (int, error) {
+	for {
+		depth := len(s.stack)
+		r := reader{p: p, data: data, pos: s.pos}
+		if depth == 0 && s.pos > 0 {
+			return s.pos, nil
+		}
+		if r.pos >= len(data) {
+			return 0, errIncomplete
+		}
+		if depth > 0 {
+			if s.total++; s.total > r.limit(p.MaxTotal, defMaxTotal) {
+				return 0, fmt.Errorf("%w, too many elements", ErrProtocol)
+			}
+		}
+		children := 0
+		switch typ := data[r.pos]; typ {
+		case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
+			r.pos++
+			line, err := r.line()
+			if err != nil {
+				s.total--
+				return 0, err
+			}
+			n, err := r.length(line, r.limit(p.MaxElements, defMaxElements))
+			if err != nil {
+				return 0, err
+			}
+			if typ == TypeMap || typ == TypeAttribute {
+				n *= 2
+			}
+			if typ == TypeAttribute {
+				// 属性之后是真正的值，作为属性的最后一个元素
+				n++
+			}
+			children = n
+		default:
+			// 标量的值，不是聚合类型时value不会递归
+			if _, err := r.value(depth, false); err != nil {
+				if err == errIncomplete && depth > 0 {
+					s.total--
+				}
+				return 0, err
+			}
+		}
+		s.pos = r.pos
+		if children > 0 {
+			if depth+1 > r.limit(p.MaxDepth, defMaxDepth) {
+				return 0, fmt.Errorf("%w, nested too deep", ErrProtocol)
+			}
+			s.stack = append(s.stack, children)
+			continue
+		}
+		// 值结束，逐层减少剩余的元素个数
+		for len(s.stack) > 0 {
+			top := len(s.stack) - 1
+			if s.stack[top]--; s.stack[top] > 0 {
+				break
+			}
+			s.stack = s.stack[:top]
+		}
+	}
+}
+
+func (p *Proto) Parse(head interface{}, body []byte) (interface{}, error) {
+	r := reader{p: p, data: body}
+	return r.value(0, true)
+}
+
+func (p *Proto) Serialize(data interface{}) ([]byte, error) {
+	switch v := data.(type) {
+	case Value:
+		return v.Append(nil), nil
+	case *Value:
+		return v.Append(nil), nil
+	case []string:
+		return Command(v...).Append(nil), nil
+	case error:
+		return Error(v.Error()).Append(nil), nil
+	}
+	return nil, fmt.Errorf("unexpect data type")
+}
+
+// reader 解析一个值，build为false时只检查是否完整
+type reader struct {
+	p     *Proto
+	data  []byte
+	pos   int
+	total int // 已经读到的元素个数
+}
+
+func (r *reader) line() ([]byte, error) {
+	i := bytes.Index(r.data[r.pos:], []byte("\r\n"))
+	if i < 0 {
+		if len(r.data)-r.pos > maxInlineLen {
+			return nil, fmt.Errorf("%w, line too long", ErrProtocol)
+		}
+		return nil, errIncomplete
+	}
+	line := r.data[r.pos : r.pos+i]
+	r.pos += i + 2
+	return line, nil
+}
+
+func (r *reader) length(line []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(line))
+	if err != nil || n < -1 {
+		return 0, fmt.Errorf("%w, bad length %q", ErrProtocol, line)
+	}
+	if n > max {
+		return 0, fmt.Errorf("%w, length %d exceeds %d", ErrProtocol, n, max)
+	}
+	return n, nil
+}
+
+func (r *reader) limit(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func (r *reader) value(depth int, build bool) (Value, error) {
+	if depth > r.limit(r.p.MaxDepth, defMaxDepth) {
+		return Value{}, fmt.Errorf("%w, nested too deep", ErrProtocol)
+	}
+	if depth > 0 {
+		if r.total++; r.total > r.limit(r.p.MaxTotal, defMaxTotal) {
+			return Value{}, fmt.Errorf("%w, too many elements", ErrProtocol)
+		}
+	}
+	if r.pos >= len(r.data) {
+		return Value{}, errIncomplete
+	}
+	typ := r.data[r.pos]
+	switch typ {
+	case TypeSimpleString, TypeError, TypeInteger, TypeBulkString, TypeArray,
+		TypeNull, TypeBoolean, TypeDouble, TypeBigNumber, TypeBulkError,
+		TypeVerbatimString, TypeMap, TypeSet, TypePush, TypeAttribute:
+	default:
+		if depth > 0 {
+			return Value{}, fmt.Errorf("%w, bad type %q", ErrProtocol, typ)
+		}
+		return r.inline(build)
+	}
+	r.pos++
+	line, err := r.line()
+	if err != nil {
+		return Value{}, err
+	}
+	v := Value{Type: typ}
+	switch typ {
+	case TypeSimpleString, TypeError, TypeBigNumber:
+		if build {
+			v.Str = string(line)
+		}
+	case TypeInteger:
+		if v.Int, err = strconv.ParseInt(string(line), 10, 64); err != nil {
+			return Value{}, fmt.Errorf("%w, bad integer %q", ErrProtocol, line)
+		}
+	case TypeNull:
+		v.Null = true
+	case TypeBoolean:
+		switch string(line) {
+		case "t":
+			v.Bool = true
+		case "f":
+		default:
+			return Value{}, fmt.Errorf("%w, bad boolean %q", ErrProtocol, line)
+		}
+	case TypeDouble:
+		if v.Float, err = strconv.ParseFloat(string(line), 64); err != nil {
+			return Value{}, fmt.Errorf("%w, bad double %q", ErrProtocol, line)
+		}
+	case TypeBulkString, TypeBulkError, TypeVerbatimString:
+		n, err := r.length(line, r.limit(r.p.MaxBulkLen, defMaxBulkLen))
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			v.Null = true
+			break
+		}
+		if len(r.data)-r.pos < n+2 {
+			return Value{}, errIncomplete
+		}
+		if r.data[r.pos+n] != '\r' || r.data[r.pos+n+1] != '\n' {
+			return Value{}, fmt.Errorf("%w, bad bulk terminator", ErrProtocol)
+		}
+		if build {
+			v.Str = string(r.data[r.pos : r.pos+n])
+		}
+		r.pos += n + 2
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
+		n, err := r.length(line, r.limit(r.p.MaxElements, defMaxElements))
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			v.Null = true
+			break
+		}
+		if typ == TypeMap || typ == TypeAttribute {
+			n *= 2
+		}
+		if build {
+			v.Array = make([]Value, 0, min(n, 1024))
+		}
+		for i := 0; i < n; i++ {
+			e, err := r.value(depth+1, build)
+			if err != nil {
+				return Value{}, err
+			}
+			if build {
+				v.Array = append(v.Array, e)
+			}
+		}
+		if typ == TypeAttribute {
+			// 属性之后是真正的值，算作属性的下一层，防止连续的属性不受MaxDepth限制
+			attrs := v.Array
+			if v, err = r.value(depth+1, build); err != nil {
+				return Value{}, err
+			}
+			v.Attrs = attrs
+		}
+	}
+	return v, nil
+}
+
+// inline 空格分隔的inline命令，如telnet输入的PING，可以只以\n结尾
+func (r *reader) inline(build bool) (Value, error) {
+	i := bytes.IndexByte(r.data[r.pos:], '\n')
+	if i < 0 {
+		if len(r.data)-r.pos > maxInlineLen {
+			return Value{}, fmt.Errorf("%w, inline command too long", ErrProtocol)
+		}
+		return Value{}, errIncomplete
+	}
+	line := r.data[r.pos : r.pos+i]
+	r.pos += i + 1
+	if !build {
+		return Value{}, nil
+	}
+	return Command(strings.Fields(string(line))...), nil
+}
@@ -0,0 +1,189 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// split 按块喂给Split，返回切分出的所有报文
+func split(t *testing.T, p *Proto, data []byte, chunk int) [][]byte {
+	t.Helper()
+	var frames [][]byte
+	var buf []byte
+	for i := 0; i < len(data); i += chunk {
+		buf = append(buf, data[i:min(i+chunk, len(data))]...)
+		for len(buf) > 0 {
+			advance, frame, err := p.Split(buf, false)
+			if err != nil {
+				t.Fatalf("split err = %v", err)
+			}
+			if advance == 0 {
+				break
+			}
+			if frame != nil {
+				frames = append(frames, append([]byte(nil), frame...))
+			}
+			buf = buf[advance:]
+		}
+	}
+	if len(buf) > 0 {
+		t.Fatalf("left %q", buf)
+	}
+	return frames
+}
+
+func TestRoundTrip(t *testing.T) {
+	values := []Value{
+		SimpleString("OK"),
+		Error("ERR bad"),
+		Integer(-42),
+		Bulk("hello\r\nworld"),
+		NullBulk(),
+		Command("SET", "k", "v"),
+		{Type: TypeMap, Array: []Value{Bulk("a"), Integer(1), Bulk("b"), {Type: TypeBoolean, Bool: true}}},
+		{Type: TypeSet, Array: []Value{{Type: TypeDouble, Float: 1.5}, {Type: TypeNull, Null: true}}},
+		{Type: TypePush, Array: []Value{Bulk("message"), Array(Bulk("x"), Array())}},
+		{Type: TypeBulkString, Str: "v", Attrs: []Value{Bulk("ttl"), Integer(3)}},
+	}
+	var stream []byte
+	for _, v := range values {
+		stream = v.Append(stream)
+	}
+	p := New()
+	for _, chunk := range []int{1, 3, 7, len(stream)} {
+		frames := split(t, p, stream, chunk)
+		if len(frames) != len(values) {
+			t.Fatalf("chunk %d got %d frames", chunk, len(frames))
+		}
+		for i, frame := range frames {
+			v, err := p.Parse(nil, frame)
+			if err != nil {
+				t.Fatalf("parse %q err = %v", frame, err)
+			}
+			want := values[i]
+			if want.Array != nil && len(want.Array) == 0 {
+				want.Array = []Value{}
+			}
+			if !reflect.DeepEqual(v.(Value).Append(nil), want.Append(nil)) {
+				t.Fatalf("chunk %d frame %d = %v, want %v", chunk, i, v, values[i])
+			}
+		}
+	}
+}
+
+func TestInline(t *testing.T) {
+	p := New()
+	frames := split(t, p, []byte("PING\r\n\r\nSET k  v\n"), 1)
+	if len(frames) != 2 {
+		t.Fatalf("frames = %q", frames)
+	}
+	v, _ := p.Parse(nil, frames[1])
+	if args := v.(Value).Args(); !reflect.DeepEqual(args, []string{"SET", "k", "v"}) {
+		t.Fatalf("args = %q", args)
+	}
+}
+
+// TestSplitShared 两个连接交替使用同一个Proto
+func TestSplitShared(t *testing.T) {
+	p := New()
+	a := Command("GET", "a").Append(nil)
+	b := Command("GET", "bb").Append(nil)
+	var bufA, bufB []byte
+	var got [][]byte
+	for i := 0; i < max(len(a), len(b)); i++ {
+		for _, c := range []struct {
+			buf  *[]byte
+			data []byte
+		}{{&bufA, a}, {&bufB, b}} {
+			if i >= len(c.data) {
+				continue
+			}
+			*c.buf = append(*c.buf, c.data[i])
+			advance, frame, err := p.Split(*c.buf, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frame != nil {
+				got = append(got, frame)
+				*c.buf = (*c.buf)[advance:]
+			}
+		}
+	}
+	if len(got) != 2 || !bytes.Equal(got[0], a) || !bytes.Equal(got[1], b) {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSplitEOF(t *testing.T) {
+	p := New()
+	if _, _, err := p.Split([]byte("*2\r\n$1\r\na\r\n"), true); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("err = %v", err)
+	}
+	// 出错后进度清除，同一个缓冲区可以重新扫描
+	if advance, frame, err := p.Split([]byte("+OK\r\n"), false); err != nil || advance != 5 || frame == nil {
+		t.Fatalf("advance = %d, err = %v", advance, err)
+	}
+}
+
+func TestAdversarial(t *testing.T) {
+	p := &Proto{MaxBulkLen: 16, MaxElements: 8, MaxDepth: 4, MaxTotal: 20}
+	nested := strings.Repeat("*1\r\n", 5) + ":1\r\n"
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"attribute chain", strings.Repeat("|1\r\n_\r\n_\r\n", 1000)},
+		{"nested", nested},
+		{"bulk too long", "$17\r\n"},
+		{"too many elements", "*9\r\n"},
+		{"too many total", "*3\r\n" + strings.Repeat("*8\r\n"+strings.Repeat(":1\r\n", 8), 3)},
+		{"negative length", "*-2\r\n"},
+		{"bad length", "$abc\r\n"},
+		{"bad type in array", "*1\r\nPING\r\n"},
+		{"bad integer", ":1x\r\n"},
+		{"bad bulk terminator", "$1\r\nabc\r\n"},
+		{"line too long", "+" + strings.Repeat("a", maxInlineLen+1)},
+	}
+	for _, tt := range tests {
+		if _, frame, err := p.Split([]byte(tt.data), false); !errors.Is(err, ErrProtocol) || frame != nil {
+			t.Errorf("%s: split err = %v", tt.name, err)
+		}
+		// 不经过Split直接Parse也受限制
+		if _, err := p.Parse(nil, []byte(tt.data)); !errors.Is(err, ErrProtocol) && err != errIncomplete {
+			t.Errorf("%s: parse err = %v", tt.name, err)
+		}
+	}
+}
+
+// TestAttributeChain 默认限制下大量连续的属性不会栈溢出
+func TestAttributeChain(t *testing.T) {
+	data := bytes.Repeat([]byte("|1\r\n_\r\n_\r\n"), 1000000)
+	p := New()
+	if _, _, err := p.Split(data, false); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("split err = %v", err)
+	}
+	if _, err := p.Parse(nil, data); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("parse err = %v", err)
+	}
+}
+
+// TestSplitLargeArray 大报文分块到达时不重复扫描已经到达的部分
+func TestSplitLargeArray(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	const count = 1000000
+	data := append([]byte("*1000000\r\n"), bytes.Repeat([]byte(":1\r\n"), count)...)
+	start := time.Now()
+	frames := split(t, New(), data, 4096)
+	if len(frames) != 1 || len(frames[0]) != len(data) {
+		t.Fatalf("frames = %d", len(frames))
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("split took %s", elapsed)
+	}
+}
@@ -0,0 +1,61 @@
+package net
+
+import (
+	"context"
+	"net"
+)
+
+// ListenWithOptions 按opts监听TCP，addr可以是逗号分隔的多个地址，opts.ReusePort>1时多个accept循环分担accept的负载。
+// 之后AddAddress增加的地址只打开一个(设置了SO_REUSEPORT的)socket
+func (n *SimpleNet) ListenWithOptions(addr string, opts *ListenOptions, proto IProto) (*Listener, error) {
+	if opts == nil {
+		opts = &ListenOptions{}
+	}
+	lc := &net.ListenConfig{}
+	if opts.ReusePort > 0 {
+		lc.Control = reusePortControl
+	}
+	if _, err := parsePrefixes(opts.ProxyTrusted); err != nil {
+		return nil, err
+	}
+	// 提前检查，避免监听之后包装失败
+	if opts.ProxyProtocol != ProxyProtocolOff && len(opts.ProxyTrusted) == 0 {
+		return nil, ErrProxyUntrusted
+	}
+	listenFunc := func(addr string) (net.Listener, error) {
+		listen, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return ProxyListener(listen, opts.ProxyProtocol, opts.ProxyTrusted...)
+	}
+
+	// 每个地址打开ReusePort个socket
+	addrs, listens, err := listenAll(addr, listenFunc)
+	if err != nil {
+		return nil, err
+	}
+	bound := addrs
+	for i := range addrs {
+		for count := 1; count < opts.ReusePort; count++ {
+			// 端口为0时其他socket使用第一个socket的实际端口
+			listen, err := listenFunc(listens[i].Addr().String())
+			if err != nil {
+				for _, v := range listens {
+					v.Close()
+				}
+				return nil, err
+			}
+			bound = append(bound, addrs[i])
+			listens = append(listens, listen)
+		}
+	}
+
+	l := n.newListener(proto, nil)
+	l.listenOptions.Store(opts)
+	l.listenFunc = listenFunc
+	for i, listen := range listens {
+		l.addListener(bound[i], listen)
+	}
+	return l, nil
+}
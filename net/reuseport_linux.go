@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package net
+
+// soReusePort syscall包没有定义Linux的SO_REUSEPORT
+const soReusePort = 0xf
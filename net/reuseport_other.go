@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package net
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT not supported")
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = os.NewSyscallError("setsockopt",
+			syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1))
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,256 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+var (
+	// ErrCallTimeout 超时没有收到响应
+	ErrCallTimeout = errors.New("call timeout")
+	// ErrCallConnClosed 收到响应前连接已经关闭
+	ErrCallConnClosed = errors.New("connection closed before reply")
+	// ErrCallCanceled 调用被取消
+	ErrCallCanceled = errors.New("call canceled")
+)
+
+// Caller proto的可选扩展，用于请求/响应式的调用
+type Caller interface {
+	// SetCallID 给请求设置调用ID，返回用于序列化的报文
+	SetCallID(req interface{}, id uint64) (interface{}, error)
+	// ReplyOf 收到的报文是响应时返回对应请求的ID
+	ReplyOf(data interface{}) (uint64, bool)
+}
+
+// CallFunc 调用完成的回调，reply为收到的响应。设置了BufferProvider时回调返回后reply引用的接收缓冲区被归还，
+// 回调之外需要保留的内容要先复制
+type CallFunc func(reply interface{}, err error)
+
+// Future 异步调用的句柄
+type Future struct {
+	ID uint64
+
+	conn  *Connection
+	timer *time.Timer
+	cb    CallFunc
+
+	done    chan struct{}
+	once    sync.Once
+	reply   interface{}
+	err     error
+	release func()
+	freed   sync.Once
+}
+
+// Done 收到响应或者失败后关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result 调用结果，未完成时返回nil, nil
+func (f *Future) Result() (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.reply, f.err
+	default:
+		return nil, nil
+	}
+}
+
+// Wait 等待响应，ctx结束时取消调用并返回ctx.Err()
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+		return f.reply, f.err
+	case <-ctx.Done():
+		if f.conn.calls.remove(f.ID) {
+			f.finish(nil, ctx.Err())
+		}
+		<-f.done
+		return f.reply, f.err
+	}
+}
+
+// Release 把Result引用的接收缓冲区还给BufferProvider，之后不能再使用Result，
+// 未完成、没有设置BufferProvider时什么也不做，重复调用无效。CallFunc的回调返回后自动归还
+func (f *Future) Release() {
+	select {
+	case <-f.done:
+	default:
+		return
+	}
+	f.freed.Do(func() {
+		if f.release != nil {
+			f.release()
+		}
+	})
+}
+
+// Cancel 取消调用，之后收到的响应被丢弃
+func (f *Future) Cancel() {
+	if f.conn.calls.remove(f.ID) {
+		f.finish(nil, ErrCallCanceled)
+	}
+}
+
+func (f *Future) finish(reply interface{}, err error) {
+	f.once.Do(func() {
+		if f.timer != nil {
+			f.timer.Stop()
+		}
+		f.reply, f.err = reply, err
+		close(f.done)
+		if f.cb != nil {
+			f.cb(reply, err)
+			f.Release()
+		}
+	})
+}
+
+// callTable 连接上等待响应的调用
+type callTable struct {
+	lock    sync.Mutex
+	nextID  atomic.Uint64
+	pending map[uint64]*Future
+	closed  bool
+}
+
+// add 加入等待表，timeout>0时启动超时定时器。定时器在锁内创建，
+// finish总是在从表中取出之后调用，读取timer不需要再加锁
+func (t *callTable) add(f *Future, timeout time.Duration) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.closed {
+		return false
+	}
+	if t.pending == nil {
+		t.pending = make(map[uint64]*Future)
+	}
+	t.pending[f.ID] = f
+	if timeout > 0 {
+		f.timer = time.AfterFunc(timeout, func() {
+			if t.remove(f.ID) {
+				f.finish(nil, ErrCallTimeout)
+			}
+		})
+	}
+	return true
+}
+
+// remove 移除调用，返回调用是否还在等待
+func (t *callTable) remove(id uint64) bool {
+	f, _ := t.take(id)
+	return f != nil
+}
+
+func (t *callTable) take(id uint64) (*Future, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	f, ok := t.pending[id]
+	if ok {
+		delete(t.pending, id)
+	}
+	return f, ok
+}
+
+// Call 发送请求并等待响应，proto需要实现Caller。ctx结束时取消调用。
+// 返回的响应引用的接收缓冲区不归还BufferProvider，由GC回收
+func (n *SimpleNet) Call(ctx context.Context, conn *Connection, req interface{}) (interface{}, error) {
+	f, err := n.call(conn, req, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Wait(ctx)
+}
+
+// CallAsync 发送请求，返回等待响应的Future，timeout<=0时一直等待直到连接关闭或者Cancel
+func (n *SimpleNet) CallAsync(conn *Connection, req interface{}, timeout time.Duration) (*Future, error) {
+	return n.call(conn, req, timeout, nil)
+}
+
+// CallFunc 发送请求，收到响应或者失败后调用cb。cb在读协程或者定时器中调用，不能阻塞，
+// 返回错误时不会调用cb
+func (n *SimpleNet) CallFunc(conn *Connection, req interface{}, timeout time.Duration, cb CallFunc) error {
+	_, err := n.call(conn, req, timeout, cb)
+	return err
+}
+
+// PendingCalls 连接上等待响应的调用数
+func (c *Connection) PendingCalls() int {
+	c.calls.lock.Lock()
+	defer c.calls.lock.Unlock()
+
+	return len(c.calls.pending)
+}
+
+func (n *SimpleNet) call(conn *Connection, req interface{}, timeout time.Duration, cb CallFunc) (*Future, error) {
+	caller, ok := protoAs[Caller](conn.proto)
+	if !ok {
+		return nil, fmt.Errorf("proto not support call")
+	}
+	id := conn.calls.nextID.Add(1)
+	data, err := caller.SetCallID(req, id)
+	if err != nil {
+		return nil, err
+	}
+	f := &Future{
+		ID:   id,
+		conn: conn,
+		cb:   cb,
+		done: make(chan struct{}),
+	}
+	// 响应可能在SendData返回前到达，先加入等待表
+	if !conn.calls.add(f, timeout) {
+		return nil, ErrConnClosed
+	}
+	if err := n.SendData(conn, data); err != nil {
+		if f, ok := conn.calls.take(id); ok && f.timer != nil {
+			f.timer.Stop()
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// handleReply 收到的报文是响应时完成对应的调用，返回true表示不需要继续处理。
+// release归还data引用的接收缓冲区，交给Future之后由Future.Release归还
+func (c *Connection) handleReply(data interface{}, release func()) bool {
+	caller, ok := protoAs[Caller](c.proto)
+	if !ok {
+		return false
+	}
+	id, ok := caller.ReplyOf(data)
+	if !ok {
+		return false
+	}
+	if f, ok := c.calls.take(id); ok {
+		f.release = release
+		f.finish(data, nil)
+	} else {
+		release()
+		c.net.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("drop reply without call, id = %d, remoteAddr = %s\n", id, c.remoteAddr))
+	}
+	return true
+}
+
+// failCalls 连接关闭时结束所有等待响应的调用
+func (c *Connection) failCalls() {
+	c.calls.lock.Lock()
+	pending := c.calls.pending
+	c.calls.pending = nil
+	c.calls.closed = true
+	c.calls.lock.Unlock()
+
+	for _, f := range pending {
+		f.finish(nil, ErrCallConnClosed)
+	}
+}
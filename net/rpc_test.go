@@ -0,0 +1,156 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// callMsg 请求或者响应
+type callMsg struct {
+	id    uint64
+	reply bool
+	body  string
+}
+
+// callProto 报文体为 ID(8) + 是否响应(1) + 内容
+type callProto struct {
+	benchProto
+}
+
+func (p *callProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	if len(body) < 9 {
+		return nil, fmt.Errorf("short call message")
+	}
+	return &callMsg{id: binary.BigEndian.Uint64(body), reply: body[8] == 1, body: string(body[9:])}, nil
+}
+
+func (p *callProto) Serialize(data interface{}) ([]byte, error) {
+	msg, ok := data.(*callMsg)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	body := binary.BigEndian.AppendUint64(nil, msg.id)
+	if msg.reply {
+		body = append(body, 1)
+	} else {
+		body = append(body, 0)
+	}
+	return p.benchProto.Serialize(append(body, msg.body...))
+}
+
+func (p *callProto) SetCallID(req interface{}, id uint64) (interface{}, error) {
+	return &callMsg{id: id, body: req.(string)}, nil
+}
+
+func (p *callProto) ReplyOf(data interface{}) (uint64, bool) {
+	msg := data.(*callMsg)
+	return msg.id, msg.reply
+}
+
+// echoHandler 回应内容不是"silent"的请求
+type echoHandler struct {
+	BaseHandler
+}
+
+func (echoHandler) OnData(conn *Connection, data interface{}) {
+	if msg := data.(*callMsg); msg.body != "silent" {
+		conn.Net().SendData(conn, &callMsg{id: msg.id, reply: true, body: "re: " + msg.body})
+	}
+}
+
+func rpcConn(t *testing.T) (*SimpleNet, *Connection) {
+	n := newTestNet(t)
+	l, err := n.Listen("127.0.0.1:0", &callProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetHandler(echoHandler{}, 1)
+	conn, err := n.Connect(l.LocalAddress(), &callProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, conn
+}
+
+func TestCall(t *testing.T) {
+	n, conn := rpcConn(t)
+	reply, err := n.Call(context.Background(), conn, "hello")
+	if err != nil || reply.(*callMsg).body != "re: hello" {
+		t.Fatalf("reply = %v, err = %v", reply, err)
+	}
+
+	done := make(chan string, 1)
+	err = n.CallFunc(conn, "func", time.Second, func(reply interface{}, err error) {
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		done <- reply.(*callMsg).body
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-done:
+		if got != "re: func" {
+			t.Fatalf("callback got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for callback")
+	}
+}
+
+func TestCallCancel(t *testing.T) {
+	n, conn := rpcConn(t)
+
+	f, err := n.CallAsync(conn, "silent", 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-f.Done()
+	if _, err := f.Result(); !errors.Is(err, ErrCallTimeout) {
+		t.Fatalf("timeout err = %v", err)
+	}
+
+	f, err = n.CallAsync(conn, "silent", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Cancel()
+	if _, err := f.Result(); !errors.Is(err, ErrCallCanceled) {
+		t.Fatalf("cancel err = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := n.Call(ctx, conn, "silent"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ctx err = %v", err)
+	}
+	if conn.PendingCalls() != 0 {
+		t.Fatalf("pending calls = %d", conn.PendingCalls())
+	}
+}
+
+func TestCallConnBroken(t *testing.T) {
+	n, conn := rpcConn(t)
+	f, err := n.CallAsync(conn, "silent", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.CloseConn(conn)
+	select {
+	case <-f.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not failed after close")
+	}
+	if _, err := f.Result(); !errors.Is(err, ErrCallConnClosed) {
+		t.Fatalf("broken err = %v", err)
+	}
+	if _, err := n.CallAsync(conn, "late", 0); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("call after close err = %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+var (
+	// ErrSessionRejected 认证失败
+	ErrSessionRejected = errors.New("session rejected")
+	// ErrAuthTimeout 连接建立后没有在SessionManager.Timeout内完成认证
+	ErrAuthTimeout = errors.New("auth timeout")
+)
+
+// Credentials 认证凭证，用户名密码或者token
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// Session 认证后的会话
+type Session struct {
+	ID       string
+	Identity string      // 认证后的身份，如用户名
+	Expire   time.Time   // 过期时间，零值不过期
+	Data     interface{} // 应用自定义数据
+}
+
+// Expired 会话是否已经过期
+func (s *Session) Expired() bool {
+	return !s.Expire.IsZero() && !time.Now().Before(s.Expire)
+}
+
+// AuthResult 认证结果，Session为nil时Reason为拒绝的原因
+type AuthResult struct {
+	Session *Session
+	Reason  string
+}
+
+// SessionProto proto的可选扩展，用于会话认证，认证请求和结果不作为EventNewConnectionData发出
+type SessionProto interface {
+	// AuthMsg 构造认证请求
+	AuthMsg(cred *Credentials) interface{}
+	// AuthOf 收到的报文是认证请求时返回凭证
+	AuthOf(data interface{}) (*Credentials, bool)
+	// AuthResultMsg 构造认证结果
+	AuthResultMsg(result *AuthResult) interface{}
+	// AuthResultOf 收到的报文是认证结果时返回结果
+	AuthResultOf(data interface{}) (*AuthResult, bool)
+}
+
+// SessionManager 接受的连接的会话管理，proto需要实现SessionProto。
+// 会话建立前(或者过期后)收到的其他报文被丢弃，心跳和确认除外。
+// 认证成功发出EventSessionEstablished(Data为*Session)，
+// 失败发出EventSessionRejected(Data为error)，回复拒绝原因后关闭连接
+type SessionManager struct {
+	// Authenticate 验证凭证，在连接的读协程中调用，返回error拒绝
+	Authenticate func(conn *Connection, cred *Credentials) (*Session, error)
+	// Timeout 连接建立后等待认证的时间，超时拒绝，<=0不限制
+	Timeout time.Duration
+}
+
+// sessionState 连接的会话状态，服务端和客户端共用
+type sessionState struct {
+	session atomic.Pointer[Session]
+
+	lock     sync.Mutex
+	timer    *time.Timer
+	rejected bool             // 已经拒绝，不再接受认证
+	closed   bool             // 连接已经关闭
+	login    chan *AuthResult // 客户端等待认证结果
+}
+
+// SetSessionManager 设置接受的连接的会话管理，对之后建立的连接生效，nil取消
+func (n *SimpleNet) SetSessionManager(m *SessionManager) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	n.sessionMgr.Store(m)
+	return nil
+}
+
+// SetSessionManager 设置监听下连接的会话管理，优先于SimpleNet的设置，nil取消
+func (l *Listener) SetSessionManager(m *SessionManager) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	l.sessionMgr.Store(m)
+	return nil
+}
+
+func (m *SessionManager) check() error {
+	if m != nil && m.Authenticate == nil {
+		return fmt.Errorf("session manager without Authenticate")
+	}
+	return nil
+}
+
+// sessionManager 只有接受的连接使用会话管理
+func (c *Connection) sessionManager() *SessionManager {
+	if c.listen == nil {
+		return nil
+	}
+	if m := c.listen.sessionMgr.Load(); m != nil {
+		return m
+	}
+	return c.net.sessionMgr.Load()
+}
+
+// Session 连接的会话，没有认证或者已经过期时返回nil
+func (c *Connection) Session() *Session {
+	s := c.session.session.Load()
+	if s == nil || s.Expired() {
+		return nil
+	}
+	return s
+}
+
+// Login 向对端发送认证请求并等待结果，成功时返回会话并设置为conn的Session，
+// 被拒绝时返回ErrSessionRejected
+func (n *SimpleNet) Login(ctx context.Context, conn *Connection, cred *Credentials) (*Session, error) {
+	sp, ok := protoAs[SessionProto](conn.proto)
+	if !ok {
+		return nil, fmt.Errorf("proto not support session")
+	}
+	ch := make(chan *AuthResult, 1)
+	s := &conn.session
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil, ErrConnClosed
+	}
+	if s.login != nil {
+		s.lock.Unlock()
+		return nil, fmt.Errorf("login in progress")
+	}
+	s.login = ch
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		if s.login == ch {
+			s.login = nil
+		}
+		s.lock.Unlock()
+	}()
+
+	if err := n.SendData(conn, sp.AuthMsg(cred)); err != nil {
+		return nil, err
+	}
+	select {
+	case result, ok := <-ch:
+		if !ok {
+			return nil, ErrConnClosed
+		}
+		if result.Session == nil {
+			return nil, fmt.Errorf("%w, reason = %s", ErrSessionRejected, result.Reason)
+		}
+		return result.Session, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// watchSession 接受的连接开始等待认证
+func (n *SimpleNet) watchSession(conn *Connection) {
+	m := conn.sessionManager()
+	if m == nil || m.Timeout <= 0 {
+		return
+	}
+	s := &conn.session
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.timer = time.AfterFunc(m.Timeout, func() {
+		if conn.session.session.Load() == nil {
+			n.rejectSession(conn, ErrAuthTimeout)
+		}
+	})
+}
+
+// handleSession 处理认证请求和结果，会话建立前丢弃其他报文，返回true表示data已经处理
+func (c *Connection) handleSession(data interface{}) bool {
+	if sp, ok := protoAs[SessionProto](c.proto); ok {
+		if cred, ok := sp.AuthOf(data); ok {
+			c.net.authenticate(c, cred)
+			return true
+		}
+		if result, ok := sp.AuthResultOf(data); ok {
+			c.net.loginResult(c, result)
+			return true
+		}
+	}
+	if c.sessionManager() == nil || c.Session() != nil {
+		return false
+	}
+	c.net.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("drop data without session, remoteAddr = %s\n", c.remoteAddr))
+	return true
+}
+
+// authenticate 服务端验证凭证，重复认证时替换原来的会话
+func (n *SimpleNet) authenticate(conn *Connection, cred *Credentials) {
+	m := conn.sessionManager()
+	if m == nil {
+		n.rejectSession(conn, fmt.Errorf("session not supported"))
+		return
+	}
+	sess, err := m.Authenticate(conn, cred)
+	if err == nil && sess == nil {
+		err = fmt.Errorf("no session")
+	}
+	if err != nil {
+		n.rejectSession(conn, err)
+		return
+	}
+
+	s := &conn.session
+	s.lock.Lock()
+	if s.rejected || s.closed {
+		s.lock.Unlock()
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	old := s.session.Swap(sess)
+	n.bindUser(conn, old, sess)
+	s.lock.Unlock()
+
+	if sp, ok := protoAs[SessionProto](conn.proto); ok {
+		n.SendData(conn, sp.AuthResultMsg(&AuthResult{Session: sess}))
+	}
+	n.logMsg(mylog.LevelInformational,
+		fmt.Sprintf("session established, identity = %s, remoteAddr = %s\n", sess.Identity, conn.remoteAddr))
+	n.emit(&ConnEvent{
+		EventType: EventSessionEstablished,
+		Conn:      conn,
+		Data:      sess,
+	})
+}
+
+// rejectSession 拒绝认证，回复原因后关闭连接
+func (n *SimpleNet) rejectSession(conn *Connection, err error) {
+	s := &conn.session
+	s.lock.Lock()
+	if s.rejected || s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.rejected = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.session.Store(nil)
+	s.lock.Unlock()
+	n.users.leaveAll(conn)
+
+	n.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("session rejected, remoteAddr = %s, err = %s\n", conn.remoteAddr, err))
+	n.emit(&ConnEvent{
+		EventType: EventSessionRejected,
+		Conn:      conn,
+		Data:      fmt.Errorf("%w, err = %w", ErrSessionRejected, err),
+	})
+	sp, ok := protoAs[SessionProto](conn.proto)
+	if !ok {
+		n.CloseConn(conn)
+		return
+	}
+	err = n.SendDataFunc(conn, sp.AuthResultMsg(&AuthResult{Reason: err.Error()}), func(err error) {
+		n.CloseConn(conn)
+	})
+	if err != nil {
+		n.CloseConn(conn)
+	}
+}
+
+// loginResult 客户端收到认证结果
+func (n *SimpleNet) loginResult(conn *Connection, result *AuthResult) {
+	s := &conn.session
+	s.lock.Lock()
+	ch := s.login
+	s.login = nil
+	s.session.Store(result.Session)
+	s.lock.Unlock()
+
+	if result.Session != nil {
+		n.emit(&ConnEvent{
+			EventType: EventSessionEstablished,
+			Conn:      conn,
+			Data:      result.Session,
+		})
+	} else {
+		n.emit(&ConnEvent{
+			EventType: EventSessionRejected,
+			Conn:      conn,
+			Data:      fmt.Errorf("%w, reason = %s", ErrSessionRejected, result.Reason),
+		})
+	}
+	if ch != nil {
+		ch <- result
+	}
+}
+
+// closeSession 连接关闭时停止认证超时，等待中的Login返回ErrConnClosed
+func (c *Connection) closeSession() {
+	s := &c.session
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.login != nil {
+		close(s.login)
+		s.login = nil
+	}
+}
@@ -0,0 +1,93 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown 优雅关闭: 停止accept和自动重连，等待所有连接的发送队列写完后关闭连接，
+// 再等待读写goroutine退出。ctx到期时强制关闭并返回ctx的错误。
+// 和SimpleNetDestroy不同，事件队列不关闭，关闭过程中的事件仍然可以PollEvent取出，
+// 读goroutine发事件时队列满会阻塞，所以关闭期间需要继续消费事件
+func (n *SimpleNet) Shutdown(ctx context.Context) error {
+	if !n.shutting.CompareAndSwap(false, true) {
+		return fmt.Errorf("shutdown already in progress")
+	}
+	n.logMsg(mylog.LevelInformational, fmt.Sprintf("shutdown, draining connections\n"))
+
+	n.lockServer.Lock()
+	listens := append([]*Listener(nil), n.connServer...)
+	n.lockServer.Unlock()
+	for _, l := range listens {
+		l.stopAccept()
+	}
+
+	err := n.drain(ctx)
+
+	for _, conn := range n.allConns() {
+		n.CloseConn(conn)
+	}
+	for _, l := range listens {
+		n.CloseListen(l)
+	}
+
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			n.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		n.logMsg(mylog.LevelWarning, fmt.Sprintf("shutdown not drained, err = %s\n", err))
+	}
+
+	n.destroy.Store(true)
+	n.cancel()
+	return err
+}
+
+// drain 等待所有连接的发送队列写完，连接断开的不再等待
+func (n *SimpleNet) drain(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		drained := true
+		for _, conn := range n.allConns() {
+			if conn.Status() == StatusConnected && conn.Queued() > 0 {
+				drained = false
+				break
+			}
+		}
+		if drained {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// stopAccept 关闭所有监听地址，已有的连接不受影响
+func (l *Listener) stopAccept() {
+	l.lockListen.Lock()
+	defer l.lockListen.Unlock()
+
+	for _, v := range l.listens {
+		v.listen.Close()
+	}
+	l.listens = nil
+}
@@ -0,0 +1,128 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// ConnOptions socket选项，零值表示使用系统(或Go)的默认值。
+// NoDelay和KeepAlive只对TCP有效，缓冲区大小对TCP/UDP/unix socket有效
+type ConnOptions struct {
+	NoDelay *bool // TCP_NODELAY，Go默认开启
+
+	KeepAlive         time.Duration // 开始探测前的空闲时间，<0关闭keepalive
+	KeepAliveInterval time.Duration // 探测间隔
+	KeepAliveCount    int           // 探测次数
+
+	ReadBuffer  int // SO_RCVBUF
+	WriteBuffer int // SO_SNDBUF
+}
+
+// ListenOptions 监听的选项，ConnOptions应用到接受的连接
+type ListenOptions struct {
+	ConnOptions
+
+	// ReusePort >0时设置SO_REUSEPORT并在同一地址打开ReusePort个socket，
+	// 每个socket独立accept，由内核分配新连接，只在ListenWithOptions时有效
+	ReusePort int
+
+	// ProxyProtocol 接受的连接先解析PROXY protocol header(ProxyProtocolXXX)，
+	// ProxyTrusted为允许发送header的地址，开启时不能为空，见ProxyListener
+	ProxyProtocol int
+	ProxyTrusted  []string
+}
+
+// errNoSocket 连接不是socket，如UDP监听的虚拟连接
+var errNoSocket = errors.New("connection has no socket")
+
+// SetConnOptions 设置新连接的socket选项，应用到Connect的连接，
+// 以及没有设置ListenOptions的监听接受的连接，nil清除
+func (n *SimpleNet) SetConnOptions(opts *ConnOptions) {
+	n.connOptions.Store(opts)
+}
+
+// SetListenOptions 设置监听的选项，只对之后接受的连接有效，nil清除
+func (l *Listener) SetListenOptions(opts *ListenOptions) {
+	l.listenOptions.Store(opts)
+}
+
+// SetOptions 修改已有连接的socket选项
+func (c *Connection) SetOptions(opts *ConnOptions) error {
+	return opts.apply(c.conn)
+}
+
+// socketOf 找到底层的socket，TLS、WebSocket等包装的连接通过NetConn取得
+func socketOf(conn net.Conn) net.Conn {
+	for {
+		switch conn.(type) {
+		case *net.TCPConn, *net.UDPConn, *net.UnixConn:
+			return conn
+		}
+		inner, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = inner.NetConn()
+	}
+}
+
+func (o *ConnOptions) apply(conn net.Conn) error {
+	sock := socketOf(conn)
+	if sock == nil {
+		return errNoSocket
+	}
+	if tcp, ok := sock.(*net.TCPConn); ok {
+		if o.NoDelay != nil {
+			if err := tcp.SetNoDelay(*o.NoDelay); err != nil {
+				return err
+			}
+		}
+		if o.KeepAlive < 0 {
+			if err := tcp.SetKeepAlive(false); err != nil {
+				return err
+			}
+		} else if o.KeepAlive > 0 || o.KeepAliveInterval > 0 || o.KeepAliveCount > 0 {
+			err := tcp.SetKeepAliveConfig(net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     o.KeepAlive,
+				Interval: o.KeepAliveInterval,
+				Count:    o.KeepAliveCount,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if o.ReadBuffer > 0 {
+		if err := sock.(interface{ SetReadBuffer(int) error }).SetReadBuffer(o.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := sock.(interface{ SetWriteBuffer(int) error }).SetWriteBuffer(o.WriteBuffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOptions 新连接应用socket选项，失败只记录日志
+func (n *SimpleNet) applyOptions(l *Listener, conn net.Conn) {
+	opts := n.connOptions.Load()
+	if l != nil {
+		if lopts := l.listenOptions.Load(); lopts != nil {
+			opts = &lopts.ConnOptions
+		}
+	}
+	if opts == nil {
+		return
+	}
+	if err := opts.apply(conn); err != nil && err != errNoSocket {
+		n.logMsg(mylog.LevelWarning,
+			fmt.Sprintf("set socket options failed, err = %s\n", err))
+	}
+}
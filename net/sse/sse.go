@@ -0,0 +1,334 @@
+package sse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+const (
+	defKeepAlive = time.Second * 15
+	defTimeout   = time.Second * 10
+	defQueue     = 1024
+)
+
+// Listener 包装net.Listener，完成SSE的HTTP握手后由Accept返回*Conn
+//
+// 只接受GET请求，握手完成后服务端只发送事件，客户端发送的数据被丢弃
+type Listener struct {
+	inner net.Listener
+
+	// Path 非空时只接受该路径
+	Path string
+	// KeepAlive 空闲时发送注释行保持连接，<=0不发送
+	KeepAlive time.Duration
+	// Retry 非0时握手后发送retry字段，单位毫秒
+	Retry int
+	// Header 额外的响应头，如Access-Control-Allow-Origin
+	Header http.Header
+	// Timeout 读取请求头的超时时间
+	Timeout time.Duration
+
+	conns chan net.Conn
+
+	once   sync.Once
+	closed chan struct{}
+	err    error
+}
+
+// NewListener 包装inner
+func NewListener(inner net.Listener) *Listener {
+	l := &Listener{
+		inner:     inner,
+		KeepAlive: defKeepAlive,
+		Timeout:   defTimeout,
+		conns:     make(chan net.Conn, defQueue),
+		closed:    make(chan struct{}),
+	}
+	go l.serve()
+	return l
+}
+
+// Listen 在addr上监听SSE
+func Listen(n *mynet.SimpleNet, addr string) (*mynet.Listener, error) {
+	inner, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachListener(NewListener(inner), &Proto{})
+}
+
+func (l *Listener) serve() {
+	for {
+		conn, err := l.inner.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			l.err = err
+			l.Close()
+			return
+		}
+		go l.handshake(conn)
+	}
+}
+
+func (l *Listener) reject(conn net.Conn, status int) {
+	fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
+		status, http.StatusText(status))
+	conn.Close()
+}
+
+func (l *Listener) handshake(conn net.Conn) {
+	if l.Timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(l.Timeout))
+	}
+	r := bufio.NewReader(conn)
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if req.Method != http.MethodGet {
+		l.reject(conn, http.StatusMethodNotAllowed)
+		return
+	}
+	if l.Path != "" && req.URL.Path != l.Path {
+		l.reject(conn, http.StatusNotFound)
+		return
+	}
+
+	lastID := req.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = req.URL.Query().Get("lastEventId")
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 200 OK\r\n")
+	b.WriteString("Content-Type: text/event-stream\r\n")
+	b.WriteString("Cache-Control: no-cache\r\n")
+	b.WriteString("Connection: keep-alive\r\n")
+	for k, vs := range l.Header {
+		for _, v := range vs {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	if l.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n\n", l.Retry)
+	}
+	if _, err = io.WriteString(conn, b.String()); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &Conn{
+		Conn:        conn,
+		r:           r,
+		req:         req,
+		lastEventID: lastID,
+		done:        make(chan struct{}),
+	}
+	if l.KeepAlive > 0 {
+		go c.keepAlive(l.KeepAlive)
+	}
+
+	select {
+	case l.conns <- c:
+	case <-l.closed:
+		c.Close()
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.closed)
+		err = l.inner.Close()
+	})
+	return err
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.inner.Addr()
+}
+
+// Conn 完成握手的SSE连接
+type Conn struct {
+	net.Conn
+	r   *bufio.Reader
+	req *http.Request
+
+	lastEventID string
+
+	wmutex    sync.Mutex
+	lastWrite time.Time
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Request 握手的HTTP请求
+func (c *Conn) Request() *http.Request {
+	return c.req
+}
+
+// LastEventID 客户端重连时带上的Last-Event-ID
+func (c *Conn) LastEventID() string {
+	return c.lastEventID
+}
+
+// Read 丢弃客户端发送的数据，直到连接关闭
+func (c *Conn) Read(p []byte) (int, error) {
+	buf := make([]byte, 512)
+	for {
+		if _, err := c.r.Read(buf); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+
+	c.lastWrite = time.Now()
+	return c.Conn.Write(p)
+}
+
+func (c *Conn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	return c.Conn.Close()
+}
+
+func (c *Conn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.wmutex.Lock()
+			if now.Sub(c.lastWrite) >= interval {
+				c.lastWrite = now
+				_, err := io.WriteString(c.Conn, ": keepalive\n\n")
+				if err != nil {
+					c.wmutex.Unlock()
+					return
+				}
+			}
+			c.wmutex.Unlock()
+		}
+	}
+}
+
+// Event 一个SSE事件
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry 非0时通知客户端重连间隔，单位毫秒
+	Retry int
+}
+
+// Proto SSE的IProto实现，SendData接受*Event, Event, string或[]byte(作为data)
+type Proto struct {
+}
+
+func (p *Proto) FilterAccept(conn *mynet.Connection) bool {
+	return true
+}
+
+// HeadLen 客户端不会发送数据，读取的数据在Conn.Read中已经丢弃
+func (p *Proto) HeadLen() uint32 {
+	return 1
+}
+
+func (p *Proto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, nil
+}
+
+func (p *Proto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return nil, fmt.Errorf("sse is server push only")
+}
+
+func (p *Proto) Serialize(data interface{}) ([]byte, error) {
+	var evt *Event
+	switch v := data.(type) {
+	case *Event:
+		evt = v
+	case Event:
+		evt = &v
+	case string:
+		evt = &Event{Data: v}
+	case []byte:
+		evt = &Event{Data: string(v)}
+	default:
+		return nil, fmt.Errorf("unexpect data type %T", data)
+	}
+	return Format(evt)
+}
+
+// Format 格式化事件，多行data拆成多个data字段
+func Format(evt *Event) ([]byte, error) {
+	if strings.ContainsAny(evt.ID, "\r\n") || strings.ContainsAny(evt.Event, "\r\n") {
+		return nil, fmt.Errorf("id or event contains newline")
+	}
+	var b strings.Builder
+	if evt.ID != "" {
+		b.WriteString("id: ")
+		b.WriteString(evt.ID)
+		b.WriteByte('\n')
+	}
+	if evt.Event != "" {
+		b.WriteString("event: ")
+		b.WriteString(evt.Event)
+		b.WriteByte('\n')
+	}
+	if evt.Retry > 0 {
+		b.WriteString("retry: ")
+		b.WriteString(strconv.Itoa(evt.Retry))
+		b.WriteByte('\n')
+	}
+	data := strings.ReplaceAll(evt.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return []byte(b.String()), nil
+}
+
+// LastEventID 连接的Last-Event-ID，非SSE连接返回空
+func LastEventID(conn *mynet.Connection) string {
+	if c, ok := conn.NetConn().(*Conn); ok {
+		return c.LastEventID()
+	}
+	return ""
+}
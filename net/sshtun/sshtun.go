@@ -0,0 +1,160 @@
+// Package sshtun 通过ssh连接访问远端服务，ssh的channel作为SimpleNet的连接，
+// 可以访问远端主机上只监听localhost的服务
+package sshtun
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	mynet "github.com/buf1024/golib/net"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Config ssh连接配置
+type Config struct {
+	User string
+	Auth []ssh.AuthMethod
+	// HostKeyCallback 为空时使用KnownHosts，KnownHosts也为空时报错，
+	// 需要不校验时显式设置ssh.InsecureIgnoreHostKey()
+	HostKeyCallback ssh.HostKeyCallback
+	KnownHosts      string
+	Timeout         time.Duration
+	// KeepAlive 大于0时定时发送keepalive请求，失败时关闭ssh连接
+	KeepAlive time.Duration
+}
+
+// Tunnel ssh隧道
+type Tunnel struct {
+	client *ssh.Client
+	done   chan struct{}
+}
+
+// Password 密码认证
+func Password(password string) ssh.AuthMethod {
+	return ssh.Password(password)
+}
+
+// PrivateKey 私钥认证，passphrase为空表示私钥未加密
+func PrivateKey(pem []byte, passphrase string) (ssh.AuthMethod, error) {
+	var (
+		signer ssh.Signer
+		err    error
+	)
+	if passphrase == "" {
+		signer, err = ssh.ParsePrivateKey(pem)
+	} else {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// PrivateKeyFile 从文件读取私钥
+func PrivateKeyFile(path string, passphrase string) (ssh.AuthMethod, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return PrivateKey(pem, passphrase)
+}
+
+// Dial 建立ssh连接，addr为ssh服务器地址
+func Dial(addr string, conf *Config) (*Tunnel, error) {
+	hostKey := conf.HostKeyCallback
+	if hostKey == nil {
+		if conf.KnownHosts == "" {
+			return nil, fmt.Errorf("no host key callback")
+		}
+		cb, err := knownhosts.New(conf.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		hostKey = cb
+	}
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            conf.User,
+		Auth:            conf.Auth,
+		HostKeyCallback: hostKey,
+		Timeout:         conf.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewTunnel(client, conf.KeepAlive), nil
+}
+
+// NewTunnel 使用已建立的ssh连接
+func NewTunnel(client *ssh.Client, keepAlive time.Duration) *Tunnel {
+	t := &Tunnel{
+		client: client,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		client.Wait()
+		close(t.done)
+	}()
+	if keepAlive > 0 {
+		go t.keepAlive(keepAlive)
+	}
+	return t
+}
+
+func (t *Tunnel) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			_, _, err := t.client.SendRequest("keepalive@openssh.com", true, nil)
+			if err != nil {
+				t.client.Close()
+				return
+			}
+		}
+	}
+}
+
+// Client 底层ssh连接
+func (t *Tunnel) Client() *ssh.Client {
+	return t.client
+}
+
+// Done ssh连接断开后关闭
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// Dial 通过ssh服务器连接addr，addr相对于ssh服务器解析
+func (t *Tunnel) Dial(addr string) (net.Conn, error) {
+	return t.client.Dial("tcp", addr)
+}
+
+// Connect 通过ssh服务器连接addr，channel作为SimpleNet的连接
+func (t *Tunnel) Connect(n *mynet.SimpleNet, addr string, proto mynet.IProto) (*mynet.Connection, error) {
+	conn, err := t.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachConn(conn, proto)
+}
+
+// Listen 在ssh服务器上监听addr(远程转发)，新的channel作为SimpleNet的连接
+func (t *Tunnel) Listen(n *mynet.SimpleNet, addr string, proto mynet.IProto) (*mynet.Listener, error) {
+	listen, err := t.client.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachListener(listen, proto)
+}
+
+// Close 关闭ssh连接，其上的channel都会断开
+func (t *Tunnel) Close() error {
+	return t.client.Close()
+}
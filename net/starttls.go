@@ -0,0 +1,116 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// upgradeTimeout UpgradeTLS等待读写暂停和握手的超时
+const upgradeTimeout = defHandshakeTimeout
+
+// upgradeState 升级期间读协程暂停，paused由读协程关闭，done由升级方关闭
+type upgradeState struct {
+	pauseOnce sync.Once
+	paused    chan struct{}
+	done      chan struct{}
+}
+
+// UpgradeTLS 在已有连接上升级为TLS(STARTTLS)，监听接受的连接作为服务端，否则作为客户端。
+// 先暂停读协程，reply不为nil时再发送reply(如"220 Ready to start TLS")，
+// 保证对端收到reply后发来的握手数据不会被当作明文读取；等待发送队列写完后握手，
+// 成功后读写在加密的流上继续。应在处理完整报文(如STARTTLS命令)时调用，
+// 读协程正在读取的不完整报文被丢弃。握手失败时关闭连接
+func (c *Connection) UpgradeTLS(config *tls.Config, reply interface{}) error {
+	if c.Status() != StatusConnected {
+		return ErrConnClosed
+	}
+	if _, ok := c.conn.(*tls.Conn); ok {
+		return fmt.Errorf("connection already tls")
+	}
+	u := &upgradeState{paused: make(chan struct{}), done: make(chan struct{})}
+	if !c.upgrade.CompareAndSwap(nil, u) {
+		return fmt.Errorf("tls upgrade in progress")
+	}
+	c.wakeReader()
+	defer func() {
+		c.upgrade.Store(nil)
+		close(u.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(c.ctx, upgradeTimeout)
+	defer cancel()
+
+	// 打断正在进行的读
+	c.conn.SetReadDeadline(time.Unix(1, 0))
+	select {
+	case <-u.paused:
+	case <-ctx.Done():
+		return c.upgradeErr(ctx)
+	}
+	c.conn.SetReadDeadline(time.Time{})
+
+	if reply != nil {
+		if err := c.net.SendData(c, reply); err != nil {
+			return err
+		}
+	}
+	// 占用写协程的标志，写协程在队列写完后退出，之后的数据在升级后发送
+	for !c.writing.CompareAndSwap(false, true) {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			return c.upgradeErr(ctx)
+		}
+	}
+	defer func() {
+		c.writing.Store(false)
+		if len(c.msgChan) > 0 {
+			c.kickWrite()
+		}
+	}()
+
+	var conn *tls.Conn
+	if c.listen != nil {
+		conn = tls.Server(c.conn, config)
+	} else {
+		conn = tls.Client(c.conn, config)
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		c.net.closeConn(c)
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *Connection) upgradeErr(ctx context.Context) error {
+	if c.ctx.Err() != nil {
+		return ErrConnClosed
+	}
+	return ctx.Err()
+}
+
+// upgradeInterrupted 读被UpgradeTLS打断
+func (c *Connection) upgradeInterrupted(err error) bool {
+	return c.upgrade.Load() != nil && errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// waitUpgrade 读协程暂停直到升级结束，连接关闭返回false
+func (c *Connection) waitUpgrade() bool {
+	u := c.upgrade.Load()
+	if u == nil {
+		return true
+	}
+	u.pauseOnce.Do(func() { close(u.paused) })
+	select {
+	case <-u.done:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,84 @@
+package net
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StateChange 连接状态变化，EventStateChanged事件的Data
+type StateChange struct {
+	Old  int64
+	New  int64
+	Time time.Time
+}
+
+// StateFunc 状态变化回调，在发生变化的协程中同步调用，不能阻塞
+type StateFunc func(conn *Connection, change StateChange)
+
+// OnStateChange 注册连接的状态变化回调
+func (c *Connection) OnStateChange(cb StateFunc) {
+	c.lockState.Lock()
+	defer c.lockState.Unlock()
+
+	c.stateCbs = append(c.stateCbs, cb)
+}
+
+// StateTime 最近一次状态变化的时间
+func (c *Connection) StateTime() time.Time {
+	c.lockState.Lock()
+	defer c.lockState.Unlock()
+
+	return c.stateAt
+}
+
+// OnStateChange 注册所有连接的状态变化回调，
+// 连接建立(StatusNone到StatusConnected)时也会回调
+func (n *SimpleNet) OnStateChange(cb StateFunc) {
+	n.lockState.Lock()
+	defer n.lockState.Unlock()
+
+	n.stateCbs = append(n.stateCbs, cb)
+}
+
+// SetStateEvents 开启后状态变化同时以EventStateChanged事件发出
+func (n *SimpleNet) SetStateEvents(enable bool) {
+	n.stateEvents.Store(enable)
+}
+
+// transition 状态从from变为to，状态不是from时返回false
+func (c *Connection) transition(from, to int64) bool {
+	return atomic.CompareAndSwapInt64(&c.status, from, to)
+}
+
+// notifyState 通知状态变化，在变化相关的清理完成后调用
+func (c *Connection) notifyState(old, new int64) {
+	change := StateChange{
+		Old:  old,
+		New:  new,
+		Time: time.Now(),
+	}
+
+	c.lockState.Lock()
+	c.stateAt = change.Time
+	cbs := c.stateCbs
+	c.lockState.Unlock()
+
+	n := c.net
+	n.lockState.Lock()
+	netCbs := n.stateCbs
+	n.lockState.Unlock()
+
+	for _, cb := range netCbs {
+		cb(c, change)
+	}
+	for _, cb := range cbs {
+		cb(c, change)
+	}
+	if n.stateEvents.Load() {
+		n.emit(&ConnEvent{
+			EventType: EventStateChanged,
+			Conn:      c,
+			Data:      change,
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package net
+
+import "github.com/buf1024/golib/metrics"
+
+// 拒绝连接的原因
+const (
+	RejectFilter = "filter"
+	RejectLimit  = "limit"
+	RejectDeny   = "deny"
+	RejectPeer   = "peer"
+)
+
+type listenerStats struct {
+	accepted          metrics.Counter
+	acceptErrors      metrics.Counter
+	handshakeFailures metrics.Counter
+	rejectFilter      metrics.Counter
+	rejectLimit       metrics.Counter
+	rejectDeny        metrics.Counter
+	rejectPeer        metrics.Counter
+	conns             metrics.Gauge
+	pending           metrics.Gauge
+	latency           *metrics.Histogram
+}
+
+func (s *listenerStats) init() {
+	s.latency = metrics.NewHistogram(nil)
+}
+
+func (s *listenerStats) reject(reason string) {
+	switch reason {
+	case RejectLimit:
+		s.rejectLimit.Inc()
+	case RejectDeny:
+		s.rejectDeny.Inc()
+	case RejectPeer:
+		s.rejectPeer.Inc()
+	default:
+		s.rejectFilter.Inc()
+	}
+}
+
+// ListenerStats 监听的统计
+type ListenerStats struct {
+	Accepted          uint64 // 成功接受的连接
+	Rejected          uint64 // 被FilterAccept、限制、黑白名单或者PeerVerifier拒绝的连接
+	AcceptErrors      uint64 // Accept返回的错误
+	HandshakeFailures uint64 // 握手(如TLS)失败
+	Conns             int64  // 当前连接数
+	Pending           int64  // 已accept，还在握手或者FilterAccept中的连接数
+}
+
+// Stats 监听的统计
+func (l *Listener) Stats() ListenerStats {
+	return ListenerStats{
+		Accepted:          l.stats.accepted.Value(),
+		Rejected:          l.stats.rejectFilter.Value() + l.stats.rejectLimit.Value() + l.stats.rejectDeny.Value() + l.stats.rejectPeer.Value(),
+		AcceptErrors:      l.stats.acceptErrors.Value(),
+		HandshakeFailures: l.stats.handshakeFailures.Value(),
+		Conns:             int64(l.stats.conns.Value()),
+		Pending:           int64(l.stats.pending.Value()),
+	}
+}
+
+// RegisterMetrics 把监听的统计注册到reg，labels为空时使用listener=监听地址
+func (l *Listener) RegisterMetrics(reg *metrics.Registry, labels metrics.Labels) {
+	if len(labels) == 0 {
+		labels = metrics.Labels{"listener": l.LocalAddress()}
+	}
+	with := func(k, v string) metrics.Labels {
+		dup := metrics.Labels{k: v}
+		for lk, lv := range labels {
+			dup[lk] = lv
+		}
+		return dup
+	}
+	reg.Help("net_listener_accepted_total", "Connections accepted.")
+	reg.Help("net_listener_rejected_total", "Connections rejected by filter, limit, ip list or peer verifier.")
+	reg.Help("net_listener_accept_errors_total", "Errors returned by Accept.")
+	reg.Help("net_listener_handshake_failures_total", "Failed connection handshakes.")
+	reg.Help("net_listener_connections", "Current connections.")
+	reg.Help("net_listener_pending", "Connections in handshake or filter.")
+	reg.Help("net_listener_accept_seconds", "Time from accept to connection ready.")
+
+	reg.Register("net_listener_accepted_total", labels, &l.stats.accepted)
+	reg.Register("net_listener_rejected_total", with("reason", RejectFilter), &l.stats.rejectFilter)
+	reg.Register("net_listener_rejected_total", with("reason", RejectLimit), &l.stats.rejectLimit)
+	reg.Register("net_listener_rejected_total", with("reason", RejectDeny), &l.stats.rejectDeny)
+	reg.Register("net_listener_rejected_total", with("reason", RejectPeer), &l.stats.rejectPeer)
+	reg.Register("net_listener_accept_errors_total", labels, &l.stats.acceptErrors)
+	reg.Register("net_listener_handshake_failures_total", labels, &l.stats.handshakeFailures)
+	reg.Register("net_listener_connections", labels, &l.stats.conns)
+	reg.Register("net_listener_pending", labels, &l.stats.pending)
+	reg.Register("net_listener_accept_seconds", labels, l.stats.latency)
+}
@@ -0,0 +1,148 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	defStreamChunkSize = 64 * 1024
+	// streamWindow 发送流时最多在队列中的分片数
+	streamWindow = 4
+)
+
+// StreamChunk 流的一个分片
+type StreamChunk struct {
+	ID     uint64 // 流ID，连接内唯一
+	Offset int64  // 分片数据在流中的位置，结束分片为流的总长度
+	Size   int64  // 流的总长度，-1为未知
+	Data   []byte
+	End    bool // 流结束，结束分片没有数据
+	Abort  bool // 发送方读取失败，流不完整
+}
+
+// Streamer proto的可选扩展，实现后可以用SendStream发送流，
+// 收到的分片作为EventStreamChunk发出，结束分片作为EventStreamEnd发出，Data为*StreamChunk。
+// 分片的Data可能引用读缓冲区，处理完后调用ConnEvent.Release
+type Streamer interface {
+	// StreamMsg 构造分片报文
+	StreamMsg(chunk *StreamChunk) interface{}
+	// ChunkOf 收到的报文是分片时返回分片
+	ChunkOf(data interface{}) (*StreamChunk, bool)
+}
+
+// SetStreamChunkSize 设置SendStream的分片大小，<=0时为64K
+func (n *SimpleNet) SetStreamChunkSize(size int) {
+	n.streamChunk.Store(int64(size))
+}
+
+func (n *SimpleNet) streamChunkSize() int {
+	if size := n.streamChunk.Load(); size > 0 {
+		return int(size)
+	}
+	return defStreamChunkSize
+}
+
+// SendStream 分片发送r中的数据，size>=0时发送size字节，不足时中止流并返回io.ErrUnexpectedEOF，
+// size<0时读到io.EOF为止。队列中最多有streamWindow个分片，不会缓存整个流。
+// 返回流ID，返回时数据已经写入socket
+func (n *SimpleNet) SendStream(conn *Connection, r io.Reader, size int64) (uint64, error) {
+	st, ok := protoAs[Streamer](conn.proto)
+	if !ok {
+		return 0, fmt.Errorf("proto not support stream")
+	}
+	if size < 0 {
+		size = -1
+	} else {
+		r = io.LimitReader(r, size)
+	}
+	id := conn.streamID.Add(1)
+	s := &streamSender{
+		net:     n,
+		conn:    conn,
+		proto:   st,
+		results: make(chan error, streamWindow),
+	}
+
+	chunkSize := n.streamChunkSize()
+	var offset int64
+	for {
+		buf := make([]byte, chunkSize)
+		count, err := io.ReadFull(r, buf)
+		if count > 0 {
+			chunk := &StreamChunk{ID: id, Offset: offset, Size: size, Data: buf[:count]}
+			if err := s.send(chunk); err != nil {
+				return id, s.wait(err)
+			}
+			offset += int64(count)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			s.send(&StreamChunk{ID: id, Offset: offset, Size: size, End: true, Abort: true})
+			return id, s.wait(err)
+		}
+	}
+	if size >= 0 && offset != size {
+		s.send(&StreamChunk{ID: id, Offset: offset, Size: size, End: true, Abort: true})
+		return id, s.wait(io.ErrUnexpectedEOF)
+	}
+	if err := s.send(&StreamChunk{ID: id, Offset: offset, Size: size, End: true}); err != nil {
+		return id, s.wait(err)
+	}
+	return id, s.wait(nil)
+}
+
+// streamSender 限制队列中的分片数
+type streamSender struct {
+	net     *SimpleNet
+	conn    *Connection
+	proto   Streamer
+	results chan error
+	pending int
+}
+
+func (s *streamSender) send(chunk *StreamChunk) error {
+	if s.pending == streamWindow {
+		s.pending--
+		if err := <-s.results; err != nil {
+			return err
+		}
+	}
+	err := s.net.SendDataFunc(s.conn, s.proto.StreamMsg(chunk), func(err error) {
+		s.results <- err
+	})
+	if err != nil {
+		return err
+	}
+	s.pending++
+	return nil
+}
+
+// wait 等待队列中的分片写完，返回err或者第一个写失败的错误
+func (s *streamSender) wait(err error) error {
+	for ; s.pending > 0; s.pending-- {
+		if werr := <-s.results; werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// dataEvent 收到的报文是流分片时转换为EventStreamChunk或者EventStreamEnd
+func (c *Connection) dataEvent(data interface{}) (int, interface{}) {
+	st, ok := protoAs[Streamer](c.proto)
+	if !ok {
+		return EventNewConnectionData, data
+	}
+	chunk, ok := st.ChunkOf(data)
+	if !ok {
+		return EventNewConnectionData, data
+	}
+	if chunk.End {
+		return EventStreamEnd, chunk
+	}
+	return EventStreamChunk, chunk
+}
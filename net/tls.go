@@ -0,0 +1,102 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+const defHandshakeTimeout = 10 * time.Second
+
+// ListenTLS 监听TLS网络，握手在accept后异步进行，受SetHandshakeTimeout控制，
+// 之后通过AddAddress增加的地址也使用同样的config。
+// crypto/tls不支持PSK(TLS 1.2的PSK密码套件和TLS 1.3的外部PSK)，
+// 无法部署证书时可以用NewCryptProto以预共享密钥加密报文
+func (n *SimpleNet) ListenTLS(addr string, config *tls.Config, proto IProto) (*Listener, error) {
+	return n.listenWith(addr, func(addr string) (net.Listener, error) {
+		return tls.Listen("tcp", addr, config)
+	}, proto, nil)
+}
+
+// ConnectTLS 以TLS连接服务器，握手完成后才返回，
+// config没有设置ServerName时使用addr中的主机名
+func (n *SimpleNet) ConnectTLS(addr string, config *tls.Config, proto IProto) (*Connection, error) {
+	newconn, err := n.dialTLS(context.Background(), addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.AttachConn(newconn, proto)
+}
+
+// SetHandshakeTimeout 设置握手超时，接受的连接实现了Handshake() error(如*tls.Conn、PROXY协议的连接)时，
+// 在FilterAccept之前完成握手。timeout<=0时使用默认的10秒
+func (l *Listener) SetHandshakeTimeout(timeout time.Duration) {
+	l.handshakeTimeout.Store(int64(timeout))
+}
+
+type handshaker interface {
+	Handshake() error
+}
+
+func (l *Listener) handshake(conn net.Conn) error {
+	hs, ok := conn.(handshaker)
+	if !ok {
+		return nil
+	}
+	timeout := time.Duration(l.handshakeTimeout.Load())
+	if timeout <= 0 {
+		timeout = defHandshakeTimeout
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+	return hs.Handshake()
+}
+
+// TLSConnectionState 返回TLS连接状态，非TLS连接返回false
+func (c *Connection) TLSConnectionState() (tls.ConnectionState, bool) {
+	conn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return conn.ConnectionState(), true
+}
+
+// PeerVerifier 校验TLS对端，state中有对端发送的证书(PeerCertificates)和
+// 按config校验后的证书链(VerifiedChains)。返回的identity保存在连接中(Connection.Identity)，
+// 返回错误时拒绝连接
+type PeerVerifier func(conn *Connection, state *tls.ConnectionState) (identity interface{}, err error)
+
+// SetPeerVerifier 设置TLS连接的校验回调，在握手完成后、proto创建和FilterAccept之前调用，
+// 用于mTLS按客户端证书决定是否接受并记录身份。非TLS连接不调用，nil清除
+func (l *Listener) SetPeerVerifier(v PeerVerifier) {
+	if v == nil {
+		l.verifier.Store(nil)
+		return
+	}
+	l.verifier.Store(&v)
+}
+
+// Identity PeerVerifier返回的对端身份
+func (c *Connection) Identity() interface{} {
+	return c.identity
+}
+
+// verifyPeer 调用PeerVerifier，没有设置或者不是TLS连接时通过
+func (l *Listener) verifyPeer(conn *Connection) error {
+	v := l.verifier.Load()
+	if v == nil {
+		return nil
+	}
+	state, ok := conn.TLSConnectionState()
+	if !ok {
+		return nil
+	}
+	identity, err := (*v)(conn, &state)
+	if err != nil {
+		return err
+	}
+	conn.identity = identity
+	return nil
+}
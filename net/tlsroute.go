@@ -0,0 +1,124 @@
+package net
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type tlsRoute struct {
+	serverName string
+	protocol   string
+	factory    ProtoFactory
+}
+
+// matchName serverName为空匹配任意值，"*.example.com"匹配下一级的任意域名
+func (r *tlsRoute) matchName(name string) bool {
+	switch {
+	case r.serverName == "":
+		return true
+	case strings.HasPrefix(r.serverName, "*."):
+		suffix := r.serverName[1:]
+		return len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) &&
+			!strings.Contains(name[:len(name)-len(suffix)], ".")
+	}
+	return strings.EqualFold(r.serverName, name)
+}
+
+// TLSRouter 在一个TLS监听上按SNI和ALPN为连接选择proto，多个服务共用一个端口。
+// 按添加的顺序匹配，握手时没有可以匹配的路由则握手失败
+type TLSRouter struct {
+	lock   sync.RWMutex
+	routes []tlsRoute
+}
+
+// NewTLSRouter 创建TLSRouter
+func NewTLSRouter() *TLSRouter {
+	return &TLSRouter{}
+}
+
+// Handle 添加路由，serverName为空匹配任意SNI(包括没有SNI)，支持"*.example.com"，
+// protocol为ALPN协议名，为空匹配任意协议(包括没有协商ALPN)。可以在运行时添加
+func (r *TLSRouter) Handle(serverName, protocol string, proto IProto) {
+	r.HandleFactory(serverName, protocol, func(*Connection) IProto { return proto })
+}
+
+// HandleFactory 同Handle，连接使用factory创建的proto
+func (r *TLSRouter) HandleFactory(serverName, protocol string, factory ProtoFactory) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.routes = append(r.routes, tlsRoute{serverName: serverName, protocol: protocol, factory: factory})
+}
+
+// configFor 按ClientHello选择ALPN，SNI没有路由或者客户端的ALPN都不支持时拒绝握手
+func (r *TLSRouter) configFor(base *tls.Config, hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var protos []string
+	matched, wildcard := false, false
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.matchName(hello.ServerName) {
+			continue
+		}
+		if route.protocol == "" {
+			matched, wildcard = true, true
+			continue
+		}
+		if slices.Contains(hello.SupportedProtos, route.protocol) && !slices.Contains(protos, route.protocol) {
+			matched = true
+			protos = append(protos, route.protocol)
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("no route for server name %q, protocols %v", hello.ServerName, hello.SupportedProtos)
+	}
+	config := base.Clone()
+	config.GetConfigForClient = nil
+	config.NextProtos = protos
+	if len(protos) == 0 && wildcard {
+		// 只有匹配任意协议的路由，不协商ALPN
+		config.NextProtos = nil
+	}
+	return config, nil
+}
+
+// route 按握手的结果选择proto
+func (r *TLSRouter) route(conn *Connection) IProto {
+	state, ok := conn.TLSConnectionState()
+	if !ok {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for i := range r.routes {
+		route := &r.routes[i]
+		if route.matchName(state.ServerName) &&
+			(route.protocol == "" || route.protocol == state.NegotiatedProtocol) {
+			return route.factory(conn)
+		}
+	}
+	return nil
+}
+
+// ListenTLSRouter 监听TLS网络，连接按router选择proto，
+// config中配置所有服务的证书(按SNI自动选择)，config的GetConfigForClient被router替换
+func (n *SimpleNet) ListenTLSRouter(addr string, config *tls.Config, router *TLSRouter) (*Listener, error) {
+	if config == nil {
+		return nil, fmt.Errorf("tls config required")
+	}
+	base := config.Clone()
+	config = base.Clone()
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		return router.configFor(base, hello)
+	}
+	return n.listenWith(addr, func(addr string) (net.Listener, error) {
+		return tls.Listen("tcp", addr, config)
+	}, nil, router.route)
+}
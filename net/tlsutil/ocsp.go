@@ -0,0 +1,225 @@
+package tlsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPStapler 定期从证书的OCSP服务器获取响应并stapling，
+// 在响应有效期过半时刷新，失败时按RetryInterval重试
+type OCSPStapler struct {
+	// Client 为空时使用http.DefaultClient
+	Client *http.Client
+	// RetryInterval 获取失败后的重试间隔，默认1分钟
+	RetryInterval time.Duration
+	// OnError 获取失败回调
+	OnError func(err error)
+
+	cert   tls.Certificate
+	leaf   *x509.Certificate
+	issuer *x509.Certificate
+
+	lock   sync.RWMutex
+	staple *tls.Certificate
+	resp   *ocsp.Response
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewOCSPStapler 创建stapler，cert.Certificate需要包含签发者证书，
+// 除非另外提供issuer
+func NewOCSPStapler(cert tls.Certificate, issuer *x509.Certificate) (*OCSPStapler, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("empty certificate")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("certificate has no ocsp server")
+	}
+	if issuer == nil {
+		if len(cert.Certificate) < 2 {
+			return nil, fmt.Errorf("no issuer certificate")
+		}
+		if issuer, err = x509.ParseCertificate(cert.Certificate[1]); err != nil {
+			return nil, err
+		}
+	}
+	s := &OCSPStapler{
+		RetryInterval: time.Minute,
+
+		cert:   cert,
+		leaf:   leaf,
+		issuer: issuer,
+	}
+	s.staple = &s.cert
+	return s, nil
+}
+
+// Fetch 立即获取OCSP响应，返回下次刷新的时间
+func (s *OCSPStapler) Fetch(ctx context.Context) (time.Time, error) {
+	req, err := ocsp.CreateRequest(s.leaf, s.issuer, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var lastErr error
+	for _, server := range s.leaf.OCSPServer {
+		var raw []byte
+		raw, lastErr = s.post(ctx, server, req)
+		if lastErr != nil {
+			continue
+		}
+		var resp *ocsp.Response
+		resp, lastErr = ocsp.ParseResponseForCert(raw, s.leaf, s.issuer)
+		if lastErr != nil {
+			continue
+		}
+		if resp.Status == ocsp.Revoked {
+			lastErr = fmt.Errorf("certificate revoked at %s", resp.RevokedAt)
+			continue
+		}
+
+		cert := s.cert
+		cert.OCSPStaple = raw
+		s.lock.Lock()
+		s.staple = &cert
+		s.resp = resp
+		s.lock.Unlock()
+
+		return refreshTime(resp), nil
+	}
+	return time.Time{}, lastErr
+}
+
+func (s *OCSPStapler) post(ctx context.Context, server string, req []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp server %s status %d", server, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// refreshTime 有效期过半时刷新，没有NextUpdate时1小时后刷新
+func refreshTime(resp *ocsp.Response) time.Time {
+	if resp.NextUpdate.IsZero() {
+		return time.Now().Add(time.Hour)
+	}
+	return resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+}
+
+// Response 当前的OCSP响应，未获取时返回nil
+func (s *OCSPStapler) Response() *ocsp.Response {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.resp
+}
+
+// Certificate 带staple的证书
+func (s *OCSPStapler) Certificate() *tls.Certificate {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// 过期的staple会导致客户端握手失败，不如不发
+	if s.resp != nil && !s.resp.NextUpdate.IsZero() && time.Now().After(s.resp.NextUpdate) {
+		return &s.cert
+	}
+	return s.staple
+}
+
+// GetCertificate 用于tls.Config.GetCertificate
+func (s *OCSPStapler) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.Certificate(), nil
+}
+
+// Apply 把stapler设置到conf上
+func (s *OCSPStapler) Apply(conf *tls.Config) {
+	conf.Certificates = nil
+	conf.GetCertificate = s.GetCertificate
+}
+
+// Start 开始后台刷新
+func (s *OCSPStapler) Start() error {
+	s.lock.Lock()
+	if s.cancel != nil {
+		s.lock.Unlock()
+		return fmt.Errorf("stapler already started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.lock.Unlock()
+
+	go s.run(ctx, s.done)
+	return nil
+}
+
+func (s *OCSPStapler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		next, err := s.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+		}
+		retry := s.RetryInterval
+		if retry <= 0 {
+			retry = time.Minute
+		}
+		wait := time.Until(next)
+		if err != nil || wait < retry {
+			wait = retry
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Stop 停止后台刷新
+func (s *OCSPStapler) Stop() {
+	s.lock.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,132 @@
+// Package tlsutil TLS服务端的辅助功能: session ticket key轮换及OCSP stapling
+package tlsutil
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TicketRotator 定期轮换session ticket key，新key用于加密，
+// 旧key保留Overlap个用于解密已发出的ticket
+type TicketRotator struct {
+	conf     *tls.Config
+	interval time.Duration
+	overlap  int
+
+	lock sync.Mutex
+	keys [][32]byte
+	stop chan struct{}
+	done chan struct{}
+
+	// OnRotate 每次轮换后回调，可用于把key同步给集群内其他节点
+	OnRotate func(keys [][32]byte)
+}
+
+// NewTicketRotator 创建轮换器，overlap为保留的旧key个数
+func NewTicketRotator(conf *tls.Config, interval time.Duration, overlap int) (*TicketRotator, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid rotate interval")
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	return &TicketRotator{
+		conf:     conf,
+		interval: interval,
+		overlap:  overlap,
+	}, nil
+}
+
+// Rotate 立即生成新key
+func (r *TicketRotator) Rotate() error {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	keys := make([][32]byte, 0, r.overlap+1)
+	keys = append(keys, key)
+	for i := 0; i < len(r.keys) && i < r.overlap; i++ {
+		keys = append(keys, r.keys[i])
+	}
+	r.keys = keys
+	r.conf.SetSessionTicketKeys(keys)
+	r.lock.Unlock()
+
+	if r.OnRotate != nil {
+		r.OnRotate(keys)
+	}
+	return nil
+}
+
+// SetKeys 设置key(如从其他节点同步)，第一个用于加密
+func (r *TicketRotator) SetKeys(keys [][32]byte) {
+	if len(keys) == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.keys = append([][32]byte(nil), keys...)
+	r.conf.SetSessionTicketKeys(r.keys)
+}
+
+// Keys 当前的key
+func (r *TicketRotator) Keys() [][32]byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([][32]byte(nil), r.keys...)
+}
+
+// Start 生成首个key并开始定期轮换
+func (r *TicketRotator) Start() error {
+	r.lock.Lock()
+	if r.stop != nil {
+		r.lock.Unlock()
+		return fmt.Errorf("rotator already started")
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	r.stop, r.done = stop, done
+	r.lock.Unlock()
+
+	// 先启动run，首次轮换失败时Stop可以等到done
+	go r.run(stop, done)
+	if err := r.Rotate(); err != nil {
+		r.Stop()
+		return err
+	}
+	return nil
+}
+
+func (r *TicketRotator) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.Rotate()
+		}
+	}
+}
+
+// Stop 停止轮换，当前的key继续有效
+func (r *TicketRotator) Stop() {
+	r.lock.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,229 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const defTLVMaxDepth = 16
+
+// ErrBadTLV TLV格式错误
+var ErrBadTLV = errors.New("bad tlv")
+
+// TLV 一个type-length-value，嵌套的tag使用Children，否则使用Value
+type TLV struct {
+	Tag      uint64
+	Value    []byte
+	Children []*TLV
+}
+
+// Find 第一个tag相同的子节点
+func (t *TLV) Find(tag uint64) *TLV {
+	for _, c := range t.Children {
+		if c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// TLVOptions TLV的格式
+type TLVOptions struct {
+	TagSize int              // tag的字节数(1/2/4/8)，0时为1
+	LenSize int              // 长度的字节数(1/2/4/8)，0时为2
+	Order   binary.ByteOrder // nil时为大端
+	// Nested 返回true的tag的value是TLV序列，解析到Children中
+	Nested func(tag uint64) bool
+	// MaxDepth 最大嵌套层数，<=0时为16
+	MaxDepth int
+}
+
+// TLVProto 每一帧是一个TLV，Parse返回*TLV，Serialize接受*TLV。
+// 没有状态，可以在多个连接间共享
+type TLVProto struct {
+	opts TLVOptions
+}
+
+// NewTLVProto 创建TLV proto，opts为nil时tag 1字节、长度2字节、大端
+func NewTLVProto(opts *TLVOptions) (*TLVProto, error) {
+	p := &TLVProto{}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.TagSize == 0 {
+		p.opts.TagSize = 1
+	}
+	if p.opts.LenSize == 0 {
+		p.opts.LenSize = 2
+	}
+	if !validUintSize(p.opts.TagSize) || !validUintSize(p.opts.LenSize) {
+		return nil, fmt.Errorf("invalid tag size %d or length size %d", p.opts.TagSize, p.opts.LenSize)
+	}
+	if p.opts.Order == nil {
+		p.opts.Order = binary.BigEndian
+	}
+	if p.opts.MaxDepth <= 0 {
+		p.opts.MaxDepth = defTLVMaxDepth
+	}
+	return p, nil
+}
+
+func validUintSize(size int) bool {
+	switch size {
+	case 1, 2, 4, 8:
+		return true
+	}
+	return false
+}
+
+// getUint 按size字节读取无符号整数
+func getUint(b []byte, size int, order binary.ByteOrder) uint64 {
+	switch size {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	}
+	return order.Uint64(b)
+}
+
+// putUint 按size字节写入无符号整数，b的长度至少为size
+func putUint(b []byte, size int, order binary.ByteOrder, v uint64) {
+	switch size {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	case 8:
+		order.PutUint64(b, v)
+	}
+}
+
+// fitsUint v是否能用size字节表示
+func fitsUint(v uint64, size int) bool {
+	return size >= 8 || v < 1<<(8*size)
+}
+
+func (p *TLVProto) headLen() int {
+	return p.opts.TagSize + p.opts.LenSize
+}
+
+func (p *TLVProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *TLVProto) HeadLen() uint32 {
+	return uint32(p.headLen())
+}
+
+func (p *TLVProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	tag := getUint(head, p.opts.TagSize, p.opts.Order)
+	size := getUint(head[p.opts.TagSize:], p.opts.LenSize, p.opts.Order)
+	if !fitsUint(size, 4) {
+		return nil, 0, fmt.Errorf("%w, tag %d too large, size = %d", ErrBadTLV, tag, size)
+	}
+	return tag, uint32(size), nil
+}
+
+func (p *TLVProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	tag, ok := head.(uint64)
+	if !ok {
+		return nil, fmt.Errorf("unexpect head type")
+	}
+	return p.decodeValue(tag, body, 0)
+}
+
+func (p *TLVProto) Serialize(data interface{}) ([]byte, error) {
+	t, ok := data.(*TLV)
+	if !ok {
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	return p.Encode(t)
+}
+
+// Encode 编码一个TLV(包括嵌套的子节点)
+func (p *TLVProto) Encode(t *TLV) ([]byte, error) {
+	return p.appendTLV(nil, t, 0)
+}
+
+// Decode 解码TLV序列，用于报文之外的TLV数据
+func (p *TLVProto) Decode(data []byte) ([]*TLV, error) {
+	return p.decodeSeq(data, 0)
+}
+
+func (p *TLVProto) appendTLV(dst []byte, t *TLV, depth int) ([]byte, error) {
+	if depth > p.opts.MaxDepth {
+		return nil, fmt.Errorf("%w, nested too deep", ErrBadTLV)
+	}
+	if !fitsUint(t.Tag, p.opts.TagSize) {
+		return nil, fmt.Errorf("%w, tag %d exceeds %d bytes", ErrBadTLV, t.Tag, p.opts.TagSize)
+	}
+	start := len(dst)
+	dst = append(dst, make([]byte, p.headLen())...)
+	putUint(dst[start:], p.opts.TagSize, p.opts.Order, t.Tag)
+	if p.nested(t.Tag) {
+		var err error
+		for _, c := range t.Children {
+			if dst, err = p.appendTLV(dst, c, depth+1); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		dst = append(dst, t.Value...)
+	}
+	size := uint64(len(dst) - start - p.headLen())
+	if !fitsUint(size, p.opts.LenSize) {
+		return nil, fmt.Errorf("%w, tag %d value too large for %d bytes length, size = %d",
+			ErrBadTLV, t.Tag, p.opts.LenSize, size)
+	}
+	putUint(dst[start+p.opts.TagSize:], p.opts.LenSize, p.opts.Order, size)
+	return dst, nil
+}
+
+func (p *TLVProto) nested(tag uint64) bool {
+	return p.opts.Nested != nil && p.opts.Nested(tag)
+}
+
+func (p *TLVProto) decodeValue(tag uint64, value []byte, depth int) (*TLV, error) {
+	if depth > p.opts.MaxDepth {
+		return nil, fmt.Errorf("%w, nested too deep", ErrBadTLV)
+	}
+	t := &TLV{Tag: tag}
+	if !p.nested(tag) {
+		t.Value = value
+		return t, nil
+	}
+	children, err := p.decodeSeq(value, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	t.Children = children
+	return t, nil
+}
+
+func (p *TLVProto) decodeSeq(data []byte, depth int) ([]*TLV, error) {
+	var seq []*TLV
+	for len(data) > 0 {
+		if len(data) < p.headLen() {
+			return nil, fmt.Errorf("%w, truncated head", ErrBadTLV)
+		}
+		tag := getUint(data, p.opts.TagSize, p.opts.Order)
+		size := getUint(data[p.opts.TagSize:], p.opts.LenSize, p.opts.Order)
+		data = data[p.headLen():]
+		if size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w, tag %d truncated, size = %d, left = %d", ErrBadTLV, tag, size, len(data))
+		}
+		t, err := p.decodeValue(tag, data[:size], depth)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, t)
+		data = data[size:]
+	}
+	return seq, nil
+}
@@ -0,0 +1,58 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// 常用的DSCP值
+const (
+	DSCPDefault = 0
+	DSCPCS1     = 8
+	DSCPAF11    = 10
+	DSCPAF21    = 18
+	DSCPAF31    = 26
+	DSCPCS4     = 32
+	DSCPAF41    = 34
+	DSCPCS5     = 40
+	DSCPEF      = 46 // expedited forwarding，适合控制报文
+	DSCPCS6     = 48
+	DSCPCS7     = 56
+)
+
+// SetTOS 设置连接的IP TOS(IPv6为Traffic Class)字段，
+// 只支持底层实现了syscall.Conn的连接
+func (c *Connection) SetTOS(tos int) error {
+	if tos < 0 || tos > 0xff {
+		return fmt.Errorf("invalid tos %d", tos)
+	}
+	sc, ok := c.conn.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("connection not support tos")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	ipv6 := false
+	if addr, ok := c.conn.LocalAddr().(*net.TCPAddr); ok {
+		ipv6 = addr.IP.To4() == nil
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = setTOS(fd, ipv6, tos)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// SetDSCP 设置DSCP，即TOS的高6位，ECN位置0
+func (c *Connection) SetDSCP(dscp int) error {
+	if dscp < 0 || dscp > 0x3f {
+		return fmt.Errorf("invalid dscp %d", dscp)
+	}
+	return c.SetTOS(dscp << 2)
+}
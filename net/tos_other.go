@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package net
+
+import "fmt"
+
+func setTOS(fd uintptr, ipv6 bool, tos int) error {
+	return fmt.Errorf("tos not supported on this platform")
+}
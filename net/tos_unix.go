@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+func setTOS(fd uintptr, ipv6 bool, tos int) error {
+	if ipv6 {
+		return os.NewSyscallError("setsockopt",
+			syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos))
+	}
+	return os.NewSyscallError("setsockopt",
+		syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos))
+}
@@ -0,0 +1,110 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrDataType 发送的数据不是proto处理的类型
+var ErrDataType = errors.New("unexpect data type")
+
+// IProtoT 类型化的proto，Parse返回T，Serialize接受T，使用Typed转换为IProto。
+// Splitter、Heartbeater等可选扩展实现在IProtoT上同样生效，扩展中的interface{}即为T
+type IProtoT[T any] interface {
+	FilterAccept(conn *Connection) bool
+	HeadLen() uint32
+	BodyLen(head []byte) (interface{}, uint32, error)
+	Parse(head interface{}, body []byte) (T, error)
+	Serialize(data T) ([]byte, error)
+}
+
+// ConnEventT 类型化的事件，EventNewConnectionData的数据是T时设置Data，
+// 其他事件(以及其他proto的连接)的数据在ConnEvent.Data中
+type ConnEventT[T any] struct {
+	*ConnEvent
+	Data T
+}
+
+// typedProto 把IProtoT包装为IProto
+type typedProto[T any] struct {
+	proto IProtoT[T]
+}
+
+// Typed 把IProtoT转换为Listen、Connect等使用的IProto
+func Typed[T any](proto IProtoT[T]) IProto {
+	return &typedProto[T]{proto: proto}
+}
+
+// PollEventT 同PollEvent，返回类型化的事件
+func PollEventT[T any](n *SimpleNet, timeout int) (*ConnEventT[T], error) {
+	evt, err := n.PollEvent(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return EventT[T](evt), nil
+}
+
+// EventT 把事件转换为类型化的事件
+func EventT[T any](evt *ConnEvent) *ConnEventT[T] {
+	e := &ConnEventT[T]{ConnEvent: evt}
+	if evt.EventType == EventNewConnectionData {
+		if data, ok := evt.Data.(T); ok {
+			e.Data = data
+		}
+	}
+	return e
+}
+
+// SendDataT 同SendData，编译期检查发送的数据类型
+func SendDataT[T any](n *SimpleNet, conn *Connection, data T) error {
+	return n.SendData(conn, data)
+}
+
+func (p *typedProto[T]) unwrapProto() interface{} {
+	return p.proto
+}
+
+func (p *typedProto[T]) FilterAccept(conn *Connection) bool {
+	return p.proto.FilterAccept(conn)
+}
+
+func (p *typedProto[T]) HeadLen() uint32 {
+	return p.proto.HeadLen()
+}
+
+func (p *typedProto[T]) BodyLen(head []byte) (interface{}, uint32, error) {
+	return p.proto.BodyLen(head)
+}
+
+func (p *typedProto[T]) Parse(head interface{}, body []byte) (interface{}, error) {
+	data, err := p.proto.Parse(head, body)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (p *typedProto[T]) Serialize(data interface{}) ([]byte, error) {
+	v, ok := data.(T)
+	if !ok {
+		return nil, fmt.Errorf("%w, expect %s, got %T", ErrDataType, reflect.TypeFor[T](), data)
+	}
+	return p.proto.Serialize(v)
+}
+
+// protoAs 查找proto实现的可选扩展，Typed包装的proto查找被包装的proto
+func protoAs[X any](proto interface{}) (X, bool) {
+	for proto != nil {
+		if x, ok := proto.(X); ok {
+			return x, true
+		}
+		u, ok := proto.(interface{ unwrapProto() interface{} })
+		if !ok {
+			break
+		}
+		proto = u.unwrapProto()
+	}
+	var zero X
+	return zero, false
+}
@@ -0,0 +1,374 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+const (
+	// DefUDPIdle UDP虚拟连接默认的空闲超时
+	DefUDPIdle = 60 * time.Second
+
+	udpMaxDatagram = 64 * 1024
+	udpQueueSize   = 128
+)
+
+// udpConn 每个远端地址对应的虚拟连接。读取时每个数据报单独按proto成帧(readDatagram)，
+// 一个数据报格式错误不影响之后的数据报；Read仍然按字节流读取，供pipeline等流式读取使用。
+// 每个报文在一个数据报中发出，所以一个报文不要超过一个数据报的大小
+type udpConn struct {
+	laddr net.Addr
+	raddr net.Addr
+	write func(b []byte) (int, error)
+
+	in   chan []byte
+	buf  []byte
+	idle time.Duration
+
+	readDeadline atomic.Pointer[time.Time]
+
+	done      chan struct{}
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func newUDPConn(laddr, raddr net.Addr, idle time.Duration, write func([]byte) (int, error)) *udpConn {
+	if idle <= 0 {
+		idle = DefUDPIdle
+	}
+	return &udpConn{
+		laddr: laddr,
+		raddr: raddr,
+		write: write,
+		in:    make(chan []byte, udpQueueSize),
+		idle:  idle,
+		done:  make(chan struct{}),
+	}
+}
+
+// deliver 收到数据报，队列满时丢弃
+func (c *udpConn) deliver(b []byte) {
+	select {
+	case c.in <- b:
+	default:
+	}
+}
+
+// next 等待下一个数据报，空闲超时后返回io.EOF
+func (c *udpConn) next() ([]byte, error) {
+	idle := time.NewTimer(c.idle)
+	defer idle.Stop()
+
+	var deadline <-chan time.Time
+	if t := c.readDeadline.Load(); t != nil && !t.IsZero() {
+		timer := time.NewTimer(time.Until(*t))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case b := <-c.in:
+		return b, nil
+	case <-c.done:
+		return nil, net.ErrClosed
+	case <-idle.C:
+		c.Close()
+		return nil, io.EOF
+	case <-deadline:
+		return nil, os.ErrDeadlineExceeded
+	}
+}
+
+// ReadDatagram 读取一个完整的数据报
+func (c *udpConn) ReadDatagram() ([]byte, error) {
+	if len(c.buf) > 0 {
+		b := c.buf
+		c.buf = nil
+		return b, nil
+	}
+	return c.next()
+}
+
+// Read 按字节流读取数据，空闲超时后返回io.EOF
+func (c *udpConn) Read(b []byte) (int, error) {
+	if len(c.buf) == 0 {
+		buf, err := c.next()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = buf
+	}
+	count := copy(b, c.buf)
+	c.buf = c.buf[count:]
+	return count, nil
+}
+
+func (c *udpConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.write(b)
+}
+
+func (c *udpConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return nil
+}
+
+func (c *udpConn) LocalAddr() net.Addr  { return c.laddr }
+func (c *udpConn) RemoteAddr() net.Addr { return c.raddr }
+
+func (c *udpConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(&t)
+	return nil
+}
+
+// SetWriteDeadline UDP写不会阻塞在对端，忽略
+func (c *udpConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// datagramConn 按数据报读取的连接
+type datagramConn interface {
+	ReadDatagram() ([]byte, error)
+}
+
+// readDatagram 读取一个数据报，按proto切分出其中的报文，没有帧格式时整个数据报作为一个报文。
+// 格式错误(包括不完整的报文)时丢弃数据报剩下的部分
+func (n *SimpleNet) readDatagram(conn *Connection, dc datagramConn) bool {
+	data, err := dc.ReadDatagram()
+	if err != nil {
+		n.checkConnErr(0, err, conn)
+		return false
+	}
+	n.logMsg(mylog.LevelInformational,
+		fmt.Sprintf("read datagram, count = %d, remoteAddr: = %s\n", len(data), conn.remoteAddr))
+
+	headlen := uint32(0)
+	if conn.proto != nil {
+		headlen = conn.proto.HeadLen()
+	}
+	if headlen <= 0 {
+		if splitter, ok := protoAs[Splitter](conn.proto); ok {
+			for len(data) > 0 {
+				advance, frame, err := splitter.Split(data, true)
+				if err != nil {
+					return n.splitError(conn, err)
+				}
+				if advance <= 0 || advance > len(data) {
+					break
+				}
+				data = data[advance:]
+				if frame != nil {
+					n.emitSplit(conn, frame)
+				}
+			}
+			return true
+		}
+		conn.mirrorFrame(false, data)
+		n.emit(&ConnEvent{
+			EventType: EventNewConnectionData,
+			Conn:      conn,
+			Data:      data,
+		})
+		return true
+	}
+
+	for len(data) > 0 {
+		if uint32(len(data)) < headlen {
+			return n.splitError(conn, fmt.Errorf("truncated datagram, head = %d, left = %d", headlen, len(data)))
+		}
+		headmsg, bodylen, err := conn.proto.BodyLen(data[:headlen])
+		if err != nil {
+			return n.splitError(conn, err)
+		}
+		if uint64(len(data))-uint64(headlen) < uint64(bodylen) {
+			return n.splitError(conn, fmt.Errorf("truncated datagram, body = %d, left = %d", bodylen, len(data)-int(headlen)))
+		}
+		frame := data[:headlen+bodylen]
+		data = data[headlen+bodylen:]
+		conn.mirrorFrame(false, frame)
+
+		parsed, err := conn.proto.Parse(headmsg, frame[headlen:])
+		if err != nil {
+			return n.splitError(conn, err)
+		}
+		if conn.intercept(parsed, noRelease) {
+			continue
+		}
+		evtType, parsed := conn.dataEvent(parsed)
+		n.emit(&ConnEvent{
+			EventType: evtType,
+			Conn:      conn,
+			Data:      parsed,
+		})
+	}
+	return true
+}
+
+// udpListener 按远端地址分发数据报，新的远端地址作为新连接Accept
+type udpListener struct {
+	pc   net.PacketConn
+	idle time.Duration
+
+	lock   sync.Mutex
+	conns  map[string]*udpConn
+	accept chan *udpConn
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func listenUDP(addr string, idle time.Duration) (net.Listener, error) {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	l := &udpListener{
+		pc:     pc,
+		idle:   idle,
+		conns:  make(map[string]*udpConn),
+		accept: make(chan *udpConn, udpQueueSize),
+		done:   make(chan struct{}),
+	}
+	go l.serve()
+	return l, nil
+}
+
+func (l *udpListener) serve() {
+	defer l.Close()
+
+	buf := make([]byte, udpMaxDatagram)
+	for {
+		count, raddr, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		data := append([]byte(nil), buf[:count]...)
+
+		key := raddr.String()
+		l.lock.Lock()
+		conn, ok := l.conns[key]
+		if !ok {
+			conn = newUDPConn(l.pc.LocalAddr(), raddr, l.idle,
+				func(b []byte) (int, error) { return l.pc.WriteTo(b, raddr) })
+			conn.onClose = func() {
+				l.lock.Lock()
+				if l.conns[key] == conn {
+					delete(l.conns, key)
+				}
+				l.lock.Unlock()
+			}
+			l.conns[key] = conn
+		}
+		l.lock.Unlock()
+
+		conn.deliver(data)
+		if !ok {
+			select {
+			case l.accept <- conn:
+			case <-l.done:
+				return
+			}
+		}
+	}
+}
+
+func (l *udpListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accept:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close 关闭监听，已有的虚拟连接共用socket，一并关闭
+func (l *udpListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.pc.Close()
+
+		l.lock.Lock()
+		conns := make([]*udpConn, 0, len(l.conns))
+		for _, conn := range l.conns {
+			conns = append(conns, conn)
+		}
+		l.lock.Unlock()
+
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return nil
+}
+
+func (l *udpListener) Addr() net.Addr {
+	return l.pc.LocalAddr()
+}
+
+// ListenUDP 监听UDP，每个远端地址作为一个虚拟连接，
+// 使用同样的事件模型，idle时间内没有收到数据的连接关闭(EventConnectionClosed)，
+// idle<=0时使用DefUDPIdle
+func (n *SimpleNet) ListenUDP(addr string, idle time.Duration, proto IProto) (*Listener, error) {
+	return n.listenWith(addr, func(addr string) (net.Listener, error) {
+		return listenUDP(addr, idle)
+	}, proto, nil)
+}
+
+// ConnectUDP 连接UDP服务器，idle时间内没有收到数据的连接关闭，
+// idle<=0时使用DefUDPIdle
+func (n *SimpleNet) ConnectUDP(addr string, idle time.Duration, proto IProto) (*Connection, error) {
+	newconn, err := n.dial(context.Background(), "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newUDPConn(newconn.LocalAddr(), newconn.RemoteAddr(), idle, newconn.Write)
+	conn.onClose = func() { newconn.Close() }
+	go func() {
+		defer conn.Close()
+
+		buf := make([]byte, udpMaxDatagram)
+		for {
+			count, err := newconn.Read(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				// 如ICMP端口不可达，继续读取直到空闲超时
+				select {
+				case <-conn.done:
+					return
+				default:
+					continue
+				}
+			}
+			conn.deliver(append([]byte(nil), buf[:count]...))
+		}
+	}()
+
+	return n.AttachConn(conn, proto)
+}
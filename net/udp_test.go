@@ -0,0 +1,92 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func dialUDP(t *testing.T, l *Listener) net.Conn {
+	t.Helper()
+	c, err := net.Dial("udp", l.LocalAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestUDPDatagramFraming 不完整的数据报只影响自己，之后的数据报正常成帧
+func TestUDPDatagramFraming(t *testing.T) {
+	n := newTestNet(t)
+	l, err := n.ListenUDP("127.0.0.1:0", time.Minute, &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialUDP(t, l)
+
+	truncated := binary.BigEndian.AppendUint32(nil, 100)
+	truncated = append(truncated, "abc"...)
+	c.Write(truncated)
+	waitEvent(t, n, EventProtoError)
+
+	// 一个数据报中的两个报文
+	msg, _ := (&benchProto{}).Serialize([]byte("hello"))
+	msg2, _ := (&benchProto{}).Serialize([]byte("world"))
+	c.Write(append(msg, msg2...))
+	for _, want := range []string{"hello", "world"} {
+		evt := waitEvent(t, n, EventNewConnectionData)
+		if got := string(evt.Data.([]byte)); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// TestUDPRawDatagram 没有帧格式时一个数据报是一个事件
+func TestUDPRawDatagram(t *testing.T) {
+	n := newTestNet(t)
+	l, err := n.ListenUDP("127.0.0.1:0", time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialUDP(t, l)
+
+	big := bytes.Repeat([]byte("x"), 3*rawReadSize)
+	c.Write(big)
+	c.Write([]byte("small"))
+	if evt := waitEvent(t, n, EventNewConnectionData); len(evt.Data.([]byte)) != len(big) {
+		t.Fatalf("got %d bytes, want %d", len(evt.Data.([]byte)), len(big))
+	}
+	if evt := waitEvent(t, n, EventNewConnectionData); string(evt.Data.([]byte)) != "small" {
+		t.Fatalf("got %q", evt.Data)
+	}
+}
+
+// TestUDPSend 一个报文在一个数据报中发出
+func TestUDPSend(t *testing.T) {
+	n := newTestNet(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	conn, err := n.ConnectUDP(pc.LocalAddr().String(), time.Minute, &benchProto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendData(conn, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	count, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := (&benchProto{}).Serialize([]byte("ping"))
+	if !bytes.Equal(buf[:count], want) {
+		t.Fatalf("got %q", buf[:count])
+	}
+}
@@ -0,0 +1,120 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// PeerCred 本地对端的进程凭证
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// ListenUnix 监听unix socket，network为"unix"或"unixpacket"，
+// 关闭时删除socket文件，进程异常退出遗留的文件需要调用方删除
+func (n *SimpleNet) ListenUnix(network, addr string, proto IProto) (*Listener, error) {
+	if network != "unix" && network != "unixpacket" {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+	return n.listenWith(addr, func(addr string) (net.Listener, error) {
+		listen, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if network == "unixpacket" {
+			return &packetListener{Listener: listen}, nil
+		}
+		return listen, nil
+	}, proto, nil)
+}
+
+// ConnectUnix 连接unix socket，network为"unix"或"unixpacket"
+func (n *SimpleNet) ConnectUnix(network, addr string, proto IProto) (*Connection, error) {
+	if network != "unix" && network != "unixpacket" {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+	newconn, err := n.dial(context.Background(), network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unixpacket" {
+		newconn = newPacketStream(newconn)
+	}
+
+	return n.AttachConn(newconn, proto)
+}
+
+// PeerCred 返回unix socket对端的进程凭证
+func (c *Connection) PeerCred() (*PeerCred, error) {
+	conn := c.conn
+	if inner, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = inner.NetConn()
+	}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("connection not unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cred *PeerCred
+		cerr error
+	)
+	err = raw.Control(func(fd uintptr) {
+		cred, cerr = peerCred(fd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, cerr
+}
+
+// packetStream 保留消息边界的连接(unixpacket)一次读取完整的消息，
+// 把消息转换为流式读取，避免读取报文头时截断消息
+type packetStream struct {
+	net.Conn
+	packet []byte
+	buf    []byte
+}
+
+func newPacketStream(conn net.Conn) *packetStream {
+	return &packetStream{Conn: conn}
+}
+
+func (p *packetStream) Read(b []byte) (int, error) {
+	if len(p.buf) == 0 {
+		if p.packet == nil {
+			p.packet = make([]byte, udpMaxDatagram)
+		}
+		count, err := p.Conn.Read(p.packet)
+		if err != nil {
+			return 0, err
+		}
+		p.buf = p.packet[:count]
+	}
+	count := copy(b, p.buf)
+	p.buf = p.buf[count:]
+	return count, nil
+}
+
+// NetConn 返回原始连接
+func (p *packetStream) NetConn() net.Conn {
+	return p.Conn
+}
+
+type packetListener struct {
+	net.Listener
+}
+
+func (l *packetListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newPacketStream(conn), nil
+}
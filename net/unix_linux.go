@@ -0,0 +1,18 @@
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+func peerCred(fd uintptr) (*PeerCred, error) {
+	ucred, err := syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	if err != nil {
+		return nil, os.NewSyscallError("getsockopt", err)
+	}
+	return &PeerCred{
+		PID: int(ucred.Pid),
+		UID: int(ucred.Uid),
+		GID: int(ucred.Gid),
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package net
+
+import "fmt"
+
+func peerCred(fd uintptr) (*PeerCred, error) {
+	return nil, fmt.Errorf("peer credential not supported on this platform")
+}
@@ -0,0 +1,50 @@
+package net
+
+import "fmt"
+
+// UserDataOwner 带UserData的对象: *SimpleNet, *Listener, *Connection
+type UserDataOwner interface {
+	userData() *interface{}
+}
+
+func (n *SimpleNet) userData() *interface{} {
+	return &n.UserData
+}
+
+func (l *Listener) userData() *interface{} {
+	return &l.UserData
+}
+
+func (c *Connection) userData() *interface{} {
+	return &c.UserData
+}
+
+// GetUserData 以类型T取出UserData，为空或者类型不符时返回零值和false，
+// 如 sess, ok := net.GetUserData[*Session](evt.Conn)
+func GetUserData[T any](o UserDataOwner) (T, bool) {
+	v, ok := (*o.userData()).(T)
+	return v, ok
+}
+
+// MustUserData 以类型T取出UserData，为空或者类型不符时panic
+func MustUserData[T any](o UserDataOwner) T {
+	p := o.userData()
+	v, ok := (*p).(T)
+	if !ok {
+		panic(fmt.Sprintf("user data is %T, not %T", *p, v))
+	}
+	return v
+}
+
+// SetUserData 设置UserData
+func SetUserData[T any](o UserDataOwner, v T) {
+	*o.userData() = v
+}
+
+// UserDataOr 以类型T取出UserData，为空或者类型不符时返回def
+func UserDataOr[T any](o UserDataOwner, def T) T {
+	if v, ok := GetUserData[T](o); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,73 @@
+package net
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// VarintProto protobuf风格的varint长度前缀：varint(报文体长度) + 报文体，
+// 长度是变长的，通过Split切分。Parse返回[]byte，Serialize接受[]byte或者string。
+// 没有状态，可以在多个连接间共享
+type VarintProto struct {
+	max uint64
+}
+
+// NewVarintProto 创建varint长度前缀的proto，max为报文体的最大长度，
+// 长度超过max时不等待报文体直接作为proto错误，<=0时只受连接的MaxBodyLen限制
+func NewVarintProto(max int) *VarintProto {
+	p := &VarintProto{}
+	if max > 0 {
+		p.max = uint64(max)
+	}
+	return p
+}
+
+func (p *VarintProto) FilterAccept(conn *Connection) bool {
+	return true
+}
+
+func (p *VarintProto) HeadLen() uint32 {
+	return 0
+}
+
+func (p *VarintProto) BodyLen(head []byte) (interface{}, uint32, error) {
+	return nil, 0, fmt.Errorf("varint proto has no fixed head")
+}
+
+func (p *VarintProto) Split(data []byte, atEOF bool) (int, []byte, error) {
+	size, n := binary.Uvarint(data)
+	if n == 0 {
+		return 0, nil, nil
+	}
+	if n < 0 {
+		// 长度溢出，无法再找到报文边界
+		return len(data), nil, fmt.Errorf("bad varint length")
+	}
+	if p.max > 0 && size > p.max {
+		return len(data), nil, fmt.Errorf("%w, size = %d, max = %d", ErrFrameTooLarge, size, p.max)
+	}
+	if uint64(len(data)-n) < size {
+		return 0, nil, nil
+	}
+	end := n + int(size)
+	return end, data[n:end:end], nil
+}
+
+func (p *VarintProto) Parse(head interface{}, body []byte) (interface{}, error) {
+	return body, nil
+}
+
+func (p *VarintProto) Serialize(data interface{}) ([]byte, error) {
+	var body []byte
+	switch v := data.(type) {
+	case []byte:
+		body = v
+	case string:
+		body = []byte(v)
+	default:
+		return nil, fmt.Errorf("unexpect data type")
+	}
+	msg := make([]byte, 0, binary.MaxVarintLen64+len(body))
+	msg = binary.AppendUvarint(msg, uint64(len(body)))
+	return append(msg, body...), nil
+}
@@ -0,0 +1,59 @@
+package net
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVarintSplit(t *testing.T) {
+	p := NewVarintProto(1000)
+	msg, err := p.Serialize(strings.Repeat("x", 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg[0]&0x80 == 0 || len(msg) != 302 {
+		t.Fatalf("expect 2 bytes varint, frame len = %d", len(msg))
+	}
+	for _, n := range []int{0, 1, 2, 301} {
+		if advance, frame, err := p.Split(msg[:n], false); advance != 0 || frame != nil || err != nil {
+			t.Fatalf("partial %d: advance = %d, frame = %v, err = %v", n, advance, frame != nil, err)
+		}
+	}
+	advance, frame, err := p.Split(append(msg, 0x01), false)
+	if err != nil || advance != len(msg) || !bytes.Equal(frame, msg[2:]) {
+		t.Fatalf("complete: advance = %d, err = %v", advance, err)
+	}
+
+	big, _ := NewVarintProto(0).Serialize(make([]byte, 1001))
+	if _, _, err := p.Split(big[:2], false); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("too large err = %v", err)
+	}
+	overflow := bytes.Repeat([]byte{0xff}, 11)
+	if _, _, err := p.Split(overflow, false); err == nil {
+		t.Fatal("expect bad varint error")
+	}
+}
+
+func TestVarintProto(t *testing.T) {
+	n := newTestNet(t)
+	n.SetClientEventQueue(64)
+	p := NewVarintProto(0)
+	conn, err := n.Connect(listenTest(t, n, p), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{strings.Repeat("y", 300), "a", ""}
+	for _, msg := range want {
+		if err := n.SendData(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, msg := range want {
+		evt := waitEvent(t, n, EventNewConnectionData)
+		if string(evt.Data.([]byte)) != msg {
+			t.Fatalf("receive %d bytes, want %d", len(evt.Data.([]byte)), len(msg))
+		}
+	}
+}
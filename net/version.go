@@ -0,0 +1,166 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+var versionMagic = []byte("GLVH")
+
+// ErrVersionMismatch 协议名或者版本不匹配
+var ErrVersionMismatch = errors.New("proto version mismatch")
+
+// VersionHandshake 版本协商，连接建立后双方先交换协议名和版本，再开始正常的收发。
+// 使用的版本是双方Version中较小的一个，协议名不同或者使用的版本低于任一方的MinVersion时协商失败，
+// 双方的判断一致。滚动升级时新版本保留对旧版本的兼容并把MinVersion设为仍在运行的最低版本
+type VersionHandshake struct {
+	Name       string // 协议名，最长255字节
+	Version    uint16
+	MinVersion uint16        // 接受的最低版本
+	Timeout    time.Duration // 协商超时，<=0时使用监听的握手超时
+}
+
+// versionHello 握手报文：magic(4) + Version(2) + MinVersion(2) + 名字长度(1) + 名字
+type versionHello struct {
+	name       string
+	version    uint16
+	minVersion uint16
+}
+
+// SetVersionHandshake 设置版本协商，对之后建立的连接(包括接受的连接)生效，nil取消。
+// 协商失败时发出EventHandshakeFailed(Data为错误)并关闭连接，
+// 接受的连接不再发出EventNewConnection，Connect返回错误
+func (n *SimpleNet) SetVersionHandshake(h *VersionHandshake) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+	n.versionHS.Store(h)
+	return nil
+}
+
+// SetVersionHandshake 设置监听下连接的版本协商，优先于SimpleNet的设置，nil取消
+func (l *Listener) SetVersionHandshake(h *VersionHandshake) error {
+	if err := h.check(); err != nil {
+		return err
+	}
+	l.versionHS.Store(h)
+	return nil
+}
+
+func (h *VersionHandshake) check() error {
+	if h == nil {
+		return nil
+	}
+	if len(h.Name) > 255 {
+		return fmt.Errorf("proto name too long")
+	}
+	if h.MinVersion > h.Version {
+		return fmt.Errorf("min version %d greater than version %d", h.MinVersion, h.Version)
+	}
+	return nil
+}
+
+// ProtoVersion 协商使用的版本，没有协商时返回0
+func (c *Connection) ProtoVersion() uint16 {
+	return c.version
+}
+
+func (c *Connection) versionHandshake() *VersionHandshake {
+	if c.listen != nil {
+		if h := c.listen.versionHS.Load(); h != nil {
+			return h
+		}
+	}
+	return c.net.versionHS.Load()
+}
+
+// negotiate 连接加入之前进行版本协商，失败时发出EventHandshakeFailed并关闭socket
+func (n *SimpleNet) negotiate(conn *Connection) error {
+	h := conn.versionHandshake()
+	if h == nil {
+		return nil
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defHandshakeTimeout
+		if conn.listen != nil && conn.listen.handshakeTimeout.Load() > 0 {
+			timeout = time.Duration(conn.listen.handshakeTimeout.Load())
+		}
+	}
+	version, err := exchangeVersion(conn, h, timeout)
+	if err == nil {
+		conn.version = version
+		return nil
+	}
+
+	n.logMsg(mylog.LevelWarning,
+		fmt.Sprintf("version handshake with %s failed, err = %s\n", conn.remoteAddr, err))
+	if conn.transition(StatusConnected, StatusBroken) {
+		conn.cancel(ErrConnClosed)
+		conn.conn.Close()
+	}
+	if conn.listen != nil {
+		conn.listen.stats.handshakeFailures.Inc()
+	}
+	n.emit(&ConnEvent{
+		EventType: EventHandshakeFailed,
+		Conn:      conn,
+		Data:      err,
+	})
+	return err
+}
+
+// exchangeVersion 发送本端的握手报文并读取对端的，返回协商的版本
+func exchangeVersion(conn *Connection, h *VersionHandshake, timeout time.Duration) (uint16, error) {
+	conn.conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.conn.SetDeadline(time.Time{})
+
+	hello := append([]byte(nil), versionMagic...)
+	hello = binary.BigEndian.AppendUint16(hello, h.Version)
+	hello = binary.BigEndian.AppendUint16(hello, h.MinVersion)
+	hello = append(hello, byte(len(h.Name)))
+	hello = append(hello, h.Name...)
+	if _, err := conn.conn.Write(hello); err != nil {
+		return 0, err
+	}
+
+	peer, err := readVersionHello(conn.conn)
+	if err != nil {
+		return 0, err
+	}
+	if peer.name != h.Name {
+		return 0, fmt.Errorf("%w, name = %s, peer name = %s", ErrVersionMismatch, h.Name, peer.name)
+	}
+	version := min(h.Version, peer.version)
+	if version < h.MinVersion || version < peer.minVersion {
+		return 0, fmt.Errorf("%w, version = %d-%d, peer version = %d-%d", ErrVersionMismatch,
+			h.MinVersion, h.Version, peer.minVersion, peer.version)
+	}
+	return version, nil
+}
+
+func readVersionHello(r io.Reader) (*versionHello, error) {
+	head := make([]byte, len(versionMagic)+5)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:len(versionMagic)], versionMagic) {
+		return nil, fmt.Errorf("%w, bad handshake magic", ErrVersionMismatch)
+	}
+	head = head[len(versionMagic):]
+	name := make([]byte, head[4])
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, err
+	}
+	return &versionHello{
+		name:       string(name),
+		version:    binary.BigEndian.Uint16(head),
+		minVersion: binary.BigEndian.Uint16(head[2:]),
+	}, nil
+}
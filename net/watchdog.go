@@ -0,0 +1,73 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	mylog "github.com/buf1024/golib/logging"
+)
+
+// ErrWriteStalled 写操作在看门狗超时内没有任何进展
+var ErrWriteStalled = errors.New("write stalled")
+
+type watchdog struct {
+	timeout    time.Duration
+	forceClose bool
+}
+
+// SetWriteWatchdog 开启写看门狗，一次写在timeout内没有写出任何字节(如对端窗口为0)时
+// 发出EventWriteStalled，forceClose为true时同时以ErrWriteStalled关闭连接，
+// 否则继续等待。timeout<=0关闭看门狗。
+// 注意TLS连接写超时后不能再使用，应该设置forceClose
+func (n *SimpleNet) SetWriteWatchdog(timeout time.Duration, forceClose bool) {
+	if timeout <= 0 {
+		n.watchdog.Store(nil)
+		return
+	}
+	n.watchdog.Store(&watchdog{
+		timeout:    timeout,
+		forceClose: forceClose,
+	})
+}
+
+// writeWatched 带看门狗的写，通过写超时检测是否有进展
+func (n *SimpleNet) writeWatched(conn *Connection, wd *watchdog, b []byte) (int, error) {
+	if wd == nil {
+		return conn.conn.Write(b)
+	}
+	defer conn.conn.SetWriteDeadline(time.Time{})
+
+	total := 0
+	stalled := false
+	for {
+		conn.conn.SetWriteDeadline(time.Now().Add(wd.timeout))
+		count, err := conn.conn.Write(b[total:])
+		total += count
+		if err == nil {
+			return total, nil
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			return total, err
+		}
+		if count > 0 {
+			stalled = false
+			continue
+		}
+		if !stalled {
+			stalled = true
+			n.logMsg(mylog.LevelWarning,
+				fmt.Sprintf("write stalled for %s, remoteAddr = %s\n",
+					wd.timeout, conn.RemoteAddress()))
+			n.emit(&ConnEvent{
+				EventType: EventWriteStalled,
+				Conn:      conn,
+				Data:      total,
+			})
+		}
+		if wd.forceClose {
+			return total, ErrWriteStalled
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package ws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 帧类型
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+)
+
+// CloseNormal 正常关闭的状态码
+const CloseNormal = 1000
+
+const (
+	wsGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defMaxFrameSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrFrameTooLarge 帧超过MaxFrameSize
+	ErrFrameTooLarge = errors.New("ws frame too large")
+	// ErrProtocol 帧不符合RFC 6455
+	ErrProtocol = errors.New("ws protocol error")
+)
+
+// Conn WebSocket连接，实现net.Conn，
+// 读取时把收到的数据帧连接成字节流，每次Write发送一个数据帧，
+// 所以可以直接交给SimpleNet，payload仍然使用IProto解析。
+// 服务端的握手在Handshake中进行，由Listener的握手超时控制
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+
+	// Request 服务端收到的升级请求
+	Request *http.Request
+	// MessageType 发送的帧类型，OpBinary(默认)或OpText
+	MessageType int
+	// MaxFrameSize 允许接收的最大帧
+	MaxFrameSize int64
+
+	upgrade       func(c *Conn) error
+	handshakeOnce sync.Once
+	handshakeErr  error
+
+	// 当前数据帧未读取的部分
+	remain int64
+	masked bool
+	mask   [4]byte
+	pos    int
+
+	wlock     sync.Mutex
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, r *bufio.Reader, client bool) *Conn {
+	if r == nil {
+		r = bufio.NewReader(conn)
+	}
+	return &Conn{
+		conn:         conn,
+		r:            r,
+		client:       client,
+		MessageType:  OpBinary,
+		MaxFrameSize: defMaxFrameSize,
+	}
+}
+
+// acceptKey 计算Sec-WebSocket-Accept
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// Handshake 完成服务端握手，客户端连接和已经升级的连接直接返回
+func (c *Conn) Handshake() error {
+	c.handshakeOnce.Do(func() {
+		if c.upgrade != nil {
+			c.handshakeErr = c.upgrade(c)
+		}
+	})
+	return c.handshakeErr
+}
+
+// NetConn 返回底层连接
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	for c.remain == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remain {
+		p = p[:c.remain]
+	}
+	count, err := c.r.Read(p)
+	if c.masked {
+		for i := 0; i < count; i++ {
+			p[i] ^= c.mask[(c.pos+i)%4]
+		}
+	}
+	c.pos += count
+	c.remain -= int64(count)
+	return count, err
+}
+
+// nextFrame 读取帧头，处理控制帧，数据帧设置remain后返回
+func (c *Conn) nextFrame() error {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return err
+	}
+	if head[0]&0x70 != 0 {
+		return ErrProtocol
+	}
+	op := int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		// 客户端必须mask，服务端不能mask
+		return ErrProtocol
+	}
+	size := int64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return err
+		}
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return err
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+		if size < 0 {
+			return ErrProtocol
+		}
+	}
+	if size > c.MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return err
+		}
+	}
+
+	switch op {
+	case OpContinuation, OpText, OpBinary:
+		c.remain, c.masked, c.mask, c.pos = size, masked, mask, 0
+		return nil
+	case OpClose, OpPing, OpPong:
+		if size > 125 {
+			return ErrProtocol
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+		switch op {
+		case OpPing:
+			return c.writeFrame(OpPong, payload)
+		case OpClose:
+			c.sendClose(payload)
+			return io.EOF
+		}
+		return nil
+	}
+	return ErrProtocol
+}
+
+// writeFrame 发送一个完整的帧，客户端发送的帧需要mask
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	head := make([]byte, 0, 14)
+	head = append(head, 0x80|byte(op))
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch size := len(payload); {
+	case size < 126:
+		head = append(head, maskBit|byte(size))
+	case size <= 0xffff:
+		head = append(head, maskBit|126)
+		head = binary.BigEndian.AppendUint16(head, uint16(size))
+	default:
+		head = append(head, maskBit|127)
+		head = binary.BigEndian.AppendUint64(head, uint64(size))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		head = append(head, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+
+	buffers := net.Buffers{head, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// Write 以一个数据帧发送p
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	if err := c.writeFrame(c.MessageType, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sendClose 发送关闭帧，只发送一次
+func (c *Conn) sendClose(payload []byte) {
+	c.closeOnce.Do(func() {
+		if len(payload) >= 2 {
+			payload = payload[:2]
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(OpClose, payload)
+	})
+}
+
+// Close 发送关闭帧后关闭底层连接
+func (c *Conn) Close() error {
+	if c.Request != nil || c.client {
+		c.sendClose(binary.BigEndian.AppendUint16(nil, CloseNormal))
+	}
+	return c.conn.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// headerContains header中以逗号分隔的值是否包含token(忽略大小写)
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkUpgrade 检查升级请求，返回Sec-WebSocket-Key
+func checkUpgrade(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", fmt.Errorf("bad method %s", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return "", fmt.Errorf("not websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", fmt.Errorf("unsupported version %s", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return "", fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	return key, nil
+}
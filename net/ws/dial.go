@@ -0,0 +1,107 @@
+package ws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// Dial 连接WebSocket服务器，rawurl为ws://或wss://，header为额外的请求头，
+// config为wss使用的TLS配置，可以为nil
+func Dial(rawurl string, header http.Header, config *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "ws":
+			host = net.JoinHostPort(u.Hostname(), "80")
+		case "wss":
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if config == nil {
+			config = &tls.Config{}
+		}
+		conn, err = tls.Dial("tcp", host, config)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := clientHandshake(conn, u, header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func clientHandshake(conn net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("bad handshake status %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("bad Sec-WebSocket-Accept")
+	}
+	return newConn(conn, r, true), nil
+}
+
+// Connect 连接WebSocket服务器，帧中的数据使用proto解析
+func Connect(n *mynet.SimpleNet, rawurl string, header http.Header, proto mynet.IProto) (*mynet.Connection, error) {
+	c, err := Dial(rawurl, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachConn(c, proto)
+}
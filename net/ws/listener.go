@@ -0,0 +1,116 @@
+package ws
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	mynet "github.com/buf1024/golib/net"
+)
+
+// Listener 包装net.Listener，Accept返回未握手的*Conn，
+// 握手在SimpleNet的acceptConn中通过Handshake完成，不阻塞accept
+type Listener struct {
+	inner net.Listener
+
+	// Path 非空时只接受该路径的升级请求
+	Path string
+	// CheckOrigin 非空时检查Origin，返回false拒绝
+	CheckOrigin func(r *http.Request) bool
+}
+
+// NewListener 包装inner
+func NewListener(inner net.Listener) *Listener {
+	return &Listener{inner: inner}
+}
+
+// Listen 在addr上监听WebSocket，帧中的数据使用proto解析
+func Listen(n *mynet.SimpleNet, addr string, proto mynet.IProto) (*mynet.Listener, error) {
+	inner, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.AttachListener(NewListener(inner), proto)
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.inner.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := newConn(conn, nil, false)
+	c.upgrade = l.upgrade
+	return c, nil
+}
+
+func (l *Listener) Close() error {
+	return l.inner.Close()
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.inner.Addr()
+}
+
+// upgrade 读取HTTP升级请求并应答
+func (l *Listener) upgrade(c *Conn) error {
+	r, err := http.ReadRequest(c.r)
+	if err != nil {
+		return err
+	}
+	status, err := l.check(r)
+	if err != nil {
+		fmt.Fprintf(c.conn, "HTTP/1.1 %d %s\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+			status, http.StatusText(status))
+		return err
+	}
+	if err := writeAccept(c.conn, r); err != nil {
+		return err
+	}
+	c.Request = r
+	return nil
+}
+
+func (l *Listener) check(r *http.Request) (int, error) {
+	if l.Path != "" && r.URL.Path != l.Path {
+		return http.StatusNotFound, fmt.Errorf("path %s not found", r.URL.Path)
+	}
+	if _, err := checkUpgrade(r); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if l.CheckOrigin != nil && !l.CheckOrigin(r) {
+		return http.StatusForbidden, fmt.Errorf("origin %s forbidden", r.Header.Get("Origin"))
+	}
+	return 0, nil
+}
+
+func writeAccept(w net.Conn, r *http.Request) error {
+	_, err := fmt.Fprintf(w, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		acceptKey(r.Header.Get("Sec-WebSocket-Key")))
+	return err
+}
+
+// Upgrade 在http.Handler中升级连接，返回的连接可以交给SimpleNet.AttachConn，
+// 用于和普通HTTP服务共用端口
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if _, err := checkUpgrade(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijack not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("hijack not supported")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAccept(conn, r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := newConn(conn, rw.Reader, false)
+	c.Request = r
+	return c, nil
+}
@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestEcho(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner)
+	l.Path = "/ws"
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	c, err := Dial("ws://"+inner.Addr().String()+"/ws", http.Header{"Origin": {"test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, size := range []int{0, 10, 200, 70000} {
+		msg := bytes.Repeat([]byte{byte(size)}, size)
+		if _, err := c.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.writeFrame(OpPing, []byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, size)
+		if _, err := io.ReadFull(c, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("size %d: echo mismatch", size)
+		}
+	}
+}
+
+func TestBadPath(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(inner)
+	l.Path = "/ws"
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*Conn).Handshake()
+	}()
+
+	if _, err := Dial("ws://"+inner.Addr().String()+"/other", nil, nil); err == nil {
+		t.Fatal("expected handshake failure")
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// RFC 6455 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("acceptKey = %s", got)
+	}
+}
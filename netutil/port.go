@@ -0,0 +1,107 @@
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+// FreePort 由系统分配一个空闲端口，network为tcp/tcp4/tcp6/udp/udp4/udp6
+func FreePort(network string) (int, error) {
+	return probePort(network, loopback(network), 0)
+}
+
+// FreePortRange 在[min, max]内随机查找一个空闲端口
+func FreePortRange(network string, min, max int) (int, error) {
+	if min <= 0 || max > 65535 || min > max {
+		return 0, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+	count := max - min + 1
+	start := rand.Intn(count)
+	for i := 0; i < count; i++ {
+		port := min + (start+i)%count
+		if p, err := probePort(network, loopback(network), port); err == nil {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port in range %d-%d", min, max)
+}
+
+// FreeAddr 返回一个可以监听的回环地址，tcp6/udp6为 [::1]:port，其他为 127.0.0.1:port
+func FreeAddr(network string) (string, error) {
+	port, err := FreePort(network)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(loopback(network), strconv.Itoa(port)), nil
+}
+
+// loopback 按network选择回环地址
+func loopback(network string) string {
+	if network == "tcp6" || network == "udp6" {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
+
+func probePort(network string, host string, port int) (int, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return 0, err
+		}
+		defer l.Close()
+		return l.Addr().(*net.TCPAddr).Port, nil
+	case "udp", "udp4", "udp6":
+		c, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return 0, err
+		}
+		defer c.Close()
+		return c.LocalAddr().(*net.UDPAddr).Port, nil
+	}
+	return 0, fmt.Errorf("unsupported network %s", network)
+}
+
+// Reachable tcp地址是否可以连接
+func Reachable(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// WaitListening 等待tcp地址可连接，直到超时
+func WaitListening(addr string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return WaitListeningContext(ctx, addr)
+}
+
+// WaitListeningContext 等待tcp地址可连接，直到ctx结束
+func WaitListeningContext(ctx context.Context, addr string) error {
+	var d net.Dialer
+	interval := time.Millisecond * 10
+	for {
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn.Close()
+		}
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("wait %s listening: %s", addr, err)
+		case <-t.C:
+		}
+		if interval < time.Millisecond*200 {
+			interval *= 2
+		}
+	}
+}
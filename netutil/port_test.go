@@ -0,0 +1,73 @@
+package netutil
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFreePort(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		port, err := FreePort(network)
+		if err != nil || port <= 0 {
+			t.Fatalf("%s free port failed, port = %d, err = %v", network, port, err)
+		}
+	}
+	port, err := FreePortRange("tcp", 40000, 40100)
+	if err != nil || port < 40000 || port > 40100 {
+		t.Fatalf("free port range failed, port = %d, err = %v", port, err)
+	}
+	if _, err = FreePortRange("tcp", 10, 1); err == nil {
+		t.Fatalf("expect invalid range")
+	}
+}
+
+func TestFreeAddr6(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("no ipv6 loopback, err = %s", err)
+	} else {
+		l.Close()
+	}
+	for _, network := range []string{"tcp6", "udp6"} {
+		addr, err := FreeAddr(network)
+		if err != nil {
+			t.Fatalf("%s free addr failed, err = %v", network, err)
+		}
+		if host, _, _ := net.SplitHostPort(addr); host != "::1" {
+			t.Fatalf("%s free addr = %s, expect ::1", network, addr)
+		}
+	}
+	if _, err := FreePortRange("tcp6", 40000, 40100); err != nil {
+		t.Fatalf("tcp6 free port range failed, err = %v", err)
+	}
+}
+
+func TestWaitListening(t *testing.T) {
+	addr, err := FreeAddr("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Reachable(addr, time.Millisecond*100); err == nil {
+		t.Fatalf("expect %s unreachable", addr)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		time.Sleep(time.Second)
+		l.Close()
+	}()
+	if err = WaitListening(addr, time.Second); err != nil {
+		t.Fatalf("wait listening failed, err = %s", err)
+	}
+
+	_, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	if _, err = FreePortRange("tcp", p, p); err == nil {
+		t.Fatalf("expect port %d in use", p)
+	}
+}
@@ -0,0 +1,336 @@
+package ping
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protoICMP   = 1
+	protoICMPv6 = 58
+
+	defCount    = 4
+	defInterval = time.Second
+	defTimeout  = time.Second * 2
+	defSize     = 32
+
+	// 载荷开头: 8字节发送时间 + 8字节token
+	headSize = 16
+)
+
+// Probe 单次探测结果
+type Probe struct {
+	Seq  int
+	Addr net.Addr
+	RTT  time.Duration
+	Size int
+	TTL  int
+}
+
+// Stats 统计结果
+type Stats struct {
+	Addr   string
+	IP     net.IP
+	Sent   int
+	Recv   int
+	Loss   float64 // 丢包率，百分比
+	MinRTT time.Duration
+	MaxRTT time.Duration
+	AvgRTT time.Duration
+	StdRTT time.Duration
+	RTTs   []time.Duration
+}
+
+// Pinger ping配置
+//
+// Privileged 为true时使用raw socket(需要root或CAP_NET_RAW)，
+// 否则使用非特权的UDP ICMP socket(linux需要net.ipv4.ping_group_range包含当前组)
+type Pinger struct {
+	Count      int
+	Interval   time.Duration
+	Timeout    time.Duration // 最后一个探测包的等待时间
+	Size       int           // 载荷字节数，不小于16
+	Privileged bool
+
+	OnRecv func(p *Probe)
+}
+
+// NewPinger 创建使用默认配置的Pinger
+func NewPinger() *Pinger {
+	return &Pinger{
+		Count:    defCount,
+		Interval: defInterval,
+		Timeout:  defTimeout,
+		Size:     defSize,
+	}
+}
+
+// Ping 使用默认配置ping count次
+func Ping(ctx context.Context, host string, count int) (*Stats, error) {
+	p := NewPinger()
+	p.Count = count
+	return p.Ping(ctx, host)
+}
+
+type session struct {
+	mutex sync.Mutex
+	sent  map[int]time.Time
+	stats *Stats
+}
+
+// Ping ping host，ctx取消时停止并返回已收集的统计结果
+func (p *Pinger) Ping(ctx context.Context, host string) (*Stats, error) {
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+	v4 := addr.IP.To4() != nil
+
+	network, listen, proto := "udp6", "::", protoICMPv6
+	if v4 {
+		network, listen, proto = "udp4", "0.0.0.0", protoICMP
+	}
+	if p.Privileged {
+		network = "ip6:ipv6-icmp"
+		if v4 {
+			network = "ip4:icmp"
+		}
+	}
+	conn, err := icmp.ListenPacket(network, listen)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if v4 {
+		conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	} else {
+		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	}
+
+	var dst net.Addr = addr
+	if !p.Privileged {
+		dst = &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+	}
+
+	token := make([]byte, 8)
+	rand.Read(token)
+	s := &session{
+		sent: make(map[int]time.Time),
+		stats: &Stats{
+			Addr: host,
+			IP:   addr.IP,
+		},
+	}
+
+	count, interval, timeout, size := p.Count, p.Interval, p.Timeout, p.Size
+	if count <= 0 {
+		count = defCount
+	}
+	if interval <= 0 {
+		interval = defInterval
+	}
+	if timeout <= 0 {
+		timeout = defTimeout
+	}
+	if size < headSize {
+		size = headSize
+	}
+
+	done := make(chan struct{})
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		p.recv(conn, proto, v4, token, s, count, done)
+	}()
+
+	id := os.Getpid() & 0xffff
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+SEND:
+	for seq := 0; seq < count; seq++ {
+		if err = p.send(conn, dst, v4, id, seq, size, token, s); err != nil {
+			break
+		}
+		if seq == count-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break SEND
+		case <-recvDone:
+			break SEND
+		case <-ticker.C:
+		}
+	}
+
+	wait := time.NewTimer(timeout)
+	select {
+	case <-ctx.Done():
+	case <-recvDone:
+	case <-wait.C:
+	}
+	wait.Stop()
+	close(done)
+	conn.SetReadDeadline(time.Now())
+	<-recvDone
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stats.calc()
+
+	return s.stats, err
+}
+
+func (p *Pinger) send(conn *icmp.PacketConn, dst net.Addr, v4 bool,
+	id, seq, size int, token []byte, s *session) error {
+	var typ icmp.Type = ipv6.ICMPTypeEchoRequest
+	if v4 {
+		typ = ipv4.ICMPTypeEcho
+	}
+	data := make([]byte, size)
+	now := time.Now()
+	binary.BigEndian.PutUint64(data, uint64(now.UnixNano()))
+	copy(data[8:], token)
+
+	msg := &icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{
+			ID:   id,
+			Seq:  seq,
+			Data: data,
+		},
+	}
+	buf, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.sent[seq] = now
+	s.stats.Sent++
+	s.mutex.Unlock()
+
+	_, err = conn.WriteTo(buf, dst)
+	return err
+}
+
+func (p *Pinger) recv(conn *icmp.PacketConn, proto int, v4 bool, token []byte,
+	s *session, count int, done chan struct{}) {
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+
+		var n, ttl int
+		var peer net.Addr
+		var err error
+		if v4 {
+			var cm *ipv4.ControlMessage
+			n, cm, peer, err = conn.IPv4PacketConn().ReadFrom(buf)
+			if cm != nil {
+				ttl = cm.TTL
+			}
+		} else {
+			var cm *ipv6.ControlMessage
+			n, cm, peer, err = conn.IPv6PacketConn().ReadFrom(buf)
+			if cm != nil {
+				ttl = cm.HopLimit
+			}
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return
+		}
+		now := time.Now()
+
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || len(echo.Data) < headSize || string(echo.Data[8:headSize]) != string(token) {
+			continue
+		}
+
+		s.mutex.Lock()
+		sent, ok := s.sent[echo.Seq]
+		if !ok {
+			s.mutex.Unlock()
+			continue
+		}
+		delete(s.sent, echo.Seq)
+		rtt := now.Sub(sent)
+		s.stats.Recv++
+		s.stats.RTTs = append(s.stats.RTTs, rtt)
+		finished := s.stats.Recv == count
+		s.mutex.Unlock()
+
+		if p.OnRecv != nil {
+			p.OnRecv(&Probe{
+				Seq:  echo.Seq,
+				Addr: peer,
+				RTT:  rtt,
+				Size: len(echo.Data),
+				TTL:  ttl,
+			})
+		}
+		if finished {
+			return
+		}
+	}
+}
+
+func (s *Stats) calc() {
+	if s.Sent > 0 {
+		s.Loss = float64(s.Sent-s.Recv) / float64(s.Sent) * 100
+	}
+	if len(s.RTTs) == 0 {
+		return
+	}
+	var total time.Duration
+	s.MinRTT = s.RTTs[0]
+	for _, v := range s.RTTs {
+		if v < s.MinRTT {
+			s.MinRTT = v
+		}
+		if v > s.MaxRTT {
+			s.MaxRTT = v
+		}
+		total += v
+	}
+	s.AvgRTT = total / time.Duration(len(s.RTTs))
+
+	var variance float64
+	for _, v := range s.RTTs {
+		d := float64(v - s.AvgRTT)
+		variance += d * d
+	}
+	s.StdRTT = time.Duration(math.Sqrt(variance / float64(len(s.RTTs))))
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("%s (%s): %d sent, %d recv, %.1f%% loss, rtt min/avg/max/std = %s/%s/%s/%s",
+		s.Addr, s.IP, s.Sent, s.Recv, s.Loss, s.MinRTT, s.AvgRTT, s.MaxRTT, s.StdRTT)
+}
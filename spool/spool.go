@@ -0,0 +1,287 @@
+// Package spool 磁盘队列，连接断开时把要发送的报文暂存到磁盘，
+// 恢复后按顺序重放，总大小和报文存放时间有上限，超出时丢弃最旧的报文
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	recordHead     = 16 // 8字节时间 + 4字节长度 + 4字节crc
+	segmentSuffix  = ".spool"
+	headFile       = "head"
+	defSegmentSize = 4 * 1024 * 1024
+)
+
+// Options 队列参数
+type Options struct {
+	MaxBytes    int64         // 磁盘占用上限，<=0不限制，超出时按段丢弃最旧的报文
+	MaxAge      time.Duration // 报文最长保存时间，<=0不限制，过期的报文重放时跳过
+	SegmentSize int64         // 段文件大小，默认4M
+}
+
+// Spool 磁盘队列，并发安全
+type Spool struct {
+	lock sync.Mutex
+	dir  string
+	opts Options
+
+	segs    []int64 // 段序号，从旧到新
+	sizes   map[int64]int64
+	w       *os.File
+	lastSeq int64
+
+	headSeg int64 // 重放位置
+	headOff int64
+
+	now func() time.Time
+}
+
+// Open 打开或创建dir下的队列
+func Open(dir string, opts *Options) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		dir:   dir,
+		sizes: make(map[int64]int64),
+		now:   time.Now,
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.SegmentSize <= 0 {
+		s.opts.SegmentSize = defSegmentSize
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segs = append(s.segs, seq)
+		s.sizes[seq] = info.Size()
+	}
+	sort.Slice(s.segs, func(i, j int) bool { return s.segs[i] < s.segs[j] })
+
+	s.loadHead()
+	return s, nil
+}
+
+func (s *Spool) segPath(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", seq, segmentSuffix))
+}
+
+func (s *Spool) loadHead() {
+	data, err := os.ReadFile(filepath.Join(s.dir, headFile))
+	if err == nil {
+		fmt.Sscanf(string(data), "%d %d", &s.headSeg, &s.headOff)
+	}
+	s.lastSeq = s.headSeg
+	if len(s.segs) > 0 {
+		if last := s.segs[len(s.segs)-1]; last > s.lastSeq {
+			s.lastSeq = last
+		}
+		if s.headSeg != s.segs[0] {
+			s.headSeg, s.headOff = s.segs[0], 0
+		}
+	}
+}
+
+func (s *Spool) saveHead() error {
+	tmp := filepath.Join(s.dir, headFile+".tmp")
+	data := fmt.Sprintf("%d %d", s.headSeg, s.headOff)
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, headFile))
+}
+
+// Size 磁盘上未重放的字节数(近似)
+func (s *Spool) Size() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.size()
+}
+
+func (s *Spool) size() int64 {
+	var total int64
+	for _, seq := range s.segs {
+		total += s.sizes[seq]
+	}
+	if len(s.segs) > 0 && s.headSeg == s.segs[0] {
+		total -= s.headOff
+	}
+	return total
+}
+
+// Push 追加报文
+func (s *Spool) Push(msg []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	length := int64(recordHead + len(msg))
+	if s.opts.MaxBytes > 0 && length > s.opts.MaxBytes {
+		return fmt.Errorf("message too large for spool")
+	}
+	if s.w == nil || s.sizes[s.segs[len(s.segs)-1]]+length > s.opts.SegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	for s.opts.MaxBytes > 0 && s.size()+length > s.opts.MaxBytes && len(s.segs) > 1 {
+		s.dropOldest()
+	}
+
+	rec := make([]byte, recordHead, length)
+	binary.BigEndian.PutUint64(rec, uint64(s.now().UnixNano()))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(msg)))
+	binary.BigEndian.PutUint32(rec[12:], crc32.ChecksumIEEE(msg))
+	rec = append(rec, msg...)
+	if _, err := s.w.Write(rec); err != nil {
+		return err
+	}
+	s.sizes[s.segs[len(s.segs)-1]] += length
+	return nil
+}
+
+func (s *Spool) rotate() error {
+	if s.w != nil {
+		s.w.Close()
+		s.w = nil
+	}
+	seq := s.lastSeq + 1
+	f, err := os.OpenFile(s.segPath(seq), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	s.w = f
+	s.lastSeq = seq
+	s.segs = append(s.segs, seq)
+	s.sizes[seq] = 0
+	if len(s.segs) == 1 {
+		s.headSeg, s.headOff = seq, 0
+	}
+	return nil
+}
+
+func (s *Spool) dropOldest() {
+	seq := s.segs[0]
+	os.Remove(s.segPath(seq))
+	delete(s.sizes, seq)
+	s.segs = s.segs[1:]
+	s.headSeg, s.headOff = s.segs[0], 0
+}
+
+// ErrStop send返回此错误时停止重放，当前报文不算已发送
+var ErrStop = errors.New("stop replay")
+
+// Replay 按顺序重放报文，send返回错误时停止并保留该报文，
+// 返回成功重放的个数
+func (s *Spool) Replay(send func(msg []byte) error) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	defer s.saveHead()
+	for len(s.segs) > 0 {
+		seq := s.segs[0]
+		done, n, err := s.replaySeg(seq, send)
+		count += n
+		if err != nil {
+			if err == ErrStop {
+				err = nil
+			}
+			return count, err
+		}
+		if !done {
+			return count, nil
+		}
+		// 段已经读完
+		if len(s.segs) == 1 {
+			if s.w != nil {
+				s.w.Close()
+				s.w = nil
+			}
+			os.Remove(s.segPath(seq))
+			delete(s.sizes, seq)
+			s.segs = nil
+			s.headSeg, s.headOff = seq+1, 0
+			return count, nil
+		}
+		s.dropOldest()
+	}
+	return count, nil
+}
+
+func (s *Spool) replaySeg(seq int64, send func(msg []byte) error) (bool, int, error) {
+	f, err := os.Open(s.segPath(seq))
+	if err != nil {
+		return false, 0, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(s.headOff, io.SeekStart); err != nil {
+		return false, 0, err
+	}
+	count := 0
+	head := make([]byte, recordHead)
+	for s.headOff < s.sizes[seq] {
+		if _, err := io.ReadFull(f, head); err != nil {
+			// 不完整的记录，丢弃段的剩余部分
+			return true, count, nil
+		}
+		ts := time.Unix(0, int64(binary.BigEndian.Uint64(head)))
+		msg := make([]byte, binary.BigEndian.Uint32(head[8:]))
+		if _, err := io.ReadFull(f, msg); err != nil ||
+			crc32.ChecksumIEEE(msg) != binary.BigEndian.Uint32(head[12:]) {
+			return true, count, nil
+		}
+		if s.opts.MaxAge <= 0 || s.now().Sub(ts) <= s.opts.MaxAge {
+			if err := send(msg); err != nil {
+				return false, count, err
+			}
+			count++
+		}
+		s.headOff += int64(recordHead + len(msg))
+	}
+	return true, count, nil
+}
+
+// Close 关闭队列，未重放的报文留在磁盘上
+func (s *Spool) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.saveHead()
+	if s.w != nil {
+		err := s.w.Close()
+		s.w = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package spool
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, &Options{SegmentSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := s.Push([]byte(fmt.Sprintf("msg-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 第4个报文发送失败，下次从它开始
+	var got []string
+	count, err := s.Replay(func(msg []byte) error {
+		if len(got) == 4 {
+			return ErrStop
+		}
+		got = append(got, string(msg))
+		return nil
+	})
+	if err != nil || count != 4 {
+		t.Fatalf("replay count = %d, err = %v", count, err)
+	}
+	s.Close()
+
+	s, err = Open(dir, &Options{SegmentSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Push([]byte("msg-10"))
+	count, _ = s.Replay(func(msg []byte) error {
+		got = append(got, string(msg))
+		return nil
+	})
+	if count != 7 || len(got) != 11 {
+		t.Fatalf("replay count = %d, got = %v", count, got)
+	}
+	for i, v := range got {
+		if v != fmt.Sprintf("msg-%d", i) {
+			t.Fatalf("order not right: %v", got)
+		}
+	}
+	if s.Size() != 0 {
+		t.Fatalf("size = %d", s.Size())
+	}
+	s.Close()
+}
+
+func TestLimits(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s, err := Open(t.TempDir(), &Options{SegmentSize: 40, MaxBytes: 100, MaxAge: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		s.Push([]byte(fmt.Sprintf("m%d", i)))
+		now = now.Add(10 * time.Second)
+	}
+	if s.Size() > 100 {
+		t.Fatalf("size = %d over limit", s.Size())
+	}
+	var got []string
+	s.Replay(func(msg []byte) error {
+		got = append(got, string(msg))
+		return nil
+	})
+	// 超出大小的旧报文被丢弃，超过1分钟的报文被跳过
+	if len(got) == 0 || got[len(got)-1] != "m9" || got[0] == "m0" {
+		t.Fatalf("got = %v", got)
+	}
+	for _, v := range got {
+		if v < "m4" {
+			t.Fatalf("expired message replayed: %v", got)
+		}
+	}
+}
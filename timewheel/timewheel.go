@@ -0,0 +1,129 @@
+// Package timewheel 哈希时间轮，适合大量精度要求不高的定时器(如连接空闲超时)，
+// 添加和取消都是O(1)
+package timewheel
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Timer 时间轮上的定时器
+type Timer struct {
+	w      *Wheel
+	fn     func()
+	slot   int
+	rounds int
+	elem   *list.Element
+}
+
+// Wheel 时间轮，并发安全，回调在时间轮的goroutine中执行，不能阻塞
+type Wheel struct {
+	tick time.Duration
+
+	lock  sync.Mutex
+	slots []*list.List
+	pos   int
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New 创建时间轮，tick为精度，slots为槽数，超过tick*slots的定时器按圈数等待
+func New(tick time.Duration, slots int) *Wheel {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	if slots <= 0 {
+		slots = 1
+	}
+	w := &Wheel{
+		tick:  tick,
+		slots: make([]*list.List, slots),
+		stop:  make(chan struct{}),
+	}
+	for i := range w.slots {
+		w.slots[i] = list.New()
+	}
+	go w.run()
+	return w
+}
+
+// AfterFunc d之后(按tick向上取整)调用fn
+func (w *Wheel) AfterFunc(d time.Duration, fn func()) *Timer {
+	ticks := int((d + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	t := &Timer{
+		w:      w,
+		fn:     fn,
+		slot:   (w.pos + ticks) % len(w.slots),
+		rounds: (ticks - 1) / len(w.slots),
+	}
+	t.elem = w.slots[t.slot].PushBack(t)
+	return t
+}
+
+// Stop 取消定时器，已经触发或者已经取消返回false
+func (t *Timer) Stop() bool {
+	t.w.lock.Lock()
+	defer t.w.lock.Unlock()
+
+	if t.elem == nil {
+		return false
+	}
+	t.w.slots[t.slot].Remove(t.elem)
+	t.elem = nil
+	return true
+}
+
+// Stop 停止时间轮，未触发的定时器不再触发
+func (w *Wheel) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *Wheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, fn := range w.advance() {
+				fn()
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// advance 前进一格，返回到期的回调
+func (w *Wheel) advance() []func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.pos = (w.pos + 1) % len(w.slots)
+	var fired []func()
+	slot := w.slots[w.pos]
+	for e := slot.Front(); e != nil; {
+		next := e.Next()
+		t := e.Value.(*Timer)
+		if t.rounds > 0 {
+			t.rounds--
+		} else {
+			slot.Remove(e)
+			t.elem = nil
+			fired = append(fired, t.fn)
+		}
+		e = next
+	}
+	return fired
+}
@@ -0,0 +1,34 @@
+package timewheel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWheel(t *testing.T) {
+	w := New(10*time.Millisecond, 4)
+	defer w.Stop()
+
+	start := time.Now()
+	fired := make(chan time.Duration, 3)
+	for _, d := range []time.Duration{15 * time.Millisecond, 40 * time.Millisecond, 95 * time.Millisecond} {
+		w.AfterFunc(d, func() { fired <- time.Since(start) })
+	}
+	var stopped atomic.Bool
+	timer := w.AfterFunc(20*time.Millisecond, func() { stopped.Store(true) })
+	if !timer.Stop() {
+		t.Fatal("stop pending timer failed")
+	}
+
+	want := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 100 * time.Millisecond}
+	for i, d := range want {
+		got := <-fired
+		if got < d-5*time.Millisecond {
+			t.Fatalf("timer %d fired at %s, want >= %s", i, got, d)
+		}
+	}
+	if stopped.Load() || timer.Stop() {
+		t.Fatal("stopped timer fired")
+	}
+}
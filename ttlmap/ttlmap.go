@@ -0,0 +1,162 @@
+// Package ttlmap 带过期时间的map，过期的key在访问和写入时清理，
+// 超过容量时淘汰最早过期的key
+package ttlmap
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key    string
+	value  interface{}
+	expire time.Time
+	index  int
+}
+
+type expireHeap []*entry
+
+func (h expireHeap) Len() int           { return len(h) }
+func (h expireHeap) Less(i, j int) bool { return h[i].expire.Before(h[j].expire) }
+func (h expireHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *expireHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *expireHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Map 带过期时间的map，并发安全
+type Map struct {
+	lock   sync.Mutex
+	items  map[string]*entry
+	heap   expireHeap
+	maxLen int
+
+	now func() time.Time
+}
+
+// New 创建Map，maxLen<=0不限制容量
+func New(maxLen int) *Map {
+	return &Map{
+		items:  make(map[string]*entry),
+		maxLen: maxLen,
+		now:    time.Now,
+	}
+}
+
+func (m *Map) expire(now time.Time) int {
+	count := 0
+	for len(m.heap) > 0 && !m.heap[0].expire.After(now) {
+		e := heap.Pop(&m.heap).(*entry)
+		delete(m.items, e.key)
+		count++
+	}
+	return count
+}
+
+func (m *Map) set(key string, value interface{}, ttl time.Duration, now time.Time) {
+	if e, ok := m.items[key]; ok {
+		e.value = value
+		e.expire = now.Add(ttl)
+		heap.Fix(&m.heap, e.index)
+		return
+	}
+	if m.maxLen > 0 && len(m.heap) >= m.maxLen {
+		e := heap.Pop(&m.heap).(*entry)
+		delete(m.items, e.key)
+	}
+	e := &entry{key: key, value: value, expire: now.Add(ttl)}
+	heap.Push(&m.heap, e)
+	m.items[key] = e
+}
+
+// Set 设置key，ttl后过期
+func (m *Map) Set(key string, value interface{}, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	m.expire(now)
+	m.set(key, value, ttl, now)
+}
+
+// SetNX key不存在(或已过期)时设置并返回true，否则返回false
+func (m *Map) SetNX(key string, value interface{}, ttl time.Duration) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	m.expire(now)
+	if _, ok := m.items[key]; ok {
+		return false
+	}
+	m.set(key, value, ttl, now)
+	return true
+}
+
+// Get 获取未过期的值
+func (m *Map) Get(key string) (interface{}, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	e, ok := m.items[key]
+	if !ok || !e.expire.After(m.now()) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// TTL key剩余的有效时间
+func (m *Map) TTL(key string) (time.Duration, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	e, ok := m.items[key]
+	if !ok {
+		return 0, false
+	}
+	ttl := e.expire.Sub(m.now())
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// Delete 删除key
+func (m *Map) Delete(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if e, ok := m.items[key]; ok {
+		heap.Remove(&m.heap, e.index)
+		delete(m.items, key)
+	}
+}
+
+// Len 当前key的个数，包括还没有清理的过期key
+func (m *Map) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.items)
+}
+
+// Expire 清理过期的key，返回清理的个数
+func (m *Map) Expire() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.expire(m.now())
+}
@@ -0,0 +1,51 @@
+package ttlmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := New(0)
+	m.now = func() time.Time { return now }
+
+	m.Set("a", 1, time.Second)
+	if !m.SetNX("b", 2, 3*time.Second) {
+		t.Fatalf("setnx b failed")
+	}
+	if m.SetNX("a", 3, time.Second) {
+		t.Fatalf("setnx a should fail")
+	}
+	if v, ok := m.Get("a"); !ok || v.(int) != 1 {
+		t.Fatalf("get a = %v, %v", v, ok)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := m.Get("a"); ok {
+		t.Fatalf("a should expire")
+	}
+	if n := m.Expire(); n != 1 || m.Len() != 1 {
+		t.Fatalf("expire = %d, len = %d", n, m.Len())
+	}
+	if ttl, ok := m.TTL("b"); !ok || ttl != time.Second {
+		t.Fatalf("ttl b = %v", ttl)
+	}
+	m.Delete("b")
+	if m.Len() != 0 {
+		t.Fatalf("len = %d", m.Len())
+	}
+}
+
+func TestMaxLen(t *testing.T) {
+	m := New(2)
+	m.Set("a", 1, time.Minute)
+	m.Set("b", 2, time.Hour)
+	m.Set("c", 3, time.Hour)
+	if _, ok := m.Get("a"); ok {
+		t.Fatalf("a should be evicted")
+	}
+	if m.Len() != 2 {
+		t.Fatalf("len = %d", m.Len())
+	}
+}